@@ -0,0 +1,62 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"notes_server_go/data"
+	"notes_server_go/middleware"
+	"notes_server_go/models"
+
+	"github.com/gorilla/mux"
+)
+
+// requireUserID извлекает ID пользователя из токена. При ошибке отправляет 401 и возвращает ok = false.
+func requireUserID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int64)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Не удалось получить ID пользователя из токена.")
+		return 0, false
+	}
+	return userID, true
+}
+
+// requireDatabaseMember извлекает ID пользователя из токена и ID совместной БД из пути ({db_id})
+// и проверяет, что пользователь является участником этой БД.
+// При ошибке сам отправляет ответ клиенту и возвращает ok = false.
+func requireDatabaseMember(w http.ResponseWriter, r *http.Request) (userID int64, dbID int64, role models.SharedDatabaseUserRole, ok bool) {
+	userID, ok = r.Context().Value(middleware.UserIDKey).(int64)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Не удалось получить ID пользователя из токена.")
+		return 0, 0, "", false
+	}
+
+	dbID, err := strconv.ParseInt(mux.Vars(r)["db_id"], 10, 64)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Неверный формат ID базы данных.")
+		return 0, 0, "", false
+	}
+
+	userRole, err := data.GetUserRoleInSharedDatabase(dbID, userID)
+	if err != nil {
+		log.Printf("Ошибка при проверке роли пользователя %d в БД %d: %v", userID, dbID, err)
+		respondError(w, http.StatusInternalServerError, "Ошибка сервера при проверке доступа к БД.")
+		return 0, 0, "", false
+	}
+	if userRole == nil {
+		respondError(w, http.StatusForbidden, "Доступ к указанной совместной базе данных запрещен.")
+		return 0, 0, "", false
+	}
+	return userID, dbID, *userRole, true
+}
+
+// parseIDVar извлекает числовой параметр пути. При ошибке отправляет 400 и возвращает ok = false.
+func parseIDVar(w http.ResponseWriter, r *http.Request, name string) (int64, bool) {
+	id, err := strconv.ParseInt(mux.Vars(r)[name], 10, 64)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Неверный формат параметра "+name+".")
+		return 0, false
+	}
+	return id, true
+}
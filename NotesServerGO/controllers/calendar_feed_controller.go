@@ -0,0 +1,175 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"notes_server_go/data"
+	"notes_server_go/ical"
+	"notes_server_go/models"
+	"notes_server_go/schedule"
+
+	"github.com/gorilla/mux"
+)
+
+// calendarFeedTokenRequest - тело запроса на выдачу токена подписки на календарь.
+type calendarFeedTokenRequest struct {
+	Name string `json:"name"`
+}
+
+// validate нормализует подпись токена.
+func (req *calendarFeedTokenRequest) validate() string {
+	req.Name = strings.TrimSpace(req.Name)
+	if len([]rune(req.Name)) > 100 {
+		return "Подпись токена не может быть длиннее 100 символов."
+	}
+	return ""
+}
+
+// calendarFeedURL возвращает ссылку на фид календаря для подписки из календарных приложений.
+func calendarFeedURL(r *http.Request, token string) string {
+	scheme := "http"
+	if r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https") {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s/api/calendar/feed/%s.ics", scheme, r.Host, token)
+}
+
+// GetCalendarFeedTokensHandler возвращает токены подписки текущего пользователя на календарь совместной БД.
+// GET /api/collaboration/databases/{db_id}/calendar/feed-tokens
+func GetCalendarFeedTokensHandler(w http.ResponseWriter, r *http.Request) {
+	userID, dbID, _, ok := requireDatabaseMember(w, r)
+	if !ok {
+		return
+	}
+
+	tokens, err := data.GetCalendarFeedTokensForUser(dbID, userID)
+	if err != nil {
+		log.Printf("Ошибка при получении токенов календаря пользователя %d в БД %d: %v", userID, dbID, err)
+		respondError(w, http.StatusInternalServerError, "Ошибка при получении токенов подписки на календарь.")
+		return
+	}
+	for i := range tokens {
+		tokens[i].FeedURL = calendarFeedURL(r, tokens[i].Token)
+	}
+	respondJSON(w, http.StatusOK, tokens)
+}
+
+// CreateCalendarFeedTokenHandler выдает текущему пользователю новый токен подписки на календарь совместной БД.
+// POST /api/collaboration/databases/{db_id}/calendar/feed-tokens
+// Тело запроса необязательно: {"name": "Телефон"}. В ответе feed_url - ссылка для календарного приложения.
+func CreateCalendarFeedTokenHandler(w http.ResponseWriter, r *http.Request) {
+	userID, dbID, _, ok := requireDatabaseMember(w, r)
+	if !ok {
+		return
+	}
+
+	var req calendarFeedTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		respondError(w, http.StatusBadRequest, "Неверный формат запроса: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+	if msg := req.validate(); msg != "" {
+		respondError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	token := &models.CalendarFeedToken{
+		DatabaseId: dbID,
+		UserId:     userID,
+		Name:       req.Name,
+	}
+	id, err := data.CreateCalendarFeedToken(token)
+	if err != nil {
+		log.Printf("Ошибка при создании токена календаря пользователя %d в БД %d: %v", userID, dbID, err)
+		respondError(w, http.StatusInternalServerError, "Не удалось создать токен подписки на календарь.")
+		return
+	}
+	token.Id = id
+	token.FeedURL = calendarFeedURL(r, token.Token)
+	respondJSON(w, http.StatusCreated, token)
+}
+
+// DeleteCalendarFeedTokenHandler отзывает токен подписки текущего пользователя.
+// DELETE /api/collaboration/databases/{db_id}/calendar/feed-tokens/{token_id}
+func DeleteCalendarFeedTokenHandler(w http.ResponseWriter, r *http.Request) {
+	userID, dbID, _, ok := requireDatabaseMember(w, r)
+	if !ok {
+		return
+	}
+	tokenID, ok := parseIDVar(w, r, "token_id")
+	if !ok {
+		return
+	}
+
+	if err := data.DeleteCalendarFeedToken(tokenID, dbID, userID); err != nil {
+		if err == sql.ErrNoRows {
+			respondError(w, http.StatusNotFound, "Токен подписки не найден.")
+			return
+		}
+		log.Printf("Ошибка при удалении токена календаря %d в БД %d: %v", tokenID, dbID, err)
+		respondError(w, http.StatusInternalServerError, "Не удалось отозвать токен подписки.")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CalendarFeedHandler отдает расписание совместной БД в формате iCalendar.
+// GET /api/calendar/feed/{token}.ics - открытый маршрут без JWT: доступ определяется токеном подписки.
+// Токен перестает действовать, если его отозвали или пользователь больше не состоит в БД.
+// Время событий выводится с TZID часового пояса записи или БД; если часовой пояс БД не задан - "плавающим".
+func CalendarFeedHandler(w http.ResponseWriter, r *http.Request) {
+	token, err := data.GetCalendarFeedTokenByToken(mux.Vars(r)["token"])
+	if err != nil {
+		log.Printf("Ошибка при проверке токена календаря: %v", err)
+		respondError(w, http.StatusInternalServerError, "Ошибка сервера при проверке токена.")
+		return
+	}
+	if token == nil {
+		respondError(w, http.StatusNotFound, "Календарь не найден.")
+		return
+	}
+
+	sdb, err := data.GetSharedDatabaseByID(token.DatabaseId, token.UserId)
+	if err != nil {
+		log.Printf("Ошибка при получении БД %d для фида календаря: %v", token.DatabaseId, err)
+		respondError(w, http.StatusInternalServerError, "Ошибка сервера при получении календаря.")
+		return
+	}
+	if sdb == nil {
+		respondError(w, http.StatusNotFound, "Календарь не найден.")
+		return
+	}
+
+	entries, err := data.GetScheduleEntriesByDBID(sdb.Id)
+	if err != nil {
+		log.Printf("Ошибка при получении записей расписания БД %d для фида календаря: %v", sdb.Id, err)
+		respondError(w, http.StatusInternalServerError, "Ошибка при получении записей расписания.")
+		return
+	}
+	exceptions, err := data.GetScheduleExceptionsBySharedDBID(sdb.Id)
+	if err != nil {
+		log.Printf("Ошибка при получении исключений расписания БД %d для фида календаря: %v", sdb.Id, err)
+		respondError(w, http.StatusInternalServerError, "Ошибка при получении исключений расписания.")
+		return
+	}
+
+	if err := data.TouchCalendarFeedToken(token.Id); err != nil {
+		log.Printf("CalendarFeedHandler: %v", err)
+	}
+
+	calendar := ical.FromSchedule(sdb.Name, entries, exceptions, schedule.DatabaseLocation(sdb))
+	w.Header().Set("Content-Type", ical.ContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="schedule-%d.ics"`, sdb.Id))
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	if err := calendar.Encode(w); err != nil {
+		log.Printf("Ошибка при отправке фида календаря БД %d: %v", sdb.Id, err)
+	}
+}
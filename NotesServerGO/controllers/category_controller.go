@@ -0,0 +1,151 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"notes_server_go/data"
+	"notes_server_go/models"
+)
+
+// categoryRequest - тело запроса на создание/обновление категории.
+type categoryRequest struct {
+	Name  string `json:"name"`
+	Color string `json:"color"`
+}
+
+// validate проверяет название категории.
+func (req *categoryRequest) validate() string {
+	req.Name = strings.TrimSpace(req.Name)
+	req.Color = strings.TrimSpace(req.Color)
+	if req.Name == "" {
+		return "Название категории не может быть пустым."
+	}
+	return ""
+}
+
+// GetCategoriesHandler возвращает список категорий совместной БД.
+// GET /api/collaboration/databases/{db_id}/categories
+func GetCategoriesHandler(w http.ResponseWriter, r *http.Request) {
+	_, dbID, _, ok := requireDatabaseMember(w, r)
+	if !ok {
+		return
+	}
+
+	categories, err := data.GetCategoriesBySharedDBID(dbID)
+	if err != nil {
+		log.Printf("Ошибка при получении категорий БД %d: %v", dbID, err)
+		respondError(w, http.StatusInternalServerError, "Ошибка при получении категорий.")
+		return
+	}
+	respondJSON(w, http.StatusOK, categories)
+}
+
+// CreateCategoryHandler создает категорию в совместной БД.
+// POST /api/collaboration/databases/{db_id}/categories
+func CreateCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	_, dbID, _, ok := requireDatabaseMember(w, r)
+	if !ok {
+		return
+	}
+
+	var req categoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Неверный формат запроса: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+	if msg := req.validate(); msg != "" {
+		respondError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	category := &models.Category{
+		DatabaseId: dbID,
+		Name:       req.Name,
+		Color:      req.Color,
+	}
+	id, err := data.CreateCategory(category)
+	if err != nil {
+		log.Printf("Ошибка при создании категории в БД %d: %v", dbID, err)
+		respondError(w, http.StatusInternalServerError, "Не удалось создать категорию.")
+		return
+	}
+	category.Id = id
+	respondJSON(w, http.StatusCreated, category)
+}
+
+// UpdateCategoryHandler обновляет название и цвет категории.
+// PUT /api/collaboration/databases/{db_id}/categories/{category_id}
+func UpdateCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	_, dbID, _, ok := requireDatabaseMember(w, r)
+	if !ok {
+		return
+	}
+	categoryID, ok := parseIDVar(w, r, "category_id")
+	if !ok {
+		return
+	}
+
+	var req categoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Неверный формат запроса: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+	if msg := req.validate(); msg != "" {
+		respondError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	category, err := data.GetCategoryByID(categoryID, dbID)
+	if err != nil {
+		log.Printf("Ошибка при получении категории %d в БД %d: %v", categoryID, dbID, err)
+		respondError(w, http.StatusInternalServerError, "Ошибка при получении категории.")
+		return
+	}
+	if category == nil {
+		respondError(w, http.StatusNotFound, "Категория не найдена.")
+		return
+	}
+
+	category.Name = req.Name
+	category.Color = req.Color
+	if err := data.UpdateCategory(category); err != nil {
+		if err == sql.ErrNoRows {
+			respondError(w, http.StatusNotFound, "Категория не найдена.")
+			return
+		}
+		log.Printf("Ошибка при обновлении категории %d в БД %d: %v", categoryID, dbID, err)
+		respondError(w, http.StatusInternalServerError, "Не удалось обновить категорию.")
+		return
+	}
+	respondJSON(w, http.StatusOK, category)
+}
+
+// DeleteCategoryHandler удаляет категорию. Заметки и записи расписания остаются без категории.
+// DELETE /api/collaboration/databases/{db_id}/categories/{category_id}
+func DeleteCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	_, dbID, _, ok := requireDatabaseMember(w, r)
+	if !ok {
+		return
+	}
+	categoryID, ok := parseIDVar(w, r, "category_id")
+	if !ok {
+		return
+	}
+
+	if err := data.DeleteCategory(categoryID, dbID); err != nil {
+		if err == sql.ErrNoRows {
+			respondError(w, http.StatusNotFound, "Категория не найдена.")
+			return
+		}
+		log.Printf("Ошибка при удалении категории %d в БД %d: %v", categoryID, dbID, err)
+		respondError(w, http.StatusInternalServerError, "Не удалось удалить категорию.")
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Категория удалена."})
+}
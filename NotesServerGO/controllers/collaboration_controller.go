@@ -6,10 +6,12 @@ import (
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"notes_server_go/data"
 	"notes_server_go/middleware"
 	"notes_server_go/models"
+	"notes_server_go/schedule"
 
 	"github.com/gorilla/mux" // Добавляем импорт gorilla/mux
 )
@@ -24,7 +26,8 @@ func CreateSharedDatabaseHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req struct {
-		Name string `json:"name"`
+		Name     string `json:"name"`
+		TimeZone string `json:"time_zone"` // Необязательно: часовой пояс IANA расписания
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		respondError(w, http.StatusBadRequest, "Неверный формат запроса: "+err.Error())
@@ -41,6 +44,14 @@ func CreateSharedDatabaseHandler(w http.ResponseWriter, r *http.Request) {
 		Name:        req.Name,
 		OwnerUserId: userID,
 	}
+	if timeZone := strings.TrimSpace(req.TimeZone); timeZone != "" {
+		loc, err := schedule.LoadTimeZone(timeZone)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Неверный часовой пояс: "+err.Error())
+			return
+		}
+		db.TimeZone = loc.String()
+	}
 
 	sdbID, err := data.CreateSharedDatabase(db)
 	if err != nil {
@@ -300,7 +311,7 @@ func RemoveUserFromSharedDatabaseHandler(w http.ResponseWriter, r *http.Request)
 	respondJSON(w, http.StatusOK, map[string]string{"message": "Пользователь успешно удален из совместной базы данных."})
 }
 
-// DeleteSharedDatabaseHandler удаляет совместную БД.
+// DeleteSharedDatabaseHandler перемещает совместную БД в корзину владельца (см. RestoreSharedDatabaseHandler).
 // DELETE /api/collaboration/databases/{db_id}
 func DeleteSharedDatabaseHandler(w http.ResponseWriter, r *http.Request) { // Удаляем dbIDStr из аргументов
 	vars := mux.Vars(r)
@@ -325,7 +336,7 @@ func DeleteSharedDatabaseHandler(w http.ResponseWriter, r *http.Request) { // У
 		return
 	}
 
-	respondJSON(w, http.StatusOK, map[string]string{"message": "Совместная база данных успешно удалена."})
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Совместная база данных перемещена в корзину."})
 }
 
 // LeaveSharedDatabaseHandler обрабатывает выход пользователя из совместной базы данных
@@ -516,7 +527,7 @@ func BackupDatabaseDataHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	defer r.Body.Close()
 
-	err = data.RestoreSharedDatabaseFromBackup(dbID, userID, &backupData)
+	warnings, err := data.RestoreSharedDatabaseFromBackup(dbID, userID, &backupData)
 	if err != nil {
 		log.Printf("Ошибка при восстановлении БД %d из бэкапа для пользователя %d: %v", dbID, userID, err)
 		// Здесь можно добавить более гранулярную обработку ошибок от data слоя
@@ -531,5 +542,11 @@ func BackupDatabaseDataHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	respondJSON(w, http.StatusOK, map[string]string{"message": "База данных успешно восстановлена из бэкапа."})
+	if warnings == nil {
+		warnings = []string{}
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"message":  "База данных успешно восстановлена из бэкапа.",
+		"warnings": warnings, // Данные бэкапа, пропущенные при восстановлении
+	})
 }
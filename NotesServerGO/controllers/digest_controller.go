@@ -0,0 +1,246 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"notes_server_go/data"
+	"notes_server_go/models"
+	"notes_server_go/notifications"
+	"notes_server_go/schedule"
+)
+
+// defaultDigestSendTime - время отправки сводки, если пользователь его не указал.
+const defaultDigestSendTime = "08:00"
+
+// digestMailer отправляет сводки по запросу пользователя (POST /api/digest/send).
+// nil - отправка писем на сервере не настроена.
+var digestMailer notifications.MailSender
+
+// SetDigestMailer задает способ отправки сводок по запросу пользователя.
+func SetDigestMailer(mailer notifications.MailSender) {
+	digestMailer = mailer
+}
+
+// digestSubscriptionRequest - тело запроса на изменение подписки на ежедневную сводку.
+type digestSubscriptionRequest struct {
+	Enabled   *bool  `json:"enabled"` // По умолчанию true
+	SendTime  string `json:"send_time"`
+	TimeZone  string `json:"time_zone"`
+	SendEmpty bool   `json:"send_empty"`
+}
+
+// validate проверяет время отправки ("HH:mm") и часовой пояс.
+func (req *digestSubscriptionRequest) validate() string {
+	if strings.TrimSpace(req.SendTime) == "" {
+		req.SendTime = defaultDigestSendTime
+	}
+	sendTime, err := schedule.NormalizeTime(req.SendTime)
+	if err != nil || strings.Contains(sendTime, "-") {
+		return "Неверное время отправки, ожидается HH:mm."
+	}
+	req.SendTime = sendTime
+	req.TimeZone = strings.TrimSpace(req.TimeZone)
+	if req.TimeZone != "" {
+		loc, err := schedule.LoadTimeZone(req.TimeZone)
+		if err != nil {
+			return "Неверный часовой пояс: " + err.Error()
+		}
+		req.TimeZone = loc.String()
+	}
+	return ""
+}
+
+// GetDigestSubscriptionHandler возвращает настройки ежедневной сводки пользователя.
+// Если пользователь не подписан, возвращаются настройки по умолчанию с enabled = false.
+// GET /api/digest/subscription
+func GetDigestSubscriptionHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requireUserID(w, r)
+	if !ok {
+		return
+	}
+	subscription, ok := loadDigestSubscription(w, userID)
+	if !ok {
+		return
+	}
+	if subscription == nil {
+		subscription = &models.DigestSubscription{UserId: userID, SendTime: defaultDigestSendTime}
+	}
+	respondJSON(w, http.StatusOK, subscription)
+}
+
+// UpdateDigestSubscriptionHandler создает или изменяет подписку на ежедневную сводку.
+// PUT /api/digest/subscription
+// Тело: {"enabled": true, "send_time": "07:30", "time_zone": "Europe/Moscow", "send_empty": false}
+func UpdateDigestSubscriptionHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requireUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var req digestSubscriptionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Неверный формат запроса: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+	if msg := req.validate(); msg != "" {
+		respondError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	subscription := &models.DigestSubscription{
+		UserId:    userID,
+		Enabled:   req.Enabled == nil || *req.Enabled,
+		SendTime:  req.SendTime,
+		TimeZone:  req.TimeZone,
+		SendEmpty: req.SendEmpty,
+	}
+	if err := data.SaveDigestSubscription(subscription); err != nil {
+		log.Printf("Ошибка при сохранении подписки на сводку пользователя %d: %v", userID, err)
+		respondError(w, http.StatusInternalServerError, "Не удалось сохранить подписку.")
+		return
+	}
+	saved, ok := loadDigestSubscription(w, userID)
+	if !ok {
+		return
+	}
+	respondJSON(w, http.StatusOK, saved)
+}
+
+// DeleteDigestSubscriptionHandler отменяет подписку на ежедневную сводку.
+// DELETE /api/digest/subscription
+func DeleteDigestSubscriptionHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requireUserID(w, r)
+	if !ok {
+		return
+	}
+	if err := data.DeleteDigestSubscription(userID); err != nil {
+		if err == sql.ErrNoRows {
+			respondError(w, http.StatusNotFound, "Подписка не найдена.")
+			return
+		}
+		log.Printf("Ошибка при удалении подписки на сводку пользователя %d: %v", userID, err)
+		respondError(w, http.StatusInternalServerError, "Не удалось удалить подписку.")
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Подписка на сводку удалена."})
+}
+
+// PreviewDigestHandler возвращает сводку пользователя без отправки.
+// GET /api/digest/preview?date=2025-05-01&tz=Europe/Moscow&format=html
+// date - день сводки (по умолчанию сегодня), tz - часовой пояс (по умолчанию из подписки),
+// format - json (по умолчанию), text или html. Заметки - измененные после предыдущей сводки.
+func PreviewDigestHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requireUserID(w, r)
+	if !ok {
+		return
+	}
+	format := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("format")))
+	if format != "" && format != "json" && format != "text" && format != "html" {
+		respondError(w, http.StatusBadRequest, "Неверный формат: ожидается json, text или html.")
+		return
+	}
+	digest, ok := buildDigestForRequest(w, r, userID)
+	if !ok {
+		return
+	}
+
+	switch format {
+	case "text", "html":
+		render, contentType := digest.Text, "text/plain; charset=utf-8"
+		if format == "html" {
+			render, contentType = digest.HTML, "text/html; charset=utf-8"
+		}
+		body, err := render()
+		if err != nil {
+			log.Printf("Ошибка при формировании сводки пользователя %d: %v", userID, err)
+			respondError(w, http.StatusInternalServerError, "Не удалось сформировать сводку.")
+			return
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(body))
+	default:
+		respondJSON(w, http.StatusOK, digest)
+	}
+}
+
+// SendDigestHandler сразу отправляет сводку пользователю письмом. Расписание отправки по подписке не меняется.
+// POST /api/digest/send?date=2025-05-01&tz=Europe/Moscow
+func SendDigestHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requireUserID(w, r)
+	if !ok {
+		return
+	}
+	if digestMailer == nil {
+		respondError(w, http.StatusServiceUnavailable, "Отправка писем на сервере не настроена.")
+		return
+	}
+	digest, ok := buildDigestForRequest(w, r, userID)
+	if !ok {
+		return
+	}
+	if err := notifications.SendDigest(r.Context(), digestMailer, digest); err != nil {
+		log.Printf("Ошибка при отправке сводки пользователю %d: %v", userID, err)
+		respondError(w, http.StatusBadGateway, "Не удалось отправить сводку: "+err.Error())
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"message":     "Сводка отправлена.",
+		"email":       digest.Recipient.Email,
+		"date":        digest.Date,
+		"occurrences": digest.OccurrenceCount(),
+	})
+}
+
+// buildDigestForRequest собирает сводку по параметрам date и tz запроса и подписке пользователя.
+// При ошибке отправляет ответ и возвращает ok = false.
+func buildDigestForRequest(w http.ResponseWriter, r *http.Request, userID int64) (*notifications.Digest, bool) {
+	subscription, ok := loadDigestSubscription(w, userID)
+	if !ok {
+		return nil, false
+	}
+	loc := notifications.DigestLocation(subscription)
+	if tz := strings.TrimSpace(r.URL.Query().Get("tz")); tz != "" {
+		var err error
+		if loc, err = schedule.LoadTimeZone(tz); err != nil {
+			respondError(w, http.StatusBadRequest, "Неверный часовой пояс: "+err.Error())
+			return nil, false
+		}
+	}
+	now := time.Now().In(loc)
+	day := now
+	if value := strings.TrimSpace(r.URL.Query().Get("date")); value != "" {
+		date, err := schedule.NormalizeDate(value)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Неверная дата: "+err.Error())
+			return nil, false
+		}
+		day, _ = time.ParseInLocation(schedule.DateLayout, date, loc)
+	}
+
+	digest, err := notifications.BuildDigest(userID, loc, day, notifications.DigestNotesSince(subscription, now))
+	if err != nil {
+		log.Printf("Ошибка при формировании сводки пользователя %d: %v", userID, err)
+		respondError(w, http.StatusInternalServerError, "Не удалось сформировать сводку.")
+		return nil, false
+	}
+	return digest, true
+}
+
+// loadDigestSubscription получает подписку пользователя (nil, если ее нет).
+// При ошибке отправляет ответ и возвращает ok = false.
+func loadDigestSubscription(w http.ResponseWriter, userID int64) (*models.DigestSubscription, bool) {
+	subscription, err := data.GetDigestSubscriptionByUserID(userID)
+	if err != nil {
+		log.Printf("Ошибка при получении подписки на сводку пользователя %d: %v", userID, err)
+		respondError(w, http.StatusInternalServerError, "Ошибка при получении подписки.")
+		return nil, false
+	}
+	return subscription, true
+}
@@ -0,0 +1,282 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"unicode"
+
+	"notes_server_go/data"
+	"notes_server_go/models"
+	"notes_server_go/schedule"
+)
+
+// dynamicFieldRequest - тело запроса на создание/обновление поля схемы.
+type dynamicFieldRequest struct {
+	Name     string                  `json:"name"`
+	Type     models.DynamicFieldType `json:"type"`
+	Required bool                    `json:"required"`
+	Options  []string                `json:"options"`
+	Position int                     `json:"position"`
+}
+
+// dynamicFieldResponse - поле схемы и записи расписания, которые не соответствуют схеме после ее изменения.
+// Такие записи сохраняются: синхронизация принимает их прежние значения, пока их не изменят.
+type dynamicFieldResponse struct {
+	*models.DynamicFieldDefinition
+	InvalidEntries []invalidScheduleEntry `json:"invalid_entries"`
+}
+
+// invalidScheduleEntry - запись расписания, динамические поля которой не соответствуют схеме.
+type invalidScheduleEntry struct {
+	EntryId int64  `json:"entry_id"`
+	Message string `json:"message"`
+}
+
+// validate проверяет описание поля. Название используется как ключ в DynamicFieldsJson,
+// поэтому не может содержать кавычки и управляющие символы.
+func (req *dynamicFieldRequest) validate() string {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return "Название поля не может быть пустым."
+	}
+	if len([]rune(req.Name)) > schedule.MaxFieldNameLength {
+		return fmt.Sprintf("Название поля длиннее %d символов.", schedule.MaxFieldNameLength)
+	}
+	if strings.ContainsAny(req.Name, `"\`) || strings.IndexFunc(req.Name, unicode.IsControl) >= 0 {
+		return "Название поля не может содержать кавычки, обратную косую черту и управляющие символы."
+	}
+	req.Type = models.DynamicFieldType(strings.ToLower(strings.TrimSpace(string(req.Type))))
+	if !req.Type.IsValid() {
+		return "Неверный тип поля: ожидается text, number, date, choice или checkbox."
+	}
+
+	if req.Type != models.DynamicFieldChoice {
+		req.Options = nil
+		return ""
+	}
+	seen := make(map[string]bool, len(req.Options))
+	options := make([]string, 0, len(req.Options))
+	for _, option := range req.Options {
+		option = strings.TrimSpace(option)
+		if option == "" || seen[strings.ToLower(option)] {
+			continue
+		}
+		seen[strings.ToLower(option)] = true
+		options = append(options, option)
+	}
+	if len(options) == 0 {
+		return "Для поля типа choice нужно указать варианты (options)."
+	}
+	req.Options = options
+	return ""
+}
+
+// applyTo переносит поля запроса в описание поля.
+func (req *dynamicFieldRequest) applyTo(definition *models.DynamicFieldDefinition) {
+	definition.Name = req.Name
+	definition.Type = req.Type
+	definition.Required = req.Required
+	definition.Options = req.Options
+	definition.Position = req.Position
+}
+
+// GetDynamicFieldsHandler возвращает схему динамических полей совместной БД.
+// GET /api/collaboration/databases/{db_id}/schedule/fields
+func GetDynamicFieldsHandler(w http.ResponseWriter, r *http.Request) {
+	_, dbID, _, ok := requireDatabaseMember(w, r)
+	if !ok {
+		return
+	}
+
+	definitions, err := data.GetDynamicFieldDefinitionsBySharedDBID(dbID)
+	if err != nil {
+		log.Printf("Ошибка при получении схемы полей БД %d: %v", dbID, err)
+		respondError(w, http.StatusInternalServerError, "Ошибка при получении схемы полей.")
+		return
+	}
+	respondJSON(w, http.StatusOK, definitions)
+}
+
+// CreateDynamicFieldHandler добавляет поле в схему полей совместной БД. Доступно только владельцу.
+// В ответе invalid_entries перечисляет записи, которые не соответствуют новой схеме (например, без обязательного поля).
+// POST /api/collaboration/databases/{db_id}/schedule/fields
+// Тело: {"name": "Аудитория", "type": "choice", "required": true, "options": ["101", "102"], "position": 0}
+func CreateDynamicFieldHandler(w http.ResponseWriter, r *http.Request) {
+	dbID, req, ok := decodeDynamicFieldRequest(w, r)
+	if !ok {
+		return
+	}
+	if !checkDynamicFieldNameFree(w, dbID, req.Name, 0) {
+		return
+	}
+
+	definition := &models.DynamicFieldDefinition{DatabaseId: dbID}
+	req.applyTo(definition)
+	id, err := data.CreateDynamicFieldDefinition(definition)
+	if err != nil {
+		log.Printf("Ошибка при создании поля схемы в БД %d: %v", dbID, err)
+		respondError(w, http.StatusInternalServerError, "Не удалось создать поле.")
+		return
+	}
+	definition.Id = id
+	if definition.Options == nil {
+		definition.Options = []string{}
+	}
+	respondJSON(w, http.StatusCreated, dynamicFieldResponse{definition, findInvalidScheduleEntries(dbID)})
+}
+
+// UpdateDynamicFieldHandler изменяет поле схемы. Доступно только владельцу.
+// При переименовании поля ключ переименовывается и в записях расписания; invalid_entries - как при создании поля.
+// PUT /api/collaboration/databases/{db_id}/schedule/fields/{field_id}
+func UpdateDynamicFieldHandler(w http.ResponseWriter, r *http.Request) {
+	dbID, req, ok := decodeDynamicFieldRequest(w, r)
+	if !ok {
+		return
+	}
+	fieldID, ok := parseIDVar(w, r, "field_id")
+	if !ok {
+		return
+	}
+
+	definition, err := data.GetDynamicFieldDefinitionByID(fieldID, dbID)
+	if err != nil {
+		log.Printf("Ошибка при получении поля схемы %d в БД %d: %v", fieldID, dbID, err)
+		respondError(w, http.StatusInternalServerError, "Ошибка при получении поля.")
+		return
+	}
+	if definition == nil {
+		respondError(w, http.StatusNotFound, "Поле не найдено.")
+		return
+	}
+	if !checkDynamicFieldNameFree(w, dbID, req.Name, fieldID) {
+		return
+	}
+
+	oldName := definition.Name
+	req.applyTo(definition)
+	if err := data.UpdateDynamicFieldDefinition(definition, oldName); err != nil {
+		if err == sql.ErrNoRows {
+			respondError(w, http.StatusNotFound, "Поле не найдено.")
+			return
+		}
+		log.Printf("Ошибка при обновлении поля схемы %d в БД %d: %v", fieldID, dbID, err)
+		respondError(w, http.StatusInternalServerError, "Не удалось обновить поле.")
+		return
+	}
+	if definition.Options == nil {
+		definition.Options = []string{}
+	}
+	respondJSON(w, http.StatusOK, dynamicFieldResponse{definition, findInvalidScheduleEntries(dbID)})
+}
+
+// DeleteDynamicFieldHandler удаляет поле из схемы. Доступно только владельцу.
+// Значения поля в записях расписания сохраняются.
+// DELETE /api/collaboration/databases/{db_id}/schedule/fields/{field_id}
+func DeleteDynamicFieldHandler(w http.ResponseWriter, r *http.Request) {
+	_, dbID, role, ok := requireDatabaseMember(w, r)
+	if !ok {
+		return
+	}
+	if role != models.RoleOwner {
+		respondError(w, http.StatusForbidden, "Изменять схему полей может только владелец базы данных.")
+		return
+	}
+	fieldID, ok := parseIDVar(w, r, "field_id")
+	if !ok {
+		return
+	}
+
+	if err := data.DeleteDynamicFieldDefinition(fieldID, dbID); err != nil {
+		if err == sql.ErrNoRows {
+			respondError(w, http.StatusNotFound, "Поле не найдено.")
+			return
+		}
+		log.Printf("Ошибка при удалении поля схемы %d в БД %d: %v", fieldID, dbID, err)
+		respondError(w, http.StatusInternalServerError, "Не удалось удалить поле.")
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Поле удалено."})
+}
+
+// decodeDynamicFieldRequest проверяет, что пользователь - владелец БД, и разбирает тело запроса.
+// При ошибке отправляет ответ и возвращает ok = false.
+func decodeDynamicFieldRequest(w http.ResponseWriter, r *http.Request) (dbID int64, req dynamicFieldRequest, ok bool) {
+	_, dbID, role, ok := requireDatabaseMember(w, r)
+	if !ok {
+		return 0, req, false
+	}
+	if role != models.RoleOwner {
+		respondError(w, http.StatusForbidden, "Изменять схему полей может только владелец базы данных.")
+		return 0, req, false
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Неверный формат запроса: "+err.Error())
+		return 0, req, false
+	}
+	defer r.Body.Close()
+	if msg := req.validate(); msg != "" {
+		respondError(w, http.StatusBadRequest, msg)
+		return 0, req, false
+	}
+	return dbID, req, true
+}
+
+// checkDynamicFieldNameFree проверяет, что в схеме нет другого поля с таким названием.
+// При ошибке отправляет ответ и возвращает false.
+func checkDynamicFieldNameFree(w http.ResponseWriter, dbID int64, name string, fieldID int64) bool {
+	existing, err := data.GetDynamicFieldDefinitionByName(name, dbID)
+	if err != nil {
+		log.Printf("Ошибка при проверке названия поля в БД %d: %v", dbID, err)
+		respondError(w, http.StatusInternalServerError, "Ошибка при проверке названия поля.")
+		return false
+	}
+	if existing != nil && existing.Id != fieldID {
+		respondError(w, http.StatusConflict, "Поле с таким названием уже есть.")
+		return false
+	}
+	return true
+}
+
+// validateScheduleEntryFields проверяет динамические поля записи по схеме полей БД и нормализует их.
+// При ошибке отправляет ответ и возвращает false.
+func validateScheduleEntryFields(w http.ResponseWriter, dbID int64, entry *models.ScheduleEntry) bool {
+	definitions, err := data.GetDynamicFieldDefinitionsBySharedDBID(dbID)
+	if err != nil {
+		log.Printf("Ошибка при получении схемы полей БД %d: %v", dbID, err)
+		respondError(w, http.StatusInternalServerError, "Ошибка при получении схемы полей.")
+		return false
+	}
+	fieldsJson, err := schedule.ValidateDynamicFields(entry.DynamicFieldsJson, definitions)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Неверные динамические поля: "+err.Error())
+		return false
+	}
+	entry.DynamicFieldsJson = fieldsJson
+	return true
+}
+
+// findInvalidScheduleEntries проверяет записи расписания БД по текущей схеме полей. Ошибка чтения
+// только логируется: поле уже сохранено, и отчет о записях не должен превращать ответ в ошибку.
+func findInvalidScheduleEntries(dbID int64) []invalidScheduleEntry {
+	invalid := []invalidScheduleEntry{}
+	definitions, err := data.GetDynamicFieldDefinitionsBySharedDBID(dbID)
+	if err != nil {
+		log.Printf("Ошибка при получении схемы полей БД %d: %v", dbID, err)
+		return invalid
+	}
+	entries, err := data.GetScheduleEntriesByDBID(dbID)
+	if err != nil {
+		log.Printf("Ошибка при получении записей расписания БД %d: %v", dbID, err)
+		return invalid
+	}
+	for i := range entries {
+		if _, err := schedule.ValidateDynamicFields(entries[i].DynamicFieldsJson, definitions); err != nil {
+			invalid = append(invalid, invalidScheduleEntry{EntryId: entries[i].Id, Message: err.Error()})
+		}
+	}
+	return invalid
+}
@@ -0,0 +1,221 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"notes_server_go/data"
+	"notes_server_go/models"
+
+	"github.com/gorilla/mux"
+)
+
+// entityLinkRequest - тело запроса на создание ссылки между сущностями.
+type entityLinkRequest struct {
+	SourceType models.EntityType `json:"source_type"`
+	SourceId   int64             `json:"source_id"`
+	TargetType models.EntityType `json:"target_type"`
+	TargetId   int64             `json:"target_id"`
+	Label      string            `json:"label"`
+}
+
+// validate проверяет типы и ID концов ссылки.
+func (req *entityLinkRequest) validate() string {
+	req.Label = strings.TrimSpace(req.Label)
+	if !req.SourceType.IsValid() || !req.TargetType.IsValid() {
+		return "Неверный тип сущности: ожидается note, pinboard_note, schedule_entry или folder."
+	}
+	if req.SourceId <= 0 || req.TargetId <= 0 {
+		return "Не указаны source_id и target_id."
+	}
+	if req.SourceType == req.TargetType && req.SourceId == req.TargetId {
+		return "Сущность не может ссылаться сама на себя."
+	}
+	return ""
+}
+
+// CreateEntityLinkHandler создает ссылку между двумя сущностями совместной БД.
+// POST /api/collaboration/databases/{db_id}/links
+// Тело: {"source_type": "schedule_entry", "source_id": 5, "target_type": "note", "target_id": 12, "label": "Протокол"}
+func CreateEntityLinkHandler(w http.ResponseWriter, r *http.Request) {
+	userID, dbID, _, ok := requireDatabaseMember(w, r)
+	if !ok {
+		return
+	}
+
+	var req entityLinkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Неверный формат запроса: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+	if msg := req.validate(); msg != "" {
+		respondError(w, http.StatusBadRequest, msg)
+		return
+	}
+	for _, end := range []struct {
+		entityType models.EntityType
+		id         int64
+	}{{req.SourceType, req.SourceId}, {req.TargetType, req.TargetId}} {
+		if !requireEntity(w, dbID, end.entityType, end.id) {
+			return
+		}
+	}
+
+	existing, err := data.GetEntityLinkByEnds(dbID, req.SourceType, req.SourceId, req.TargetType, req.TargetId)
+	if err != nil {
+		log.Printf("Ошибка при проверке ссылки в БД %d: %v", dbID, err)
+		respondError(w, http.StatusInternalServerError, "Не удалось создать ссылку.")
+		return
+	}
+	if existing != nil {
+		respondError(w, http.StatusConflict, "Такая ссылка уже есть.")
+		return
+	}
+
+	link := &models.EntityLink{
+		DatabaseId:      dbID,
+		SourceType:      req.SourceType,
+		SourceId:        req.SourceId,
+		TargetType:      req.TargetType,
+		TargetId:        req.TargetId,
+		Label:           req.Label,
+		CreatedByUserId: &userID,
+	}
+	id, err := data.CreateEntityLink(link)
+	if err != nil {
+		log.Printf("Ошибка при создании ссылки в БД %d: %v", dbID, err)
+		respondError(w, http.StatusInternalServerError, "Не удалось создать ссылку.")
+		return
+	}
+	link.Id = id
+	respondJSON(w, http.StatusCreated, link)
+}
+
+// UpdateEntityLinkHandler меняет подпись ссылки. Концы ссылки не меняются: для этого ее нужно пересоздать.
+// PUT /api/collaboration/databases/{db_id}/links/{link_id}
+// Тело: {"label": "Протокол встречи"}
+func UpdateEntityLinkHandler(w http.ResponseWriter, r *http.Request) {
+	_, dbID, _, ok := requireDatabaseMember(w, r)
+	if !ok {
+		return
+	}
+	linkID, ok := parseIDVar(w, r, "link_id")
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Label string `json:"label"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Неверный формат запроса: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+
+	link, err := data.GetEntityLinkByID(linkID, dbID)
+	if err != nil {
+		log.Printf("Ошибка при получении ссылки %d в БД %d: %v", linkID, dbID, err)
+		respondError(w, http.StatusInternalServerError, "Ошибка при получении ссылки.")
+		return
+	}
+	if link == nil {
+		respondError(w, http.StatusNotFound, "Ссылка не найдена.")
+		return
+	}
+
+	link.Label = strings.TrimSpace(req.Label)
+	if err := data.UpdateEntityLink(link); err != nil {
+		if err == sql.ErrNoRows {
+			respondError(w, http.StatusNotFound, "Ссылка не найдена.")
+			return
+		}
+		log.Printf("Ошибка при обновлении ссылки %d в БД %d: %v", linkID, dbID, err)
+		respondError(w, http.StatusInternalServerError, "Не удалось обновить ссылку.")
+		return
+	}
+	respondJSON(w, http.StatusOK, link)
+}
+
+// DeleteEntityLinkHandler удаляет ссылку.
+// DELETE /api/collaboration/databases/{db_id}/links/{link_id}
+func DeleteEntityLinkHandler(w http.ResponseWriter, r *http.Request) {
+	_, dbID, _, ok := requireDatabaseMember(w, r)
+	if !ok {
+		return
+	}
+	linkID, ok := parseIDVar(w, r, "link_id")
+	if !ok {
+		return
+	}
+
+	if err := data.DeleteEntityLink(linkID, dbID); err != nil {
+		if err == sql.ErrNoRows {
+			respondError(w, http.StatusNotFound, "Ссылка не найдена.")
+			return
+		}
+		log.Printf("Ошибка при удалении ссылки %d в БД %d: %v", linkID, dbID, err)
+		respondError(w, http.StatusInternalServerError, "Не удалось удалить ссылку.")
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Ссылка удалена."})
+}
+
+// GetEntityLinksHandler возвращает исходящие ссылки сущности с заголовками целей.
+// GET /api/collaboration/databases/{db_id}/entities/{entity_type}/{entity_id}/links
+func GetEntityLinksHandler(w http.ResponseWriter, r *http.Request) {
+	getLinkedEntities(w, r, data.GetOutgoingEntityLinks)
+}
+
+// GetEntityBacklinksHandler возвращает обратные ссылки на сущность (кто на нее ссылается) с заголовками источников.
+// GET /api/collaboration/databases/{db_id}/entities/{entity_type}/{entity_id}/backlinks
+func GetEntityBacklinksHandler(w http.ResponseWriter, r *http.Request) {
+	getLinkedEntities(w, r, data.GetIncomingEntityLinks)
+}
+
+// getLinkedEntities - общая часть обработчиков ссылок и обратных ссылок сущности.
+func getLinkedEntities(w http.ResponseWriter, r *http.Request, load func(int64, models.EntityType, int64) ([]models.LinkedEntity, error)) {
+	_, dbID, _, ok := requireDatabaseMember(w, r)
+	if !ok {
+		return
+	}
+	entityType := models.EntityType(mux.Vars(r)["entity_type"])
+	if !entityType.IsValid() {
+		respondError(w, http.StatusBadRequest, "Неверный тип сущности: ожидается note, pinboard_note, schedule_entry или folder.")
+		return
+	}
+	entityID, ok := parseIDVar(w, r, "entity_id")
+	if !ok {
+		return
+	}
+	if !requireEntity(w, dbID, entityType, entityID) {
+		return
+	}
+
+	links, err := load(dbID, entityType, entityID)
+	if err != nil {
+		log.Printf("Ошибка при получении ссылок %s %d в БД %d: %v", entityType, entityID, dbID, err)
+		respondError(w, http.StatusInternalServerError, "Ошибка при получении ссылок.")
+		return
+	}
+	respondJSON(w, http.StatusOK, links)
+}
+
+// requireEntity проверяет, что сущность есть в совместной БД; иначе отвечает 404.
+func requireEntity(w http.ResponseWriter, dbID int64, entityType models.EntityType, id int64) bool {
+	exists, err := data.EntityExists(dbID, entityType, id)
+	if err != nil {
+		log.Printf("Ошибка при проверке сущности %s %d в БД %d: %v", entityType, id, dbID, err)
+		respondError(w, http.StatusInternalServerError, "Ошибка при проверке сущности.")
+		return false
+	}
+	if !exists {
+		respondError(w, http.StatusNotFound, "Сущность "+string(entityType)+" не найдена.")
+		return false
+	}
+	return true
+}
@@ -0,0 +1,134 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"notes_server_go/data"
+	"notes_server_go/models"
+	"notes_server_go/schedule"
+)
+
+// freeBusyUser - занятость одного участника совместной БД.
+type freeBusyUser struct {
+	UserId      int64                   `json:"user_id"`
+	DisplayName *string                 `json:"display_name"`
+	Busy        []schedule.BusyInterval `json:"busy"`
+}
+
+// freeBusySource - записи и исключения одной совместной БД, загруженные для расчета занятости.
+type freeBusySource struct {
+	occurrences []schedule.Occurrence
+	location    *time.Location
+}
+
+// GetScheduleFreeBusyHandler возвращает интервалы занятости участников совместной БД в диапазоне дат.
+// Занятость собирается по всем совместным БД, в которых состоит каждый участник, и включает только
+// время: сами записи (текст, поля, БД) не раскрываются. Запись относится к ответственному за нее,
+// а если он не назначен - к автору. Записи без времени окончания занятость не создают.
+// GET /api/collaboration/databases/{db_id}/schedule/freebusy?from=2025-03-01&to=2025-03-07&tz=Europe/Moscow&user_id=2
+// tz - часовой пояс диапазона и ответа (по умолчанию часовой пояс БД), user_id - только указанный участник.
+func GetScheduleFreeBusyHandler(w http.ResponseWriter, r *http.Request) {
+	_, dbID, _, ok := requireDatabaseMember(w, r)
+	if !ok {
+		return
+	}
+
+	from, to, ok := parseDateRange(w, r)
+	if !ok {
+		return
+	}
+	dbLoc, ok := databaseLocation(w, dbID)
+	if !ok {
+		return
+	}
+	loc, ok := viewerLocation(w, r, dbLoc)
+	if !ok {
+		return
+	}
+	var onlyUserID int64
+	if value := strings.TrimSpace(r.URL.Query().Get("user_id")); value != "" {
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || id <= 0 {
+			respondError(w, http.StatusBadRequest, "Неверный параметр user_id.")
+			return
+		}
+		onlyUserID = id
+	}
+
+	members, err := data.GetUsersInSharedDatabaseWithDetails(dbID)
+	if err != nil {
+		log.Printf("Ошибка при получении участников БД %d: %v", dbID, err)
+		respondError(w, http.StatusInternalServerError, "Ошибка при получении участников базы данных.")
+		return
+	}
+
+	// Границы диапазона - полночь в часовом поясе ответа; to включается целиком
+	rangeStart := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, loc)
+	rangeEnd := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, loc).AddDate(0, 0, 1)
+
+	users := []freeBusyUser{}
+	sources := make(map[int64]*freeBusySource) // Совместные БД, общие для нескольких участников, загружаются один раз
+	for _, member := range members {
+		if onlyUserID != 0 && member.UserId != onlyUserID {
+			continue
+		}
+		dbs, err := data.GetSharedDatabasesForUser(member.UserId)
+		if err != nil {
+			log.Printf("Ошибка при получении совместных БД пользователя %d: %v", member.UserId, err)
+			respondError(w, http.StatusInternalServerError, "Ошибка при получении занятости.")
+			return
+		}
+		busy := []schedule.BusyInterval{}
+		for i := range dbs {
+			source, err := loadFreeBusySource(sources, &dbs[i], from, to)
+			if err != nil {
+				log.Printf("Ошибка при получении расписания БД %d: %v", dbs[i].Id, err)
+				respondError(w, http.StatusInternalServerError, "Ошибка при получении занятости.")
+				return
+			}
+			busy = append(busy, schedule.BusyIntervals(source.occurrences, source.location, member.UserId, rangeStart, rangeEnd)...)
+		}
+		users = append(users, freeBusyUser{
+			UserId:      member.UserId,
+			DisplayName: member.DisplayName,
+			Busy:        schedule.MergeBusyIntervals(busy, loc),
+		})
+	}
+	if onlyUserID != 0 && len(users) == 0 {
+		respondError(w, http.StatusNotFound, "Пользователь не является участником базы данных.")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"from":      from.Format(schedule.DateLayout),
+		"to":        to.Format(schedule.DateLayout),
+		"time_zone": loc.String(),
+		"users":     users,
+	})
+}
+
+// loadFreeBusySource разворачивает повторения записей совместной БД в диапазоне [from, to] с запасом
+// в сутки с каждой стороны (часовые пояса записей и ответа могут различаться) и кэширует результат.
+func loadFreeBusySource(sources map[int64]*freeBusySource, db *models.SharedDatabase, from time.Time, to time.Time) (*freeBusySource, error) {
+	if source, ok := sources[db.Id]; ok {
+		return source, nil
+	}
+	entries, err := data.GetScheduleEntriesByDBID(db.Id)
+	if err != nil {
+		return nil, err
+	}
+	source := &freeBusySource{location: schedule.DatabaseLocation(db)}
+	if len(entries) > 0 {
+		exceptions, err := data.GetScheduleExceptionsBySharedDBID(db.Id)
+		if err != nil {
+			return nil, err
+		}
+		source.occurrences = schedule.ExpandAll(entries, exceptions, from.AddDate(0, 0, -1), to.AddDate(0, 0, 1))
+	}
+	sources[db.Id] = source
+	return source, nil
+}
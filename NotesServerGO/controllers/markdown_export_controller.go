@@ -0,0 +1,72 @@
+package controllers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+
+	"notes_server_go/data"
+	"notes_server_go/markdown"
+)
+
+// ExportMarkdownHandler выгружает заметки совместной БД в zip-архив Markdown: папки - директории,
+// заметки - файлы .md с front matter, изображения - рядом с заметками с относительными ссылками.
+// GET /api/collaboration/databases/{db_id}/export/markdown
+func ExportMarkdownHandler(w http.ResponseWriter, r *http.Request) {
+	_, dbID, _, ok := requireDatabaseMember(w, r)
+	if !ok {
+		return
+	}
+
+	sdb, err := data.GetSharedDatabaseDetails(dbID)
+	if err != nil || sdb == nil {
+		log.Printf("Ошибка при получении совместной БД %d для экспорта в Markdown: %v", dbID, err)
+		respondError(w, http.StatusInternalServerError, "Ошибка при получении базы данных.")
+		return
+	}
+	archive, err := loadMarkdownArchive(dbID)
+	if err != nil {
+		log.Printf("Ошибка при подготовке экспорта БД %d в Markdown: %v", dbID, err)
+		respondError(w, http.StatusInternalServerError, "Ошибка при подготовке экспорта.")
+		return
+	}
+
+	w.Header().Set("Content-Type", markdown.ContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="notes-%d.zip"; filename*=UTF-8''%s`,
+		dbID, url.PathEscape(sdb.Name+".zip")))
+	w.WriteHeader(http.StatusOK)
+	if err := archive.WriteZip(w); err != nil {
+		log.Printf("Ошибка при экспорте БД %d в Markdown: %v", dbID, err)
+	}
+}
+
+// loadMarkdownArchive загружает папки, заметки, теги, категории и изображения совместной БД.
+func loadMarkdownArchive(dbID int64) (*markdown.Archive, error) {
+	folders, err := data.GetFoldersForDatabase(dbID)
+	if err != nil {
+		return nil, err
+	}
+	notes, err := data.GetNotesForDatabase(dbID)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := data.GetNoteTagsMap(dbID)
+	if err != nil {
+		return nil, err
+	}
+	categories, err := data.GetCategoriesBySharedDBID(dbID)
+	if err != nil {
+		return nil, err
+	}
+	images, err := data.GetAllNoteImagesBySharedDBID(dbID)
+	if err != nil {
+		return nil, err
+	}
+
+	archive := &markdown.Archive{Folders: folders, Notes: notes, Tags: tags, Categories: make(map[int64]string), Images: images}
+	for _, category := range categories {
+		archive.Categories[category.Id] = category.Name
+	}
+	return archive, nil
+}
@@ -0,0 +1,186 @@
+package controllers
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+
+	"notes_server_go/data"
+	"notes_server_go/models"
+	"notes_server_go/revisions"
+)
+
+// noteRevisionDiff - построчное сравнение текста двух версий заметки.
+type noteRevisionDiff struct {
+	From          models.NoteRevisionSummary  `json:"from"`
+	To            *models.NoteRevisionSummary `json:"to"` // null - текущее состояние заметки
+	TitleChanged  bool                        `json:"title_changed"`
+	FolderChanged bool                        `json:"folder_changed"`
+	Lines         []revisions.Line            `json:"lines"`
+	Stats         revisions.Stats             `json:"stats"`
+}
+
+// GetNoteRevisionsHandler возвращает историю версий заметки (без содержимого), начиная с последней.
+// GET /api/collaboration/databases/{db_id}/notes/{note_id}/revisions
+func GetNoteRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	_, dbID, _, ok := requireDatabaseMember(w, r)
+	if !ok {
+		return
+	}
+	noteID, ok := parseIDVar(w, r, "note_id")
+	if !ok {
+		return
+	}
+	if !requireEntity(w, dbID, models.EntityNote, noteID) {
+		return
+	}
+
+	revisionsList, err := data.GetNoteRevisions(dbID, noteID)
+	if err != nil {
+		log.Printf("Ошибка при получении версий заметки %d в БД %d: %v", noteID, dbID, err)
+		respondError(w, http.StatusInternalServerError, "Ошибка при получении версий заметки.")
+		return
+	}
+	respondJSON(w, http.StatusOK, revisionsList)
+}
+
+// GetNoteRevisionHandler возвращает версию заметки с содержимым.
+// GET /api/collaboration/databases/{db_id}/notes/{note_id}/revisions/{revision_id}
+func GetNoteRevisionHandler(w http.ResponseWriter, r *http.Request) {
+	_, dbID, _, ok := requireDatabaseMember(w, r)
+	if !ok {
+		return
+	}
+	noteID, ok := parseIDVar(w, r, "note_id")
+	if !ok {
+		return
+	}
+	revisionID, ok := parseIDVar(w, r, "revision_id")
+	if !ok {
+		return
+	}
+
+	revision, ok := findNoteRevision(w, dbID, noteID, revisionID)
+	if !ok {
+		return
+	}
+	respondJSON(w, http.StatusOK, revision)
+}
+
+// DiffNoteRevisionsHandler построчно сравнивает текст двух версий заметки.
+// Без параметра to версия from сравнивается с текущим состоянием заметки.
+// GET /api/collaboration/databases/{db_id}/notes/{note_id}/revisions/diff?from=3&to=7
+func DiffNoteRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	_, dbID, _, ok := requireDatabaseMember(w, r)
+	if !ok {
+		return
+	}
+	noteID, ok := parseIDVar(w, r, "note_id")
+	if !ok {
+		return
+	}
+	query := r.URL.Query()
+	fromID, err := strconv.ParseInt(query.Get("from"), 10, 64)
+	if err != nil || fromID <= 0 {
+		respondError(w, http.StatusBadRequest, "Неверный параметр from: ожидается ID версии.")
+		return
+	}
+
+	from, ok := findNoteRevision(w, dbID, noteID, fromID)
+	if !ok {
+		return
+	}
+	var to *models.NoteRevision
+	var toNote *models.Note
+	if raw := query.Get("to"); raw != "" {
+		toID, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || toID <= 0 {
+			respondError(w, http.StatusBadRequest, "Неверный параметр to: ожидается ID версии.")
+			return
+		}
+		if to, ok = findNoteRevision(w, dbID, noteID, toID); !ok {
+			return
+		}
+		toNote = &models.Note{Title: to.Title, Content: to.Content, ContentJson: to.ContentJson, FolderID: to.FolderId}
+	} else {
+		toNote, err = data.GetNoteByID(noteID, dbID)
+		if err != nil {
+			log.Printf("Ошибка при получении заметки %d в БД %d: %v", noteID, dbID, err)
+			respondError(w, http.StatusInternalServerError, "Ошибка при получении заметки.")
+			return
+		}
+		if toNote == nil {
+			respondError(w, http.StatusNotFound, "Заметка не найдена.")
+			return
+		}
+	}
+
+	fromNote := &models.Note{Title: from.Title, Content: from.Content, ContentJson: from.ContentJson, FolderID: from.FolderId}
+	lines := revisions.Diff(data.NotePlainText(fromNote), data.NotePlainText(toNote))
+	diff := noteRevisionDiff{
+		From:          from.NoteRevisionSummary,
+		TitleChanged:  fromNote.Title != toNote.Title,
+		FolderChanged: !sameFolder(fromNote.FolderID, toNote.FolderID),
+		Lines:         lines,
+		Stats:         revisions.Count(lines),
+	}
+	if to != nil {
+		diff.To = &to.NoteRevisionSummary
+	}
+	respondJSON(w, http.StatusOK, diff)
+}
+
+// RestoreNoteRevisionHandler возвращает заметке заголовок, содержимое и папку версии.
+// Восстановление сохраняется как новая версия, поэтому его тоже можно отменить.
+// POST /api/collaboration/databases/{db_id}/notes/{note_id}/revisions/{revision_id}/restore
+func RestoreNoteRevisionHandler(w http.ResponseWriter, r *http.Request) {
+	userID, dbID, _, ok := requireDatabaseMember(w, r)
+	if !ok {
+		return
+	}
+	noteID, ok := parseIDVar(w, r, "note_id")
+	if !ok {
+		return
+	}
+	revisionID, ok := parseIDVar(w, r, "revision_id")
+	if !ok {
+		return
+	}
+
+	revision, ok := findNoteRevision(w, dbID, noteID, revisionID)
+	if !ok {
+		return
+	}
+	note, err := data.RestoreNoteRevision(dbID, revision, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			respondError(w, http.StatusNotFound, "Заметка не найдена.")
+			return
+		}
+		log.Printf("Ошибка при восстановлении заметки %d из версии %d в БД %d: %v", noteID, revisionID, dbID, err)
+		respondError(w, http.StatusInternalServerError, "Не удалось восстановить версию заметки.")
+		return
+	}
+	respondJSON(w, http.StatusOK, note)
+}
+
+// findNoteRevision загружает версию заметки; если ее нет, отвечает 404.
+func findNoteRevision(w http.ResponseWriter, dbID int64, noteID int64, revisionID int64) (*models.NoteRevision, bool) {
+	revision, err := data.GetNoteRevisionByID(revisionID, dbID, noteID)
+	if err != nil {
+		log.Printf("Ошибка при получении версии %d заметки %d в БД %d: %v", revisionID, noteID, dbID, err)
+		respondError(w, http.StatusInternalServerError, "Ошибка при получении версии заметки.")
+		return nil, false
+	}
+	if revision == nil {
+		respondError(w, http.StatusNotFound, "Версия заметки не найдена.")
+		return nil, false
+	}
+	return revision, true
+}
+
+// sameFolder сравнивает папки двух состояний заметки (nil - без папки).
+func sameFolder(a, b *int64) bool {
+	return (a == nil && b == nil) || (a != nil && b != nil && *a == *b)
+}
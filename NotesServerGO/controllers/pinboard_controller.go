@@ -0,0 +1,283 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"notes_server_go/data"
+	"notes_server_go/models"
+)
+
+// pinboardRequest - тело запроса на создание/обновление доски.
+type pinboardRequest struct {
+	Name     string `json:"name"`
+	Position *int   `json:"position"` // Не задано - в конец списка (при создании) или без изменений (при обновлении)
+}
+
+// validate проверяет название и позицию доски.
+func (req *pinboardRequest) validate() string {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return "Название доски не может быть пустым."
+	}
+	if req.Position != nil && *req.Position < 0 {
+		return "Позиция доски не может быть отрицательной."
+	}
+	return ""
+}
+
+// pinboardSummary - доска в списке досок с количеством карточек на ней.
+type pinboardSummary struct {
+	models.Pinboard
+	NoteCount int `json:"note_count"`
+}
+
+// GetPinboardsHandler возвращает доски совместной БД в порядке position.
+// Если досок еще нет, создается доска по умолчанию.
+// GET /api/collaboration/databases/{db_id}/pinboards
+func GetPinboardsHandler(w http.ResponseWriter, r *http.Request) {
+	_, dbID, _, ok := requireDatabaseMember(w, r)
+	if !ok {
+		return
+	}
+
+	if _, err := data.GetDefaultPinboardID(dbID); err != nil {
+		log.Printf("Ошибка при получении доски по умолчанию БД %d: %v", dbID, err)
+		respondError(w, http.StatusInternalServerError, "Ошибка при получении досок.")
+		return
+	}
+	boards, err := data.GetPinboardsBySharedDBID(dbID)
+	if err != nil {
+		log.Printf("Ошибка при получении досок БД %d: %v", dbID, err)
+		respondError(w, http.StatusInternalServerError, "Ошибка при получении досок.")
+		return
+	}
+	counts, err := data.CountPinboardNotesByBoard(dbID)
+	if err != nil {
+		log.Printf("Ошибка при подсчете карточек досок БД %d: %v", dbID, err)
+		respondError(w, http.StatusInternalServerError, "Ошибка при получении досок.")
+		return
+	}
+	summaries := make([]pinboardSummary, 0, len(boards))
+	for _, board := range boards {
+		summaries = append(summaries, pinboardSummary{Pinboard: board, NoteCount: counts[board.Id]})
+	}
+	respondJSON(w, http.StatusOK, summaries)
+}
+
+// CreatePinboardHandler создает доску в совместной БД.
+// POST /api/collaboration/databases/{db_id}/pinboards
+// Тело: {"name": "Идеи", "position": 1}
+func CreatePinboardHandler(w http.ResponseWriter, r *http.Request) {
+	_, dbID, _, ok := requireDatabaseMember(w, r)
+	if !ok {
+		return
+	}
+
+	var req pinboardRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Неверный формат запроса: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+	if msg := req.validate(); msg != "" {
+		respondError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	board := &models.Pinboard{DatabaseId: dbID, Name: req.Name}
+	if req.Position != nil {
+		board.Position = *req.Position
+	} else {
+		position, err := data.GetNextPinboardPosition(dbID)
+		if err != nil {
+			log.Printf("Ошибка при создании доски в БД %d: %v", dbID, err)
+			respondError(w, http.StatusInternalServerError, "Не удалось создать доску.")
+			return
+		}
+		board.Position = position
+	}
+	id, err := data.CreatePinboard(board)
+	if err != nil {
+		log.Printf("Ошибка при создании доски в БД %d: %v", dbID, err)
+		respondError(w, http.StatusInternalServerError, "Не удалось создать доску.")
+		return
+	}
+	board.Id = id
+	respondJSON(w, http.StatusCreated, board)
+}
+
+// UpdatePinboardHandler переименовывает доску и/или меняет ее позицию.
+// PUT /api/collaboration/databases/{db_id}/pinboards/{pinboard_id}
+func UpdatePinboardHandler(w http.ResponseWriter, r *http.Request) {
+	_, dbID, _, ok := requireDatabaseMember(w, r)
+	if !ok {
+		return
+	}
+	pinboardID, ok := parseIDVar(w, r, "pinboard_id")
+	if !ok {
+		return
+	}
+
+	var req pinboardRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Неверный формат запроса: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+	if msg := req.validate(); msg != "" {
+		respondError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	board, ok := findPinboard(w, dbID, pinboardID)
+	if !ok {
+		return
+	}
+
+	board.Name = req.Name
+	if req.Position != nil {
+		board.Position = *req.Position
+	}
+	if err := data.UpdatePinboard(board); err != nil {
+		if err == sql.ErrNoRows {
+			respondError(w, http.StatusNotFound, "Доска не найдена.")
+			return
+		}
+		log.Printf("Ошибка при обновлении доски %d в БД %d: %v", pinboardID, dbID, err)
+		respondError(w, http.StatusInternalServerError, "Не удалось обновить доску.")
+		return
+	}
+	respondJSON(w, http.StatusOK, board)
+}
+
+// DeletePinboardHandler удаляет доску вместе с ее карточками и связями.
+// Последнюю доску совместной БД удалить нельзя.
+// DELETE /api/collaboration/databases/{db_id}/pinboards/{pinboard_id}
+func DeletePinboardHandler(w http.ResponseWriter, r *http.Request) {
+	_, dbID, _, ok := requireDatabaseMember(w, r)
+	if !ok {
+		return
+	}
+	pinboardID, ok := parseIDVar(w, r, "pinboard_id")
+	if !ok {
+		return
+	}
+
+	boards, err := data.GetPinboardsBySharedDBID(dbID)
+	if err != nil {
+		log.Printf("Ошибка при получении досок БД %d: %v", dbID, err)
+		respondError(w, http.StatusInternalServerError, "Не удалось удалить доску.")
+		return
+	}
+	found := false
+	for _, board := range boards {
+		found = found || board.Id == pinboardID
+	}
+	if !found {
+		respondError(w, http.StatusNotFound, "Доска не найдена.")
+		return
+	}
+	if len(boards) == 1 {
+		respondError(w, http.StatusConflict, "Нельзя удалить единственную доску базы данных.")
+		return
+	}
+
+	if err := data.DeletePinboard(pinboardID, dbID); err != nil {
+		if err == sql.ErrNoRows {
+			respondError(w, http.StatusNotFound, "Доска не найдена.")
+			return
+		}
+		log.Printf("Ошибка при удалении доски %d в БД %d: %v", pinboardID, dbID, err)
+		respondError(w, http.StatusInternalServerError, "Не удалось удалить доску.")
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Доска удалена."})
+}
+
+// GetPinboardNotesHandler возвращает карточки доски и связи между ними.
+// GET /api/collaboration/databases/{db_id}/pinboards/{pinboard_id}/notes
+func GetPinboardNotesHandler(w http.ResponseWriter, r *http.Request) {
+	_, dbID, _, ok := requireDatabaseMember(w, r)
+	if !ok {
+		return
+	}
+	pinboardID, ok := parseIDVar(w, r, "pinboard_id")
+	if !ok {
+		return
+	}
+
+	board, ok := findPinboard(w, dbID, pinboardID)
+	if !ok {
+		return
+	}
+	notes, connections, ok := loadPinboardContents(w, dbID, board.Id)
+	if !ok {
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"pinboard":    board,
+		"notes":       notes,
+		"connections": connections,
+	})
+}
+
+// resolvePinboard возвращает доску из параметра запроса pinboard_id, а без него - доску по умолчанию.
+// При ошибке отправляет ответ и возвращает ok = false.
+func resolvePinboard(w http.ResponseWriter, r *http.Request, dbID int64) (*models.Pinboard, bool) {
+	var pinboardID int64
+	if raw := r.URL.Query().Get("pinboard_id"); raw != "" {
+		id, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || id <= 0 {
+			respondError(w, http.StatusBadRequest, "Неверный параметр pinboard_id.")
+			return nil, false
+		}
+		pinboardID = id
+	} else {
+		id, err := data.GetDefaultPinboardID(dbID)
+		if err != nil {
+			log.Printf("Ошибка при получении доски по умолчанию БД %d: %v", dbID, err)
+			respondError(w, http.StatusInternalServerError, "Ошибка при получении доски.")
+			return nil, false
+		}
+		pinboardID = id
+	}
+	return findPinboard(w, dbID, pinboardID)
+}
+
+// findPinboard загружает доску совместной БД по ID; если ее нет, отвечает 404.
+func findPinboard(w http.ResponseWriter, dbID int64, pinboardID int64) (*models.Pinboard, bool) {
+	board, err := data.GetPinboardByID(pinboardID, dbID)
+	if err != nil {
+		log.Printf("Ошибка при получении доски %d в БД %d: %v", pinboardID, dbID, err)
+		respondError(w, http.StatusInternalServerError, "Ошибка при получении доски.")
+		return nil, false
+	}
+	if board == nil {
+		respondError(w, http.StatusNotFound, "Доска не найдена.")
+		return nil, false
+	}
+	return board, true
+}
+
+// loadPinboardContents загружает карточки доски и связи между ними.
+// При ошибке отправляет ответ и возвращает ok = false.
+func loadPinboardContents(w http.ResponseWriter, dbID int64, pinboardID int64) ([]models.PinboardNote, []models.Connection, bool) {
+	notes, err := data.GetPinboardNotesByPinboardID(dbID, pinboardID)
+	if err != nil {
+		log.Printf("Ошибка при получении карточек доски %d БД %d: %v", pinboardID, dbID, err)
+		respondError(w, http.StatusInternalServerError, "Ошибка при получении доски.")
+		return nil, nil, false
+	}
+	connections, err := data.GetConnectionsByPinboardID(dbID, pinboardID)
+	if err != nil {
+		log.Printf("Ошибка при получении связей доски %d БД %d: %v", pinboardID, dbID, err)
+		respondError(w, http.StatusInternalServerError, "Ошибка при получении доски.")
+		return nil, nil, false
+	}
+	return notes, connections, true
+}
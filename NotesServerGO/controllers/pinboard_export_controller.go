@@ -0,0 +1,68 @@
+package controllers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"notes_server_go/data"
+	"notes_server_go/pinboard"
+)
+
+// ExportPinboardHandler выгружает доску совместной БД (карточки и связи) в SVG, Graphviz DOT или GraphML.
+// GET /api/collaboration/databases/{db_id}/pinboard/export?format=svg&download=true&pinboard_id=1
+// format - svg (по умолчанию), dot или graphml; download=true - отдать файлом (Content-Disposition: attachment);
+// pinboard_id - доска (по умолчанию доска по умолчанию).
+func ExportPinboardHandler(w http.ResponseWriter, r *http.Request) {
+	_, dbID, _, ok := requireDatabaseMember(w, r)
+	if !ok {
+		return
+	}
+	query := r.URL.Query()
+	format := strings.ToLower(strings.TrimSpace(query.Get("format")))
+	if format == "" {
+		format = pinboard.FormatSVG
+	}
+	contentType, known := pinboard.ContentTypes[format]
+	if !known {
+		respondError(w, http.StatusBadRequest, "Неверный формат: ожидается svg, dot или graphml.")
+		return
+	}
+	download := false
+	if value := query.Get("download"); value != "" {
+		var err error
+		if download, err = strconv.ParseBool(value); err != nil {
+			respondError(w, http.StatusBadRequest, "Неверный параметр download.")
+			return
+		}
+	}
+
+	sdb, err := data.GetSharedDatabaseDetails(dbID)
+	if err != nil || sdb == nil {
+		log.Printf("Ошибка при получении совместной БД %d для экспорта доски: %v", dbID, err)
+		respondError(w, http.StatusInternalServerError, "Ошибка при получении базы данных.")
+		return
+	}
+	pb, ok := resolvePinboard(w, r, dbID)
+	if !ok {
+		return
+	}
+	notes, connections, ok := loadPinboardContents(w, dbID, pb.Id)
+	if !ok {
+		return
+	}
+
+	board := pinboard.NewBoard(sdb.Name+" - "+pb.Name, notes, connections)
+	disposition := "inline"
+	if download {
+		disposition = "attachment"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`%s; filename="pinboard-%d-%d.%s"`, disposition, dbID, pb.Id, format))
+	w.WriteHeader(http.StatusOK)
+	if err := board.Encode(w, format); err != nil {
+		log.Printf("Ошибка при экспорте доски БД %d в %s: %v", dbID, format, err)
+	}
+}
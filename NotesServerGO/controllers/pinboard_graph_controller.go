@@ -0,0 +1,189 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"notes_server_go/data"
+	"notes_server_go/pinboard"
+)
+
+// pinboardComponent - компонента связности доски.
+type pinboardComponent struct {
+	Size  int             `json:"size"`
+	Cards []pinboard.Card `json:"cards"`
+}
+
+// GetPinboardNeighborsHandler возвращает соседей карточки доски по связям.
+// GET /api/collaboration/databases/{db_id}/pinboard/notes/{note_id}/neighbors?direction=both
+// direction - out (связи из карточки), in (связи в карточку) или both (по умолчанию).
+// Учитываются связи внутри доски, на которой лежит карточка.
+func GetPinboardNeighborsHandler(w http.ResponseWriter, r *http.Request) {
+	_, dbID, _, ok := requireDatabaseMember(w, r)
+	if !ok {
+		return
+	}
+	noteID, ok := parseIDVar(w, r, "note_id")
+	if !ok {
+		return
+	}
+	direction := pinboard.DirectionBoth
+	if value := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("direction"))); value != "" {
+		direction = pinboard.Direction(value)
+		if !direction.IsValid() {
+			respondError(w, http.StatusBadRequest, "Неверный параметр direction: ожидается in, out или both.")
+			return
+		}
+	}
+
+	note, err := data.GetPinboardNoteByID(noteID, dbID)
+	if err != nil {
+		log.Printf("Ошибка при получении карточки %d доски БД %d: %v", noteID, dbID, err)
+		respondError(w, http.StatusInternalServerError, "Ошибка при получении доски.")
+		return
+	}
+	if note == nil {
+		respondError(w, http.StatusNotFound, "Карточка доски не найдена.")
+		return
+	}
+	graph, ok := loadPinboardGraph(w, dbID, note.PinboardId)
+	if !ok {
+		return
+	}
+	card, found := graph.Card(noteID)
+	if !found {
+		respondError(w, http.StatusNotFound, "Карточка доски не найдена.")
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"card":      card,
+		"direction": direction,
+		"neighbors": graph.Neighbors(noteID, direction),
+	})
+}
+
+// GetPinboardPathHandler возвращает кратчайший путь (по числу связей) между двумя карточками доски.
+// GET /api/collaboration/databases/{db_id}/pinboard/path?from=1&to=5&directed=false&pinboard_id=1
+// directed=true - только по направлению связей; по умолчанию связи проходятся в обе стороны.
+// pinboard_id - доска (по умолчанию доска по умолчанию); обе карточки должны лежать на ней.
+// Если пути нет, возвращается found = false.
+func GetPinboardPathHandler(w http.ResponseWriter, r *http.Request) {
+	_, dbID, _, ok := requireDatabaseMember(w, r)
+	if !ok {
+		return
+	}
+	query := r.URL.Query()
+	from, errFrom := strconv.ParseInt(query.Get("from"), 10, 64)
+	to, errTo := strconv.ParseInt(query.Get("to"), 10, 64)
+	if errFrom != nil || errTo != nil || from <= 0 || to <= 0 {
+		respondError(w, http.StatusBadRequest, "Параметры from и to должны быть ID карточек доски.")
+		return
+	}
+	directed := false
+	if value := query.Get("directed"); value != "" {
+		var err error
+		if directed, err = strconv.ParseBool(value); err != nil {
+			respondError(w, http.StatusBadRequest, "Неверный параметр directed.")
+			return
+		}
+	}
+
+	board, ok := resolvePinboard(w, r, dbID)
+	if !ok {
+		return
+	}
+	graph, ok := loadPinboardGraph(w, dbID, board.Id)
+	if !ok {
+		return
+	}
+	if !graph.HasCard(from) || !graph.HasCard(to) {
+		respondError(w, http.StatusNotFound, "Карточка доски не найдена.")
+		return
+	}
+	cards, edges, found := graph.ShortestPath(from, to, directed)
+	if !found {
+		cards, edges = []pinboard.Card{}, []pinboard.Edge{}
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"found":       found,
+		"directed":    directed,
+		"length":      len(edges),
+		"cards":       cards,
+		"connections": edges,
+	})
+}
+
+// GetPinboardComponentsHandler возвращает компоненты связности доски (связи без учета направления),
+// от больших к меньшим. min_size - минимальный размер компоненты (по умолчанию 1, то есть все карточки).
+// pinboard_id - доска (по умолчанию доска по умолчанию).
+// GET /api/collaboration/databases/{db_id}/pinboard/components?min_size=2&pinboard_id=1
+func GetPinboardComponentsHandler(w http.ResponseWriter, r *http.Request) {
+	_, dbID, _, ok := requireDatabaseMember(w, r)
+	if !ok {
+		return
+	}
+	minSize := 1
+	if value := r.URL.Query().Get("min_size"); value != "" {
+		size, err := strconv.Atoi(value)
+		if err != nil || size < 1 {
+			respondError(w, http.StatusBadRequest, "Неверный параметр min_size.")
+			return
+		}
+		minSize = size
+	}
+
+	board, ok := resolvePinboard(w, r, dbID)
+	if !ok {
+		return
+	}
+	graph, ok := loadPinboardGraph(w, dbID, board.Id)
+	if !ok {
+		return
+	}
+	components := []pinboardComponent{}
+	for _, ids := range graph.Components() {
+		if len(ids) < minSize {
+			continue
+		}
+		component := pinboardComponent{Size: len(ids), Cards: make([]pinboard.Card, 0, len(ids))}
+		for _, id := range ids {
+			card, _ := graph.Card(id)
+			component.Cards = append(component.Cards, card)
+		}
+		components = append(components, component)
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"count":      len(components),
+		"components": components,
+	})
+}
+
+// GetPinboardOrphansHandler возвращает карточки доски, не связанные с другими карточками.
+// GET /api/collaboration/databases/{db_id}/pinboard/orphans?pinboard_id=1
+func GetPinboardOrphansHandler(w http.ResponseWriter, r *http.Request) {
+	_, dbID, _, ok := requireDatabaseMember(w, r)
+	if !ok {
+		return
+	}
+	board, ok := resolvePinboard(w, r, dbID)
+	if !ok {
+		return
+	}
+	graph, ok := loadPinboardGraph(w, dbID, board.Id)
+	if !ok {
+		return
+	}
+	respondJSON(w, http.StatusOK, graph.Orphans())
+}
+
+// loadPinboardGraph загружает карточки и связи доски pinboardID совместной БД и строит граф.
+// При ошибке отправляет ответ и возвращает ok = false.
+func loadPinboardGraph(w http.ResponseWriter, dbID int64, pinboardID int64) (*pinboard.Graph, bool) {
+	notes, connections, ok := loadPinboardContents(w, dbID, pinboardID)
+	if !ok {
+		return nil, false
+	}
+	return pinboard.NewGraph(notes, connections), true
+}
@@ -0,0 +1,112 @@
+package controllers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"notes_server_go/data"
+	"notes_server_go/pinboard"
+)
+
+// pinboardLayoutRequest - тело запроса на автоматическую раскладку доски.
+type pinboardLayoutRequest struct {
+	Algorithm  string  `json:"algorithm"`   // force (по умолчанию) или layered
+	Spacing    float64 `json:"spacing"`     // Минимальный зазор между карточками, по умолчанию pinboard.DefaultLayoutSpacing
+	Iterations int     `json:"iterations"`  // Итерации силовой раскладки, по умолчанию pinboard.DefaultLayoutIterations
+	Apply      bool    `json:"apply"`       // true - сохранить положения карточек
+	PinboardId int64   `json:"pinboard_id"` // Доска; 0 - доска по умолчанию
+}
+
+// LayoutPinboardHandler рассчитывает автоматическую раскладку карточек доски с учетом их размеров
+// и связей и возвращает предлагаемые положения. С apply = true положения всех карточек сохраняются
+// в одной транзакции (клиенты получат их при следующей синхронизации). В ответе iterations - выполненные
+// итерации силовой раскладки (на больших досках меньше запрошенных), overlaps = true - перекрытия остались.
+// POST /api/collaboration/databases/{db_id}/pinboard/layout
+// Тело: {"algorithm": "layered", "spacing": 40, "apply": false, "pinboard_id": 1}
+func LayoutPinboardHandler(w http.ResponseWriter, r *http.Request) {
+	userID, dbID, _, ok := requireDatabaseMember(w, r)
+	if !ok {
+		return
+	}
+
+	var req pinboardLayoutRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondError(w, http.StatusBadRequest, "Неверный формат запроса: "+err.Error())
+			return
+		}
+	}
+	defer r.Body.Close()
+	opts := pinboard.LayoutOptions{
+		Algorithm:  strings.ToLower(strings.TrimSpace(req.Algorithm)),
+		Spacing:    req.Spacing,
+		Iterations: req.Iterations,
+	}
+	if err := opts.Normalize(); err != nil {
+		respondError(w, http.StatusBadRequest, "Неверные параметры раскладки: "+err.Error())
+		return
+	}
+
+	if req.PinboardId < 0 {
+		respondError(w, http.StatusBadRequest, "Неверный параметр pinboard_id.")
+		return
+	}
+	if req.PinboardId == 0 {
+		id, err := data.GetDefaultPinboardID(dbID)
+		if err != nil {
+			log.Printf("Ошибка при получении доски по умолчанию БД %d: %v", dbID, err)
+			respondError(w, http.StatusInternalServerError, "Ошибка при получении доски.")
+			return
+		}
+		req.PinboardId = id
+	}
+	board, ok := findPinboard(w, dbID, req.PinboardId)
+	if !ok {
+		return
+	}
+	notes, connections, ok := loadPinboardContents(w, dbID, board.Id)
+	if !ok {
+		return
+	}
+	layout, err := pinboard.Layout(notes, connections, opts)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	positions := layout.Positions
+
+	if req.Apply && len(positions) > 0 {
+		tx, err := data.MainDB.Beginx()
+		if err != nil {
+			log.Printf("Ошибка начала транзакции раскладки доски БД %d: %v", dbID, err)
+			respondError(w, http.StatusInternalServerError, "Не удалось сохранить раскладку.")
+			return
+		}
+		defer tx.Rollback()
+		for _, position := range positions {
+			if err := data.UpdatePinboardNotePositionWithTx(tx, position.Id, dbID, position.X, position.Y); err != nil {
+				log.Printf("Ошибка при сохранении раскладки доски БД %d: %v", dbID, err)
+				respondError(w, http.StatusInternalServerError, "Не удалось сохранить раскладку.")
+				return
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			log.Printf("Ошибка фиксации раскладки доски БД %d: %v", dbID, err)
+			respondError(w, http.StatusInternalServerError, "Не удалось сохранить раскладку.")
+			return
+		}
+		log.Printf("Пользователь %d применил раскладку %s к доске %d БД %d (карточек: %d)", userID, opts.Algorithm, board.Id, dbID, len(positions))
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"pinboard_id": board.Id,
+		"algorithm":   opts.Algorithm,
+		"spacing":     opts.Spacing,
+		"applied":     req.Apply && len(positions) > 0,
+		"iterations":  layout.Iterations,
+		"overlaps":    layout.Overlaps,
+		"positions":   positions,
+	})
+}
@@ -0,0 +1,139 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"notes_server_go/data"
+	"notes_server_go/models"
+	"notes_server_go/pinboard"
+)
+
+// GetPinboardViewportHandler возвращает карточки и связи доски, попадающие в область просмотра,
+// чтобы клиент мог подгружать большую доску по частям.
+// GET /api/collaboration/databases/{db_id}/pinboard/viewport?x=0&y=0&width=1920&height=1080&margin=200&limit=500&pinboard_id=1
+// x, y - левый верхний угол области, width и height - ее размеры (обязательны); margin - запас вокруг области;
+// pinboard_id - доска (по умолчанию доска по умолчанию).
+// cards - карточки, пересекающие область (по возрастанию ID, не больше limit; truncated = true, если их больше);
+// connections - связи, у которых видна одна из карточек или линия проходит через область;
+// anchors - карточки за пределами cards, на которые ссылаются эти связи (чтобы нарисовать линии).
+func GetPinboardViewportHandler(w http.ResponseWriter, r *http.Request) {
+	_, dbID, _, ok := requireDatabaseMember(w, r)
+	if !ok {
+		return
+	}
+	query := r.URL.Query()
+	values := make(map[string]float64, 5)
+	for _, name := range []string{"x", "y", "width", "height", "margin"} {
+		raw := query.Get(name)
+		if raw == "" {
+			if name == "margin" {
+				continue
+			}
+			respondError(w, http.StatusBadRequest, "Параметры x, y, width и height обязательны.")
+			return
+		}
+		value, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Неверный параметр "+name+".")
+			return
+		}
+		values[name] = value
+	}
+	viewport, err := pinboard.NewViewport(values["x"], values["y"], values["width"], values["height"])
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Неверная область просмотра: "+err.Error())
+		return
+	}
+	if values["margin"] < 0 {
+		respondError(w, http.StatusBadRequest, "Параметр margin не может быть отрицательным.")
+		return
+	}
+	viewport = viewport.Expand(values["margin"])
+	limit := pinboard.DefaultViewportLimit
+	if raw := query.Get("limit"); raw != "" {
+		value, err := strconv.Atoi(raw)
+		if err != nil || value < 1 || value > pinboard.MaxViewportLimit {
+			respondError(w, http.StatusBadRequest, "Неверный параметр limit.")
+			return
+		}
+		limit = value
+	}
+	board, ok := resolvePinboard(w, r, dbID)
+	if !ok {
+		return
+	}
+
+	cards, err := data.GetPinboardNotesInRect(dbID, board.Id, viewport.MinX, viewport.MinY, viewport.MaxX, viewport.MaxY, limit+1)
+	if err != nil {
+		log.Printf("Ошибка при получении карточек доски БД %d в области: %v", dbID, err)
+		respondError(w, http.StatusInternalServerError, "Ошибка при получении доски.")
+		return
+	}
+	truncated := len(cards) > limit
+	if truncated {
+		cards = cards[:limit]
+	}
+	candidates, err := data.GetConnectionsNearRect(dbID, board.Id, viewport.MinX, viewport.MinY, viewport.MaxX, viewport.MaxY)
+	if err != nil {
+		log.Printf("Ошибка при получении связей доски БД %d в области: %v", dbID, err)
+		respondError(w, http.StatusInternalServerError, "Ошибка при получении доски.")
+		return
+	}
+
+	// Карточки на концах связей: видимые уже загружены, остальные догружаются по ID
+	byID := make(map[int64]*models.PinboardNote, len(cards))
+	for i := range cards {
+		byID[cards[i].Id] = &cards[i]
+	}
+	missing := []int64{}
+	requested := make(map[int64]bool)
+	for _, conn := range candidates {
+		for _, id := range []int64{conn.FromNoteId, conn.ToNoteId} {
+			if byID[id] == nil && !requested[id] {
+				requested[id] = true
+				missing = append(missing, id)
+			}
+		}
+	}
+	others, err := data.GetPinboardNotesByIDs(dbID, missing)
+	if err != nil {
+		log.Printf("Ошибка при получении карточек доски БД %d: %v", dbID, err)
+		respondError(w, http.StatusInternalServerError, "Ошибка при получении доски.")
+		return
+	}
+	endpoints := make(map[int64]*models.PinboardNote, len(byID)+len(others))
+	for id, note := range byID {
+		endpoints[id] = note
+	}
+	for i := range others {
+		endpoints[others[i].Id] = &others[i]
+	}
+
+	connections := []models.Connection{}
+	anchors := []models.PinboardNote{}
+	anchored := make(map[int64]bool)
+	for _, conn := range candidates {
+		from, to := endpoints[conn.FromNoteId], endpoints[conn.ToNoteId]
+		if from == nil || to == nil || !viewport.ConnectionVisible(from, to) {
+			continue
+		}
+		connections = append(connections, conn)
+		for _, note := range []*models.PinboardNote{from, to} {
+			if byID[note.Id] == nil && !anchored[note.Id] {
+				anchored[note.Id] = true
+				anchors = append(anchors, *note)
+			}
+		}
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"pinboard_id": board.Id,
+		"viewport":    viewport,
+		"cards":       cards,
+		"connections": connections,
+		"anchors":     anchors,
+		"truncated":   truncated,
+	})
+}
@@ -0,0 +1,335 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"notes_server_go/data"
+	"notes_server_go/models"
+	"notes_server_go/schedule"
+)
+
+// GetScheduleEntriesHandler возвращает записи расписания совместной БД.
+// GET /api/collaboration/databases/{db_id}/schedule/entries?tag=работа&tag=срочно
+// Параметр tag можно повторять: возвращаются записи, отмеченные всеми указанными тегами.
+// Отбор по динамическому полю: field=Аудитория&value=101 или field=Бюджет&min=100&max=500
+// (диапазон - для полей типа number и date). Значения сравниваются по типу поля из схемы полей БД.
+func GetScheduleEntriesHandler(w http.ResponseWriter, r *http.Request) {
+	_, dbID, _, ok := requireDatabaseMember(w, r)
+	if !ok {
+		return
+	}
+	fieldFilter, ok := parseFieldFilter(w, r, dbID)
+	if !ok {
+		return
+	}
+
+	var tags []string
+	for _, t := range r.URL.Query()["tag"] {
+		if t = data.NormalizeTag(t); t != "" {
+			tags = append(tags, t)
+		}
+	}
+
+	var entries []models.ScheduleEntry
+	var err error
+	if len(tags) > 0 {
+		entries, err = data.GetScheduleEntriesByTags(dbID, tags)
+	} else {
+		entries, err = data.GetScheduleEntriesByDBID(dbID)
+	}
+	if err != nil {
+		log.Printf("Ошибка при получении записей расписания БД %d (теги %v): %v", dbID, tags, err)
+		respondError(w, http.StatusInternalServerError, "Ошибка при получении записей расписания.")
+		return
+	}
+	if fieldFilter != nil {
+		matched := entries[:0]
+		for i := range entries {
+			if fieldFilter.Matches(&entries[i]) {
+				matched = append(matched, entries[i])
+			}
+		}
+		entries = matched
+	}
+	if entries == nil {
+		entries = []models.ScheduleEntry{}
+	}
+	respondJSON(w, http.StatusOK, entries)
+}
+
+// parseFieldFilter разбирает параметры отбора по динамическому полю (field, value, min, max).
+// Возвращает nil без ошибки, если параметр field не указан. При ошибке отправляет 400 и возвращает ok = false.
+func parseFieldFilter(w http.ResponseWriter, r *http.Request, dbID int64) (*schedule.FieldFilter, bool) {
+	query := r.URL.Query()
+	name := strings.TrimSpace(query.Get("field"))
+	if name == "" {
+		return nil, true
+	}
+	optional := func(key string) *string {
+		if !query.Has(key) {
+			return nil
+		}
+		value := query.Get(key)
+		return &value
+	}
+
+	definition, err := data.GetDynamicFieldDefinitionByName(name, dbID)
+	if err != nil {
+		log.Printf("Ошибка при получении поля схемы '%s' БД %d: %v", name, dbID, err)
+		respondError(w, http.StatusInternalServerError, "Ошибка при получении схемы полей.")
+		return nil, false
+	}
+	if definition == nil {
+		// Поля нет в схеме - значение сравнивается как текст
+		definition = &models.DynamicFieldDefinition{DatabaseId: dbID, Name: name, Type: models.DynamicFieldText}
+	}
+	filter, err := schedule.NewFieldFilter(*definition, optional("value"), optional("min"), optional("max"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Неверный отбор по полю: "+err.Error())
+		return nil, false
+	}
+	return filter, true
+}
+
+// GetScheduleTagStatsHandler возвращает статистику по тегам расписания совместной БД.
+// GET /api/collaboration/databases/{db_id}/schedule/tags/stats
+func GetScheduleTagStatsHandler(w http.ResponseWriter, r *http.Request) {
+	_, dbID, _, ok := requireDatabaseMember(w, r)
+	if !ok {
+		return
+	}
+
+	stats, err := data.GetScheduleTagStats(dbID)
+	if err != nil {
+		log.Printf("Ошибка при получении статистики тегов расписания БД %d: %v", dbID, err)
+		respondError(w, http.StatusInternalServerError, "Ошибка при получении статистики тегов.")
+		return
+	}
+	respondJSON(w, http.StatusOK, stats)
+}
+
+// GetScheduleOccurrencesHandler разворачивает повторяющиеся записи расписания в конкретные повторения.
+// GET /api/collaboration/databases/{db_id}/schedule/occurrences?from=2025-03-01&to=2025-03-31&tz=Asia/Yekaterinburg
+// Обе границы включительно, диапазон не длиннее schedule.MaxRangeDays дней. Исключения (отмена/перенос повторений) учитываются.
+// Даты from/to - даты записей. У каждого повторения start/end, local_date и local_time - время в часовом поясе
+// пользователя (параметр tz или заголовок X-Time-Zone, по умолчанию - часовой пояс БД).
+func GetScheduleOccurrencesHandler(w http.ResponseWriter, r *http.Request) {
+	_, dbID, _, ok := requireDatabaseMember(w, r)
+	if !ok {
+		return
+	}
+
+	from, to, ok := parseDateRange(w, r)
+	if !ok {
+		return
+	}
+	dbLoc, ok := databaseLocation(w, dbID)
+	if !ok {
+		return
+	}
+	viewer, ok := viewerLocation(w, r, dbLoc)
+	if !ok {
+		return
+	}
+
+	entries, err := data.GetScheduleEntriesByDBID(dbID)
+	if err != nil {
+		log.Printf("Ошибка при получении записей расписания БД %d: %v", dbID, err)
+		respondError(w, http.StatusInternalServerError, "Ошибка при получении записей расписания.")
+		return
+	}
+
+	exceptions, err := data.GetScheduleExceptionsBySharedDBID(dbID)
+	if err != nil {
+		log.Printf("Ошибка при получении исключений расписания БД %d: %v", dbID, err)
+		respondError(w, http.StatusInternalServerError, "Ошибка при получении исключений расписания.")
+		return
+	}
+
+	occurrences := schedule.ExpandAll(entries, exceptions, from, to)
+	schedule.Localize(occurrences, dbLoc, viewer)
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"from":        from.Format(schedule.DateLayout),
+		"to":          to.Format(schedule.DateLayout),
+		"time_zone":   viewer.String(),
+		"occurrences": occurrences,
+	})
+}
+
+// GetScheduleConflictsHandler возвращает пересечения по времени записей расписания в диапазоне дат
+// (с учетом повторений и исключений).
+// GET /api/collaboration/databases/{db_id}/schedule/conflicts?from=2025-03-01&to=2025-03-31
+// truncated = true, если пересечений больше schedule.MaxConflicts и список обрезан.
+// Записи в разных часовых поясах сравниваются по абсолютному времени, даты пересечений - в часовом поясе БД.
+func GetScheduleConflictsHandler(w http.ResponseWriter, r *http.Request) {
+	_, dbID, _, ok := requireDatabaseMember(w, r)
+	if !ok {
+		return
+	}
+
+	from, to, ok := parseDateRange(w, r)
+	if !ok {
+		return
+	}
+	dbLoc, ok := databaseLocation(w, dbID)
+	if !ok {
+		return
+	}
+
+	entries, err := data.GetScheduleEntriesByDBID(dbID)
+	if err != nil {
+		log.Printf("Ошибка при получении записей расписания БД %d: %v", dbID, err)
+		respondError(w, http.StatusInternalServerError, "Ошибка при получении записей расписания.")
+		return
+	}
+
+	exceptions, err := data.GetScheduleExceptionsBySharedDBID(dbID)
+	if err != nil {
+		log.Printf("Ошибка при получении исключений расписания БД %d: %v", dbID, err)
+		respondError(w, http.StatusInternalServerError, "Ошибка при получении исключений расписания.")
+		return
+	}
+
+	conflicts, truncated := schedule.FindScheduleConflicts(entries, exceptions, dbLoc, from, to)
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"from":      from.Format(schedule.DateLayout),
+		"to":        to.Format(schedule.DateLayout),
+		"time_zone": dbLoc.String(),
+		"conflicts": conflicts,
+		"truncated": truncated,
+	})
+}
+
+// scheduleTimeZoneRequest - тело запроса на изменение часового пояса расписания совместной БД.
+type scheduleTimeZoneRequest struct {
+	TimeZone string `json:"time_zone"`
+}
+
+// GetScheduleTimeZoneHandler возвращает часовой пояс расписания совместной БД.
+// GET /api/collaboration/databases/{db_id}/schedule/time-zone
+// time_zone - сохраненное значение ("" - не задан), effective - часовой пояс, который фактически используется.
+func GetScheduleTimeZoneHandler(w http.ResponseWriter, r *http.Request) {
+	_, dbID, _, ok := requireDatabaseMember(w, r)
+	if !ok {
+		return
+	}
+
+	database, err := data.GetSharedDatabaseDetails(dbID)
+	if err != nil || database == nil {
+		log.Printf("Ошибка при получении совместной БД %d: %v", dbID, err)
+		respondError(w, http.StatusInternalServerError, "Ошибка при получении часового пояса.")
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]string{
+		"time_zone": database.TimeZone,
+		"effective": schedule.DatabaseLocation(database).String(),
+	})
+}
+
+// UpdateScheduleTimeZoneHandler устанавливает часовой пояс расписания совместной БД. Время записей
+// без собственного часового пояса отсчитывается в нем.
+// PUT /api/collaboration/databases/{db_id}/schedule/time-zone
+// Тело: {"time_zone": "Europe/Moscow"}; пустая строка возвращает часовой пояс сервера.
+func UpdateScheduleTimeZoneHandler(w http.ResponseWriter, r *http.Request) {
+	_, dbID, _, ok := requireDatabaseMember(w, r)
+	if !ok {
+		return
+	}
+
+	var req scheduleTimeZoneRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Неверный формат запроса: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+
+	timeZone := strings.TrimSpace(req.TimeZone)
+	if timeZone != "" {
+		loc, err := schedule.LoadTimeZone(timeZone)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Неверный часовой пояс: "+err.Error())
+			return
+		}
+		timeZone = loc.String()
+	}
+
+	if err := data.UpdateSharedDatabaseTimeZone(dbID, timeZone); err != nil {
+		if err == sql.ErrNoRows {
+			respondError(w, http.StatusNotFound, "Совместная база данных не найдена.")
+			return
+		}
+		log.Printf("Ошибка при изменении часового пояса БД %d: %v", dbID, err)
+		respondError(w, http.StatusInternalServerError, "Не удалось изменить часовой пояс.")
+		return
+	}
+	database := &models.SharedDatabase{TimeZone: timeZone}
+	respondJSON(w, http.StatusOK, map[string]string{
+		"time_zone": timeZone,
+		"effective": schedule.DatabaseLocation(database).String(),
+	})
+}
+
+// databaseLocation возвращает часовой пояс расписания совместной БД.
+// При ошибке отправляет 500 и возвращает ok = false.
+func databaseLocation(w http.ResponseWriter, dbID int64) (*time.Location, bool) {
+	database, err := data.GetSharedDatabaseDetails(dbID)
+	if err != nil || database == nil {
+		log.Printf("Ошибка при получении совместной БД %d: %v", dbID, err)
+		respondError(w, http.StatusInternalServerError, "Ошибка при получении часового пояса расписания.")
+		return nil, false
+	}
+	return schedule.DatabaseLocation(database), true
+}
+
+// viewerLocation возвращает часовой пояс пользователя из параметра tz или заголовка X-Time-Zone.
+// Если ни то ни другое не указано, возвращает fallback. При неверном значении отправляет 400.
+func viewerLocation(w http.ResponseWriter, r *http.Request, fallback *time.Location) (*time.Location, bool) {
+	name := strings.TrimSpace(r.URL.Query().Get("tz"))
+	if name == "" {
+		name = strings.TrimSpace(r.Header.Get("X-Time-Zone"))
+	}
+	if name == "" {
+		return fallback, true
+	}
+	loc, err := schedule.LoadTimeZone(name)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Неверный часовой пояс: "+err.Error())
+		return nil, false
+	}
+	return loc, true
+}
+
+// parseDateRange извлекает обязательные параметры from и to (yyyy-MM-dd) и проверяет диапазон.
+// При ошибке отправляет 400 и возвращает ok = false.
+func parseDateRange(w http.ResponseWriter, r *http.Request) (from time.Time, to time.Time, ok bool) {
+	query := r.URL.Query()
+	if query.Get("from") == "" || query.Get("to") == "" {
+		respondError(w, http.StatusBadRequest, "Параметры from и to обязательны.")
+		return from, to, false
+	}
+	from, err := schedule.ParseDate(query.Get("from"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Неверный параметр from: "+err.Error())
+		return from, to, false
+	}
+	to, err = schedule.ParseDate(query.Get("to"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Неверный параметр to: "+err.Error())
+		return from, to, false
+	}
+	if to.Before(from) {
+		respondError(w, http.StatusBadRequest, "Параметр to не может быть раньше from.")
+		return from, to, false
+	}
+	if to.Sub(from).Hours()/24 > schedule.MaxRangeDays {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Диапазон дат не может превышать %d дней.", schedule.MaxRangeDays))
+		return from, to, false
+	}
+	return from, to, true
+}
@@ -0,0 +1,249 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"notes_server_go/data"
+	"notes_server_go/models"
+	"notes_server_go/schedule"
+)
+
+// scheduleEntryRequest - тело запроса на создание/обновление записи расписания.
+// Формат полей совпадает с моделью ScheduleEntry клиента.
+type scheduleEntryRequest struct {
+	Time              string  `json:"time"`
+	Date              string  `json:"date"`
+	Note              *string `json:"note"`
+	DynamicFieldsJson *string `json:"dynamic_fields_json"`
+	RecurrenceJson    *string `json:"recurrence_json"`
+	TagsJson          *string `json:"tags_json"`
+	CategoryId        *int64  `json:"category_id"`
+	TimeZone          *string `json:"time_zone"`        // Имя IANA; не указан или "" - часовой пояс совместной БД
+	AssigneeUserId    *int64  `json:"assignee_user_id"` // Ответственный участник БД; не указан - запись без ответственного
+}
+
+func (req *scheduleEntryRequest) validate() string {
+	probe := models.ScheduleEntry{Date: req.Date, Time: req.Time, TimeZone: req.TimeZone, RecurrenceJson: req.RecurrenceJson}
+	if err := schedule.NormalizeEntry(&probe); err != nil {
+		return "Неверная запись расписания: " + err.Error()
+	}
+	req.Date, req.Time, req.TimeZone = probe.Date, probe.Time, probe.TimeZone
+	if _, err := probe.ParseRecurrence(); err != nil {
+		return "Неверное правило повторения: " + err.Error()
+	}
+	if req.CategoryId != nil && *req.CategoryId <= 0 {
+		req.CategoryId = nil
+	}
+	if req.AssigneeUserId != nil && *req.AssigneeUserId <= 0 {
+		req.AssigneeUserId = nil
+	}
+	return ""
+}
+
+// scheduleEntryResponse - запись расписания и ее пересечения с другими записями.
+// Пересечения - предупреждение: запись сохраняется в любом случае.
+type scheduleEntryResponse struct {
+	Entry              *models.ScheduleEntry `json:"entry"`
+	Conflicts          []schedule.Conflict   `json:"conflicts"`
+	ConflictsTruncated bool                  `json:"conflicts_truncated"`
+}
+
+// CreateScheduleEntryHandler создает запись расписания и возвращает ее пересечения с другими записями.
+// Динамические поля проверяются по схеме полей БД (см. GetDynamicFieldsHandler).
+// Автором записи становится текущий пользователь, ответственным может быть только участник БД.
+// POST /api/collaboration/databases/{db_id}/schedule/entries
+// Тело: {"time": "10:00 - 11:00", "date": "2025-05-01", "note": "...", "recurrence_json": "{\"type\":2}", "time_zone": "Europe/Moscow", "assignee_user_id": 2, ...}
+func CreateScheduleEntryHandler(w http.ResponseWriter, r *http.Request) {
+	userID, dbID, _, ok := requireDatabaseMember(w, r)
+	if !ok {
+		return
+	}
+
+	var req scheduleEntryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Неверный формат запроса: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+	if msg := req.validate(); msg != "" {
+		respondError(w, http.StatusBadRequest, msg)
+		return
+	}
+	if !checkScheduleEntryCategory(w, dbID, req.CategoryId) || !checkScheduleEntryAssignee(w, dbID, req.AssigneeUserId) {
+		return
+	}
+
+	entry := &models.ScheduleEntry{DatabaseId: dbID, CreatedByUserId: &userID}
+	req.applyTo(entry)
+	if !validateScheduleEntryFields(w, dbID, entry) {
+		return
+	}
+	id, err := data.CreateScheduleEntry(entry)
+	if err != nil {
+		log.Printf("Ошибка при создании записи расписания в БД %d: %v", dbID, err)
+		respondError(w, http.StatusInternalServerError, "Не удалось создать запись расписания.")
+		return
+	}
+	entry.Id = id
+	respondJSON(w, http.StatusCreated, scheduleEntryWithConflicts(entry))
+}
+
+// UpdateScheduleEntryHandler изменяет запись расписания и возвращает ее пересечения с другими записями.
+// PUT /api/collaboration/databases/{db_id}/schedule/entries/{entry_id}
+func UpdateScheduleEntryHandler(w http.ResponseWriter, r *http.Request) {
+	_, dbID, _, ok := requireDatabaseMember(w, r)
+	if !ok {
+		return
+	}
+	entryID, ok := parseIDVar(w, r, "entry_id")
+	if !ok {
+		return
+	}
+
+	var req scheduleEntryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Неверный формат запроса: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+	if msg := req.validate(); msg != "" {
+		respondError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	entry, err := data.GetScheduleEntryByID(entryID, dbID)
+	if err != nil {
+		log.Printf("Ошибка при получении записи расписания %d в БД %d: %v", entryID, dbID, err)
+		respondError(w, http.StatusInternalServerError, "Ошибка при получении записи расписания.")
+		return
+	}
+	if entry == nil {
+		respondError(w, http.StatusNotFound, "Запись расписания не найдена.")
+		return
+	}
+	if !checkScheduleEntryCategory(w, dbID, req.CategoryId) || !checkScheduleEntryAssignee(w, dbID, req.AssigneeUserId) {
+		return
+	}
+
+	req.applyTo(entry)
+	if !validateScheduleEntryFields(w, dbID, entry) {
+		return
+	}
+	if err := data.UpdateScheduleEntry(entry); err != nil {
+		if err == sql.ErrNoRows {
+			respondError(w, http.StatusNotFound, "Запись расписания не найдена.")
+			return
+		}
+		log.Printf("Ошибка при обновлении записи расписания %d в БД %d: %v", entryID, dbID, err)
+		respondError(w, http.StatusInternalServerError, "Не удалось обновить запись расписания.")
+		return
+	}
+	respondJSON(w, http.StatusOK, scheduleEntryWithConflicts(entry))
+}
+
+// DeleteScheduleEntryHandler удаляет запись расписания вместе с ее исключениями.
+// DELETE /api/collaboration/databases/{db_id}/schedule/entries/{entry_id}
+func DeleteScheduleEntryHandler(w http.ResponseWriter, r *http.Request) {
+	userID, dbID, _, ok := requireDatabaseMember(w, r)
+	if !ok {
+		return
+	}
+	entryID, ok := parseIDVar(w, r, "entry_id")
+	if !ok {
+		return
+	}
+
+	if err := data.DeleteScheduleEntry(entryID, dbID, userID); err != nil {
+		if err == sql.ErrNoRows {
+			respondError(w, http.StatusNotFound, "Запись расписания не найдена.")
+			return
+		}
+		log.Printf("Ошибка при удалении записи расписания %d в БД %d: %v", entryID, dbID, err)
+		respondError(w, http.StatusInternalServerError, "Не удалось удалить запись расписания.")
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Запись расписания удалена."})
+}
+
+// applyTo переносит поля запроса в запись расписания.
+func (req *scheduleEntryRequest) applyTo(entry *models.ScheduleEntry) {
+	entry.Time = req.Time
+	entry.Date = req.Date
+	entry.Note = req.Note
+	entry.DynamicFieldsJson = req.DynamicFieldsJson
+	entry.RecurrenceJson = req.RecurrenceJson
+	entry.TagsJson = req.TagsJson
+	entry.CategoryId = req.CategoryId
+	entry.TimeZone = req.TimeZone
+	entry.AssigneeUserId = req.AssigneeUserId
+}
+
+// checkScheduleEntryCategory проверяет, что категория записи существует в совместной БД.
+// При ошибке отправляет ответ и возвращает false.
+func checkScheduleEntryCategory(w http.ResponseWriter, dbID int64, categoryID *int64) bool {
+	if categoryID == nil {
+		return true
+	}
+	category, err := data.GetCategoryByID(*categoryID, dbID)
+	if err != nil {
+		log.Printf("Ошибка при получении категории %d в БД %d: %v", *categoryID, dbID, err)
+		respondError(w, http.StatusInternalServerError, "Ошибка при получении категории.")
+		return false
+	}
+	if category == nil {
+		respondError(w, http.StatusBadRequest, "Категория не найдена.")
+		return false
+	}
+	return true
+}
+
+// checkScheduleEntryAssignee проверяет, что ответственный за запись - участник совместной БД.
+// При ошибке отправляет ответ и возвращает false.
+func checkScheduleEntryAssignee(w http.ResponseWriter, dbID int64, assigneeID *int64) bool {
+	if assigneeID == nil {
+		return true
+	}
+	role, err := data.GetUserRoleInSharedDatabase(dbID, *assigneeID)
+	if err != nil {
+		log.Printf("Ошибка при проверке роли пользователя %d в БД %d: %v", *assigneeID, dbID, err)
+		respondError(w, http.StatusInternalServerError, "Ошибка при проверке ответственного.")
+		return false
+	}
+	if role == nil {
+		respondError(w, http.StatusBadRequest, "Ответственный не является участником базы данных.")
+		return false
+	}
+	return true
+}
+
+// scheduleEntryWithConflicts формирует ответ с пересечениями сохраненной записи в окне schedule.ConflictWindow.
+// Ошибка поиска пересечений не мешает ответу - запись уже сохранена.
+func scheduleEntryWithConflicts(entry *models.ScheduleEntry) scheduleEntryResponse {
+	response := scheduleEntryResponse{Entry: entry, Conflicts: []schedule.Conflict{}}
+	database, err := data.GetSharedDatabaseDetails(entry.DatabaseId)
+	if err != nil {
+		log.Printf("Ошибка при получении совместной БД %d для проверки пересечений: %v", entry.DatabaseId, err)
+		return response
+	}
+	dbLoc := schedule.DatabaseLocation(database)
+	from, to, err := schedule.ConflictWindow(entry, time.Now().In(dbLoc))
+	if err != nil {
+		return response
+	}
+	entries, err := data.GetScheduleEntriesByDBID(entry.DatabaseId)
+	if err != nil {
+		log.Printf("Ошибка при получении записей расписания БД %d для проверки пересечений: %v", entry.DatabaseId, err)
+		return response
+	}
+	exceptions, err := data.GetScheduleExceptionsBySharedDBID(entry.DatabaseId)
+	if err != nil {
+		log.Printf("Ошибка при получении исключений расписания БД %d для проверки пересечений: %v", entry.DatabaseId, err)
+		return response
+	}
+	response.Conflicts, response.ConflictsTruncated = schedule.FindEntryConflicts(entries, exceptions, entry.Id, dbLoc, from, to)
+	return response
+}
@@ -0,0 +1,200 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"notes_server_go/data"
+	"notes_server_go/models"
+	"notes_server_go/schedule"
+)
+
+// scheduleExceptionRequest - тело запроса на создание/обновление исключения повторения.
+type scheduleExceptionRequest struct {
+	OriginalDate string  `json:"original_date"`
+	Type         string  `json:"type"`
+	NewDate      *string `json:"new_date"`
+	NewTime      *string `json:"new_time"`
+	NewNote      *string `json:"new_note"`
+}
+
+// GetScheduleExceptionsHandler возвращает исключения повторяющейся записи расписания.
+// GET /api/collaboration/databases/{db_id}/schedule/entries/{entry_id}/exceptions
+func GetScheduleExceptionsHandler(w http.ResponseWriter, r *http.Request) {
+	_, dbID, _, ok := requireDatabaseMember(w, r)
+	if !ok {
+		return
+	}
+	entryID, ok := parseIDVar(w, r, "entry_id")
+	if !ok {
+		return
+	}
+
+	entry, err := data.GetScheduleEntryByID(entryID, dbID)
+	if err != nil {
+		log.Printf("Ошибка при получении записи расписания %d в БД %d: %v", entryID, dbID, err)
+		respondError(w, http.StatusInternalServerError, "Ошибка при получении записи расписания.")
+		return
+	}
+	if entry == nil {
+		respondError(w, http.StatusNotFound, "Запись расписания не найдена.")
+		return
+	}
+
+	exceptions, err := data.GetScheduleExceptionsByEntryID(entryID, dbID)
+	if err != nil {
+		log.Printf("Ошибка при получении исключений записи %d в БД %d: %v", entryID, dbID, err)
+		respondError(w, http.StatusInternalServerError, "Ошибка при получении исключений.")
+		return
+	}
+	respondJSON(w, http.StatusOK, exceptions)
+}
+
+// CreateScheduleExceptionHandler отменяет или изменяет одно повторение записи расписания.
+// POST /api/collaboration/databases/{db_id}/schedule/entries/{entry_id}/exceptions
+// Тело: {"original_date": "2025-05-01", "type": "skip"} или
+// {"original_date": "2025-05-01", "type": "modify", "new_date": "2025-05-02", "new_time": "10:00 - 11:00", "new_note": "..."}
+func CreateScheduleExceptionHandler(w http.ResponseWriter, r *http.Request) {
+	_, dbID, _, ok := requireDatabaseMember(w, r)
+	if !ok {
+		return
+	}
+	entryID, ok := parseIDVar(w, r, "entry_id")
+	if !ok {
+		return
+	}
+
+	var req scheduleExceptionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Неверный формат запроса: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+
+	exception := &models.ScheduleEntryException{
+		ScheduleEntryId: entryID,
+		DatabaseId:      dbID,
+		OriginalDate:    req.OriginalDate,
+		Type:            req.Type,
+		NewDate:         req.NewDate,
+		NewTime:         req.NewTime,
+		NewNote:         req.NewNote,
+	}
+	if err := schedule.ValidateException(exception); err != nil {
+		respondError(w, http.StatusBadRequest, "Неверное исключение: "+err.Error())
+		return
+	}
+
+	entry, err := data.GetScheduleEntryByID(entryID, dbID)
+	if err != nil {
+		log.Printf("Ошибка при получении записи расписания %d в БД %d: %v", entryID, dbID, err)
+		respondError(w, http.StatusInternalServerError, "Ошибка при получении записи расписания.")
+		return
+	}
+	if entry == nil {
+		respondError(w, http.StatusNotFound, "Запись расписания не найдена.")
+		return
+	}
+	originalDate, _ := schedule.ParseDate(exception.OriginalDate)
+	if !schedule.IsOccurrenceDate(entry, originalDate) {
+		respondError(w, http.StatusBadRequest, "На дату "+exception.OriginalDate+" нет повторения этой записи.")
+		return
+	}
+
+	existing, err := data.GetScheduleExceptionByOriginalDate(entryID, exception.OriginalDate)
+	if err != nil {
+		log.Printf("Ошибка при проверке исключения записи %d на %s: %v", entryID, exception.OriginalDate, err)
+		respondError(w, http.StatusInternalServerError, "Ошибка при создании исключения.")
+		return
+	}
+	if existing != nil {
+		respondError(w, http.StatusConflict, "Для этого повторения уже есть исключение. Измените его.")
+		return
+	}
+
+	id, err := data.CreateScheduleException(exception)
+	if err != nil {
+		log.Printf("Ошибка при создании исключения записи %d в БД %d: %v", entryID, dbID, err)
+		respondError(w, http.StatusInternalServerError, "Не удалось создать исключение.")
+		return
+	}
+	exception.Id = id
+	respondJSON(w, http.StatusCreated, exception)
+}
+
+// UpdateScheduleExceptionHandler изменяет тип и новые значения исключения. Исходная дата не меняется.
+// PUT /api/collaboration/databases/{db_id}/schedule/exceptions/{exception_id}
+func UpdateScheduleExceptionHandler(w http.ResponseWriter, r *http.Request) {
+	_, dbID, _, ok := requireDatabaseMember(w, r)
+	if !ok {
+		return
+	}
+	exceptionID, ok := parseIDVar(w, r, "exception_id")
+	if !ok {
+		return
+	}
+
+	var req scheduleExceptionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Неверный формат запроса: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+
+	exception, err := data.GetScheduleExceptionByID(exceptionID, dbID)
+	if err != nil {
+		log.Printf("Ошибка при получении исключения %d в БД %d: %v", exceptionID, dbID, err)
+		respondError(w, http.StatusInternalServerError, "Ошибка при получении исключения.")
+		return
+	}
+	if exception == nil {
+		respondError(w, http.StatusNotFound, "Исключение не найдено.")
+		return
+	}
+
+	exception.Type = req.Type
+	exception.NewDate = req.NewDate
+	exception.NewTime = req.NewTime
+	exception.NewNote = req.NewNote
+	if err := schedule.ValidateException(exception); err != nil {
+		respondError(w, http.StatusBadRequest, "Неверное исключение: "+err.Error())
+		return
+	}
+
+	if err := data.UpdateScheduleException(exception); err != nil {
+		if err == sql.ErrNoRows {
+			respondError(w, http.StatusNotFound, "Исключение не найдено.")
+			return
+		}
+		log.Printf("Ошибка при обновлении исключения %d в БД %d: %v", exceptionID, dbID, err)
+		respondError(w, http.StatusInternalServerError, "Не удалось обновить исключение.")
+		return
+	}
+	respondJSON(w, http.StatusOK, exception)
+}
+
+// DeleteScheduleExceptionHandler удаляет исключение - повторение снова следует правилу.
+// DELETE /api/collaboration/databases/{db_id}/schedule/exceptions/{exception_id}
+func DeleteScheduleExceptionHandler(w http.ResponseWriter, r *http.Request) {
+	_, dbID, _, ok := requireDatabaseMember(w, r)
+	if !ok {
+		return
+	}
+	exceptionID, ok := parseIDVar(w, r, "exception_id")
+	if !ok {
+		return
+	}
+
+	if err := data.DeleteScheduleException(exceptionID, dbID); err != nil {
+		if err == sql.ErrNoRows {
+			respondError(w, http.StatusNotFound, "Исключение не найдено.")
+			return
+		}
+		log.Printf("Ошибка при удалении исключения %d в БД %d: %v", exceptionID, dbID, err)
+		respondError(w, http.StatusInternalServerError, "Не удалось удалить исключение.")
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Исключение удалено."})
+}
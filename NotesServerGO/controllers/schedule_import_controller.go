@@ -0,0 +1,169 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"notes_server_go/data"
+	"notes_server_go/ical"
+	"notes_server_go/schedule"
+)
+
+// ImportScheduleICSHandler импортирует события календаря iCalendar (.ics) в записи расписания совместной БД.
+// POST /api/collaboration/databases/{db_id}/schedule/import-ics?tz=Europe/Moscow&dry_run=true
+// Файл передается полем "file" multipart-формы или телом запроса (text/calendar).
+// tz - часовой пояс, в который переводится время событий (по умолчанию - часовой пояс БД);
+// если он отличается от часового пояса БД, он сохраняется в импортированных записях.
+// dry_run=true возвращает результат преобразования без сохранения.
+// В ответе skipped - события, которые невозможно представить записями расписания или которые
+// не проходят проверку по схеме динамических полей БД, с причиной.
+func ImportScheduleICSHandler(w http.ResponseWriter, r *http.Request) {
+	userID, dbID, _, ok := requireDatabaseMember(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	dbLoc, ok := databaseLocation(w, dbID)
+	if !ok {
+		return
+	}
+	loc := dbLoc
+	if tz := strings.TrimSpace(query.Get("tz")); tz != "" {
+		var err error
+		if loc, err = schedule.LoadTimeZone(tz); err != nil {
+			respondError(w, http.StatusBadRequest, "Неверный часовой пояс: "+err.Error())
+			return
+		}
+	}
+	dryRun := false
+	if value := query.Get("dry_run"); value != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(value); err != nil {
+			respondError(w, http.StatusBadRequest, "Неверный параметр dry_run.")
+			return
+		}
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+	defer r.Body.Close()
+	var body io.Reader = r.Body
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+		if err := r.ParseMultipartForm(maxUploadSize); err != nil {
+			respondICSReadError(w, err)
+			return
+		}
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "Не удалось получить файл из запроса: "+err.Error())
+			return
+		}
+		defer file.Close()
+		body = file
+	}
+
+	components, err := ical.Parse(body)
+	if err != nil {
+		respondICSReadError(w, err)
+		return
+	}
+	result, err := ical.ToScheduleEntries(components, loc)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if loc.String() != dbLoc.String() {
+		// Время событий переведено в часовой пояс tz - он сохраняется в записях
+		timeZone := loc.String()
+		for i := range result.Entries {
+			result.Entries[i].Entry.TimeZone = &timeZone
+		}
+	}
+
+	// События, динамические поля которых не проходят проверку по схеме полей БД, пропускаются
+	definitions, err := data.GetDynamicFieldDefinitionsBySharedDBID(dbID)
+	if err != nil {
+		log.Printf("Ошибка при получении схемы полей БД %d: %v", dbID, err)
+		respondError(w, http.StatusInternalServerError, "Не удалось импортировать календарь.")
+		return
+	}
+	valid := result.Entries[:0]
+	for _, imported := range result.Entries {
+		fieldsJson, err := schedule.ValidateDynamicFields(imported.Entry.DynamicFieldsJson, definitions)
+		if err != nil {
+			skipped := ical.SkippedEvent{UID: imported.UID, Reason: "динамические поля не соответствуют схеме: " + err.Error()}
+			if imported.Entry.Note != nil {
+				skipped.Summary = *imported.Entry.Note
+			}
+			result.Skipped = append(result.Skipped, skipped)
+			continue
+		}
+		imported.Entry.DynamicFieldsJson = fieldsJson
+		valid = append(valid, imported)
+	}
+	result.Entries = valid
+
+	if !dryRun && len(result.Entries) > 0 {
+		tx, err := data.MainDB.Beginx()
+		if err != nil {
+			log.Printf("Ошибка начала транзакции импорта расписания в БД %d: %v", dbID, err)
+			respondError(w, http.StatusInternalServerError, "Не удалось импортировать календарь.")
+			return
+		}
+		defer tx.Rollback()
+
+		for i := range result.Entries {
+			imported := &result.Entries[i]
+			imported.Entry.DatabaseId = dbID
+			imported.Entry.CreatedByUserId = &userID
+			entryID, err := data.CreateScheduleEntryWithTx(tx, &imported.Entry)
+			if err != nil {
+				log.Printf("Ошибка импорта события %s в БД %d: %v", imported.UID, dbID, err)
+				respondError(w, http.StatusInternalServerError, "Не удалось импортировать календарь.")
+				return
+			}
+			imported.Entry.Id = entryID
+			for j := range imported.Exceptions {
+				exception := &imported.Exceptions[j]
+				exception.ScheduleEntryId, exception.DatabaseId = entryID, dbID
+				if exception.Id, err = data.CreateScheduleExceptionWithTx(tx, exception); err != nil {
+					log.Printf("Ошибка импорта исключения события %s в БД %d: %v", imported.UID, dbID, err)
+					respondError(w, http.StatusInternalServerError, "Не удалось импортировать календарь.")
+					return
+				}
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			log.Printf("Ошибка коммита импорта расписания в БД %d: %v", dbID, err)
+			respondError(w, http.StatusInternalServerError, "Не удалось импортировать календарь.")
+			return
+		}
+		log.Printf("Импортировано %d записей расписания из календаря в БД %d, пропущено событий: %d", len(result.Entries), dbID, len(result.Skipped))
+	}
+
+	status := http.StatusCreated
+	if dryRun {
+		status = http.StatusOK
+	}
+	respondJSON(w, status, map[string]interface{}{
+		"dry_run":  dryRun,
+		"imported": len(result.Entries),
+		"entries":  result.Entries,
+		"skipped":  result.Skipped,
+	})
+}
+
+// respondICSReadError отправляет ошибку чтения или разбора файла календаря.
+func respondICSReadError(w http.ResponseWriter, err error) {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		respondError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Размер файла не должен превышать %dMB.", maxUploadSize/1024/1024))
+		return
+	}
+	respondError(w, http.StatusBadRequest, "Не удалось разобрать файл календаря: "+err.Error())
+}
@@ -0,0 +1,71 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"notes_server_go/data"
+	"notes_server_go/middleware"
+	"notes_server_go/models"
+)
+
+// defaultSearchLimit - количество результатов поиска по умолчанию.
+const defaultSearchLimit = 50
+
+// maxSearchLimit - максимальное количество результатов, которое можно запросить.
+const maxSearchLimit = 200
+
+// SearchHandler выполняет поиск заметок по всем совместным БД пользователя.
+// GET /api/search?q=текст&limit=50
+// Запрос поддерживает фильтры tag:, folder:, updated:, created: (см. data.ParseSearchQuery).
+func SearchHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int64)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Не удалось получить ID пользователя из токена.")
+		return
+	}
+
+	rawQuery := strings.TrimSpace(r.URL.Query().Get("q"))
+	if rawQuery == "" {
+		respondError(w, http.StatusBadRequest, "Параметр q не может быть пустым.")
+		return
+	}
+
+	limit := defaultSearchLimit
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		parsed, err := strconv.Atoi(limitStr)
+		if err != nil || parsed <= 0 {
+			respondError(w, http.StatusBadRequest, "Неверный параметр limit.")
+			return
+		}
+		limit = parsed
+	}
+	if limit > maxSearchLimit {
+		limit = maxSearchLimit
+	}
+
+	query, err := data.ParseSearchQuery(rawQuery)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Неверный поисковый запрос: "+err.Error())
+		return
+	}
+	if query.IsEmpty() {
+		respondError(w, http.StatusBadRequest, "Поисковый запрос не содержит условий.")
+		return
+	}
+
+	results, total, err := data.SearchNotesForUser(userID, query, limit)
+	if err != nil {
+		log.Printf("SearchHandler: ошибка поиска для пользователя %d: %v", userID, err)
+		respondError(w, http.StatusInternalServerError, "Ошибка при выполнении поиска.")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, models.SearchResponse{
+		Query:   rawQuery,
+		Total:   total,
+		Results: results,
+	})
+}
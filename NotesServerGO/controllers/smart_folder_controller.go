@@ -0,0 +1,228 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"notes_server_go/data"
+	"notes_server_go/models"
+)
+
+// smartFolderRequest - тело запроса на создание/обновление умной папки.
+type smartFolderRequest struct {
+	Name  string `json:"name"`
+	Query string `json:"query"`
+	Color int    `json:"color"`
+}
+
+// validate проверяет название и запрос умной папки.
+func (req *smartFolderRequest) validate() string {
+	req.Name = strings.TrimSpace(req.Name)
+	req.Query = strings.TrimSpace(req.Query)
+	if req.Name == "" {
+		return "Название умной папки не может быть пустым."
+	}
+	query, err := data.ParseSearchQuery(req.Query)
+	if err != nil {
+		return "Неверный запрос умной папки: " + err.Error()
+	}
+	if query.IsEmpty() {
+		return "Запрос умной папки не может быть пустым."
+	}
+	return ""
+}
+
+// GetFoldersWithSmartFoldersHandler возвращает обычные и умные папки совместной БД одним списком.
+// GET /api/collaboration/databases/{db_id}/folders
+func GetFoldersWithSmartFoldersHandler(w http.ResponseWriter, r *http.Request) {
+	_, dbID, _, ok := requireDatabaseMember(w, r)
+	if !ok {
+		return
+	}
+
+	folders, err := data.GetAllFoldersBySharedDBID(dbID)
+	if err != nil {
+		log.Printf("Ошибка при получении папок БД %d: %v", dbID, err)
+		respondError(w, http.StatusInternalServerError, "Ошибка при получении папок.")
+		return
+	}
+	if folders == nil {
+		folders = []models.Folder{}
+	}
+	smartFolders, err := data.GetAllSmartFoldersBySharedDBID(dbID)
+	if err != nil {
+		log.Printf("Ошибка при получении умных папок БД %d: %v", dbID, err)
+		respondError(w, http.StatusInternalServerError, "Ошибка при получении умных папок.")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"folders":       folders,
+		"smart_folders": smartFolders,
+	})
+}
+
+// GetSmartFoldersHandler возвращает список умных папок совместной БД.
+// GET /api/collaboration/databases/{db_id}/smart-folders
+func GetSmartFoldersHandler(w http.ResponseWriter, r *http.Request) {
+	_, dbID, _, ok := requireDatabaseMember(w, r)
+	if !ok {
+		return
+	}
+
+	smartFolders, err := data.GetAllSmartFoldersBySharedDBID(dbID)
+	if err != nil {
+		log.Printf("Ошибка при получении умных папок БД %d: %v", dbID, err)
+		respondError(w, http.StatusInternalServerError, "Ошибка при получении умных папок.")
+		return
+	}
+	respondJSON(w, http.StatusOK, smartFolders)
+}
+
+// CreateSmartFolderHandler создает умную папку в совместной БД.
+// POST /api/collaboration/databases/{db_id}/smart-folders
+func CreateSmartFolderHandler(w http.ResponseWriter, r *http.Request) {
+	userID, dbID, _, ok := requireDatabaseMember(w, r)
+	if !ok {
+		return
+	}
+
+	var req smartFolderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Неверный формат запроса: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+	if msg := req.validate(); msg != "" {
+		respondError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	smartFolder := &models.SmartFolder{
+		DatabaseId:      dbID,
+		Name:            req.Name,
+		Query:           req.Query,
+		Color:           req.Color,
+		CreatedByUserId: userID,
+	}
+	id, err := data.CreateSmartFolder(smartFolder)
+	if err != nil {
+		log.Printf("Ошибка при создании умной папки в БД %d: %v", dbID, err)
+		respondError(w, http.StatusInternalServerError, "Не удалось создать умную папку.")
+		return
+	}
+	smartFolder.Id = id
+	respondJSON(w, http.StatusCreated, smartFolder)
+}
+
+// UpdateSmartFolderHandler обновляет название, запрос и цвет умной папки.
+// PUT /api/collaboration/databases/{db_id}/smart-folders/{smart_folder_id}
+func UpdateSmartFolderHandler(w http.ResponseWriter, r *http.Request) {
+	_, dbID, _, ok := requireDatabaseMember(w, r)
+	if !ok {
+		return
+	}
+	smartFolderID, ok := parseIDVar(w, r, "smart_folder_id")
+	if !ok {
+		return
+	}
+
+	var req smartFolderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Неверный формат запроса: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+	if msg := req.validate(); msg != "" {
+		respondError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	smartFolder, err := data.GetSmartFolderByID(smartFolderID, dbID)
+	if err != nil {
+		log.Printf("Ошибка при получении умной папки %d в БД %d: %v", smartFolderID, dbID, err)
+		respondError(w, http.StatusInternalServerError, "Ошибка при получении умной папки.")
+		return
+	}
+	if smartFolder == nil {
+		respondError(w, http.StatusNotFound, "Умная папка не найдена.")
+		return
+	}
+
+	smartFolder.Name = req.Name
+	smartFolder.Query = req.Query
+	smartFolder.Color = req.Color
+	if err := data.UpdateSmartFolder(smartFolder); err != nil {
+		if err == sql.ErrNoRows {
+			respondError(w, http.StatusNotFound, "Умная папка не найдена.")
+			return
+		}
+		log.Printf("Ошибка при обновлении умной папки %d в БД %d: %v", smartFolderID, dbID, err)
+		respondError(w, http.StatusInternalServerError, "Не удалось обновить умную папку.")
+		return
+	}
+	respondJSON(w, http.StatusOK, smartFolder)
+}
+
+// DeleteSmartFolderHandler удаляет умную папку.
+// DELETE /api/collaboration/databases/{db_id}/smart-folders/{smart_folder_id}
+func DeleteSmartFolderHandler(w http.ResponseWriter, r *http.Request) {
+	_, dbID, _, ok := requireDatabaseMember(w, r)
+	if !ok {
+		return
+	}
+	smartFolderID, ok := parseIDVar(w, r, "smart_folder_id")
+	if !ok {
+		return
+	}
+
+	if err := data.DeleteSmartFolder(smartFolderID, dbID); err != nil {
+		if err == sql.ErrNoRows {
+			respondError(w, http.StatusNotFound, "Умная папка не найдена.")
+			return
+		}
+		log.Printf("Ошибка при удалении умной папки %d в БД %d: %v", smartFolderID, dbID, err)
+		respondError(w, http.StatusInternalServerError, "Не удалось удалить умную папку.")
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Умная папка удалена."})
+}
+
+// GetSmartFolderNotesHandler вычисляет содержимое умной папки и возвращает подходящие заметки.
+// GET /api/collaboration/databases/{db_id}/smart-folders/{smart_folder_id}/notes
+func GetSmartFolderNotesHandler(w http.ResponseWriter, r *http.Request) {
+	_, dbID, _, ok := requireDatabaseMember(w, r)
+	if !ok {
+		return
+	}
+	smartFolderID, ok := parseIDVar(w, r, "smart_folder_id")
+	if !ok {
+		return
+	}
+
+	smartFolder, err := data.GetSmartFolderByID(smartFolderID, dbID)
+	if err != nil {
+		log.Printf("Ошибка при получении умной папки %d в БД %d: %v", smartFolderID, dbID, err)
+		respondError(w, http.StatusInternalServerError, "Ошибка при получении умной папки.")
+		return
+	}
+	if smartFolder == nil {
+		respondError(w, http.StatusNotFound, "Умная папка не найдена.")
+		return
+	}
+
+	notes, err := data.EvaluateSmartFolder(smartFolder)
+	if err != nil {
+		log.Printf("Ошибка при вычислении умной папки %d в БД %d: %v", smartFolderID, dbID, err)
+		respondError(w, http.StatusInternalServerError, "Не удалось вычислить содержимое умной папки.")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"smart_folder": smartFolder,
+		"notes":        notes,
+	})
+}
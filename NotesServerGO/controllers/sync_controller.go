@@ -17,8 +17,10 @@ import (
 	"notes_server_go/data"
 	"notes_server_go/middleware"
 	"notes_server_go/models"
+	"notes_server_go/schedule"
 
 	"github.com/gorilla/mux"
+	"github.com/jmoiron/sqlx"
 )
 
 // SyncDataRequest определяет структуру для данных синхронизации.
@@ -27,26 +29,47 @@ import (
 // запись обновляется. Если ID не существует или равен 0 (или null), запись создается.
 // Сервер должен генерировать свои ID для новых записей и возвращать их клиенту (пока не реализовано в ответе).
 type SyncDataRequest struct {
-	Notes           []models.Note          `json:"notes"`
-	Folders         []models.Folder        `json:"folders"`
-	ScheduleEntries []models.ScheduleEntry `json:"schedule_entries"`
-	PinboardNotes   []models.PinboardNote  `json:"pinboard_notes"`
-	Connections     []models.Connection    `json:"connections"`
-	NoteImages      []models.NoteImage     `json:"note_images"`
+	Notes              []models.Note                   `json:"notes"`
+	Folders            []models.Folder                 `json:"folders"`
+	ScheduleEntries    []models.ScheduleEntry          `json:"schedule_entries"`
+	PinboardNotes      []models.PinboardNote           `json:"pinboard_notes"`
+	Connections        []models.Connection             `json:"connections"`
+	NoteImages         []models.NoteImage              `json:"note_images"`
+	NoteTags           []models.NoteTag                `json:"note_tags"`           // nil (поле отсутствует) - теги на сервере не трогаем
+	Categories         []models.Category               `json:"categories"`          // nil (поле отсутствует) - категории и их назначения не трогаем
+	ScheduleExceptions []models.ScheduleEntryException `json:"schedule_exceptions"` // nil (поле отсутствует) - исключения не трогаем
+	Pinboards          []models.Pinboard               `json:"pinboards"`           // nil (поле отсутствует) - доски не трогаем, карточки без pinboard_id остаются на своих досках
 }
 
 // SyncDataResponse определяет структуру ответа для синхронизации, аналогичную BackupData на клиенте.
 type SyncDataResponse struct {
-	Folders         []models.Folder        `json:"folders"`
-	Notes           []models.Note          `json:"notes"`
-	ScheduleEntries []models.ScheduleEntry `json:"schedule_entries"`
-	PinboardNotes   []models.PinboardNote  `json:"pinboard_notes"`
-	Connections     []models.Connection    `json:"connections"`
-	Images          []models.NoteImage     `json:"images"` // Клиент ожидает "images"
-	LastModified    string                 `json:"lastModified"`
-	CreatedAt       string                 `json:"createdAt"`  // Обычно это дата создания самой SharedDatabase
-	DatabaseId      string                 `json:"databaseId"` // ID совместной БД как строка
-	UserId          string                 `json:"userId"`     // ID владельца БД как строка
+	Folders                    []models.Folder                 `json:"folders"`
+	Notes                      []models.Note                   `json:"notes"`
+	ScheduleEntries            []models.ScheduleEntry          `json:"schedule_entries"`
+	PinboardNotes              []models.PinboardNote           `json:"pinboard_notes"`
+	Connections                []models.Connection             `json:"connections"`
+	Images                     []models.NoteImage              `json:"images"`        // Клиент ожидает "images"
+	SmartFolders               []models.SmartFolder            `json:"smart_folders"` // Только для чтения, управляются через REST
+	NoteTags                   []models.NoteTag                `json:"note_tags"`
+	Categories                 []models.Category               `json:"categories"`
+	ScheduleExceptions         []models.ScheduleEntryException `json:"schedule_exceptions"`
+	Pinboards                  []models.Pinboard               `json:"pinboards"`
+	ScheduleConflicts          []schedule.Conflict             `json:"schedule_conflicts"`           // Пересечения записей на ближайшие schedule.ConflictCheckDays дней (предупреждение)
+	ScheduleConflictsTruncated bool                            `json:"schedule_conflicts_truncated"` // true - пересечений больше, чем вернулось в schedule_conflicts
+	ScheduleWarnings           []scheduleEntryWarning          `json:"schedule_warnings"`            // Записи, сохраненные с прежними значениями части полей
+	DynamicFields              []models.DynamicFieldDefinition `json:"dynamic_fields"`               // Схема динамических полей; только для чтения, управляется через REST
+	LastModified               string                          `json:"lastModified"`
+	CreatedAt                  string                          `json:"createdAt"`  // Обычно это дата создания самой SharedDatabase
+	DatabaseId                 string                          `json:"databaseId"` // ID совместной БД как строка
+	UserId                     string                          `json:"userId"`     // ID владельца БД как строка
+}
+
+// scheduleEntryWarning - предупреждение о записи расписания: запись сохранена, но часть присланных значений
+// не прошла проверку и осталась прежней (или была сброшена).
+type scheduleEntryWarning struct {
+	EntryId       int64  `json:"entry_id"`        // Серверный ID записи
+	ClientEntryId int64  `json:"client_entry_id"` // ID записи, присланный клиентом
+	Message       string `json:"message"`
 }
 
 // SyncSharedDatabaseHandler обрабатывает синхронизацию данных для указанной совместной БД.
@@ -95,6 +118,10 @@ func SyncSharedDatabaseHandler(w http.ResponseWriter, r *http.Request) {
 	log.Printf("  - PinboardNotes: %d", len(syncData.PinboardNotes))
 	log.Printf("  - Connections: %d", len(syncData.Connections))
 	log.Printf("  - NoteImages: %d", len(syncData.NoteImages))
+	log.Printf("  - NoteTags: %d", len(syncData.NoteTags))
+	log.Printf("  - Categories: %d", len(syncData.Categories))
+	log.Printf("  - ScheduleExceptions: %d", len(syncData.ScheduleExceptions))
+	log.Printf("  - Pinboards: %d", len(syncData.Pinboards))
 
 	// Выводим первую заметку для отладки, если есть
 	if len(syncData.Notes) > 0 {
@@ -119,6 +146,75 @@ func SyncSharedDatabaseHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}()
 
+	// Обработка Categories: обрабатываются первыми, т.к. на них ссылаются заметки и записи расписания.
+	// Старые клиенты не присылают поле categories - в этом случае категории и их назначения не изменяются.
+	clientToServerCategoryMap := make(map[int64]int64)
+	if syncData.Categories != nil {
+		existingCategoryIDs, categoryErr := data.GetAllCategoryIDsForSharedDBWithTx(tx, sharedDbID)
+		if categoryErr != nil {
+			err = fmt.Errorf("ошибка при получении ID существующих Categories для БД %d: %w", sharedDbID, categoryErr)
+			log.Printf("Sync Error (DB %d, User %d): %v", sharedDbID, currentUserID, err)
+			respondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		processedCategoryIDs := make(map[int64]bool)
+
+		for _, clientCategory := range syncData.Categories {
+			clientCategory.DatabaseId = sharedDbID
+			var serverCategoryID int64
+
+			existingCategory, getErr := data.GetCategoryByIDWithTx(tx, clientCategory.Id, sharedDbID)
+			if getErr != nil {
+				err = fmt.Errorf("ошибка при поиске Category (ID %d, DB %d): %w", clientCategory.Id, sharedDbID, getErr)
+				log.Printf("Sync Error (DB %d, User %d): %v", sharedDbID, currentUserID, err)
+				respondError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+
+			if existingCategory != nil {
+				updateErr := data.UpdateCategoryWithTx(tx, &clientCategory)
+				if updateErr != nil {
+					err = fmt.Errorf("ошибка при обновлении Category (ID %d, DB %d): %w", clientCategory.Id, sharedDbID, updateErr)
+					log.Printf("Sync Error (DB %d, User %d): %v", sharedDbID, currentUserID, err)
+					respondError(w, http.StatusInternalServerError, err.Error())
+					return
+				}
+				serverCategoryID = clientCategory.Id
+			} else {
+				newCategoryToCreate := clientCategory
+				newCategoryToCreate.Id = 0
+				createdID, createErr := data.CreateCategoryWithTx(tx, &newCategoryToCreate)
+				if createErr != nil {
+					err = fmt.Errorf("ошибка при создании Category (клиентский ID %d, БД %d): %w", clientCategory.Id, sharedDbID, createErr)
+					log.Printf("Sync Error (DB %d, User %d): %v", sharedDbID, currentUserID, err)
+					respondError(w, http.StatusInternalServerError, err.Error())
+					return
+				}
+				serverCategoryID = createdID
+				log.Printf("Sync: Создана Category с серверным ID %d (клиентский ID %d) для БД %d", serverCategoryID, clientCategory.Id, sharedDbID)
+			}
+			processedCategoryIDs[serverCategoryID] = true
+			clientToServerCategoryMap[clientCategory.Id] = serverCategoryID
+		}
+
+		// Удаление Categories, которые есть на сервере, но не пришли от клиента.
+		// Заметки и записи расписания ниже получат актуальные category_id, так что внешний ключ SET NULL здесь безопасен.
+		for _, serverID := range existingCategoryIDs {
+			if processedCategoryIDs[serverID] {
+				continue
+			}
+			deleteErr := data.DeleteCategoryWithTx(tx, serverID, sharedDbID)
+			if deleteErr != nil && deleteErr != sql.ErrNoRows {
+				err = fmt.Errorf("ошибка при удалении Category (ID %d, DB %d): %w", serverID, sharedDbID, deleteErr)
+				log.Printf("Sync Error (DB %d, User %d): %v", sharedDbID, currentUserID, err)
+				respondError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			log.Printf("Sync: Удалена Category с ID %d из БД %d, так как она не пришла от клиента.", serverID, sharedDbID)
+		}
+	}
+	// Конец обработки Categories
+
 	// Обработка ScheduleEntries
 	existingScheduleEntryIDs, err := data.GetAllScheduleEntryIDsForDBWithTx(tx, sharedDbID)
 	if err != nil {
@@ -127,10 +223,82 @@ func SyncSharedDatabaseHandler(w http.ResponseWriter, r *http.Request) {
 		respondError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	fieldDefinitions, err := data.GetDynamicFieldDefinitionsBySharedDBIDWithTx(tx, sharedDbID)
+	if err != nil {
+		log.Printf("Sync Error (DB %d, User %d): %v", sharedDbID, currentUserID, err)
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	memberIDs, err := data.GetSharedDatabaseMemberIDsWithTx(tx, sharedDbID)
+	if err != nil {
+		log.Printf("Sync Error (DB %d, User %d): %v", sharedDbID, currentUserID, err)
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	isMember := make(map[int64]bool, len(memberIDs))
+	for _, id := range memberIDs {
+		isMember[id] = true
+	}
 	processedScheduleEntryIDs := make(map[int64]bool) // Для отслеживания обработанных ID
+	// Мапинг клиентских ID записей расписания на серверные ID (нужен для исключений)
+	clientToServerScheduleEntryMap := make(map[int64]int64)
+	var scheduleWarnings []scheduleEntryWarning
 
 	for _, clientEntry := range syncData.ScheduleEntries {
 		clientEntry.DatabaseId = sharedDbID // Убеждаемся, что DatabaseId установлен корректно
+		// Сохраненная запись с тем же ID: неизменившиеся значения, которые не проходят проверки, не блокируют синхронизацию
+		var existingEntry *models.ScheduleEntry
+		if clientEntry.Id != 0 {
+			var getErr error
+			existingEntry, getErr = data.GetScheduleEntryByIDWithTx(tx, clientEntry.Id, sharedDbID)
+			if getErr != nil && getErr != sql.ErrNoRows {
+				err = fmt.Errorf("ошибка при поиске ScheduleEntry (ID %d, DB %d): %w", clientEntry.Id, sharedDbID, getErr)
+				log.Printf("Sync Error (DB %d, User %d): %v", sharedDbID, currentUserID, err)
+				respondError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+		}
+		var entryWarnings []string
+		// nil - клиент не знает о часовых поясах записей, "" - сбросить на часовой пояс БД
+		keepTimeZone := clientEntry.TimeZone == nil
+		// Ответственного клиент может не знать (nil - оставить прежнего); автора задает сервер
+		keepAssignee := clientEntry.AssigneeUserId == nil
+		if !keepAssignee && !isMember[*clientEntry.AssigneeUserId] {
+			// Ответственный покинул БД: запись сохраняется с прежним ответственным, если он еще участник, иначе без него
+			entryWarnings = append(entryWarnings, fmt.Sprintf("ответственный %d не является участником БД: назначение не сохранено", *clientEntry.AssigneeUserId))
+			clientEntry.AssigneeUserId = nil
+			if existingEntry != nil && existingEntry.AssigneeUserId != nil && isMember[*existingEntry.AssigneeUserId] {
+				keepAssignee = true
+			}
+		}
+		clientEntry.CreatedByUserId = &currentUserID
+		warnings, normalizeErr := normalizeSyncEntry(&clientEntry, existingEntry)
+		if normalizeErr != nil {
+			err = normalizeErr
+			log.Printf("Sync Error (DB %d, User %d): запись расписания %d: %v", sharedDbID, currentUserID, clientEntry.Id, normalizeErr)
+			respondError(w, http.StatusBadRequest, fmt.Sprintf("Неверная запись расписания (ID %d): %v", clientEntry.Id, normalizeErr))
+			return
+		}
+		entryWarnings = append(entryWarnings, warnings...)
+		// Проверяются только измененные поля: после изменения схемы прежние значения не должны блокировать синхронизацию
+		fieldsJson, fieldsErr := schedule.ValidateDynamicFields(clientEntry.DynamicFieldsJson, fieldDefinitions)
+		if fieldsErr != nil {
+			if existingEntry == nil || !schedule.DynamicFieldsEqual(clientEntry.DynamicFieldsJson, existingEntry.DynamicFieldsJson) {
+				err = fieldsErr
+				log.Printf("Sync Error (DB %d, User %d): динамические поля записи расписания %d: %v", sharedDbID, currentUserID, clientEntry.Id, err)
+				respondError(w, http.StatusBadRequest, fmt.Sprintf("Неверные динамические поля записи расписания (ID %d): %v", clientEntry.Id, err))
+				return
+			}
+			entryWarnings = append(entryWarnings, fmt.Sprintf("сохранены прежние динамические поля: %v", fieldsErr))
+			fieldsJson = existingEntry.DynamicFieldsJson
+		}
+		clientEntry.DynamicFieldsJson = fieldsJson
+		clientEntry.CategoryId, err = resolveSyncCategoryID(tx, sharedDbID, clientEntry.CategoryId, clientToServerCategoryMap)
+		if err != nil {
+			log.Printf("Sync Error (DB %d, User %d): %v", sharedDbID, currentUserID, err)
+			respondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
 		var serverEntryID int64
 
 		if clientEntry.Id == 0 { // Явное указание на новую запись
@@ -147,18 +315,20 @@ func SyncSharedDatabaseHandler(w http.ResponseWriter, r *http.Request) {
 			log.Printf("Sync: Успешно создана ScheduleEntry с ID %d для БД %d", serverEntryID, sharedDbID)
 		} else { // Попытка обновить существующую или создать, если ID клиента не найден на сервере
 			log.Printf("Sync: Попытка обновления/создания ScheduleEntry с клиентским ID %d для БД %d", clientEntry.Id, sharedDbID)
-			// Сначала пытаемся найти по ID, который прислал клиент (предполагая, что это серверный ID)
-			existingEntry, getErr := data.GetScheduleEntryByIDWithTx(tx, clientEntry.Id, sharedDbID) // Нужна версия с Tx
-			if getErr != nil && getErr != sql.ErrNoRows {
-				err = fmt.Errorf("ошибка при поиске ScheduleEntry (ID %d, DB %d): %w", clientEntry.Id, sharedDbID, getErr)
-				log.Printf("Sync Error (DB %d, User %d): %v", sharedDbID, currentUserID, err)
-				respondError(w, http.StatusInternalServerError, err.Error())
-				return
-			}
-
+			// existingEntry найдена выше по ID, который прислал клиент (предполагая, что это серверный ID)
 			if existingEntry != nil { // Запись найдена, обновляем
 				log.Printf("Sync: Обновление ScheduleEntry ID %d для БД %d", clientEntry.Id, sharedDbID)
-				clientEntry.UpdatedAt = time.Now()                            // Обновляем время, т.к. Create/Update в data слое это делают
+				clientEntry.UpdatedAt = time.Now() // Обновляем время, т.к. Create/Update в data слое это делают
+				if syncData.Categories == nil {
+					clientEntry.CategoryId = existingEntry.CategoryId // Старый клиент не знает о категориях
+				}
+				if keepTimeZone {
+					clientEntry.TimeZone = existingEntry.TimeZone
+				}
+				if keepAssignee {
+					clientEntry.AssigneeUserId = existingEntry.AssigneeUserId
+				}
+				clientEntry.CreatedByUserId = existingEntry.CreatedByUserId
 				updateErr := data.UpdateScheduleEntryWithTx(tx, &clientEntry) // Нужна версия с Tx
 				if updateErr != nil {
 					err = fmt.Errorf("ошибка при обновлении ScheduleEntry (ID %d, DB %d): %w", clientEntry.Id, sharedDbID, updateErr)
@@ -186,6 +356,11 @@ func SyncSharedDatabaseHandler(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 		processedScheduleEntryIDs[serverEntryID] = true
+		clientToServerScheduleEntryMap[clientEntry.Id] = serverEntryID
+		for _, message := range entryWarnings {
+			log.Printf("Sync Warning (DB %d, User %d): запись расписания %d: %s", sharedDbID, currentUserID, serverEntryID, message)
+			scheduleWarnings = append(scheduleWarnings, scheduleEntryWarning{EntryId: serverEntryID, ClientEntryId: clientEntry.Id, Message: message})
+		}
 	}
 
 	// КРИТИЧЕСКОЕ ИСПРАВЛЕНИЕ: Удаление ScheduleEntries, которые есть на сервере, но не были обработаны
@@ -204,6 +379,36 @@ func SyncSharedDatabaseHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	// Конец обработки ScheduleEntries
 
+	// Обработка ScheduleExceptions: клиент присылает полный набор исключений, заменяем им серверный.
+	// Старые клиенты не присылают поле schedule_exceptions - в этом случае исключения не изменяются.
+	if syncData.ScheduleExceptions != nil {
+		serverExceptions := make([]models.ScheduleEntryException, 0, len(syncData.ScheduleExceptions))
+		for _, clientException := range syncData.ScheduleExceptions {
+			serverEntryID, exists := clientToServerScheduleEntryMap[clientException.ScheduleEntryId]
+			if !exists {
+				if !processedScheduleEntryIDs[clientException.ScheduleEntryId] {
+					log.Printf("Sync: Предупреждение - запись расписания с клиентским ID %d не найдена для исключения на %s, исключение пропущено", clientException.ScheduleEntryId, clientException.OriginalDate)
+					continue
+				}
+				serverEntryID = clientException.ScheduleEntryId
+			}
+			clientException.ScheduleEntryId = serverEntryID
+			if validateErr := schedule.ValidateException(&clientException); validateErr != nil {
+				log.Printf("Sync: Предупреждение - некорректное исключение записи %d пропущено: %v", serverEntryID, validateErr)
+				continue
+			}
+			serverExceptions = append(serverExceptions, clientException)
+		}
+		if replaceErr := data.ReplaceScheduleExceptionsWithTx(tx, sharedDbID, serverExceptions); replaceErr != nil {
+			err = fmt.Errorf("ошибка при обновлении ScheduleExceptions для БД %d: %w", sharedDbID, replaceErr)
+			log.Printf("Sync Error (DB %d, User %d): %v", sharedDbID, currentUserID, err)
+			respondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		log.Printf("Sync: Сохранено %d исключений расписания для БД %d", len(serverExceptions), sharedDbID)
+	}
+	// Конец обработки ScheduleExceptions
+
 	// Обработка Folders
 	existingFolderIDs, err := data.GetAllFolderIDsForSharedDBWithTx(tx, sharedDbID)
 	if err != nil {
@@ -295,6 +500,8 @@ func SyncSharedDatabaseHandler(w http.ResponseWriter, r *http.Request) {
 	processedNoteIDs := make(map[int64]bool)
 	// КРИТИЧЕСКОЕ ИСПРАВЛЕНИЕ: Мапинг клиентских ID заметок на серверные ID
 	clientToServerNoteMap := make(map[int64]int64)
+	// Переименованные заметки: вики-ссылки на их старые заголовки переписываются после обработки Notes
+	var noteRenames []data.NoteTitleRename
 
 	for _, clientNote := range syncData.Notes {
 		clientNote.DatabaseID = sharedDbID // Убеждаемся, что DatabaseID установлен корректно
@@ -321,6 +528,13 @@ func SyncSharedDatabaseHandler(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 
+		clientNote.CategoryId, err = resolveSyncCategoryID(tx, sharedDbID, clientNote.CategoryId, clientToServerCategoryMap)
+		if err != nil {
+			log.Printf("Sync Error (DB %d, User %d): %v", sharedDbID, currentUserID, err)
+			respondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
 		// Обновляем JSON поля перед обработкой, если они есть в модели Note и используются
 		if err := clientNote.UpdateJsonProperties(); err != nil {
 			err = fmt.Errorf("ошибка при обновлении JSON свойств для Note (клиентский ID %d, БД %d): %w", clientNote.ID, sharedDbID, err)
@@ -329,6 +543,7 @@ func SyncSharedDatabaseHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		var serverNoteID int64
+		var noteBefore *models.Note // Состояние заметки до обновления; nil - заметка создается
 
 		if clientNote.ID == 0 {
 			log.Printf("Sync: Создание новой Note для БД %d, клиентские данные (title): %s", sharedDbID, clientNote.Title)
@@ -354,15 +569,28 @@ func SyncSharedDatabaseHandler(w http.ResponseWriter, r *http.Request) {
 			if existingNote != nil {
 				log.Printf("Sync: Обновление Note ID %d для БД %d", clientNote.ID, sharedDbID)
 				clientNote.UpdatedAt = time.Now()
-				updateErr := data.UpdateNoteWithTx(tx, &clientNote)
-				if updateErr != nil {
-					err = fmt.Errorf("ошибка при обновлении Note (ID %d, DB %d): %w", clientNote.ID, sharedDbID, updateErr)
-					log.Printf("Sync Error (DB %d, User %d): %v", sharedDbID, currentUserID, err)
-					respondError(w, http.StatusInternalServerError, err.Error())
-					return
+				if syncData.Categories == nil {
+					clientNote.CategoryId = existingNote.CategoryId // Старый клиент не знает о категориях
+				}
+				noteBefore = existingNote
+				if existingNote.Title != clientNote.Title {
+					noteRenames = append(noteRenames, data.NoteTitleRename{NoteId: clientNote.ID, OldTitle: existingNote.Title, NewTitle: clientNote.Title})
+				}
+				if data.SameNoteFields(existingNote, &clientNote) {
+					// Клиент присылает все заметки; неизмененные не обновляются, чтобы сохранить их UpdatedAt
+					serverNoteID = clientNote.ID
+					log.Printf("Sync: Note ID %d для БД %d не изменилась", serverNoteID, sharedDbID)
+				} else {
+					updateErr := data.UpdateNoteWithTx(tx, &clientNote)
+					if updateErr != nil {
+						err = fmt.Errorf("ошибка при обновлении Note (ID %d, DB %d): %w", clientNote.ID, sharedDbID, updateErr)
+						log.Printf("Sync Error (DB %d, User %d): %v", sharedDbID, currentUserID, err)
+						respondError(w, http.StatusInternalServerError, err.Error())
+						return
+					}
+					serverNoteID = clientNote.ID
+					log.Printf("Sync: Успешно обновлена Note с ID %d для БД %d", serverNoteID, sharedDbID)
 				}
-				serverNoteID = clientNote.ID
-				log.Printf("Sync: Успешно обновлена Note с ID %d для БД %d", serverNoteID, sharedDbID)
 			} else {
 				log.Printf("Sync: Note с клиентским ID %d не найдена для БД %d. Создание новой.", clientNote.ID, sharedDbID)
 				newNoteToCreate := clientNote
@@ -378,6 +606,12 @@ func SyncSharedDatabaseHandler(w http.ResponseWriter, r *http.Request) {
 				log.Printf("Sync: Успешно создана Note с серверным ID %d (клиентский ID %d) для БД %d", serverNoteID, clientNote.ID, sharedDbID)
 			}
 		}
+		if revErr := recordSyncNoteRevision(tx, noteBefore, clientNote, serverNoteID, currentUserID); revErr != nil {
+			err = fmt.Errorf("ошибка при сохранении версии Note (ID %d, DB %d): %w", serverNoteID, sharedDbID, revErr)
+			log.Printf("Sync Error (DB %d, User %d): %v", sharedDbID, currentUserID, err)
+			respondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
 		processedNoteIDs[serverNoteID] = true
 		// Сохраняем мапинг клиентского ID на серверный ID
 		clientToServerNoteMap[clientNote.ID] = serverNoteID
@@ -386,8 +620,8 @@ func SyncSharedDatabaseHandler(w http.ResponseWriter, r *http.Request) {
 	// Удаление Notes, которые есть на сервере, но не были обработаны
 	for _, serverID := range existingNoteIDs {
 		if _, ok := processedNoteIDs[serverID]; !ok {
-			log.Printf("Sync: Удаление Note с ID %d из БД %d, так как она не пришла от клиента.", serverID, sharedDbID)
-			deleteErr := data.DeleteNoteWithTx(tx, serverID, sharedDbID)
+			log.Printf("Sync: Удаление Note с ID %d из БД %d в корзину, так как она не пришла от клиента.", serverID, sharedDbID)
+			deleteErr := data.TrashNoteWithTx(tx, serverID, sharedDbID, currentUserID)
 			if deleteErr != nil && deleteErr != sql.ErrNoRows {
 				err = fmt.Errorf("ошибка при удалении Note (ID %d, DB %d): %w", serverID, sharedDbID, deleteErr)
 				log.Printf("Sync Error (DB %d, User %d): %v", sharedDbID, currentUserID, err)
@@ -397,8 +631,120 @@ func SyncSharedDatabaseHandler(w http.ResponseWriter, r *http.Request) {
 			log.Printf("Sync: Успешно удалена Note с ID %d из БД %d.", serverID, sharedDbID)
 		}
 	}
+	// Вики-ссылки [[...]]: сначала переписываем ссылки на старые заголовки переименованных заметок,
+	// затем строим ссылки заново по тексту заметок после синхронизации
+	if _, renameErr := data.RenameNoteWikiLinksWithTx(tx, sharedDbID, noteRenames, currentUserID); renameErr != nil {
+		err = fmt.Errorf("ошибка при обновлении вики-ссылок переименованных заметок БД %d: %w", sharedDbID, renameErr)
+		log.Printf("Sync Error (DB %d, User %d): %v", sharedDbID, currentUserID, err)
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if rebuildErr := data.RebuildNoteWikiLinksWithTx(tx, sharedDbID); rebuildErr != nil {
+		err = fmt.Errorf("ошибка при построении вики-ссылок БД %d: %w", sharedDbID, rebuildErr)
+		log.Printf("Sync Error (DB %d, User %d): %v", sharedDbID, currentUserID, err)
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
 	// Конец обработки Notes
 
+	// Обработка NoteTags: клиент присылает полный набор тегов, заменяем им серверный.
+	// Старые клиенты не присылают поле note_tags - в этом случае теги не изменяются.
+	if syncData.NoteTags != nil {
+		serverTags := make([]models.NoteTag, 0, len(syncData.NoteTags))
+		for _, clientTag := range syncData.NoteTags {
+			serverNoteID, exists := clientToServerNoteMap[clientTag.NoteId]
+			if !exists {
+				if !processedNoteIDs[clientTag.NoteId] {
+					log.Printf("Sync: Предупреждение - заметка с клиентским ID %d не найдена для тега '%s', тег пропущен", clientTag.NoteId, clientTag.Tag)
+					continue
+				}
+				serverNoteID = clientTag.NoteId
+			}
+			clientTag.NoteId = serverNoteID
+			serverTags = append(serverTags, clientTag)
+		}
+		if replaceErr := data.ReplaceNoteTagsWithTx(tx, sharedDbID, serverTags); replaceErr != nil {
+			err = fmt.Errorf("ошибка при обновлении NoteTags для БД %d: %w", sharedDbID, replaceErr)
+			log.Printf("Sync Error (DB %d, User %d): %v", sharedDbID, currentUserID, err)
+			respondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		log.Printf("Sync: Сохранено %d тегов заметок для БД %d", len(serverTags), sharedDbID)
+	}
+	// Конец обработки NoteTags
+
+	// Обработка Pinboards: обрабатываются до PinboardNotes, т.к. на них ссылаются карточки.
+	// Удаление доски удаляет и ее карточки (ON DELETE CASCADE).
+	clientToServerPinboardMap := make(map[int64]int64)
+	if syncData.Pinboards != nil {
+		existingPinboardIDs, pinboardsErr := data.GetAllPinboardIDsForSharedDBWithTx(tx, sharedDbID)
+		if pinboardsErr != nil {
+			err = fmt.Errorf("ошибка при получении ID существующих Pinboards для БД %d: %w", sharedDbID, pinboardsErr)
+			log.Printf("Sync Error (DB %d, User %d): %v", sharedDbID, currentUserID, err)
+			respondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		processedPinboardIDs := make(map[int64]bool)
+
+		for _, clientPinboard := range syncData.Pinboards {
+			clientPinboard.DatabaseId = sharedDbID
+			clientPinboard.Name = strings.TrimSpace(clientPinboard.Name)
+			if clientPinboard.Name == "" {
+				clientPinboard.Name = data.DefaultPinboardName
+			}
+			var serverPinboardID int64
+
+			existingPinboard, getErr := data.GetPinboardByIDWithTx(tx, clientPinboard.Id, sharedDbID)
+			if getErr != nil {
+				err = fmt.Errorf("ошибка при поиске Pinboard (ID %d, DB %d): %w", clientPinboard.Id, sharedDbID, getErr)
+				log.Printf("Sync Error (DB %d, User %d): %v", sharedDbID, currentUserID, err)
+				respondError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+
+			if existingPinboard != nil {
+				updateErr := data.UpdatePinboardWithTx(tx, &clientPinboard)
+				if updateErr != nil {
+					err = fmt.Errorf("ошибка при обновлении Pinboard (ID %d, DB %d): %w", clientPinboard.Id, sharedDbID, updateErr)
+					log.Printf("Sync Error (DB %d, User %d): %v", sharedDbID, currentUserID, err)
+					respondError(w, http.StatusInternalServerError, err.Error())
+					return
+				}
+				serverPinboardID = clientPinboard.Id
+			} else {
+				newPinboardToCreate := clientPinboard
+				newPinboardToCreate.Id = 0
+				createdID, createErr := data.CreatePinboardWithTx(tx, &newPinboardToCreate)
+				if createErr != nil {
+					err = fmt.Errorf("ошибка при создании Pinboard (клиентский ID %d, БД %d): %w", clientPinboard.Id, sharedDbID, createErr)
+					log.Printf("Sync Error (DB %d, User %d): %v", sharedDbID, currentUserID, err)
+					respondError(w, http.StatusInternalServerError, err.Error())
+					return
+				}
+				serverPinboardID = createdID
+				log.Printf("Sync: Создана Pinboard с серверным ID %d (клиентский ID %d) для БД %d", serverPinboardID, clientPinboard.Id, sharedDbID)
+			}
+			processedPinboardIDs[serverPinboardID] = true
+			clientToServerPinboardMap[clientPinboard.Id] = serverPinboardID
+		}
+
+		// Удаление Pinboards, которые есть на сервере, но не пришли от клиента.
+		for _, serverID := range existingPinboardIDs {
+			if processedPinboardIDs[serverID] {
+				continue
+			}
+			deleteErr := data.DeletePinboardWithTx(tx, serverID, sharedDbID)
+			if deleteErr != nil && deleteErr != sql.ErrNoRows {
+				err = fmt.Errorf("ошибка при удалении Pinboard (ID %d, DB %d): %w", serverID, sharedDbID, deleteErr)
+				log.Printf("Sync Error (DB %d, User %d): %v", sharedDbID, currentUserID, err)
+				respondError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			log.Printf("Sync: Удалена Pinboard с ID %d из БД %d, так как она не пришла от клиента.", serverID, sharedDbID)
+		}
+	}
+	// Конец обработки Pinboards
+
 	// Обработка PinboardNotes
 	existingPinboardNoteIDs, pinboardErr := data.GetAllPinboardNoteIDsForSharedDBWithTx(tx, sharedDbID)
 	if pinboardErr != nil {
@@ -415,6 +761,13 @@ func SyncSharedDatabaseHandler(w http.ResponseWriter, r *http.Request) {
 		clientPinboardNote.DatabaseId = sharedDbID
 		var serverPinboardNoteID int64
 
+		clientPinboardNote.PinboardId, err = resolveSyncPinboardID(tx, sharedDbID, clientPinboardNote.PinboardId, clientToServerPinboardMap)
+		if err != nil {
+			log.Printf("Sync Error (DB %d, User %d): %v", sharedDbID, currentUserID, err)
+			respondError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
 		if clientPinboardNote.Id == 0 {
 			log.Printf("Sync: Создание новой PinboardNote для БД %d, клиентские данные (title): %s", sharedDbID, clientPinboardNote.Title)
 			createdID, createErr := data.CreatePinboardNoteWithTx(tx, &clientPinboardNote)
@@ -829,17 +1182,18 @@ func SyncSharedDatabaseHandler(w http.ResponseWriter, r *http.Request) {
 	// Конец обработки NoteImages
 
 	// КРИТИЧЕСКОЕ ИСПРАВЛЕНИЕ: Теперь безопасно удаляем папки в конце
-	// Все заметки уже обработаны и их folder_id либо замаплены, либо обнулены
-	for _, folderID := range foldersToDelete {
-		log.Printf("Sync: Удаление отложенной Folder с ID %d из БД %d", folderID, sharedDbID)
-		deleteErr := data.DeleteFolderWithTx(tx, folderID, sharedDbID)
-		if deleteErr != nil && deleteErr != sql.ErrNoRows {
-			err = fmt.Errorf("ошибка при отложенном удалении Folder (ID %d, DB %d): %w", folderID, sharedDbID, deleteErr)
+	// Все заметки уже обработаны и их folder_id либо замаплены, либо обнулены.
+	// Папки вместе с вложенными перемещаются в корзину
+	if len(foldersToDelete) > 0 {
+		log.Printf("Sync: Удаление отложенных Folders %v из БД %d в корзину", foldersToDelete, sharedDbID)
+		trashed, deleteErr := data.TrashFoldersWithTx(tx, foldersToDelete, sharedDbID, currentUserID)
+		if deleteErr != nil {
+			err = fmt.Errorf("ошибка при отложенном удалении Folders (DB %d): %w", sharedDbID, deleteErr)
 			log.Printf("Sync Error (DB %d, User %d): %v", sharedDbID, currentUserID, err)
 			respondError(w, http.StatusInternalServerError, err.Error())
 			return
 		}
-		log.Printf("Sync: Успешно удалена отложенная Folder с ID %d из БД %d", folderID, sharedDbID)
+		log.Printf("Sync: Успешно перемещено в корзину %d Folders из БД %d", trashed, sharedDbID)
 	}
 
 	// Получаем все актуальные данные для ответа ДО коммита транзакции
@@ -867,6 +1221,19 @@ func SyncSharedDatabaseHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	log.Printf("Sync: Получено %d актуальных заметок для ответа БД %d", len(actualNotes), sharedDbID)
 
+	// Доска по умолчанию нужна клиенту, чтобы было куда класть новые карточки
+	if _, err = data.GetDefaultPinboardIDWithTx(tx, sharedDbID); err != nil {
+		log.Printf("Sync Error (DB %d, User %d): ошибка при получении доски по умолчанию: %v", sharedDbID, currentUserID, err)
+		respondError(w, http.StatusInternalServerError, "Ошибка сервера при подготовке ответа синхронизации (pinboards).")
+		return
+	}
+	actualPinboards, err := data.GetPinboardsBySharedDBIDWithTx(tx, sharedDbID)
+	if err != nil {
+		log.Printf("Sync Error (DB %d, User %d): ошибка при получении досок для ответа: %v", sharedDbID, currentUserID, err)
+		respondError(w, http.StatusInternalServerError, "Ошибка сервера при подготовке ответа синхронизации (pinboards).")
+		return
+	}
+
 	actualPinboardNotes, err := data.GetAllPinboardNotesBySharedDBIDWithTx(tx, sharedDbID)
 	if err != nil {
 		log.Printf("Sync Error (DB %d, User %d): ошибка при получении актуальных PinboardNotes для ответа: %v", sharedDbID, currentUserID, err)
@@ -891,6 +1258,34 @@ func SyncSharedDatabaseHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	log.Printf("Sync: Получено %d актуальных изображений для ответа БД %d", len(actualNoteImages), sharedDbID)
 
+	actualSmartFolders, err := data.GetAllSmartFoldersBySharedDBIDWithTx(tx, sharedDbID)
+	if err != nil {
+		log.Printf("Sync Error (DB %d, User %d): ошибка при получении умных папок для ответа: %v", sharedDbID, currentUserID, err)
+		respondError(w, http.StatusInternalServerError, "Ошибка сервера при подготовке ответа синхронизации (smart_folders).")
+		return
+	}
+
+	actualNoteTags, err := data.GetNoteTagsBySharedDBIDWithTx(tx, sharedDbID)
+	if err != nil {
+		log.Printf("Sync Error (DB %d, User %d): ошибка при получении тегов заметок для ответа: %v", sharedDbID, currentUserID, err)
+		respondError(w, http.StatusInternalServerError, "Ошибка сервера при подготовке ответа синхронизации (note_tags).")
+		return
+	}
+
+	actualCategories, err := data.GetCategoriesBySharedDBIDWithTx(tx, sharedDbID)
+	if err != nil {
+		log.Printf("Sync Error (DB %d, User %d): ошибка при получении категорий для ответа: %v", sharedDbID, currentUserID, err)
+		respondError(w, http.StatusInternalServerError, "Ошибка сервера при подготовке ответа синхронизации (categories).")
+		return
+	}
+
+	actualScheduleExceptions, err := data.GetScheduleExceptionsBySharedDBIDWithTx(tx, sharedDbID)
+	if err != nil {
+		log.Printf("Sync Error (DB %d, User %d): ошибка при получении исключений расписания для ответа: %v", sharedDbID, currentUserID, err)
+		respondError(w, http.StatusInternalServerError, "Ошибка сервера при подготовке ответа синхронизации (schedule_exceptions).")
+		return
+	}
+
 	sharedDBInfo, err := data.GetSharedDatabaseDetails(sharedDbID)
 	if err != nil || sharedDBInfo == nil {
 		log.Printf("Sync Error: Не удалось получить детали SharedDatabase %d для ответа: %v", sharedDbID, err)
@@ -938,17 +1333,27 @@ func SyncSharedDatabaseHandler(w http.ResponseWriter, r *http.Request) {
 	// Также нужно получить данные для LastModified, CreatedAt (для SharedDatabase), DatabaseId (как string), UserId (owner)
 
 	response := SyncDataResponse{
-		ScheduleEntries: actualScheduleEntries,
-		Folders:         actualFolders,
-		Notes:           actualNotes,
-		PinboardNotes:   actualPinboardNotes,
-		Connections:     actualConnections,
-		Images:          actualNoteImages, // Клиент ожидает "images"
-		LastModified:    sharedDBInfo.UpdatedAt.Format(time.RFC3339Nano),
-		CreatedAt:       sharedDBInfo.CreatedAt.Format(time.RFC3339Nano),
-		DatabaseId:      strconv.FormatInt(sharedDBInfo.Id, 10),
-		UserId:          strconv.FormatInt(sharedDBInfo.OwnerUserId, 10),
+		ScheduleEntries:    actualScheduleEntries,
+		Folders:            actualFolders,
+		Notes:              actualNotes,
+		Pinboards:          actualPinboards,
+		PinboardNotes:      actualPinboardNotes,
+		Connections:        actualConnections,
+		Images:             actualNoteImages, // Клиент ожидает "images"
+		SmartFolders:       actualSmartFolders,
+		NoteTags:           actualNoteTags,
+		Categories:         actualCategories,
+		LastModified:       sharedDBInfo.UpdatedAt.Format(time.RFC3339Nano),
+		CreatedAt:          sharedDBInfo.CreatedAt.Format(time.RFC3339Nano),
+		DatabaseId:         strconv.FormatInt(sharedDBInfo.Id, 10),
+		UserId:             strconv.FormatInt(sharedDBInfo.OwnerUserId, 10),
+		ScheduleExceptions: actualScheduleExceptions,
+		DynamicFields:      fieldDefinitions,
 	}
+	dbLoc := schedule.DatabaseLocation(sharedDBInfo)
+	today := time.Now().In(dbLoc)
+	response.ScheduleWarnings = scheduleWarnings
+	response.ScheduleConflicts, response.ScheduleConflictsTruncated = schedule.FindScheduleConflicts(actualScheduleEntries, actualScheduleExceptions, dbLoc, today, today.AddDate(0, 0, schedule.ConflictCheckDays))
 
 	// Если err == nil (транзакция может быть закоммичена), удаляем файлы
 	if err == nil && len(imagePathsToDeleteAfterCommit) > 0 {
@@ -980,3 +1385,81 @@ func SyncSharedDatabaseHandler(w http.ResponseWriter, r *http.Request) {
 
 	respondJSON(w, http.StatusOK, response)
 }
+
+// normalizeSyncEntry строго проверяет дату, время и часовой пояс присланной записи расписания.
+// Неверные дата или время, совпадающие с сохраненными в existing (запись создана до появления строгой
+// проверки), принимаются как есть с предупреждением: клиенты присылают все записи при каждой синхронизации,
+// и такая запись иначе блокировала бы синхронизацию всей БД. Для новых и измененных значений возвращается ошибка.
+func normalizeSyncEntry(entry *models.ScheduleEntry, existing *models.ScheduleEntry) ([]string, error) {
+	var warnings []string
+	if date, err := schedule.NormalizeDate(entry.Date); err == nil {
+		entry.Date = date
+	} else if existing != nil && entry.Date == existing.Date {
+		warnings = append(warnings, fmt.Sprintf("сохранена прежняя дата: %v", err))
+	} else {
+		return nil, err
+	}
+	if timeValue, err := schedule.NormalizeTime(entry.Time); err == nil {
+		entry.Time = timeValue
+	} else if existing != nil && entry.Time == existing.Time {
+		warnings = append(warnings, fmt.Sprintf("сохранено прежнее время: %v", err))
+	} else {
+		return nil, err
+	}
+	timeZone, err := schedule.NormalizeTimeZone(entry.TimeZone)
+	if err != nil {
+		return nil, err
+	}
+	entry.TimeZone = timeZone
+	return warnings, nil
+}
+
+// resolveSyncCategoryID приводит category_id, присланный клиентом, к серверному ID категории.
+// Если категория не найдена ни среди присланных клиентом, ни на сервере, возвращает nil,
+// чтобы не нарушить внешний ключ.
+func resolveSyncCategoryID(tx *sqlx.Tx, sharedDbID int64, categoryID *int64, clientToServerCategoryMap map[int64]int64) (*int64, error) {
+	if categoryID == nil || *categoryID <= 0 {
+		return nil, nil
+	}
+	if serverID, exists := clientToServerCategoryMap[*categoryID]; exists {
+		return &serverID, nil
+	}
+	existingCategory, err := data.GetCategoryByIDWithTx(tx, *categoryID, sharedDbID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при поиске Category (ID %d, DB %d): %w", *categoryID, sharedDbID, err)
+	}
+	if existingCategory == nil {
+		log.Printf("Sync: Предупреждение - категория с ID %d не найдена в БД %d, category_id обнулен", *categoryID, sharedDbID)
+		return nil, nil
+	}
+	return categoryID, nil
+}
+
+// resolveSyncPinboardID приводит pinboard_id карточки, присланный клиентом, к серверному ID доски.
+// Возвращает 0, если доска не указана или не найдена ни среди присланных клиентом, ни на сервере:
+// новая карточка тогда попадает на доску по умолчанию, существующая остается на своей доске.
+func resolveSyncPinboardID(tx *sqlx.Tx, sharedDbID int64, pinboardID int64, clientToServerPinboardMap map[int64]int64) (int64, error) {
+	// Новые доски клиент может ссылать по временным (в том числе отрицательным) ID
+	if serverID, exists := clientToServerPinboardMap[pinboardID]; exists {
+		return serverID, nil
+	}
+	if pinboardID <= 0 {
+		return 0, nil
+	}
+	existingPinboard, err := data.GetPinboardByIDWithTx(tx, pinboardID, sharedDbID)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка при поиске Pinboard (ID %d, DB %d): %w", pinboardID, sharedDbID, err)
+	}
+	if existingPinboard == nil {
+		log.Printf("Sync: Предупреждение - доска с ID %d не найдена в БД %d, pinboard_id сброшен", pinboardID, sharedDbID)
+		return 0, nil
+	}
+	return pinboardID, nil
+}
+
+// recordSyncNoteRevision сохраняет в истории версий заметку, созданную (before = nil) или измененную синхронизацией.
+func recordSyncNoteRevision(tx *sqlx.Tx, before *models.Note, after models.Note, serverNoteID int64, userID int64) error {
+	after.ID = serverNoteID
+	_, err := data.RecordNoteRevisionWithTx(tx, before, &after, &userID, models.NoteRevisionReasonSync)
+	return err
+}
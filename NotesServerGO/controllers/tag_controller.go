@@ -0,0 +1,125 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"notes_server_go/data"
+)
+
+// GetNoteTagsHandler возвращает теги заметок совместной БД с количеством использований.
+// GET /api/collaboration/databases/{db_id}/tags
+func GetNoteTagsHandler(w http.ResponseWriter, r *http.Request) {
+	_, dbID, _, ok := requireDatabaseMember(w, r)
+	if !ok {
+		return
+	}
+
+	usage, err := data.GetTagUsage(dbID)
+	if err != nil {
+		log.Printf("Ошибка при получении тегов БД %d: %v", dbID, err)
+		respondError(w, http.StatusInternalServerError, "Ошибка при получении тегов.")
+		return
+	}
+	respondJSON(w, http.StatusOK, usage)
+}
+
+// RenameNoteTagHandler переименовывает тег во всех заметках совместной БД.
+// POST /api/collaboration/databases/{db_id}/tags/rename
+// Тело: {"from": "старый", "to": "новый"}. Если тег "to" уже существует, возвращается 409 - используйте merge.
+func RenameNoteTagHandler(w http.ResponseWriter, r *http.Request) {
+	_, dbID, _, ok := requireDatabaseMember(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		From string `json:"from"`
+		To   string `json:"to"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Неверный формат запроса: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+
+	from, to := data.NormalizeTag(req.From), data.NormalizeTag(req.To)
+	if from == "" || to == "" {
+		respondError(w, http.StatusBadRequest, "Поля from и to не могут быть пустыми.")
+		return
+	}
+	if from == to {
+		respondError(w, http.StatusBadRequest, "Новое имя тега совпадает со старым.")
+		return
+	}
+
+	exists, err := data.NoteTagExists(dbID, to)
+	if err != nil {
+		log.Printf("Ошибка при проверке тега '%s' в БД %d: %v", to, dbID, err)
+		respondError(w, http.StatusInternalServerError, "Ошибка при переименовании тега.")
+		return
+	}
+	if exists {
+		respondError(w, http.StatusConflict, "Тег '"+to+"' уже существует. Используйте слияние тегов.")
+		return
+	}
+
+	affected, err := data.RenameNoteTag(dbID, from, to)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			respondError(w, http.StatusNotFound, "Тег '"+from+"' не найден.")
+			return
+		}
+		log.Printf("Ошибка при переименовании тега '%s' в БД %d: %v", from, dbID, err)
+		respondError(w, http.StatusInternalServerError, "Ошибка при переименовании тега.")
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"tag": to, "affected_notes": affected})
+}
+
+// MergeNoteTagsHandler сливает один или несколько тегов в целевой тег.
+// POST /api/collaboration/databases/{db_id}/tags/merge
+// Тело: {"sources": ["a", "b"], "target": "c"} или {"source": "a", "target": "c"}.
+func MergeNoteTagsHandler(w http.ResponseWriter, r *http.Request) {
+	_, dbID, _, ok := requireDatabaseMember(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Source  string   `json:"source"`
+		Sources []string `json:"sources"`
+		Target  string   `json:"target"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "Неверный формат запроса: "+err.Error())
+		return
+	}
+	defer r.Body.Close()
+
+	target := data.NormalizeTag(req.Target)
+	var sources []string
+	for _, s := range append(req.Sources, req.Source) {
+		if s = data.NormalizeTag(s); s != "" && s != target {
+			sources = append(sources, s)
+		}
+	}
+	if target == "" || len(sources) == 0 {
+		respondError(w, http.StatusBadRequest, "Необходимо указать target и хотя бы один исходный тег, отличный от target.")
+		return
+	}
+
+	affected, err := data.MergeNoteTags(dbID, sources, target)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			respondError(w, http.StatusNotFound, "Исходные теги не найдены.")
+			return
+		}
+		log.Printf("Ошибка при слиянии тегов %v в '%s' в БД %d: %v", sources, target, dbID, err)
+		respondError(w, http.StatusInternalServerError, "Ошибка при слиянии тегов.")
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{"tag": target, "affected_notes": affected})
+}
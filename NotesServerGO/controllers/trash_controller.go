@@ -0,0 +1,183 @@
+package controllers
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"time"
+
+	"notes_server_go/data"
+	"notes_server_go/middleware"
+	"notes_server_go/models"
+)
+
+// deletedSharedDatabase - совместная БД в корзине владельца.
+type deletedSharedDatabase struct {
+	models.SharedDatabase
+	ExpiresAt *time.Time `json:"expires_at"` // nil - хранится без ограничения срока
+}
+
+// GetTrashHandler возвращает корзину совместной БД: удаленные заметки и папки, начиная с последних.
+// GET /api/collaboration/databases/{db_id}/trash
+func GetTrashHandler(w http.ResponseWriter, r *http.Request) {
+	_, dbID, _, ok := requireDatabaseMember(w, r)
+	if !ok {
+		return
+	}
+
+	items, err := data.GetTrashItems(dbID)
+	if err != nil {
+		log.Printf("Ошибка при получении корзины БД %d: %v", dbID, err)
+		respondError(w, http.StatusInternalServerError, "Ошибка при получении корзины.")
+		return
+	}
+	respondJSON(w, http.StatusOK, items)
+}
+
+// RestoreTrashItemHandler восстанавливает заметку или папку из корзины, при необходимости создавая
+// заново папки ее пути. Синхронизация заменяет данные БД целиком, поэтому клиент должен загрузить
+// восстановленные данные до следующей отправки, иначе заметка снова попадет в корзину.
+// POST /api/collaboration/databases/{db_id}/trash/{item_id}/restore
+func RestoreTrashItemHandler(w http.ResponseWriter, r *http.Request) {
+	userID, dbID, _, ok := requireDatabaseMember(w, r)
+	if !ok {
+		return
+	}
+	itemID, ok := parseIDVar(w, r, "item_id")
+	if !ok {
+		return
+	}
+
+	result, err := data.RestoreTrashItem(dbID, itemID, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			respondError(w, http.StatusNotFound, "Элемент корзины не найден.")
+			return
+		}
+		log.Printf("Ошибка при восстановлении элемента корзины %d в БД %d: %v", itemID, dbID, err)
+		respondError(w, http.StatusInternalServerError, "Не удалось восстановить элемент корзины.")
+		return
+	}
+	respondJSON(w, http.StatusOK, result)
+}
+
+// PurgeTrashItemHandler окончательно удаляет элемент корзины вместе с файлами изображений заметки.
+// DELETE /api/collaboration/databases/{db_id}/trash/{item_id}
+func PurgeTrashItemHandler(w http.ResponseWriter, r *http.Request) {
+	_, dbID, _, ok := requireDatabaseMember(w, r)
+	if !ok {
+		return
+	}
+	itemID, ok := parseIDVar(w, r, "item_id")
+	if !ok {
+		return
+	}
+
+	if err := data.PurgeTrashItem(dbID, itemID); err != nil {
+		if err == sql.ErrNoRows {
+			respondError(w, http.StatusNotFound, "Элемент корзины не найден.")
+			return
+		}
+		log.Printf("Ошибка при удалении элемента корзины %d в БД %d: %v", itemID, dbID, err)
+		respondError(w, http.StatusInternalServerError, "Не удалось удалить элемент корзины.")
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Элемент корзины удален."})
+}
+
+// EmptyTrashHandler окончательно удаляет все элементы корзины совместной БД. Доступно только владельцу.
+// DELETE /api/collaboration/databases/{db_id}/trash
+func EmptyTrashHandler(w http.ResponseWriter, r *http.Request) {
+	_, dbID, role, ok := requireDatabaseMember(w, r)
+	if !ok {
+		return
+	}
+	if role != models.RoleOwner {
+		respondError(w, http.StatusForbidden, "Очистить корзину может только владелец базы данных.")
+		return
+	}
+
+	purged, err := data.EmptyTrash(dbID)
+	if err != nil {
+		log.Printf("Ошибка при очистке корзины БД %d: %v", dbID, err)
+		respondError(w, http.StatusInternalServerError, "Не удалось очистить корзину.")
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]int64{"purged": purged})
+}
+
+// GetDeletedSharedDatabasesHandler возвращает совместные БД текущего пользователя, находящиеся в корзине.
+// GET /api/collaboration/databases/trash
+func GetDeletedSharedDatabasesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int64)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Не удалось получить ID пользователя из токена.")
+		return
+	}
+
+	dbs, err := data.GetDeletedSharedDatabasesForOwner(userID)
+	if err != nil {
+		log.Printf("Ошибка при получении удаленных БД пользователя %d: %v", userID, err)
+		respondError(w, http.StatusInternalServerError, "Ошибка при получении удаленных баз данных.")
+		return
+	}
+	result := make([]deletedSharedDatabase, 0, len(dbs))
+	for _, db := range dbs {
+		item := deletedSharedDatabase{SharedDatabase: db}
+		if db.DeletedAt != nil {
+			item.ExpiresAt = data.TrashExpiresAt(*db.DeletedAt)
+		}
+		result = append(result, item)
+	}
+	respondJSON(w, http.StatusOK, result)
+}
+
+// RestoreSharedDatabaseHandler возвращает совместную БД текущего пользователя из корзины.
+// POST /api/collaboration/databases/trash/{db_id}/restore
+func RestoreSharedDatabaseHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int64)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Не удалось получить ID пользователя из токена.")
+		return
+	}
+	dbID, ok := parseIDVar(w, r, "db_id")
+	if !ok {
+		return
+	}
+
+	if err := data.RestoreSharedDatabase(dbID, userID); err != nil {
+		if err == sql.ErrNoRows {
+			respondError(w, http.StatusNotFound, "Удаленная база данных не найдена.")
+			return
+		}
+		log.Printf("Ошибка при восстановлении БД %d пользователем %d: %v", dbID, userID, err)
+		respondError(w, http.StatusInternalServerError, "Не удалось восстановить базу данных.")
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Совместная база данных восстановлена."})
+}
+
+// PurgeSharedDatabaseHandler окончательно удаляет совместную БД текущего пользователя из корзины.
+// DELETE /api/collaboration/databases/trash/{db_id}
+func PurgeSharedDatabaseHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int64)
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "Не удалось получить ID пользователя из токена.")
+		return
+	}
+	dbID, ok := parseIDVar(w, r, "db_id")
+	if !ok {
+		return
+	}
+
+	if err := data.PurgeSharedDatabase(dbID, userID); err != nil {
+		if err == sql.ErrNoRows {
+			respondError(w, http.StatusNotFound, "Удаленная база данных не найдена.")
+			return
+		}
+		log.Printf("Ошибка при окончательном удалении БД %d пользователем %d: %v", dbID, userID, err)
+		respondError(w, http.StatusInternalServerError, "Не удалось удалить базу данных.")
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Совместная база данных удалена окончательно."})
+}
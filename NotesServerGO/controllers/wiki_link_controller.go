@@ -0,0 +1,63 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+
+	"notes_server_go/data"
+	"notes_server_go/models"
+)
+
+// GetNoteWikiLinksHandler возвращает вики-ссылки [[...]] из текста заметки, в том числе битые
+// (target_note_id = null).
+// GET /api/collaboration/databases/{db_id}/notes/{note_id}/wiki-links
+func GetNoteWikiLinksHandler(w http.ResponseWriter, r *http.Request) {
+	getNoteWikiLinks(w, r, data.GetOutgoingNoteWikiLinks)
+}
+
+// GetNoteWikiBacklinksHandler возвращает вики-ссылки других заметок на заметку.
+// GET /api/collaboration/databases/{db_id}/notes/{note_id}/wiki-backlinks
+func GetNoteWikiBacklinksHandler(w http.ResponseWriter, r *http.Request) {
+	getNoteWikiLinks(w, r, data.GetNoteWikiBacklinks)
+}
+
+// GetBrokenWikiLinksHandler возвращает отчет о битых вики-ссылках совместной БД:
+// ссылки на заголовки, которых нет ни у одной заметки, сгруппированные по заметкам-источникам.
+// GET /api/collaboration/databases/{db_id}/wiki-links/broken
+func GetBrokenWikiLinksHandler(w http.ResponseWriter, r *http.Request) {
+	_, dbID, _, ok := requireDatabaseMember(w, r)
+	if !ok {
+		return
+	}
+
+	links, err := data.GetBrokenNoteWikiLinks(dbID)
+	if err != nil {
+		log.Printf("Ошибка при получении битых вики-ссылок БД %d: %v", dbID, err)
+		respondError(w, http.StatusInternalServerError, "Ошибка при получении битых ссылок.")
+		return
+	}
+	respondJSON(w, http.StatusOK, links)
+}
+
+// getNoteWikiLinks - общая часть обработчиков вики-ссылок и обратных вики-ссылок заметки.
+func getNoteWikiLinks(w http.ResponseWriter, r *http.Request, load func(int64, int64) ([]models.NoteWikiLinkDetails, error)) {
+	_, dbID, _, ok := requireDatabaseMember(w, r)
+	if !ok {
+		return
+	}
+	noteID, ok := parseIDVar(w, r, "note_id")
+	if !ok {
+		return
+	}
+	if !requireEntity(w, dbID, models.EntityNote, noteID) {
+		return
+	}
+
+	links, err := load(dbID, noteID)
+	if err != nil {
+		log.Printf("Ошибка при получении вики-ссылок заметки %d в БД %d: %v", noteID, dbID, err)
+		respondError(w, http.StatusInternalServerError, "Ошибка при получении ссылок.")
+		return
+	}
+	respondJSON(w, http.StatusOK, links)
+}
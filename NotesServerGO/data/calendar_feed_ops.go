@@ -0,0 +1,107 @@
+package data
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"time"
+
+	"notes_server_go/models"
+)
+
+// calendarFeedTokenBytes - длина случайной части токена подписки на календарь (в hex вдвое длиннее).
+const calendarFeedTokenBytes = 32
+
+// generateCalendarFeedToken создает случайный токен подписки на календарь.
+func generateCalendarFeedToken() (string, error) {
+	buf := make([]byte, calendarFeedTokenBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// CreateCalendarFeedToken выдает пользователю новый токен подписки на календарь совместной БД.
+// Поля token.DatabaseId и token.UserId должны быть установлены; Token и CreatedAt заполняются здесь.
+// Возвращает ID созданного токена.
+func CreateCalendarFeedToken(token *models.CalendarFeedToken) (int64, error) {
+	value, err := generateCalendarFeedToken()
+	if err != nil {
+		return 0, fmt.Errorf("CreateCalendarFeedToken: ошибка генерации токена: %w", err)
+	}
+	token.Token = value
+	token.CreatedAt = models.FlexibleTime{Time: time.Now()}
+
+	query := `INSERT INTO CalendarFeedTokens (Token, DatabaseId, UserId, Name, CreatedAt)
+	          VALUES (:Token, :DatabaseId, :UserId, :Name, :CreatedAt)`
+	result, err := MainDB.NamedExec(query, token)
+	if err != nil {
+		return 0, fmt.Errorf("CreateCalendarFeedToken: ошибка вставки токена для пользователя %d, SharedDBID %d: %w", token.UserId, token.DatabaseId, err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("CreateCalendarFeedToken: ошибка получения LastInsertId: %w", err)
+	}
+	log.Printf("Создан токен подписки на календарь %d для пользователя %d, DatabaseId: %d", id, token.UserId, token.DatabaseId)
+	return id, nil
+}
+
+// GetCalendarFeedTokenByToken извлекает токен подписки по его значению.
+func GetCalendarFeedTokenByToken(value string) (*models.CalendarFeedToken, error) {
+	token := &models.CalendarFeedToken{}
+	query := `SELECT Id, Token, DatabaseId, UserId, Name, CreatedAt, LastUsedAt
+	          FROM CalendarFeedTokens WHERE Token = ?`
+	err := MainDB.Get(token, query, value)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // Не найдено
+		}
+		return nil, fmt.Errorf("GetCalendarFeedTokenByToken: ошибка получения токена: %w", err)
+	}
+	return token, nil
+}
+
+// GetCalendarFeedTokensForUser извлекает токены подписки пользователя на календарь совместной БД.
+func GetCalendarFeedTokensForUser(sharedDbID int64, userID int64) ([]models.CalendarFeedToken, error) {
+	tokens := []models.CalendarFeedToken{}
+	query := `SELECT Id, Token, DatabaseId, UserId, Name, CreatedAt, LastUsedAt
+	          FROM CalendarFeedTokens WHERE DatabaseId = ? AND UserId = ? ORDER BY CreatedAt ASC`
+	err := MainDB.Select(&tokens, query, sharedDbID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("GetCalendarFeedTokensForUser: ошибка получения токенов пользователя %d, SharedDBID %d: %w", userID, sharedDbID, err)
+	}
+	return tokens, nil
+}
+
+// TouchCalendarFeedToken отмечает время последнего обращения к фиду по токену.
+func TouchCalendarFeedToken(id int64) error {
+	if _, err := MainDB.Exec(`UPDATE CalendarFeedTokens SET LastUsedAt = ? WHERE Id = ?`, time.Now(), id); err != nil {
+		return fmt.Errorf("TouchCalendarFeedToken: ошибка обновления токена ID %d: %w", id, err)
+	}
+	return nil
+}
+
+// DeleteCalendarFeedToken отзывает токен подписки пользователя.
+func DeleteCalendarFeedToken(id int64, sharedDbID int64, userID int64) error {
+	result, err := MainDB.Exec(`DELETE FROM CalendarFeedTokens WHERE Id = ? AND DatabaseId = ? AND UserId = ?`, id, sharedDbID, userID)
+	if err != nil {
+		return fmt.Errorf("DeleteCalendarFeedToken: ошибка удаления токена ID %d, SharedDBID %d: %w", id, sharedDbID, err)
+	}
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return sql.ErrNoRows // Не найдено для удаления
+	}
+	log.Printf("Отозван токен подписки на календарь %d пользователя %d, DatabaseId: %d", id, userID, sharedDbID)
+	return nil
+}
+
+// DeleteCalendarFeedTokensForUser отзывает все токены подписки пользователя на календарь совместной БД.
+// Вызывается, когда пользователь покидает БД или удаляется из нее.
+func DeleteCalendarFeedTokensForUser(sharedDbID int64, userID int64) error {
+	if _, err := MainDB.Exec(`DELETE FROM CalendarFeedTokens WHERE DatabaseId = ? AND UserId = ?`, sharedDbID, userID); err != nil {
+		return fmt.Errorf("DeleteCalendarFeedTokensForUser: ошибка удаления токенов пользователя %d, SharedDBID %d: %w", userID, sharedDbID, err)
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+package data
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"notes_server_go/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// CreateCategory создает новую категорию в указанной совместной БД.
+// Поле category.DatabaseId должно быть установлено.
+// Возвращает ID созданной категории.
+func CreateCategory(category *models.Category) (int64, error) {
+	now := time.Now()
+	if category.CreatedAt.IsZero() {
+		category.CreatedAt = models.FlexibleTime{Time: now}
+	}
+	category.UpdatedAt = now
+
+	query := `INSERT INTO Categories (DatabaseId, Name, Color, CreatedAt, UpdatedAt)
+	          VALUES (:DatabaseId, :Name, :Color, :CreatedAt, :UpdatedAt)`
+
+	result, err := MainDB.NamedExec(query, category)
+	if err != nil {
+		return 0, fmt.Errorf("CreateCategory: ошибка вставки категории: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("CreateCategory: ошибка получения LastInsertId: %w", err)
+	}
+	log.Printf("Создана категория с ID: %d для DatabaseId: %d", id, category.DatabaseId)
+	return id, nil
+}
+
+// GetCategoryByID извлекает категорию по ее ID и ID совместной БД.
+func GetCategoryByID(id int64, sharedDbID int64) (*models.Category, error) {
+	category := &models.Category{}
+	query := `SELECT Id, DatabaseId, Name, Color, CreatedAt, UpdatedAt
+	          FROM Categories WHERE Id = ? AND DatabaseId = ?`
+	err := MainDB.Get(category, query, id, sharedDbID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // Не найдено
+		}
+		return nil, fmt.Errorf("GetCategoryByID: ошибка получения категории ID %d, SharedDBID %d: %w", id, sharedDbID, err)
+	}
+	return category, nil
+}
+
+// GetCategoriesBySharedDBID извлекает все категории для указанной совместной БД.
+func GetCategoriesBySharedDBID(sharedDbID int64) ([]models.Category, error) {
+	categories := []models.Category{}
+	query := `SELECT Id, DatabaseId, Name, Color, CreatedAt, UpdatedAt
+	          FROM Categories WHERE DatabaseId = ? ORDER BY Name ASC`
+	err := MainDB.Select(&categories, query, sharedDbID)
+	if err != nil {
+		return nil, fmt.Errorf("GetCategoriesBySharedDBID: ошибка получения категорий для SharedDBID %d: %w", sharedDbID, err)
+	}
+	return categories, nil
+}
+
+// UpdateCategory обновляет название и цвет категории.
+// Поля category.Id и category.DatabaseId должны быть установлены.
+func UpdateCategory(category *models.Category) error {
+	category.UpdatedAt = time.Now()
+
+	query := `UPDATE Categories SET Name = :Name, Color = :Color, UpdatedAt = :UpdatedAt
+	          WHERE Id = :Id AND DatabaseId = :DatabaseId`
+	result, err := MainDB.NamedExec(query, category)
+	if err != nil {
+		return fmt.Errorf("UpdateCategory: ошибка обновления категории ID %d, SharedDBID %d: %w", category.Id, category.DatabaseId, err)
+	}
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return sql.ErrNoRows // Не найдено для обновления
+	}
+	log.Printf("Обновлена категория с ID: %d для DatabaseId: %d", category.Id, category.DatabaseId)
+	return nil
+}
+
+// DeleteCategory удаляет категорию из указанной совместной БД.
+// У заметок и записей расписания этой категории CategoryId обнуляется внешним ключом (ON DELETE SET NULL).
+func DeleteCategory(id int64, sharedDbID int64) error {
+	result, err := MainDB.Exec(`DELETE FROM Categories WHERE Id = ? AND DatabaseId = ?`, id, sharedDbID)
+	if err != nil {
+		return fmt.Errorf("DeleteCategory: ошибка удаления категории ID %d, SharedDBID %d: %w", id, sharedDbID, err)
+	}
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return sql.ErrNoRows // Не найдено для удаления
+	}
+	log.Printf("Удалена категория с ID: %d для DatabaseId: %d", id, sharedDbID)
+	return nil
+}
+
+// --- Функции, работающие с транзакциями ---
+
+// CreateCategoryWithTx создает новую категорию в рамках транзакции.
+func CreateCategoryWithTx(tx *sqlx.Tx, category *models.Category) (int64, error) {
+	now := time.Now()
+	if category.CreatedAt.IsZero() {
+		category.CreatedAt = models.FlexibleTime{Time: now}
+	}
+	category.UpdatedAt = now
+
+	query := `INSERT INTO Categories (DatabaseId, Name, Color, CreatedAt, UpdatedAt)
+	          VALUES (:DatabaseId, :Name, :Color, :CreatedAt, :UpdatedAt)`
+	result, err := tx.NamedExec(query, category)
+	if err != nil {
+		return 0, fmt.Errorf("CreateCategoryWithTx: ошибка вставки категории: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("CreateCategoryWithTx: ошибка получения LastInsertId: %w", err)
+	}
+	return id, nil
+}
+
+// GetCategoryByIDWithTx извлекает категорию по ID и ID совместной БД в рамках транзакции.
+func GetCategoryByIDWithTx(tx *sqlx.Tx, id int64, sharedDbID int64) (*models.Category, error) {
+	category := &models.Category{}
+	query := `SELECT Id, DatabaseId, Name, Color, CreatedAt, UpdatedAt
+	          FROM Categories WHERE Id = ? AND DatabaseId = ?`
+	err := tx.Get(category, query, id, sharedDbID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // Не найдено
+		}
+		return nil, fmt.Errorf("GetCategoryByIDWithTx: ошибка получения категории ID %d, SharedDBID %d: %w", id, sharedDbID, err)
+	}
+	return category, nil
+}
+
+// UpdateCategoryWithTx обновляет название и цвет категории в рамках транзакции.
+func UpdateCategoryWithTx(tx *sqlx.Tx, category *models.Category) error {
+	category.UpdatedAt = time.Now()
+
+	query := `UPDATE Categories SET Name = :Name, Color = :Color, UpdatedAt = :UpdatedAt
+	          WHERE Id = :Id AND DatabaseId = :DatabaseId`
+	result, err := tx.NamedExec(query, category)
+	if err != nil {
+		return fmt.Errorf("UpdateCategoryWithTx: ошибка обновления категории ID %d, SharedDBID %d: %w", category.Id, category.DatabaseId, err)
+	}
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return sql.ErrNoRows // Не найдено для обновления
+	}
+	return nil
+}
+
+// DeleteCategoryWithTx удаляет категорию в рамках транзакции.
+func DeleteCategoryWithTx(tx *sqlx.Tx, id int64, sharedDbID int64) error {
+	result, err := tx.Exec(`DELETE FROM Categories WHERE Id = ? AND DatabaseId = ?`, id, sharedDbID)
+	if err != nil {
+		return fmt.Errorf("DeleteCategoryWithTx: ошибка удаления категории ID %d, SharedDBID %d: %w", id, sharedDbID, err)
+	}
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return sql.ErrNoRows // Не найдено для удаления
+	}
+	return nil
+}
+
+// GetAllCategoryIDsForSharedDBWithTx извлекает ID всех категорий совместной БД в рамках транзакции.
+func GetAllCategoryIDsForSharedDBWithTx(tx *sqlx.Tx, sharedDbID int64) ([]int64, error) {
+	ids := []int64{}
+	err := tx.Select(&ids, `SELECT Id FROM Categories WHERE DatabaseId = ?`, sharedDbID)
+	if err != nil {
+		return nil, fmt.Errorf("GetAllCategoryIDsForSharedDBWithTx: ошибка получения ID для SharedDBID %d: %w", sharedDbID, err)
+	}
+	return ids, nil
+}
+
+// GetCategoriesBySharedDBIDWithTx извлекает все категории совместной БД в рамках транзакции.
+func GetCategoriesBySharedDBIDWithTx(tx *sqlx.Tx, sharedDbID int64) ([]models.Category, error) {
+	categories := []models.Category{}
+	query := `SELECT Id, DatabaseId, Name, Color, CreatedAt, UpdatedAt
+	          FROM Categories WHERE DatabaseId = ? ORDER BY Name ASC`
+	err := tx.Select(&categories, query, sharedDbID)
+	if err != nil {
+		return nil, fmt.Errorf("GetCategoriesBySharedDBIDWithTx: ошибка получения категорий для SharedDBID %d: %w", sharedDbID, err)
+	}
+	return categories, nil
+}
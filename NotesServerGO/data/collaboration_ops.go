@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"notes_server_go/models"
+	"notes_server_go/schedule"
 
 	"github.com/jmoiron/sqlx"
 )
@@ -27,9 +28,9 @@ func CreateSharedDatabase(db *models.SharedDatabase) (int64, error) {
 	}
 	defer tx.Rollback() // Откатываем, если что-то пошло не так
 
-	queryDb := `INSERT INTO SharedDatabases (Name, OwnerUserId, CreatedAt, UpdatedAt)
-	            VALUES (?, ?, ?, ?)`
-	result, err := tx.Exec(queryDb, db.Name, db.OwnerUserId, db.CreatedAt, db.UpdatedAt)
+	queryDb := `INSERT INTO SharedDatabases (Name, OwnerUserId, CreatedAt, UpdatedAt, TimeZone)
+	            VALUES (?, ?, ?, ?, ?)`
+	result, err := tx.Exec(queryDb, db.Name, db.OwnerUserId, db.CreatedAt, db.UpdatedAt, db.TimeZone)
 	if err != nil {
 		return 0, fmt.Errorf("failed to insert shared database: %w", err)
 	}
@@ -70,7 +71,7 @@ func GetSharedDatabaseByID(sdbID int64, userID int64) (*models.SharedDatabase, e
 		return nil, nil // Нет доступа или БД не существует для этого пользователя
 	}
 
-	queryGet := `SELECT Id, Name, OwnerUserId, CreatedAt, UpdatedAt FROM SharedDatabases WHERE Id = ?`
+	queryGet := `SELECT Id, Name, OwnerUserId, CreatedAt, UpdatedAt, TimeZone FROM SharedDatabases WHERE Id = ? AND DeletedAt IS NULL`
 	err = MainDB.Get(sdb, queryGet, sdbID)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -84,10 +85,10 @@ func GetSharedDatabaseByID(sdbID int64, userID int64) (*models.SharedDatabase, e
 // GetSharedDatabasesForUser извлекает все совместные БД, к которым пользователь имеет доступ.
 func GetSharedDatabasesForUser(userID int64) ([]models.SharedDatabase, error) {
 	var dbs []models.SharedDatabase
-	query := `SELECT sd.Id, sd.Name, sd.OwnerUserId, sd.CreatedAt, sd.UpdatedAt
+	query := `SELECT sd.Id, sd.Name, sd.OwnerUserId, sd.CreatedAt, sd.UpdatedAt, sd.TimeZone
 	          FROM SharedDatabases sd
 	          JOIN SharedDatabaseUsers sdu ON sd.Id = sdu.SharedDatabaseId
-	          WHERE sdu.UserId = ?
+	          WHERE sdu.UserId = ? AND sd.DeletedAt IS NULL
 	          ORDER BY sd.Name ASC`
 	err := MainDB.Select(&dbs, query, userID)
 	if err != nil {
@@ -125,10 +126,12 @@ func AddUserToSharedDatabase(sdbID int64, userToAddID int64, role models.SharedD
 }
 
 // GetUserRoleInSharedDatabase возвращает роль пользователя в указанной совместной БД.
-// Возвращает (nil, nil) если пользователь не состоит в БД.
+// Возвращает (nil, nil) если пользователь не состоит в БД или БД перемещена в корзину.
 func GetUserRoleInSharedDatabase(sdbID int64, userID int64) (*models.SharedDatabaseUserRole, error) {
 	var sdu models.SharedDatabaseUser
-	query := `SELECT Role FROM SharedDatabaseUsers WHERE SharedDatabaseId = ? AND UserId = ?`
+	query := `SELECT sdu.Role FROM SharedDatabaseUsers sdu
+	          JOIN SharedDatabases sd ON sd.Id = sdu.SharedDatabaseId
+	          WHERE sdu.SharedDatabaseId = ? AND sdu.UserId = ? AND sd.DeletedAt IS NULL`
 	err := MainDB.Get(&sdu, query, sdbID, userID)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -139,6 +142,15 @@ func GetUserRoleInSharedDatabase(sdbID int64, userID int64) (*models.SharedDatab
 	return &sdu.Role, nil
 }
 
+// GetSharedDatabaseMemberIDsWithTx возвращает ID участников совместной БД в рамках транзакции.
+func GetSharedDatabaseMemberIDsWithTx(tx *sqlx.Tx, sdbID int64) ([]int64, error) {
+	ids := []int64{}
+	if err := tx.Select(&ids, `SELECT UserId FROM SharedDatabaseUsers WHERE SharedDatabaseId = ?`, sdbID); err != nil {
+		return nil, fmt.Errorf("GetSharedDatabaseMemberIDsWithTx: ошибка получения участников БД %d: %w", sdbID, err)
+	}
+	return ids, nil
+}
+
 // RemoveUserFromSharedDatabase удаляет пользователя из совместной БД.
 // ИСПРАВЛЕНИЕ: Обновлена логика согласно новым требованиям разрешений
 func RemoveUserFromSharedDatabase(sdbID int64, userIDToRemove int64, currentUserID int64) error {
@@ -180,9 +192,15 @@ func RemoveUserFromSharedDatabase(sdbID int64, userIDToRemove int64, currentUser
 		return fmt.Errorf("user %d does not have permission to remove users from shared DB ID %d", currentUserID, sdbID)
 	}
 
-	// 4. Удалить пользователя
+	// 4. Удалить пользователя и снять его с записей расписания в одной транзакции
+	tx, err := MainDB.Beginx()
+	if err != nil {
+		return fmt.Errorf("RemoveUserFromSharedDatabase: failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
 	query := `DELETE FROM SharedDatabaseUsers WHERE SharedDatabaseId = ? AND UserId = ?`
-	result, err := MainDB.Exec(query, sdbID, userIDToRemove)
+	result, err := tx.Exec(query, sdbID, userIDToRemove)
 	if err != nil {
 		return fmt.Errorf("failed to remove user %d from shared DB ID %d: %w", userIDToRemove, sdbID, err)
 	}
@@ -190,6 +208,15 @@ func RemoveUserFromSharedDatabase(sdbID int64, userIDToRemove int64, currentUser
 	if rowsAffected == 0 {
 		return fmt.Errorf("user %d not found in shared DB ID %d or already removed", userIDToRemove, sdbID)
 	}
+	if err := ClearScheduleAssigneeForUserWithTx(tx, sdbID, userIDToRemove); err != nil {
+		return fmt.Errorf("RemoveUserFromSharedDatabase: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("RemoveUserFromSharedDatabase: failed to commit transaction: %w", err)
+	}
+	if err := DeleteCalendarFeedTokensForUser(sdbID, userIDToRemove); err != nil {
+		log.Printf("RemoveUserFromSharedDatabase: %v", err)
+	}
 	return nil
 }
 
@@ -264,7 +291,9 @@ func UpdateUserRoleInSharedDatabase(sdbID int64, userIDToUpdate int64, newRole m
 	return nil
 }
 
-// DeleteSharedDatabase удаляет совместную БД и все связанные с ней записи.
+// DeleteSharedDatabase перемещает совместную БД в корзину: она пропадает у всех участников,
+// а данные удаляются окончательно по истечении срока хранения корзины (см. PurgeExpiredTrash)
+// или вызовом PurgeSharedDatabase. До этого владелец может восстановить БД (RestoreSharedDatabase).
 // Только владелец может удалить БД.
 func DeleteSharedDatabase(sdbID int64, currentUserID int64) error {
 	tx, err := MainDB.Beginx() // Начинаем транзакцию
@@ -275,7 +304,7 @@ func DeleteSharedDatabase(sdbID int64, currentUserID int64) error {
 
 	// 1. Проверить, является ли currentUserID владельцем
 	var ownerID int64
-	queryOwner := `SELECT OwnerUserId FROM SharedDatabases WHERE Id = ?`
+	queryOwner := `SELECT OwnerUserId FROM SharedDatabases WHERE Id = ? AND DeletedAt IS NULL`
 	err = tx.Get(&ownerID, queryOwner, sdbID)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -287,28 +316,40 @@ func DeleteSharedDatabase(sdbID int64, currentUserID int64) error {
 		return fmt.Errorf("user %d is not the owner of shared DB ID %d and cannot delete it", currentUserID, sdbID)
 	}
 
-	// 2. Удалить все заметки, связанные с этой БД
+	// 2. Переместить БД в корзину
+	_, err = tx.Exec(`UPDATE SharedDatabases SET DeletedAt = ? WHERE Id = ?`, time.Now(), sdbID)
+	if err != nil {
+		return fmt.Errorf("failed to move shared database with ID %d to trash: %w", sdbID, err)
+	}
+
+	return tx.Commit()
+}
+
+// purgeSharedDatabaseWithTx окончательно удаляет совместную БД и все связанные с ней записи.
+// Файлы БД удаляются вызывающим после коммита (см. purgeSharedDatabase в trash_ops.go).
+func purgeSharedDatabaseWithTx(tx *sqlx.Tx, sdbID int64) error {
+	// 1. Удалить все заметки, связанные с этой БД
 	queryDeleteNotes := `DELETE FROM Notes WHERE DatabaseId = ?`
-	_, err = tx.Exec(queryDeleteNotes, sdbID)
+	_, err := tx.Exec(queryDeleteNotes, sdbID)
 	if err != nil {
 		return fmt.Errorf("failed to delete notes for shared DB ID %d: %w", sdbID, err)
 	}
 
-	// 3. Удалить все папки, связанные с этой БД
+	// 2. Удалить все папки, связанные с этой БД
 	queryDeleteFolders := `DELETE FROM Folders WHERE DatabaseId = ?`
 	_, err = tx.Exec(queryDeleteFolders, sdbID)
 	if err != nil {
 		return fmt.Errorf("failed to delete folders for shared DB ID %d: %w", sdbID, err)
 	}
 
-	// 4. Удалить всех пользователей из SharedDatabaseUsers
+	// 3. Удалить всех пользователей из SharedDatabaseUsers
 	queryDeleteUsers := `DELETE FROM SharedDatabaseUsers WHERE SharedDatabaseId = ?`
 	_, err = tx.Exec(queryDeleteUsers, sdbID)
 	if err != nil {
 		return fmt.Errorf("failed to delete users from shared DB ID %d: %w", sdbID, err)
 	}
 
-	// 5. Удалить саму SharedDatabase
+	// 4. Удалить саму SharedDatabase (остальные таблицы удаляются каскадно)
 	queryDeleteDb := `DELETE FROM SharedDatabases WHERE Id = ?`
 	result, err := tx.Exec(queryDeleteDb, sdbID)
 	if err != nil {
@@ -316,18 +357,16 @@ func DeleteSharedDatabase(sdbID int64, currentUserID int64) error {
 	}
 	rowsAffected, _ := result.RowsAffected()
 	if rowsAffected == 0 {
-		// Это не должно произойти, если проверка владельца прошла успешно
-		return fmt.Errorf("shared database with ID %d not found during final delete step", sdbID)
+		return sql.ErrNoRows
 	}
-
-	return tx.Commit()
+	return nil
 }
 
 // GetSharedDatabaseDetails извлекает детали SharedDatabase по ID без проверки доступа пользователя.
-// Используется внутри других функций data слоя, где доступ уже проверен или не требуется.
+// Используется внутри других функций data слоя, где доступ уже проверен или не требуется. Удаленные в корзину БД не возвращаются.
 func GetSharedDatabaseDetails(sdbID int64) (*models.SharedDatabase, error) {
 	sdb := &models.SharedDatabase{}
-	query := `SELECT Id, Name, OwnerUserId, CreatedAt, UpdatedAt FROM SharedDatabases WHERE Id = ?`
+	query := `SELECT Id, Name, OwnerUserId, CreatedAt, UpdatedAt, TimeZone FROM SharedDatabases WHERE Id = ? AND DeletedAt IS NULL`
 	err := MainDB.Get(sdb, query, sdbID)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -338,12 +377,25 @@ func GetSharedDatabaseDetails(sdbID int64) (*models.SharedDatabase, error) {
 	return sdb, nil
 }
 
+// UpdateSharedDatabaseTimeZone устанавливает часовой пояс расписания совместной БД.
+// Пустая строка возвращает часовой пояс сервера. Возвращает sql.ErrNoRows, если БД не найдена.
+func UpdateSharedDatabaseTimeZone(sdbID int64, timeZone string) error {
+	result, err := MainDB.Exec(`UPDATE SharedDatabases SET TimeZone = ?, UpdatedAt = ? WHERE Id = ?`, timeZone, time.Now(), sdbID)
+	if err != nil {
+		return fmt.Errorf("UpdateSharedDatabaseTimeZone: ошибка обновления часового пояса БД %d: %w", sdbID, err)
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
 // LeaveSharedDatabase удаляет пользователя из указанной совместной базы данных.
 // Если пользователь является владельцем, операция не допускается.
 func LeaveSharedDatabase(dbID int64, userID int64) error {
 	// Проверяем, не является ли пользователь владельцем
 	var ownerID int64
-	err := MainDB.QueryRow("SELECT OwnerUserId FROM SharedDatabases WHERE Id = ?", dbID).Scan(&ownerID)
+	err := MainDB.QueryRow("SELECT OwnerUserId FROM SharedDatabases WHERE Id = ? AND DeletedAt IS NULL", dbID).Scan(&ownerID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return fmt.Errorf("база данных с ID %d не найдена", dbID)
@@ -355,11 +407,25 @@ func LeaveSharedDatabase(dbID int64, userID int64) error {
 		return fmt.Errorf("владелец не может покинуть совместную базу данных. Удалите базу данных или передайте права владения.")
 	}
 
-	// Удаляем пользователя из таблицы участников
-	_, err = MainDB.Exec("DELETE FROM SharedDatabaseUsers WHERE SharedDatabaseId = ? AND UserId = ?", dbID, userID)
+	// Удаляем пользователя из таблицы участников и снимаем его с записей расписания в одной транзакции
+	tx, err := MainDB.Beginx()
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback()
+	_, err = tx.Exec("DELETE FROM SharedDatabaseUsers WHERE SharedDatabaseId = ? AND UserId = ?", dbID, userID)
 	if err != nil {
 		return fmt.Errorf("ошибка при удалении пользователя %d из совместной БД %d: %w", userID, dbID, err)
 	}
+	if err := ClearScheduleAssigneeForUserWithTx(tx, dbID, userID); err != nil {
+		return fmt.Errorf("LeaveSharedDatabase: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("ошибка коммита выхода пользователя %d из совместной БД %d: %w", userID, dbID, err)
+	}
+	if err := DeleteCalendarFeedTokensForUser(dbID, userID); err != nil {
+		log.Printf("LeaveSharedDatabase: %v", err)
+	}
 
 	log.Printf("Пользователь %d покинул совместную базу данных %d", userID, dbID)
 	return nil
@@ -439,10 +505,10 @@ func ImportSharedDatabase(externalDbIDStr string, userID int64) (*models.SharedD
 // извлекает все совместные БД по имени, к которым пользователь имеет доступ.
 func GetSharedDatabasesForUserByName(userID int64, name string) ([]models.SharedDatabase, error) {
 	var dbs []models.SharedDatabase
-	query := `SELECT sd.Id, sd.Name, sd.OwnerUserId, sd.CreatedAt, sd.UpdatedAt
+	query := `SELECT sd.Id, sd.Name, sd.OwnerUserId, sd.CreatedAt, sd.UpdatedAt, sd.TimeZone
 	          FROM SharedDatabases sd
 	          JOIN SharedDatabaseUsers sdu ON sd.Id = sdu.SharedDatabaseId
-	          WHERE sdu.UserId = ? AND sd.Name = ?
+	          WHERE sdu.UserId = ? AND sd.Name = ? AND sd.DeletedAt IS NULL
 	          ORDER BY sd.Name ASC`
 	err := MainDB.Select(&dbs, query, userID, name)
 	if err != nil {
@@ -500,6 +566,14 @@ func ExportSharedDatabase(dbID int64, userID int64) (*models.BackupData, error)
 	backupData.ScheduleEntries = scheduleEntries
 	log.Printf("ExportSharedDatabase: получено %d записей расписания для БД %d", len(scheduleEntries), dbID)
 
+	// Получение досок
+	pinboards, err := GetPinboardsBySharedDBID(dbID)
+	if err != nil {
+		log.Printf("ExportSharedDatabase: ошибка получения досок для БД %d: %v", dbID, err)
+		return nil, fmt.Errorf("ошибка получения досок для БД %d: %w", dbID, err)
+	}
+	backupData.Pinboards = pinboards
+
 	// Получение заметок с доски
 	log.Printf("ExportSharedDatabase: получение заметок с доски для БД %d", dbID)
 	pinboardNotes, err := GetPinboardNotesForDatabase(dbID)
@@ -567,6 +641,54 @@ func ExportSharedDatabase(dbID int64, userID int64) (*models.BackupData, error)
 	}
 	backupData.NoteImages = allNoteImages
 
+	// Получение умных папок
+	smartFolders, err := GetAllSmartFoldersBySharedDBID(dbID)
+	if err != nil {
+		log.Printf("ExportSharedDatabase: ошибка получения умных папок для БД %d: %v", dbID, err)
+		return nil, fmt.Errorf("ошибка получения умных папок для БД %d: %w", dbID, err)
+	}
+	backupData.SmartFolders = smartFolders
+
+	// Получение тегов заметок
+	noteTags, err := GetNoteTagsBySharedDBID(dbID)
+	if err != nil {
+		log.Printf("ExportSharedDatabase: ошибка получения тегов заметок для БД %d: %v", dbID, err)
+		return nil, fmt.Errorf("ошибка получения тегов заметок для БД %d: %w", dbID, err)
+	}
+	backupData.NoteTags = noteTags
+
+	// Получение категорий
+	categories, err := GetCategoriesBySharedDBID(dbID)
+	if err != nil {
+		log.Printf("ExportSharedDatabase: ошибка получения категорий для БД %d: %v", dbID, err)
+		return nil, fmt.Errorf("ошибка получения категорий для БД %d: %w", dbID, err)
+	}
+	backupData.Categories = categories
+
+	// Получение исключений повторяющихся записей расписания
+	scheduleExceptions, err := GetScheduleExceptionsBySharedDBID(dbID)
+	if err != nil {
+		log.Printf("ExportSharedDatabase: ошибка получения исключений расписания для БД %d: %v", dbID, err)
+		return nil, fmt.Errorf("ошибка получения исключений расписания для БД %d: %w", dbID, err)
+	}
+	backupData.ScheduleExceptions = scheduleExceptions
+
+	// Получение схемы динамических полей
+	dynamicFields, err := GetDynamicFieldDefinitionsBySharedDBID(dbID)
+	if err != nil {
+		log.Printf("ExportSharedDatabase: ошибка получения схемы полей для БД %d: %v", dbID, err)
+		return nil, fmt.Errorf("ошибка получения схемы полей для БД %d: %w", dbID, err)
+	}
+	backupData.DynamicFields = dynamicFields
+
+	// Получение ссылок между сущностями
+	entityLinks, err := GetEntityLinksBySharedDBID(dbID)
+	if err != nil {
+		log.Printf("ExportSharedDatabase: ошибка получения ссылок для БД %d: %v", dbID, err)
+		return nil, fmt.Errorf("ошибка получения ссылок для БД %d: %w", dbID, err)
+	}
+	backupData.EntityLinks = entityLinks
+
 	log.Printf("ExportSharedDatabase: данные для экспорта БД %d собраны для пользователя %d: %d папок, %d заметок, %d записей расписания, %d заметок доски, %d соединений, %d изображений",
 		dbID, userID, len(backupData.Folders), len(backupData.Notes), len(backupData.ScheduleEntries),
 		len(backupData.PinboardNotes), len(backupData.Connections), len(backupData.NoteImages))
@@ -574,22 +696,23 @@ func ExportSharedDatabase(dbID int64, userID int64) (*models.BackupData, error)
 }
 
 // RestoreSharedDatabaseFromBackup перезаписывает данные совместной БД из предоставленного бэкапа.
-// Требуются права на запись (владелец или редактор).
-func RestoreSharedDatabaseFromBackup(dbID int64, userID int64, backup *models.BackupData) error {
+// Требуются права на запись (владелец или редактор). Возвращает предупреждения о пропущенных данных бэкапа.
+func RestoreSharedDatabaseFromBackup(dbID int64, userID int64, backup *models.BackupData) ([]string, error) {
 	// 1. Проверить права доступа (владелец или редактор)
 	role, err := GetUserRoleInSharedDatabase(dbID, userID)
 	if err != nil {
-		return fmt.Errorf("ошибка проверки доступа пользователя %d к БД %d: %w", userID, dbID, err)
+		return nil, fmt.Errorf("ошибка проверки доступа пользователя %d к БД %d: %w", userID, dbID, err)
 	}
 	if role == nil || (*role != models.RoleOwner && *role != models.RoleCollaborator) {
-		return fmt.Errorf("пользователь %d не имеет прав на запись в БД %d", userID, dbID)
+		return nil, fmt.Errorf("пользователь %d не имеет прав на запись в БД %d", userID, dbID)
 	}
 
 	tx, err := MainDB.Beginx()
 	if err != nil {
-		return fmt.Errorf("RestoreFromBackup: failed to begin transaction for DB %d: %w", dbID, err)
+		return nil, fmt.Errorf("RestoreFromBackup: failed to begin transaction for DB %d: %w", dbID, err)
 	}
 	defer tx.Rollback()
+	var warnings []string // Пропущенные и исправленные при восстановлении данные
 
 	// 2. Очистить существующие данные для этой БД
 	// TODO: Добавить удаление файлов изображений с диска перед удалением записей из NoteImages
@@ -599,49 +722,116 @@ func RestoreSharedDatabaseFromBackup(dbID int64, userID int64, backup *models.Ba
 	queryNoteIDs := `SELECT Id FROM Notes WHERE DatabaseId = ?`
 	err = tx.Select(&noteIDsForDeletion, queryNoteIDs, dbID)
 	if err != nil && err != sql.ErrNoRows {
-		return fmt.Errorf("ошибка получения ID заметок для удаления изображений в БД %d: %w", dbID, err)
+		return nil, fmt.Errorf("ошибка получения ID заметок для удаления изображений в БД %d: %w", dbID, err)
 	}
 	if len(noteIDsForDeletion) > 0 {
 		// Здесь хорошо бы получить пути к файлам перед удалением записей, чтобы удалить и файлы
 		// Пока оставляем только удаление записей
 		queryDeleteImages, args, inErr := sqlx.In(`DELETE FROM NoteImages WHERE NoteId IN (?) AND DatabaseId = ?`, noteIDsForDeletion, dbID) // Добавил DatabaseId для точности
 		if inErr != nil {
-			return fmt.Errorf("ошибка построения запроса удаления изображений для БД %d: %w", dbID, inErr)
+			return nil, fmt.Errorf("ошибка построения запроса удаления изображений для БД %d: %w", dbID, inErr)
 		}
 		queryDeleteImages = tx.Rebind(queryDeleteImages)
 		if _, execErr := tx.Exec(queryDeleteImages, args...); execErr != nil {
-			return fmt.Errorf("ошибка удаления записей изображений для БД %d: %w", dbID, execErr)
+			return nil, fmt.Errorf("ошибка удаления записей изображений для БД %d: %w", dbID, execErr)
 		}
 	}
 
 	// 2.2 Удалить соединения
 	if _, err = tx.Exec(`DELETE FROM Connections WHERE DatabaseId = ?`, dbID); err != nil {
-		return fmt.Errorf("ошибка удаления соединений для БД %d: %w", dbID, err)
+		return nil, fmt.Errorf("ошибка удаления соединений для БД %d: %w", dbID, err)
 	}
 	// 2.3 Удалить заметки с доски
 	if _, err = tx.Exec(`DELETE FROM PinboardNotes WHERE DatabaseId = ?`, dbID); err != nil {
-		return fmt.Errorf("ошибка удаления заметок с доски для БД %d: %w", dbID, err)
+		return nil, fmt.Errorf("ошибка удаления заметок с доски для БД %d: %w", dbID, err)
 	}
 	// 2.4 Удалить записи расписания
 	if _, err = tx.Exec(`DELETE FROM ScheduleEntries WHERE DatabaseId = ?`, dbID); err != nil {
-		return fmt.Errorf("ошибка удаления записей расписания для БД %d: %w", dbID, err)
+		return nil, fmt.Errorf("ошибка удаления записей расписания для БД %d: %w", dbID, err)
+	}
+	// 2.5 Удалить заметки. Прежнее состояние заметок нужно для истории версий: заметка из бэкапа
+	// восстанавливается с прежним ID, если он свободен, и ее изменение сохраняется как версия
+	notesBeforeRestore, err := GetAllNotesBySharedDBIDWithTx(tx, dbID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения заметок БД %d перед восстановлением: %w", dbID, err)
+	}
+	noteBeforeRestore := make(map[int64]*models.Note, len(notesBeforeRestore))
+	for i := range notesBeforeRestore {
+		noteBeforeRestore[notesBeforeRestore[i].ID] = &notesBeforeRestore[i]
 	}
-	// 2.5 Удалить заметки
 	if _, err = tx.Exec(`DELETE FROM Notes WHERE DatabaseId = ?`, dbID); err != nil {
-		return fmt.Errorf("ошибка удаления заметок для БД %d: %w", dbID, err)
+		return nil, fmt.Errorf("ошибка удаления заметок для БД %d: %w", dbID, err)
 	}
 	// 2.6 Удалить папки
 	if _, err = tx.Exec(`DELETE FROM Folders WHERE DatabaseId = ?`, dbID); err != nil {
-		return fmt.Errorf("ошибка удаления папок для БД %d: %w", dbID, err)
+		return nil, fmt.Errorf("ошибка удаления папок для БД %d: %w", dbID, err)
+	}
+
+	// 2.7 Удалить умные папки, если бэкап их содержит (в старых бэкапах поля нет)
+	if backup.SmartFolders != nil {
+		if _, err = tx.Exec(`DELETE FROM SmartFolders WHERE DatabaseId = ?`, dbID); err != nil {
+			return nil, fmt.Errorf("ошибка удаления умных папок для БД %d: %w", dbID, err)
+		}
+	}
+
+	// 2.8 Удалить категории, если бэкап их содержит (в старых бэкапах поля нет)
+	if backup.Categories != nil {
+		if _, err = tx.Exec(`DELETE FROM Categories WHERE DatabaseId = ?`, dbID); err != nil {
+			return nil, fmt.Errorf("ошибка удаления категорий для БД %d: %w", dbID, err)
+		}
+	}
+
+	// 2.9 Удалить доски, если бэкап их содержит (в старых бэкапах поля нет; карточки уже удалены)
+	if backup.Pinboards != nil {
+		if _, err = tx.Exec(`DELETE FROM Pinboards WHERE DatabaseId = ?`, dbID); err != nil {
+			return nil, fmt.Errorf("ошибка удаления досок для БД %d: %w", dbID, err)
+		}
+	}
+
+	// 2.10 Заменить схему динамических полей, если бэкап ее содержит (в старых бэкапах поля нет)
+	if backup.DynamicFields != nil {
+		if _, err = tx.Exec(`DELETE FROM DynamicFieldDefinitions WHERE DatabaseId = ?`, dbID); err != nil {
+			return nil, fmt.Errorf("ошибка удаления схемы полей для БД %d: %w", dbID, err)
+		}
+		for _, definition := range backup.DynamicFields {
+			definition.DatabaseId = dbID
+			if _, err = CreateDynamicFieldDefinitionWithTx(tx, &definition); err != nil {
+				return nil, fmt.Errorf("ошибка вставки поля схемы %s: %w", definition.Name, err)
+			}
+		}
+	}
+
+	// 2.11 Удалить ссылки между сущностями, если бэкап их содержит (ссылки удаленных выше сущностей уже удалены триггерами)
+	if backup.EntityLinks != nil {
+		if _, err = tx.Exec(`DELETE FROM EntityLinks WHERE DatabaseId = ?`, dbID); err != nil {
+			return nil, fmt.Errorf("ошибка удаления ссылок для БД %d: %w", dbID, err)
+		}
 	}
 
 	// 3. Вставить новые данные
 	// Для каждой категории данных, проходимся по списку и вставляем.
 	// Важно: присваиваем userID и dbID каждой записи перед вставкой.
-	// Это упрощенная вставка, в реальности нужно обрабатывать ParentId для папок и т.д.
 
-	for _, folder := range backup.Folders {
+	// Соответствие ID папок из бэкапа новым ID, чтобы перенести parent_id папок, folder_id заметок и концы ссылок.
+	// Папки вставляются начиная с родительских, чтобы ID родителя был известен при вставке вложенной папки
+	backupToNewFolderID := make(map[int64]int64, len(backup.Folders))
+	// restoreFolderID переводит ID папки из бэкапа в ID восстановленной папки. Прежние папки БД уже удалены,
+	// поэтому ссылка на папку не из бэкапа (или на родителя в цикле) сбрасывается с предупреждением
+	restoreFolderID := func(folderID *int64, owner string, field string) *int64 {
+		if folderID == nil {
+			return nil
+		}
+		if newID, ok := backupToNewFolderID[*folderID]; ok {
+			return &newID
+		}
+		warning := fmt.Sprintf("у %s сброшен %s %d: такой папки нет в бэкапе", owner, field, *folderID)
+		log.Printf("Предупреждение: RestoreBackup: %s", warning)
+		warnings = append(warnings, warning)
+		return nil
+	}
+	for _, folder := range foldersParentsFirst(backup.Folders) {
 		folder.DatabaseID = dbID
+		folder.ParentID = restoreFolderID(folder.ParentID, fmt.Sprintf("папки %d", folder.ID), "parent_id")
 		query := `INSERT INTO Folders (Name, ParentId, CreatedAt, UpdatedAt, DatabaseId, Color, IsExpanded)
 		          VALUES (?, ?, ?, ?, ?, ?, ?)`
 		// Убедимся, что CreatedAt и UpdatedAt не нулевые (хотя клиент должен их слать)
@@ -652,12 +842,54 @@ func RestoreSharedDatabaseFromBackup(dbID int64, userID int64, backup *models.Ba
 			folder.UpdatedAt = time.Now()
 		}
 
-		_, err = tx.Exec(query, folder.Name, folder.ParentID, folder.CreatedAt, folder.UpdatedAt, folder.DatabaseID, folder.Color, bool(folder.IsExpanded))
-		if err != nil {
-			return fmt.Errorf("ошибка вставки папки %s: %w", folder.Name, err)
+		result, insertErr := tx.Exec(query, folder.Name, folder.ParentID, folder.CreatedAt, folder.UpdatedAt, folder.DatabaseID, folder.Color, bool(folder.IsExpanded))
+		if insertErr != nil {
+			return nil, fmt.Errorf("ошибка вставки папки %s: %w", folder.Name, insertErr)
+		}
+		newFolderID, idErr := result.LastInsertId()
+		if idErr != nil {
+			return nil, fmt.Errorf("ошибка получения ID папки %s: %w", folder.Name, idErr)
+		}
+		backupToNewFolderID[folder.ID] = newFolderID
+	}
+
+	// Соответствие ID категорий из бэкапа новым ID, чтобы перенести category_id заметок и записей расписания
+	backupToNewCategoryID := make(map[int64]int64, len(backup.Categories))
+	for _, category := range backup.Categories {
+		category.DatabaseId = dbID
+		if category.CreatedAt.IsZero() {
+			category.CreatedAt = models.FlexibleTime{Time: time.Now()}
+		}
+		newCategoryID, createErr := CreateCategoryWithTx(tx, &category)
+		if createErr != nil {
+			return nil, fmt.Errorf("ошибка вставки категории %s: %w", category.Name, createErr)
 		}
+		backupToNewCategoryID[category.Id] = newCategoryID
+	}
+	// restoreCategoryID переводит category_id из бэкапа в ID восстановленной категории.
+	// Для старых бэкапов без категорий сохраняется ссылка на уже существующую категорию этой БД.
+	restoreCategoryID := func(categoryID *int64) (*int64, error) {
+		if categoryID == nil {
+			return nil, nil
+		}
+		if newID, ok := backupToNewCategoryID[*categoryID]; ok {
+			return &newID, nil
+		}
+		if backup.Categories == nil {
+			existing, getErr := GetCategoryByIDWithTx(tx, *categoryID, dbID)
+			if getErr != nil {
+				return nil, getErr
+			}
+			if existing != nil {
+				return categoryID, nil
+			}
+		}
+		log.Printf("Предупреждение: RestoreBackup: категория %d отсутствует в бэкапе, category_id обнулен", *categoryID)
+		return nil, nil
 	}
 
+	// Соответствие ID заметок из бэкапа новым ID, чтобы перенести ссылки на заметки (теги, изображения)
+	backupToNewNoteID := make(map[int64]int64, len(backup.Notes))
 	for _, note := range backup.Notes {
 		note.DatabaseID = dbID
 		// Убедимся, что CreatedAt и UpdatedAt не нулевые
@@ -674,33 +906,168 @@ func RestoreSharedDatabaseFromBackup(dbID int64, userID int64, backup *models.Ba
 		// которые мы переименовали в модели.
 		// n.ImagesJson (тег json:"images") и n.MetadataJson (тег json:"metadata") должны заполниться при анмаршалинге.
 
-		query := `INSERT INTO Notes (Title, Content, CreatedAt, UpdatedAt, FolderId, DatabaseId, ImagesJson, MetadataJson, ContentJson)
-		          VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
-		_, err = tx.Exec(query, note.Title, note.Content, note.CreatedAt, note.UpdatedAt, note.FolderID, note.DatabaseID,
+		if note.CategoryId, err = restoreCategoryID(note.CategoryId); err != nil {
+			return nil, fmt.Errorf("ошибка проверки категории заметки %s: %w", note.Title, err)
+		}
+		note.FolderID = restoreFolderID(note.FolderID, fmt.Sprintf("заметки %d", note.ID), "folder_id")
+
+		noteID, idErr := freeIDOrNil(tx, "Notes", note.ID)
+		if idErr != nil {
+			return nil, fmt.Errorf("ошибка проверки ID заметки %s: %w", note.Title, idErr)
+		}
+		query := `INSERT INTO Notes (Id, Title, Content, CreatedAt, UpdatedAt, FolderId, CategoryId, DatabaseId, ImagesJson, MetadataJson, ContentJson)
+		          VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+		result, insertErr := tx.Exec(query, noteID, note.Title, note.Content, note.CreatedAt, note.UpdatedAt, note.FolderID, note.CategoryId, note.DatabaseID,
 			note.ImagesJson, note.MetadataJson, note.ContentJson)
-		if err != nil {
-			return fmt.Errorf("ошибка вставки заметки %s: %w", note.Title, err)
+		if insertErr != nil {
+			return nil, fmt.Errorf("ошибка вставки заметки %s: %w", note.Title, insertErr)
+		}
+		newNoteID, idErr := result.LastInsertId()
+		if idErr != nil {
+			return nil, fmt.Errorf("ошибка получения ID восстановленной заметки %s: %w", note.Title, idErr)
+		}
+		backupToNewNoteID[note.ID] = newNoteID
+
+		restoredNote := note
+		restoredNote.ID = newNoteID
+		if _, err = RecordNoteRevisionWithTx(tx, noteBeforeRestore[newNoteID], &restoredNote, &userID, models.NoteRevisionReasonBackup); err != nil {
+			return nil, fmt.Errorf("ошибка сохранения версии восстановленной заметки %s: %w", note.Title, err)
+		}
+	}
+
+	// Восстановление тегов заметок
+	if len(backup.NoteTags) > 0 {
+		restoredTags := make([]models.NoteTag, 0, len(backup.NoteTags))
+		for _, tag := range backup.NoteTags {
+			newNoteID, ok := backupToNewNoteID[tag.NoteId]
+			if !ok {
+				log.Printf("Предупреждение: RestoreBackup: пропуск тега '%s' - заметка %d отсутствует в бэкапе", tag.Tag, tag.NoteId)
+				continue
+			}
+			tag.NoteId = newNoteID
+			restoredTags = append(restoredTags, tag)
+		}
+		if err = ReplaceNoteTagsWithTx(tx, dbID, restoredTags); err != nil {
+			return nil, fmt.Errorf("ошибка восстановления тегов заметок: %w", err)
 		}
 	}
 
 	// Восстановление записей расписания
+	memberIDs, err := GetSharedDatabaseMemberIDsWithTx(tx, dbID)
+	if err != nil {
+		return nil, err
+	}
+	isMember := make(map[int64]bool, len(memberIDs))
+	for _, id := range memberIDs {
+		isMember[id] = true
+	}
+	// Схема полей уже восстановлена выше (или осталась прежней)
+	fieldDefinitions, err := GetDynamicFieldDefinitionsBySharedDBIDWithTx(tx, dbID)
+	if err != nil {
+		return nil, err
+	}
+	backupToNewEntryID := make(map[int64]int64, len(backup.ScheduleEntries))
 	for _, entry := range backup.ScheduleEntries {
 		entry.DatabaseId = dbID
+		// Записи с неверными датой, временем или часовым поясом пропускаются: они блокировали бы синхронизацию БД
+		if normalizeErr := schedule.NormalizeEntry(&entry); normalizeErr != nil {
+			warning := fmt.Sprintf("запись расписания %d пропущена: %v", entry.Id, normalizeErr)
+			log.Printf("Предупреждение: RestoreBackup: %s", warning)
+			warnings = append(warnings, warning)
+			continue
+		}
+		// Ответственный, не состоящий в БД, сбрасывается: иначе запись не прошла бы синхронизацию
+		if entry.AssigneeUserId != nil && !isMember[*entry.AssigneeUserId] {
+			warning := fmt.Sprintf("у записи расписания %d сброшен ответственный %d: он не является участником БД", entry.Id, *entry.AssigneeUserId)
+			log.Printf("Предупреждение: RestoreBackup: %s", warning)
+			warnings = append(warnings, warning)
+			entry.AssigneeUserId = nil
+		}
+		// Значения, не соответствующие схеме, сохраняются как есть: синхронизация их не изменяет, пока их не правят
+		if fieldsJson, fieldsErr := schedule.ValidateDynamicFields(entry.DynamicFieldsJson, fieldDefinitions); fieldsErr != nil {
+			warning := fmt.Sprintf("динамические поля записи расписания %d не соответствуют схеме: %v", entry.Id, fieldsErr)
+			log.Printf("Предупреждение: RestoreBackup: %s", warning)
+			warnings = append(warnings, warning)
+		} else {
+			entry.DynamicFieldsJson = fieldsJson
+		}
 		if entry.CreatedAt.IsZero() {
 			entry.CreatedAt = time.Now()
 		}
 		if entry.UpdatedAt.IsZero() {
 			entry.UpdatedAt = time.Now()
 		}
-		query := `INSERT INTO ScheduleEntries (Time, Date, Note, DynamicFieldsJson, RecurrenceJson, TagsJson, CreatedAt, UpdatedAt, DatabaseId)
-		          VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
-		_, err = tx.Exec(query, entry.Time, entry.Date, entry.Note, entry.DynamicFieldsJson, entry.RecurrenceJson, entry.TagsJson, entry.CreatedAt, entry.UpdatedAt, entry.DatabaseId)
-		if err != nil {
+		if entry.CategoryId, err = restoreCategoryID(entry.CategoryId); err != nil {
+			return nil, fmt.Errorf("ошибка проверки категории записи расписания (ID: %d): %w", entry.Id, err)
+		}
+		query := `INSERT INTO ScheduleEntries (Time, Date, Note, DynamicFieldsJson, RecurrenceJson, TagsJson, CategoryId, TimeZone, AssigneeUserId, CreatedByUserId, CreatedAt, UpdatedAt, DatabaseId)
+		          VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+		result, insertErr := tx.Exec(query, entry.Time, entry.Date, entry.Note, entry.DynamicFieldsJson, entry.RecurrenceJson, entry.TagsJson, entry.CategoryId, entry.TimeZone, entry.AssigneeUserId, entry.CreatedByUserId, entry.CreatedAt, entry.UpdatedAt, entry.DatabaseId)
+		if insertErr != nil {
 			log.Printf("Ошибка вставки записи расписания: %+v\n", entry)
-			return fmt.Errorf("ошибка вставки записи расписания (ID: %d): %w", entry.Id, err)
+			return nil, fmt.Errorf("ошибка вставки записи расписания (ID: %d): %w", entry.Id, insertErr)
+		}
+		newEntryID, idErr := result.LastInsertId()
+		if idErr != nil {
+			return nil, fmt.Errorf("ошибка получения ID восстановленной записи расписания (ID: %d): %w", entry.Id, idErr)
+		}
+		if err = rebuildScheduleTags(tx, newEntryID, dbID, entry.TagsJson); err != nil {
+			return nil, fmt.Errorf("ошибка восстановления тегов записи расписания: %w", err)
+		}
+		backupToNewEntryID[entry.Id] = newEntryID
+	}
+
+	// Восстановление исключений повторяющихся записей (записи расписания пересозданы, старые исключения удалены каскадно)
+	if len(backup.ScheduleExceptions) > 0 {
+		restoredExceptions := make([]models.ScheduleEntryException, 0, len(backup.ScheduleExceptions))
+		for _, exception := range backup.ScheduleExceptions {
+			newEntryID, ok := backupToNewEntryID[exception.ScheduleEntryId]
+			if !ok {
+				log.Printf("Предупреждение: RestoreBackup: пропуск исключения на %s - запись %d отсутствует в бэкапе", exception.OriginalDate, exception.ScheduleEntryId)
+				continue
+			}
+			exception.ScheduleEntryId = newEntryID
+			restoredExceptions = append(restoredExceptions, exception)
+		}
+		if err = ReplaceScheduleExceptionsWithTx(tx, dbID, restoredExceptions); err != nil {
+			return nil, fmt.Errorf("ошибка восстановления исключений расписания: %w", err)
 		}
 	}
 
+	// Соответствие ID досок из бэкапа новым ID, чтобы перенести pinboard_id карточек
+	backupToNewPinboardID := make(map[int64]int64, len(backup.Pinboards))
+	for _, board := range backup.Pinboards {
+		board.DatabaseId = dbID
+		if board.CreatedAt.IsZero() {
+			board.CreatedAt = models.FlexibleTime{Time: time.Now()}
+		}
+		newPinboardID, createErr := CreatePinboardWithTx(tx, &board)
+		if createErr != nil {
+			return nil, fmt.Errorf("ошибка вставки доски %s: %w", board.Name, createErr)
+		}
+		backupToNewPinboardID[board.Id] = newPinboardID
+	}
+	// restorePinboardID переводит pinboard_id карточки из бэкапа в ID восстановленной доски.
+	// Для старых бэкапов без досок сохраняется ссылка на уже существующую доску этой БД,
+	// остальные карточки попадают на доску по умолчанию.
+	restorePinboardID := func(pinboardID int64) (int64, error) {
+		if newID, ok := backupToNewPinboardID[pinboardID]; ok {
+			return newID, nil
+		}
+		if backup.Pinboards == nil && pinboardID > 0 {
+			existing, getErr := GetPinboardByIDWithTx(tx, pinboardID, dbID)
+			if getErr != nil {
+				return 0, getErr
+			}
+			if existing != nil {
+				return pinboardID, nil
+			}
+		}
+		return GetDefaultPinboardIDWithTx(tx, dbID)
+	}
+
+	// Соответствие ID карточек из бэкапа новым ID, чтобы перенести концы связей
+	backupToNewPinboardNoteID := make(map[int64]int64, len(backup.PinboardNotes))
 	for _, pNote := range backup.PinboardNotes {
 		pNote.DatabaseId = dbID
 		if pNote.CreatedAt.IsZero() {
@@ -709,13 +1076,21 @@ func RestoreSharedDatabaseFromBackup(dbID int64, userID int64, backup *models.Ba
 		if pNote.UpdatedAt.IsZero() {
 			pNote.UpdatedAt = time.Now()
 		}
-		query := `INSERT INTO PinboardNotes (Title, Content, CreatedAt, UpdatedAt, DatabaseId, PositionX, PositionY, Width, Height, BackgroundColor, IconCodePoint)
-		          VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
-		_, err = tx.Exec(query, pNote.Title, pNote.Content, pNote.CreatedAt, pNote.UpdatedAt, pNote.DatabaseId,
+		if pNote.PinboardId, err = restorePinboardID(pNote.PinboardId); err != nil {
+			return nil, fmt.Errorf("ошибка определения доски для заметки с доски %s: %w", pNote.Title, err)
+		}
+		query := `INSERT INTO PinboardNotes (Title, Content, CreatedAt, UpdatedAt, DatabaseId, PinboardId, PositionX, PositionY, Width, Height, BackgroundColor, IconCodePoint)
+		          VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+		result, insertErr := tx.Exec(query, pNote.Title, pNote.Content, pNote.CreatedAt, pNote.UpdatedAt, pNote.DatabaseId, pNote.PinboardId,
 			pNote.PositionX, pNote.PositionY, pNote.Width, pNote.Height, pNote.BackgroundColor, pNote.IconCodePoint)
-		if err != nil {
-			return fmt.Errorf("ошибка вставки заметки с доски %s: %w", pNote.Title, err)
+		if insertErr != nil {
+			return nil, fmt.Errorf("ошибка вставки заметки с доски %s: %w", pNote.Title, insertErr)
+		}
+		newPinboardNoteID, idErr := result.LastInsertId()
+		if idErr != nil {
+			return nil, fmt.Errorf("ошибка получения ID заметки с доски %s: %w", pNote.Title, idErr)
 		}
+		backupToNewPinboardNoteID[pNote.Id] = newPinboardNoteID
 	}
 
 	for _, conn := range backup.Connections {
@@ -726,17 +1101,60 @@ func RestoreSharedDatabaseFromBackup(dbID int64, userID int64, backup *models.Ba
 		if conn.UpdatedAt.IsZero() {
 			conn.UpdatedAt = time.Now()
 		}
-		query := `INSERT INTO Connections (SourceNoteId, TargetNoteId, Label, CreatedAt, UpdatedAt, DatabaseId, ConnectionColor)
+		fromNoteID, fromOK := backupToNewPinboardNoteID[conn.FromNoteId]
+		toNoteID, toOK := backupToNewPinboardNoteID[conn.ToNoteId]
+		if !fromOK || !toOK {
+			log.Printf("Предупреждение: RestoreBackup: пропуск соединения %d - заметки с доски %d или %d нет в бэкапе", conn.Id, conn.FromNoteId, conn.ToNoteId)
+			continue
+		}
+		query := `INSERT INTO Connections (FromNoteId, ToNoteId, Name, CreatedAt, UpdatedAt, DatabaseId, ConnectionColor)
 		          VALUES (?, ?, ?, ?, ?, ?, ?)`
-		_, err = tx.Exec(query, conn.FromNoteId, conn.ToNoteId, conn.Name, conn.CreatedAt, conn.UpdatedAt, conn.DatabaseId, conn.ConnectionColor)
+		_, err = tx.Exec(query, fromNoteID, toNoteID, conn.Name, conn.CreatedAt, conn.UpdatedAt, conn.DatabaseId, conn.ConnectionColor)
 		if err != nil {
-			return fmt.Errorf("ошибка вставки соединения для заметки %d: %w", conn.FromNoteId, err)
+			return nil, fmt.Errorf("ошибка вставки соединения для заметки %d: %w", conn.FromNoteId, err)
+		}
+	}
+
+	// Ссылки между сущностями: оба конца переносятся на новые ID, ссылки на сущности не из бэкапа пропускаются
+	newEntityID := func(entityType models.EntityType, id int64) (int64, bool) {
+		var ids map[int64]int64
+		switch entityType {
+		case models.EntityNote:
+			ids = backupToNewNoteID
+		case models.EntityPinboardNote:
+			ids = backupToNewPinboardNoteID
+		case models.EntityScheduleEntry:
+			ids = backupToNewEntryID
+		case models.EntityFolder:
+			ids = backupToNewFolderID
+		}
+		newID, ok := ids[id]
+		return newID, ok
+	}
+	for _, link := range backup.EntityLinks {
+		sourceID, sourceOK := newEntityID(link.SourceType, link.SourceId)
+		targetID, targetOK := newEntityID(link.TargetType, link.TargetId)
+		if !sourceOK || !targetOK {
+			log.Printf("Предупреждение: RestoreBackup: пропуск ссылки %d - %s %d или %s %d нет в бэкапе", link.Id, link.SourceType, link.SourceId, link.TargetType, link.TargetId)
+			continue
+		}
+		link.DatabaseId = dbID
+		link.SourceId = sourceID
+		link.TargetId = targetID
+		if link.CreatedByUserId == nil {
+			link.CreatedByUserId = &userID
+		}
+		if _, err = CreateEntityLinkWithTx(tx, &link); err != nil {
+			return nil, fmt.Errorf("ошибка вставки ссылки %s %d -> %s %d: %w", link.SourceType, link.SourceId, link.TargetType, link.TargetId, err)
 		}
 	}
 
 	// Вставка изображений с сохранением файлов
 	for _, image := range backup.NoteImages {
 		image.DatabaseId = dbID // Устанавливаем ID текущей БД
+		if newNoteID, ok := backupToNewNoteID[image.NoteId]; ok {
+			image.NoteId = newNoteID
+		}
 
 		if image.FileName == "" {
 			log.Printf("Предупреждение: RestoreBackup: пропуск изображения для NoteId %d из-за отсутствия FileName", image.NoteId)
@@ -757,13 +1175,13 @@ func RestoreSharedDatabaseFromBackup(dbID int64, userID int64, backup *models.Ba
 
 		imageDir := filepath.Join("uploads", "shared_db_images", fmt.Sprintf("%d", dbID))
 		if mkdirErr := os.MkdirAll(imageDir, 0755); mkdirErr != nil {
-			return fmt.Errorf("ошибка создания директории для изображений %s: %w", imageDir, mkdirErr)
+			return nil, fmt.Errorf("ошибка создания директории для изображений %s: %w", imageDir, mkdirErr)
 		}
 
 		serverImagePath := filepath.Join(imageDir, image.FileName)
 
 		if writeErr := ioutil.WriteFile(serverImagePath, decodedImageData, 0644); writeErr != nil {
-			return fmt.Errorf("ошибка сохранения файла изображения %s: %w", serverImagePath, writeErr)
+			return nil, fmt.Errorf("ошибка сохранения файла изображения %s: %w", serverImagePath, writeErr)
 		}
 
 		if image.CreatedAt.IsZero() {
@@ -776,21 +1194,72 @@ func RestoreSharedDatabaseFromBackup(dbID int64, userID int64, backup *models.Ba
 		_, err = tx.Exec(query, image.NoteId, image.FileName, serverImagePath, image.CreatedAt, image.UpdatedAt, image.DatabaseId)
 		if err != nil {
 			os.Remove(serverImagePath)
-			return fmt.Errorf("ошибка вставки записи для изображения %s в БД: %w", image.FileName, err)
+			return nil, fmt.Errorf("ошибка вставки записи для изображения %s в БД: %w", image.FileName, err)
+		}
+	}
+
+	for _, smartFolder := range backup.SmartFolders {
+		if smartFolder.CreatedAt.IsZero() {
+			smartFolder.CreatedAt = time.Now()
+		}
+		if smartFolder.UpdatedAt.IsZero() {
+			smartFolder.UpdatedAt = time.Now()
+		}
+		if smartFolder.CreatedByUserId == 0 {
+			smartFolder.CreatedByUserId = userID
+		}
+		query := `INSERT INTO SmartFolders (DatabaseId, Name, Query, Color, CreatedByUserId, CreatedAt, UpdatedAt)
+		          VALUES (?, ?, ?, ?, ?, ?, ?)`
+		_, err = tx.Exec(query, dbID, smartFolder.Name, smartFolder.Query, smartFolder.Color, smartFolder.CreatedByUserId, smartFolder.CreatedAt, smartFolder.UpdatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка вставки умной папки %s: %w", smartFolder.Name, err)
 		}
 	}
 
+	// Вики-ссылки восстановленных заметок строятся заново по их тексту
+	if err = RebuildNoteWikiLinksWithTx(tx, dbID); err != nil {
+		return nil, fmt.Errorf("ошибка построения вики-ссылок для БД %d: %w", dbID, err)
+	}
+
 	// Обновляем UpdatedAt для самой SharedDatabase
 	if _, err = tx.Exec(`UPDATE SharedDatabases SET UpdatedAt = ? WHERE Id = ?`, time.Now(), dbID); err != nil {
-		return fmt.Errorf("ошибка обновления UpdatedAt для БД %d: %w", dbID, err)
+		return nil, fmt.Errorf("ошибка обновления UpdatedAt для БД %d: %w", dbID, err)
 	}
 
 	if err = tx.Commit(); err != nil {
-		return fmt.Errorf("RestoreFromBackup: failed to commit transaction for DB %d: %w", dbID, err)
+		return nil, fmt.Errorf("RestoreFromBackup: failed to commit transaction for DB %d: %w", dbID, err)
 	}
 
 	log.Printf("Данные для БД %d восстановлены из бэкапа пользователем %d", dbID, userID)
-	return nil
+	return warnings, nil
+}
+
+// foldersParentsFirst упорядочивает папки бэкапа так, чтобы родительская папка шла раньше вложенных.
+// В цикле родителей (некорректный бэкап) одна из папок окажется раньше своего родителя.
+func foldersParentsFirst(folders []models.Folder) []models.Folder {
+	indexByID := make(map[int64]int, len(folders))
+	for i, folder := range folders {
+		indexByID[folder.ID] = i
+	}
+	ordered := make([]models.Folder, 0, len(folders))
+	visited := make([]bool, len(folders))
+	var visit func(i int)
+	visit = func(i int) {
+		if visited[i] {
+			return
+		}
+		visited[i] = true
+		if parentID := folders[i].ParentID; parentID != nil {
+			if parent, ok := indexByID[*parentID]; ok {
+				visit(parent)
+			}
+		}
+		ordered = append(ordered, folders[i])
+	}
+	for i := range folders {
+		visit(i)
+	}
+	return ordered
 }
 
 // SharedDatabaseWithUsers представляет совместную базу данных с информацией о пользователях
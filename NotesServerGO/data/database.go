@@ -88,11 +88,51 @@ func InitMainDB() error {
 		return fmt.Errorf("failed to upgrade folder schema: %w", err)
 	}
 
+	// Обновляем схему для добавления недостающих полей в Notes
+	if err = EnsureNotesSchemaUpgrade(); err != nil {
+		return fmt.Errorf("failed to upgrade notes schema: %w", err)
+	}
+
 	// Обновляем схему для добавления недостающих полей в ScheduleEntries
 	if err = EnsureScheduleEntriesSchemaUpgrade(); err != nil {
 		return fmt.Errorf("failed to upgrade schedule entries schema: %w", err)
 	}
 
+	// Обновляем схему для добавления недостающих полей в SharedDatabases
+	if err = EnsureSharedDatabasesSchemaUpgrade(); err != nil {
+		return fmt.Errorf("failed to upgrade shared databases schema: %w", err)
+	}
+
+	// Обновляем схему для добавления недостающих полей в PinboardNotes
+	if err = EnsurePinboardNotesSchemaUpgrade(); err != nil {
+		return fmt.Errorf("failed to upgrade pinboard notes schema: %w", err)
+	}
+
+	// Переносим карточки, созданные до появления нескольких досок, на доску по умолчанию
+	if err = EnsureDefaultPinboards(); err != nil {
+		return fmt.Errorf("failed to migrate pinboard notes to default boards: %w", err)
+	}
+
+	// Добавляем в пространственный индекс карточки доски, созданные до его появления
+	if err = EnsurePinboardSpatialIndex(); err != nil {
+		return fmt.Errorf("failed to backfill pinboard spatial index: %w", err)
+	}
+
+	// Переносим в NoteTags теги из метаданных заметок, сохраненных до появления таблицы
+	if err = EnsureNoteTagsBackfill(); err != nil {
+		return fmt.Errorf("failed to backfill note tags: %w", err)
+	}
+
+	// Заполняем ScheduleTags по TagsJson записей, созданных до появления таблицы
+	if err = EnsureScheduleTagsBackfill(); err != nil {
+		return fmt.Errorf("failed to backfill schedule tags: %w", err)
+	}
+
+	// Строим вики-ссылки [[...]] заметок, сохраненных до появления таблицы NoteWikiLinks
+	if err = EnsureNoteWikiLinksBackfill(); err != nil {
+		return fmt.Errorf("failed to backfill note wiki links: %w", err)
+	}
+
 	return nil
 }
 
@@ -203,5 +243,146 @@ func EnsureScheduleEntriesSchemaUpgrade() error {
 		log.Printf("Добавлена колонка TagsJson в таблицу ScheduleEntries")
 	}
 
+	// Проверяем, есть ли поле CategoryId
+	var categoryIdColumnExists bool
+	err = MainDB.Get(&categoryIdColumnExists, `
+		SELECT COUNT(*) > 0 
+		FROM pragma_table_info('ScheduleEntries') 
+		WHERE name = 'CategoryId'
+	`)
+	if err != nil {
+		log.Printf("Ошибка проверки колонки CategoryId: %v", err)
+	} else if !categoryIdColumnExists {
+		_, err = MainDB.Exec(`ALTER TABLE ScheduleEntries ADD COLUMN CategoryId INTEGER REFERENCES Categories(Id) ON DELETE SET NULL`)
+		if err != nil {
+			return fmt.Errorf("failed to add CategoryId column to ScheduleEntries: %w", err)
+		}
+		log.Printf("Добавлена колонка CategoryId в таблицу ScheduleEntries")
+	}
+
+	// Проверяем, есть ли поле TimeZone
+	var timeZoneColumnExists bool
+	err = MainDB.Get(&timeZoneColumnExists, `
+		SELECT COUNT(*) > 0 
+		FROM pragma_table_info('ScheduleEntries') 
+		WHERE name = 'TimeZone'
+	`)
+	if err != nil {
+		log.Printf("Ошибка проверки колонки TimeZone: %v", err)
+	} else if !timeZoneColumnExists {
+		_, err = MainDB.Exec(`ALTER TABLE ScheduleEntries ADD COLUMN TimeZone TEXT`)
+		if err != nil {
+			return fmt.Errorf("failed to add TimeZone column to ScheduleEntries: %w", err)
+		}
+		log.Printf("Добавлена колонка TimeZone в таблицу ScheduleEntries")
+	}
+
+	// Проверяем, есть ли поля AssigneeUserId и CreatedByUserId (пользователи из AuthDB, без внешнего ключа)
+	for _, column := range []string{"AssigneeUserId", "CreatedByUserId"} {
+		var columnExists bool
+		err = MainDB.Get(&columnExists, `
+		SELECT COUNT(*) > 0 
+		FROM pragma_table_info('ScheduleEntries') 
+		WHERE name = ?
+	`, column)
+		if err != nil {
+			log.Printf("Ошибка проверки колонки %s: %v", column, err)
+		} else if !columnExists {
+			_, err = MainDB.Exec(`ALTER TABLE ScheduleEntries ADD COLUMN ` + column + ` INTEGER`)
+			if err != nil {
+				return fmt.Errorf("failed to add %s column to ScheduleEntries: %w", column, err)
+			}
+			log.Printf("Добавлена колонка %s в таблицу ScheduleEntries", column)
+		}
+	}
+
+	return nil
+}
+
+// EnsureSharedDatabasesSchemaUpgrade добавляет недостающие поля в таблицу SharedDatabases
+func EnsureSharedDatabasesSchemaUpgrade() error {
+	// Проверяем, есть ли поле TimeZone
+	var timeZoneColumnExists bool
+	err := MainDB.Get(&timeZoneColumnExists, `
+		SELECT COUNT(*) > 0 
+		FROM pragma_table_info('SharedDatabases') 
+		WHERE name = 'TimeZone'
+	`)
+	if err != nil {
+		log.Printf("Ошибка проверки колонки TimeZone: %v", err)
+	} else if !timeZoneColumnExists {
+		_, err = MainDB.Exec(`ALTER TABLE SharedDatabases ADD COLUMN TimeZone TEXT NOT NULL DEFAULT ''`)
+		if err != nil {
+			return fmt.Errorf("failed to add TimeZone column to SharedDatabases: %w", err)
+		}
+		log.Printf("Добавлена колонка TimeZone в таблицу SharedDatabases")
+	}
+
+	// Проверяем, есть ли поле DeletedAt (корзина совместных БД)
+	var deletedAtColumnExists bool
+	err = MainDB.Get(&deletedAtColumnExists, `
+		SELECT COUNT(*) > 0 
+		FROM pragma_table_info('SharedDatabases') 
+		WHERE name = 'DeletedAt'
+	`)
+	if err != nil {
+		log.Printf("Ошибка проверки колонки DeletedAt: %v", err)
+	} else if !deletedAtColumnExists {
+		_, err = MainDB.Exec(`ALTER TABLE SharedDatabases ADD COLUMN DeletedAt DATETIME`)
+		if err != nil {
+			return fmt.Errorf("failed to add DeletedAt column to SharedDatabases: %w", err)
+		}
+		log.Printf("Добавлена колонка DeletedAt в таблицу SharedDatabases")
+	}
+
+	return nil
+}
+
+// EnsureNotesSchemaUpgrade добавляет недостающие поля в таблицу Notes
+func EnsureNotesSchemaUpgrade() error {
+	// Проверяем, есть ли поле CategoryId
+	var categoryIdColumnExists bool
+	err := MainDB.Get(&categoryIdColumnExists, `
+		SELECT COUNT(*) > 0 
+		FROM pragma_table_info('Notes') 
+		WHERE name = 'CategoryId'
+	`)
+	if err != nil {
+		log.Printf("Ошибка проверки колонки CategoryId: %v", err)
+	} else if !categoryIdColumnExists {
+		_, err = MainDB.Exec(`ALTER TABLE Notes ADD COLUMN CategoryId INTEGER REFERENCES Categories(Id) ON DELETE SET NULL`)
+		if err != nil {
+			return fmt.Errorf("failed to add CategoryId column to Notes: %w", err)
+		}
+		log.Printf("Добавлена колонка CategoryId в таблицу Notes")
+	}
+
+	return nil
+}
+
+// EnsurePinboardNotesSchemaUpgrade добавляет недостающие поля в таблицу PinboardNotes
+func EnsurePinboardNotesSchemaUpgrade() error {
+	// Проверяем, есть ли поле PinboardId
+	var pinboardIdColumnExists bool
+	err := MainDB.Get(&pinboardIdColumnExists, `
+		SELECT COUNT(*) > 0 
+		FROM pragma_table_info('PinboardNotes') 
+		WHERE name = 'PinboardId'
+	`)
+	if err != nil {
+		log.Printf("Ошибка проверки колонки PinboardId: %v", err)
+	} else if !pinboardIdColumnExists {
+		_, err = MainDB.Exec(`ALTER TABLE PinboardNotes ADD COLUMN PinboardId INTEGER REFERENCES Pinboards(Id) ON DELETE CASCADE`)
+		if err != nil {
+			return fmt.Errorf("failed to add PinboardId column to PinboardNotes: %w", err)
+		}
+		log.Printf("Добавлена колонка PinboardId в таблицу PinboardNotes")
+	}
+
+	// Индекс создается здесь, а не в схеме: в старых БД колонка появляется только после ALTER TABLE
+	if _, err = MainDB.Exec(`CREATE INDEX IF NOT EXISTS IX_PinboardNotes_PinboardId ON PinboardNotes (PinboardId)`); err != nil {
+		return fmt.Errorf("failed to create PinboardId index on PinboardNotes: %w", err)
+	}
+
 	return nil
 }
@@ -0,0 +1,124 @@
+package data
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"notes_server_go/models"
+)
+
+// GetDigestSubscriptionByUserID извлекает настройки ежедневной сводки пользователя.
+func GetDigestSubscriptionByUserID(userID int64) (*models.DigestSubscription, error) {
+	subscription := &models.DigestSubscription{}
+	query := `SELECT Id, UserId, Enabled, SendTime, TimeZone, SendEmpty, LastSentDate, LastSentAt, CreatedAt, UpdatedAt
+	          FROM DigestSubscriptions WHERE UserId = ?`
+	err := MainDB.Get(subscription, query, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // Не найдено
+		}
+		return nil, fmt.Errorf("GetDigestSubscriptionByUserID: ошибка получения подписки пользователя %d: %w", userID, err)
+	}
+	return subscription, nil
+}
+
+// GetEnabledDigestSubscriptions извлекает все включенные подписки на ежедневную сводку.
+func GetEnabledDigestSubscriptions() ([]models.DigestSubscription, error) {
+	subscriptions := []models.DigestSubscription{}
+	query := `SELECT Id, UserId, Enabled, SendTime, TimeZone, SendEmpty, LastSentDate, LastSentAt, CreatedAt, UpdatedAt
+	          FROM DigestSubscriptions WHERE Enabled = 1 ORDER BY Id ASC`
+	if err := MainDB.Select(&subscriptions, query); err != nil {
+		return nil, fmt.Errorf("GetEnabledDigestSubscriptions: ошибка получения подписок: %w", err)
+	}
+	return subscriptions, nil
+}
+
+// SaveDigestSubscription создает или обновляет настройки ежедневной сводки пользователя
+// (у пользователя одна подписка). Поле subscription.UserId должно быть установлено.
+// Дата последней отправки сохраняется, чтобы изменение настроек не приводило к повторной сводке за день.
+func SaveDigestSubscription(subscription *models.DigestSubscription) error {
+	now := time.Now()
+	subscription.UpdatedAt = now
+	if subscription.CreatedAt.IsZero() {
+		subscription.CreatedAt = models.FlexibleTime{Time: now}
+	}
+
+	query := `INSERT INTO DigestSubscriptions (UserId, Enabled, SendTime, TimeZone, SendEmpty, CreatedAt, UpdatedAt)
+	          VALUES (:UserId, :Enabled, :SendTime, :TimeZone, :SendEmpty, :CreatedAt, :UpdatedAt)
+	          ON CONFLICT (UserId) DO UPDATE SET Enabled = excluded.Enabled, SendTime = excluded.SendTime,
+	              TimeZone = excluded.TimeZone, SendEmpty = excluded.SendEmpty, UpdatedAt = excluded.UpdatedAt`
+	if _, err := MainDB.NamedExec(query, subscription); err != nil {
+		return fmt.Errorf("SaveDigestSubscription: ошибка сохранения подписки пользователя %d: %w", subscription.UserId, err)
+	}
+	log.Printf("Сохранена подписка на ежедневную сводку пользователя %d (включена: %v, %s %s)",
+		subscription.UserId, subscription.Enabled, subscription.SendTime, subscription.TimeZone)
+	return nil
+}
+
+// DeleteDigestSubscription удаляет подписку пользователя на ежедневную сводку.
+func DeleteDigestSubscription(userID int64) error {
+	result, err := MainDB.Exec(`DELETE FROM DigestSubscriptions WHERE UserId = ?`, userID)
+	if err != nil {
+		return fmt.Errorf("DeleteDigestSubscription: ошибка удаления подписки пользователя %d: %w", userID, err)
+	}
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return sql.ErrNoRows // Не найдено для удаления
+	}
+	return nil
+}
+
+// ClaimDigest захватывает отправку сводки за дату date: возвращает false, если сводка за эту дату
+// уже отправлена или захвачена (в том числе до перезапуска сервера).
+func ClaimDigest(subscriptionID int64, date string) (bool, error) {
+	result, err := MainDB.Exec(`UPDATE DigestSubscriptions SET LastSentDate = ?
+	          WHERE Id = ? AND (LastSentDate IS NULL OR LastSentDate < ?)`, date, subscriptionID, date)
+	if err != nil {
+		return false, fmt.Errorf("ClaimDigest: ошибка захвата сводки подписки %d за %s: %w", subscriptionID, date, err)
+	}
+	rowsAffected, _ := result.RowsAffected()
+	return rowsAffected == 1, nil
+}
+
+// ReleaseDigest снимает захват неотправленной сводки за дату date, возвращая прежнюю дату последней отправки.
+func ReleaseDigest(subscriptionID int64, date string, previousDate *string) error {
+	_, err := MainDB.Exec(`UPDATE DigestSubscriptions SET LastSentDate = ? WHERE Id = ? AND LastSentDate = ?`,
+		previousDate, subscriptionID, date)
+	if err != nil {
+		return fmt.Errorf("ReleaseDigest: ошибка снятия захвата сводки подписки %d за %s: %w", subscriptionID, date, err)
+	}
+	return nil
+}
+
+// MarkDigestSent запоминает время отправки сводки: следующая сводка покажет заметки, измененные после него.
+func MarkDigestSent(subscriptionID int64, sentAt time.Time) error {
+	if _, err := MainDB.Exec(`UPDATE DigestSubscriptions SET LastSentAt = ? WHERE Id = ?`, sentAt, subscriptionID); err != nil {
+		return fmt.Errorf("MarkDigestSent: ошибка обновления подписки %d: %w", subscriptionID, err)
+	}
+	return nil
+}
+
+// GetNotesUpdatedSince возвращает заметки совместной БД, измененные после since, от новых к старым
+// (не больше limit). Возвращаются только Id, DatabaseId, Title, CreatedAt и UpdatedAt.
+// Время сравнивается в Go: даты в БД могут быть записаны со смещениями разных часовых поясов.
+func GetNotesUpdatedSince(sharedDbID int64, since time.Time, limit int) ([]models.Note, error) {
+	var notes []models.Note
+	query := `SELECT Id, DatabaseId, Title, CreatedAt, UpdatedAt FROM Notes WHERE DatabaseId = ?`
+	if err := MainDB.Select(&notes, query, sharedDbID); err != nil {
+		return nil, fmt.Errorf("GetNotesUpdatedSince: ошибка получения заметок для SharedDBID %d: %w", sharedDbID, err)
+	}
+	recent := make([]models.Note, 0, len(notes))
+	for _, note := range notes {
+		if note.UpdatedAt.After(since) {
+			recent = append(recent, note)
+		}
+	}
+	sort.Slice(recent, func(i, j int) bool { return recent[i].UpdatedAt.After(recent[j].UpdatedAt) })
+	if limit > 0 && len(recent) > limit {
+		recent = recent[:limit]
+	}
+	return recent, nil
+}
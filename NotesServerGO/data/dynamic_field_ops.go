@@ -0,0 +1,200 @@
+package data
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"notes_server_go/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// encodeDynamicFieldOptions переносит Options описания поля в OptionsJson перед записью в БД.
+func encodeDynamicFieldOptions(definition *models.DynamicFieldDefinition) error {
+	if len(definition.Options) == 0 {
+		definition.OptionsJson = nil
+		return nil
+	}
+	raw, err := json.Marshal(definition.Options)
+	if err != nil {
+		return err
+	}
+	optionsJson := string(raw)
+	definition.OptionsJson = &optionsJson
+	return nil
+}
+
+// decodeDynamicFieldOptions заполняет Options описаний полей по OptionsJson после чтения из БД.
+func decodeDynamicFieldOptions(definitions []models.DynamicFieldDefinition) {
+	for i := range definitions {
+		definition := &definitions[i]
+		definition.Options = []string{}
+		if definition.OptionsJson == nil || *definition.OptionsJson == "" {
+			continue
+		}
+		if err := json.Unmarshal([]byte(*definition.OptionsJson), &definition.Options); err != nil {
+			log.Printf("decodeDynamicFieldOptions: не удалось разобрать OptionsJson поля %d: %v", definition.Id, err)
+			definition.Options = []string{}
+		}
+	}
+}
+
+// CreateDynamicFieldDefinition добавляет поле в схему полей совместной БД.
+// Поле definition.DatabaseId должно быть установлено. Возвращает ID созданного описания.
+func CreateDynamicFieldDefinition(definition *models.DynamicFieldDefinition) (int64, error) {
+	return createDynamicFieldDefinition(MainDB, definition, "CreateDynamicFieldDefinition")
+}
+
+// CreateDynamicFieldDefinitionWithTx добавляет поле в схему полей в рамках транзакции.
+func CreateDynamicFieldDefinitionWithTx(tx *sqlx.Tx, definition *models.DynamicFieldDefinition) (int64, error) {
+	return createDynamicFieldDefinition(tx, definition, "CreateDynamicFieldDefinitionWithTx")
+}
+
+func createDynamicFieldDefinition(db sqlx.Ext, definition *models.DynamicFieldDefinition, funcName string) (int64, error) {
+	now := time.Now()
+	if definition.CreatedAt.IsZero() {
+		definition.CreatedAt = models.FlexibleTime{Time: now}
+	}
+	definition.UpdatedAt = now
+	if err := encodeDynamicFieldOptions(definition); err != nil {
+		return 0, fmt.Errorf("%s: ошибка кодирования вариантов поля '%s': %w", funcName, definition.Name, err)
+	}
+
+	query := `INSERT INTO DynamicFieldDefinitions (DatabaseId, Name, Type, Required, OptionsJson, Position, CreatedAt, UpdatedAt)
+	          VALUES (:DatabaseId, :Name, :Type, :Required, :OptionsJson, :Position, :CreatedAt, :UpdatedAt)`
+	result, err := sqlx.NamedExec(db, query, definition)
+	if err != nil {
+		return 0, fmt.Errorf("%s: ошибка вставки поля '%s': %w", funcName, definition.Name, err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: ошибка получения LastInsertId: %w", funcName, err)
+	}
+	return id, nil
+}
+
+// GetDynamicFieldDefinitionByID извлекает описание поля по его ID и ID совместной БД.
+func GetDynamicFieldDefinitionByID(id int64, sharedDbID int64) (*models.DynamicFieldDefinition, error) {
+	definitions := []models.DynamicFieldDefinition{}
+	query := `SELECT Id, DatabaseId, Name, Type, Required, OptionsJson, Position, CreatedAt, UpdatedAt
+	          FROM DynamicFieldDefinitions WHERE Id = ? AND DatabaseId = ?`
+	if err := MainDB.Select(&definitions, query, id, sharedDbID); err != nil {
+		return nil, fmt.Errorf("GetDynamicFieldDefinitionByID: ошибка получения поля ID %d, SharedDBID %d: %w", id, sharedDbID, err)
+	}
+	if len(definitions) == 0 {
+		return nil, nil // Не найдено
+	}
+	decodeDynamicFieldOptions(definitions)
+	return &definitions[0], nil
+}
+
+// GetDynamicFieldDefinitionByName извлекает описание поля по названию. Возвращает nil, если поля нет.
+func GetDynamicFieldDefinitionByName(name string, sharedDbID int64) (*models.DynamicFieldDefinition, error) {
+	definitions := []models.DynamicFieldDefinition{}
+	query := `SELECT Id, DatabaseId, Name, Type, Required, OptionsJson, Position, CreatedAt, UpdatedAt
+	          FROM DynamicFieldDefinitions WHERE Name = ? AND DatabaseId = ?`
+	if err := MainDB.Select(&definitions, query, name, sharedDbID); err != nil {
+		return nil, fmt.Errorf("GetDynamicFieldDefinitionByName: ошибка получения поля '%s', SharedDBID %d: %w", name, sharedDbID, err)
+	}
+	if len(definitions) == 0 {
+		return nil, nil // Не найдено
+	}
+	decodeDynamicFieldOptions(definitions)
+	return &definitions[0], nil
+}
+
+// GetDynamicFieldDefinitionsBySharedDBID извлекает схему полей совместной БД в порядке Position.
+func GetDynamicFieldDefinitionsBySharedDBID(sharedDbID int64) ([]models.DynamicFieldDefinition, error) {
+	return getDynamicFieldDefinitions(MainDB, sharedDbID, "GetDynamicFieldDefinitionsBySharedDBID")
+}
+
+// GetDynamicFieldDefinitionsBySharedDBIDWithTx извлекает схему полей совместной БД в рамках транзакции.
+func GetDynamicFieldDefinitionsBySharedDBIDWithTx(tx *sqlx.Tx, sharedDbID int64) ([]models.DynamicFieldDefinition, error) {
+	return getDynamicFieldDefinitions(tx, sharedDbID, "GetDynamicFieldDefinitionsBySharedDBIDWithTx")
+}
+
+func getDynamicFieldDefinitions(db sqlx.Queryer, sharedDbID int64, funcName string) ([]models.DynamicFieldDefinition, error) {
+	definitions := []models.DynamicFieldDefinition{}
+	query := `SELECT Id, DatabaseId, Name, Type, Required, OptionsJson, Position, CreatedAt, UpdatedAt
+	          FROM DynamicFieldDefinitions WHERE DatabaseId = ? ORDER BY Position ASC, Id ASC`
+	if err := sqlx.Select(db, &definitions, query, sharedDbID); err != nil {
+		return nil, fmt.Errorf("%s: ошибка получения схемы полей для SharedDBID %d: %w", funcName, sharedDbID, err)
+	}
+	decodeDynamicFieldOptions(definitions)
+	return definitions, nil
+}
+
+// UpdateDynamicFieldDefinition обновляет описание поля. Если поле переименовано (oldName != definition.Name),
+// ключ поля переименовывается и в DynamicFieldsJson записей расписания совместной БД.
+// Поля definition.Id и definition.DatabaseId должны быть установлены.
+func UpdateDynamicFieldDefinition(definition *models.DynamicFieldDefinition, oldName string) error {
+	definition.UpdatedAt = time.Now()
+	if err := encodeDynamicFieldOptions(definition); err != nil {
+		return fmt.Errorf("UpdateDynamicFieldDefinition: ошибка кодирования вариантов поля '%s': %w", definition.Name, err)
+	}
+
+	tx, err := MainDB.Beginx()
+	if err != nil {
+		return fmt.Errorf("UpdateDynamicFieldDefinition: ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE DynamicFieldDefinitions SET Name = :Name, Type = :Type, Required = :Required, OptionsJson = :OptionsJson,
+	          Position = :Position, UpdatedAt = :UpdatedAt
+	          WHERE Id = :Id AND DatabaseId = :DatabaseId`
+	result, err := tx.NamedExec(query, definition)
+	if err != nil {
+		return fmt.Errorf("UpdateDynamicFieldDefinition: ошибка обновления поля ID %d, SharedDBID %d: %w", definition.Id, definition.DatabaseId, err)
+	}
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return sql.ErrNoRows // Не найдено для обновления
+	}
+
+	if oldName != definition.Name {
+		// Значения объектов и массивов json_extract возвращает текстом - их нужно снова разобрать функцией json()
+		oldPath, newPath := dynamicFieldPath(oldName), dynamicFieldPath(definition.Name)
+		renamed, err := tx.Exec(`UPDATE ScheduleEntries
+		          SET DynamicFieldsJson = json_set(json_remove(DynamicFieldsJson, ?), ?,
+		              CASE WHEN json_type(DynamicFieldsJson, ?) IN ('object', 'array')
+		                   THEN json(json_extract(DynamicFieldsJson, ?)) ELSE json_extract(DynamicFieldsJson, ?) END),
+		              UpdatedAt = ?
+		          WHERE DatabaseId = ? AND json_valid(DynamicFieldsJson) AND json_type(DynamicFieldsJson, ?) IS NOT NULL`,
+			oldPath, newPath, oldPath, oldPath, oldPath, definition.UpdatedAt, definition.DatabaseId, oldPath)
+		if err != nil {
+			return fmt.Errorf("UpdateDynamicFieldDefinition: ошибка переименования поля '%s' в записях SharedDBID %d: %w", oldName, definition.DatabaseId, err)
+		}
+		count, _ := renamed.RowsAffected()
+		log.Printf("Поле '%s' переименовано в '%s' в %d записях расписания БД %d", oldName, definition.Name, count, definition.DatabaseId)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("UpdateDynamicFieldDefinition: ошибка коммита: %w", err)
+	}
+	log.Printf("Обновлено поле схемы с ID: %d для DatabaseId: %d", definition.Id, definition.DatabaseId)
+	return nil
+}
+
+// DeleteDynamicFieldDefinition удаляет поле из схемы полей совместной БД.
+// Значения поля в записях расписания сохраняются и больше не проверяются.
+func DeleteDynamicFieldDefinition(id int64, sharedDbID int64) error {
+	result, err := MainDB.Exec(`DELETE FROM DynamicFieldDefinitions WHERE Id = ? AND DatabaseId = ?`, id, sharedDbID)
+	if err != nil {
+		return fmt.Errorf("DeleteDynamicFieldDefinition: ошибка удаления поля ID %d, SharedDBID %d: %w", id, sharedDbID, err)
+	}
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return sql.ErrNoRows // Не найдено для удаления
+	}
+	log.Printf("Удалено поле схемы с ID: %d для DatabaseId: %d", id, sharedDbID)
+	return nil
+}
+
+// dynamicFieldPath возвращает путь JSON SQLite к ключу name. Название поля не содержит кавычек
+// (проверяется при сохранении схемы), поэтому ключ можно заключить в кавычки без экранирования.
+func dynamicFieldPath(name string) string {
+	return `$."` + name + `"`
+}
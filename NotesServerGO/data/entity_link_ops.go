@@ -0,0 +1,203 @@
+package data
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"notes_server_go/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// entityTables - таблица и выражение заголовка для каждого типа сущности, которую можно связать ссылкой.
+// У записи расписания заголовка нет: используется ее заметка, а без нее - дата и время.
+var entityTables = map[models.EntityType]struct {
+	table string
+	title string
+}{
+	models.EntityNote:          {table: "Notes", title: "%[1]s.Title"},
+	models.EntityPinboardNote:  {table: "PinboardNotes", title: "%[1]s.Title"},
+	models.EntityScheduleEntry: {table: "ScheduleEntries", title: "COALESCE(NULLIF(%[1]s.Note, ''), %[1]s.Date || ' ' || %[1]s.Time)"},
+	models.EntityFolder:        {table: "Folders", title: "%[1]s.Name"},
+}
+
+// EntityExists проверяет, что сущность указанного типа есть в совместной БД.
+func EntityExists(sharedDbID int64, entityType models.EntityType, id int64) (bool, error) {
+	return entityExists(MainDB, sharedDbID, entityType, id, "EntityExists")
+}
+
+// EntityExistsWithTx проверяет, что сущность указанного типа есть в совместной БД, в рамках транзакции.
+func EntityExistsWithTx(tx *sqlx.Tx, sharedDbID int64, entityType models.EntityType, id int64) (bool, error) {
+	return entityExists(tx, sharedDbID, entityType, id, "EntityExistsWithTx")
+}
+
+func entityExists(db sqlx.Queryer, sharedDbID int64, entityType models.EntityType, id int64, funcName string) (bool, error) {
+	entity, known := entityTables[entityType]
+	if !known {
+		return false, fmt.Errorf("%s: неизвестный тип сущности %q", funcName, entityType)
+	}
+	var count int
+	query := fmt.Sprintf(`SELECT COUNT(*) FROM %s WHERE Id = ? AND DatabaseId = ?`, entity.table)
+	if err := sqlx.Get(db, &count, query, id, sharedDbID); err != nil {
+		return false, fmt.Errorf("%s: ошибка проверки %s ID %d, SharedDBID %d: %w", funcName, entityType, id, sharedDbID, err)
+	}
+	return count > 0, nil
+}
+
+// CreateEntityLink создает ссылку между сущностями совместной БД.
+// Поле link.DatabaseId должно быть установлено. Возвращает ID созданной ссылки.
+func CreateEntityLink(link *models.EntityLink) (int64, error) {
+	return createEntityLink(MainDB, link, "CreateEntityLink")
+}
+
+// CreateEntityLinkWithTx создает ссылку между сущностями совместной БД в рамках транзакции.
+func CreateEntityLinkWithTx(tx *sqlx.Tx, link *models.EntityLink) (int64, error) {
+	return createEntityLink(tx, link, "CreateEntityLinkWithTx")
+}
+
+func createEntityLink(db sqlx.Ext, link *models.EntityLink, funcName string) (int64, error) {
+	now := time.Now()
+	if link.CreatedAt.IsZero() {
+		link.CreatedAt = models.FlexibleTime{Time: now}
+	}
+	link.UpdatedAt = now
+
+	query := `INSERT INTO EntityLinks (DatabaseId, SourceType, SourceId, TargetType, TargetId, Label, CreatedByUserId, CreatedAt, UpdatedAt)
+	          VALUES (:DatabaseId, :SourceType, :SourceId, :TargetType, :TargetId, :Label, :CreatedByUserId, :CreatedAt, :UpdatedAt)`
+	result, err := sqlx.NamedExec(db, query, link)
+	if err != nil {
+		return 0, fmt.Errorf("%s: ошибка вставки ссылки: %w", funcName, err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: ошибка получения LastInsertId: %w", funcName, err)
+	}
+	log.Printf("Создана ссылка %s %d -> %s %d с ID: %d для DatabaseId: %d", link.SourceType, link.SourceId, link.TargetType, link.TargetId, id, link.DatabaseId)
+	return id, nil
+}
+
+// GetEntityLinksBySharedDBID извлекает все ссылки совместной БД (для резервной копии).
+func GetEntityLinksBySharedDBID(sharedDbID int64) ([]models.EntityLink, error) {
+	links := []models.EntityLink{}
+	query := `SELECT Id, DatabaseId, SourceType, SourceId, TargetType, TargetId, Label, CreatedByUserId, CreatedAt, UpdatedAt
+	          FROM EntityLinks WHERE DatabaseId = ? ORDER BY Id ASC`
+	if err := MainDB.Select(&links, query, sharedDbID); err != nil {
+		return nil, fmt.Errorf("GetEntityLinksBySharedDBID: ошибка получения ссылок для SharedDBID %d: %w", sharedDbID, err)
+	}
+	return links, nil
+}
+
+// GetEntityLinksForEntityWithTx извлекает ссылки из сущности и на нее в рамках транзакции
+// (чтобы сохранить их до удаления сущности, которое удаляет ссылки триггером).
+func GetEntityLinksForEntityWithTx(tx *sqlx.Tx, sharedDbID int64, entityType models.EntityType, id int64) ([]models.EntityLink, error) {
+	links := []models.EntityLink{}
+	query := `SELECT Id, DatabaseId, SourceType, SourceId, TargetType, TargetId, Label, CreatedByUserId, CreatedAt, UpdatedAt
+	          FROM EntityLinks WHERE DatabaseId = ? AND ((SourceType = ? AND SourceId = ?) OR (TargetType = ? AND TargetId = ?)) ORDER BY Id ASC`
+	if err := tx.Select(&links, query, sharedDbID, entityType, id, entityType, id); err != nil {
+		return nil, fmt.Errorf("GetEntityLinksForEntityWithTx: ошибка получения ссылок %s ID %d, SharedDBID %d: %w", entityType, id, sharedDbID, err)
+	}
+	return links, nil
+}
+
+// GetEntityLinkByID извлекает ссылку по ее ID и ID совместной БД.
+func GetEntityLinkByID(id int64, sharedDbID int64) (*models.EntityLink, error) {
+	link := &models.EntityLink{}
+	query := `SELECT Id, DatabaseId, SourceType, SourceId, TargetType, TargetId, Label, CreatedByUserId, CreatedAt, UpdatedAt
+	          FROM EntityLinks WHERE Id = ? AND DatabaseId = ?`
+	err := MainDB.Get(link, query, id, sharedDbID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // Не найдено
+		}
+		return nil, fmt.Errorf("GetEntityLinkByID: ошибка получения ссылки ID %d, SharedDBID %d: %w", id, sharedDbID, err)
+	}
+	return link, nil
+}
+
+// GetEntityLinkByEnds извлекает ссылку между двумя сущностями (с учетом направления).
+func GetEntityLinkByEnds(sharedDbID int64, sourceType models.EntityType, sourceID int64, targetType models.EntityType, targetID int64) (*models.EntityLink, error) {
+	link := &models.EntityLink{}
+	query := `SELECT Id, DatabaseId, SourceType, SourceId, TargetType, TargetId, Label, CreatedByUserId, CreatedAt, UpdatedAt
+	          FROM EntityLinks WHERE DatabaseId = ? AND SourceType = ? AND SourceId = ? AND TargetType = ? AND TargetId = ?`
+	err := MainDB.Get(link, query, sharedDbID, sourceType, sourceID, targetType, targetID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // Не найдено
+		}
+		return nil, fmt.Errorf("GetEntityLinkByEnds: ошибка получения ссылки %s %d -> %s %d: %w", sourceType, sourceID, targetType, targetID, err)
+	}
+	return link, nil
+}
+
+// GetOutgoingEntityLinks извлекает ссылки из сущности (по возрастанию ID) с заголовками сущностей-целей.
+func GetOutgoingEntityLinks(sharedDbID int64, entityType models.EntityType, id int64) ([]models.LinkedEntity, error) {
+	links, err := selectLinkedEntities("Source", "Target", sharedDbID, entityType, id)
+	if err != nil {
+		return nil, fmt.Errorf("GetOutgoingEntityLinks: ошибка получения ссылок из %s ID %d, SharedDBID %d: %w", entityType, id, sharedDbID, err)
+	}
+	return links, nil
+}
+
+// GetIncomingEntityLinks извлекает обратные ссылки на сущность (по возрастанию ID) с заголовками сущностей-источников.
+func GetIncomingEntityLinks(sharedDbID int64, entityType models.EntityType, id int64) ([]models.LinkedEntity, error) {
+	links, err := selectLinkedEntities("Target", "Source", sharedDbID, entityType, id)
+	if err != nil {
+		return nil, fmt.Errorf("GetIncomingEntityLinks: ошибка получения ссылок на %s ID %d, SharedDBID %d: %w", entityType, id, sharedDbID, err)
+	}
+	return links, nil
+}
+
+// selectLinkedEntities выбирает ссылки, у которых конец side (Source или Target) - указанная сущность,
+// и подставляет заголовок сущности на другом конце (other).
+func selectLinkedEntities(side, other string, sharedDbID int64, entityType models.EntityType, id int64) ([]models.LinkedEntity, error) {
+	joins, titles := "", ""
+	for _, t := range []models.EntityType{models.EntityNote, models.EntityPinboardNote, models.EntityScheduleEntry, models.EntityFolder} {
+		entity := entityTables[t]
+		alias := "e_" + string(t)
+		joins += fmt.Sprintf("\n\t          LEFT JOIN %s %s ON l.%sType = '%s' AND %s.Id = l.%sId", entity.table, alias, other, t, alias, other)
+		titles += fmt.Sprintf(entity.title, alias) + ", "
+	}
+	query := fmt.Sprintf(`SELECT l.Id, l.DatabaseId, l.SourceType, l.SourceId, l.TargetType, l.TargetId, l.Label, l.CreatedByUserId, l.CreatedAt, l.UpdatedAt,
+	                 COALESCE(%s'') AS Title
+	          FROM EntityLinks l%s
+	          WHERE l.DatabaseId = ? AND l.%sType = ? AND l.%sId = ?
+	          ORDER BY l.Id ASC`, titles, joins, side, side)
+	links := []models.LinkedEntity{}
+	if err := MainDB.Select(&links, query, sharedDbID, entityType, id); err != nil {
+		return nil, err
+	}
+	return links, nil
+}
+
+// UpdateEntityLink обновляет подпись ссылки.
+// Поля link.Id и link.DatabaseId должны быть установлены.
+func UpdateEntityLink(link *models.EntityLink) error {
+	link.UpdatedAt = time.Now()
+
+	query := `UPDATE EntityLinks SET Label = :Label, UpdatedAt = :UpdatedAt WHERE Id = :Id AND DatabaseId = :DatabaseId`
+	result, err := MainDB.NamedExec(query, link)
+	if err != nil {
+		return fmt.Errorf("UpdateEntityLink: ошибка обновления ссылки ID %d, SharedDBID %d: %w", link.Id, link.DatabaseId, err)
+	}
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return sql.ErrNoRows // Не найдено для обновления
+	}
+	return nil
+}
+
+// DeleteEntityLink удаляет ссылку из указанной совместной БД.
+func DeleteEntityLink(id int64, sharedDbID int64) error {
+	result, err := MainDB.Exec(`DELETE FROM EntityLinks WHERE Id = ? AND DatabaseId = ?`, id, sharedDbID)
+	if err != nil {
+		return fmt.Errorf("DeleteEntityLink: ошибка удаления ссылки ID %d, SharedDBID %d: %w", id, sharedDbID, err)
+	}
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return sql.ErrNoRows // Не найдено для удаления
+	}
+	log.Printf("Удалена ссылка с ID: %d для DatabaseId: %d", id, sharedDbID)
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"notes_server_go/models"
@@ -215,3 +216,29 @@ func GetFoldersForDatabase(databaseID int64) ([]models.Folder, error) {
 	}
 	return folders, nil
 }
+
+// BuildFolderPaths строит полные пути папок вида "Родитель / Дочерняя" по их ID.
+// Защищена от циклов в ParentId: при повторном посещении папки построение пути прерывается.
+func BuildFolderPaths(folders []models.Folder) map[int64]string {
+	byID := make(map[int64]models.Folder, len(folders))
+	for _, f := range folders {
+		byID[f.ID] = f
+	}
+
+	paths := make(map[int64]string, len(folders))
+	for _, f := range folders {
+		var parts []string
+		visited := make(map[int64]bool)
+		current, ok := f, true
+		for ok && !visited[current.ID] {
+			visited[current.ID] = true
+			parts = append([]string{current.Name}, parts...)
+			if current.ParentID == nil {
+				break
+			}
+			current, ok = byID[*current.ParentID]
+		}
+		paths[f.ID] = strings.Join(parts, " / ")
+	}
+	return paths
+}
@@ -0,0 +1,210 @@
+package data
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"notes_server_go/models"
+	"notes_server_go/revisions"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// noteRevisionRetention - настройки хранения истории версий заметок, см. SetNoteRevisionRetention.
+var noteRevisionRetention = revisions.DefaultRetention
+
+// SetNoteRevisionRetention задает настройки хранения истории версий заметок.
+func SetNoteRevisionRetention(retention revisions.Retention) {
+	noteRevisionRetention = retention
+}
+
+// RecordNoteRevisionWithTx сохраняет версию заметки after после ее создания (before = nil) или изменения.
+// Если заголовок, содержимое и папка не изменились, версия не создается. Если у измененной заметки
+// еще нет версий (она создана до появления истории), сначала сохраняется ее прежнее состояние before.
+// Возвращает true, если версия создана.
+func RecordNoteRevisionWithTx(tx *sqlx.Tx, before *models.Note, after *models.Note, authorUserID *int64, reason string) (bool, error) {
+	if before != nil {
+		if sameNoteRevisionFields(before, after) {
+			return false, nil
+		}
+		var count int
+		if err := tx.Get(&count, `SELECT COUNT(*) FROM NoteRevisions WHERE DatabaseId = ? AND NoteId = ?`, after.DatabaseID, before.ID); err != nil {
+			return false, fmt.Errorf("RecordNoteRevisionWithTx: ошибка проверки версий заметки ID %d: %w", before.ID, err)
+		}
+		if count == 0 {
+			createdAt := before.UpdatedAt
+			if createdAt.IsZero() {
+				createdAt = time.Now()
+			}
+			if err := insertNoteRevisionWithTx(tx, before, nil, models.NoteRevisionReasonInitial, createdAt); err != nil {
+				return false, fmt.Errorf("RecordNoteRevisionWithTx: %w", err)
+			}
+		}
+	}
+	if err := insertNoteRevisionWithTx(tx, after, authorUserID, reason, time.Now()); err != nil {
+		return false, fmt.Errorf("RecordNoteRevisionWithTx: %w", err)
+	}
+	if err := pruneNoteRevisionsWithTx(tx, after.DatabaseID, after.ID); err != nil {
+		return false, fmt.Errorf("RecordNoteRevisionWithTx: %w", err)
+	}
+	return true, nil
+}
+
+// sameNoteRevisionFields сравнивает поля заметки, которые хранятся в версиях.
+func sameNoteRevisionFields(a, b *models.Note) bool {
+	return a.Title == b.Title && sameStringPtr(a.Content, b.Content) && sameStringPtr(a.ContentJson, b.ContentJson) && sameInt64Ptr(a.FolderID, b.FolderID)
+}
+
+func sameStringPtr(a, b *string) bool {
+	return (a == nil && b == nil) || (a != nil && b != nil && *a == *b)
+}
+
+func sameInt64Ptr(a, b *int64) bool {
+	return (a == nil && b == nil) || (a != nil && b != nil && *a == *b)
+}
+
+// insertNoteRevisionWithTx сохраняет состояние заметки как версию.
+func insertNoteRevisionWithTx(tx *sqlx.Tx, note *models.Note, authorUserID *int64, reason string, createdAt time.Time) error {
+	query := `INSERT INTO NoteRevisions (DatabaseId, NoteId, Title, Content, ContentJson, FolderId, AuthorUserId, Reason, CreatedAt)
+	          VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err := tx.Exec(query, note.DatabaseID, note.ID, note.Title, note.Content, note.ContentJson, note.FolderID, authorUserID, reason, createdAt)
+	if err != nil {
+		return fmt.Errorf("ошибка вставки версии заметки ID %d: %w", note.ID, err)
+	}
+	return nil
+}
+
+// pruneNoteRevisionsWithTx удаляет версии заметки сверх настроек хранения. Последняя версия не удаляется.
+// Версии отбираются и по БД: ID заметки может повторяться в разных БД, пока жива история удаленной заметки.
+func pruneNoteRevisionsWithTx(tx *sqlx.Tx, sharedDbID int64, noteID int64) error {
+	if limit := noteRevisionRetention.MaxPerNote; limit > 0 {
+		_, err := tx.Exec(`DELETE FROM NoteRevisions WHERE DatabaseId = ? AND NoteId = ? AND Id NOT IN (
+		                     SELECT Id FROM NoteRevisions WHERE DatabaseId = ? AND NoteId = ? ORDER BY Id DESC LIMIT ?)`,
+			sharedDbID, noteID, sharedDbID, noteID, limit)
+		if err != nil {
+			return fmt.Errorf("ошибка удаления лишних версий заметки ID %d: %w", noteID, err)
+		}
+	}
+	if maxAge := noteRevisionRetention.MaxAge; maxAge > 0 {
+		_, err := tx.Exec(`DELETE FROM NoteRevisions WHERE DatabaseId = ? AND NoteId = ? AND CreatedAt < ? AND Id <> (
+		                     SELECT MAX(Id) FROM NoteRevisions WHERE DatabaseId = ? AND NoteId = ?)`,
+			sharedDbID, noteID, time.Now().Add(-maxAge), sharedDbID, noteID)
+		if err != nil {
+			return fmt.Errorf("ошибка удаления старых версий заметки ID %d: %w", noteID, err)
+		}
+	}
+	return nil
+}
+
+// PurgeNoteRevisions применяет настройки хранения ко всем заметкам: удаляет версии старше MaxAge
+// и сверх MaxPerNote, кроме последней версии каждой заметки. Возвращает количество удаленных версий.
+func PurgeNoteRevisions() (int64, error) {
+	var total int64
+	if limit := noteRevisionRetention.MaxPerNote; limit > 0 {
+		result, err := MainDB.Exec(`DELETE FROM NoteRevisions WHERE Id IN (
+		                              SELECT Id FROM (
+		                                SELECT Id, ROW_NUMBER() OVER (PARTITION BY DatabaseId, NoteId ORDER BY Id DESC) AS RowNumber FROM NoteRevisions)
+		                              WHERE RowNumber > ?)`, limit)
+		if err != nil {
+			return 0, fmt.Errorf("PurgeNoteRevisions: ошибка удаления лишних версий: %w", err)
+		}
+		rowsAffected, _ := result.RowsAffected()
+		total += rowsAffected
+	}
+	if maxAge := noteRevisionRetention.MaxAge; maxAge > 0 {
+		result, err := MainDB.Exec(`DELETE FROM NoteRevisions WHERE CreatedAt < ? AND Id NOT IN (
+		                              SELECT MAX(Id) FROM NoteRevisions GROUP BY DatabaseId, NoteId)`, time.Now().Add(-maxAge))
+		if err != nil {
+			return 0, fmt.Errorf("PurgeNoteRevisions: ошибка удаления старых версий: %w", err)
+		}
+		rowsAffected, _ := result.RowsAffected()
+		total += rowsAffected
+	}
+	if total > 0 {
+		log.Printf("PurgeNoteRevisions: удалено %d версий заметок", total)
+	}
+	return total, nil
+}
+
+// GetNoteRevisions извлекает версии заметки (без содержимого), начиная с последней.
+func GetNoteRevisions(sharedDbID int64, noteID int64) ([]models.NoteRevisionSummary, error) {
+	revisionsList := []models.NoteRevisionSummary{}
+	query := `SELECT Id, DatabaseId, NoteId, Title, FolderId, AuthorUserId, Reason, CreatedAt
+	          FROM NoteRevisions WHERE DatabaseId = ? AND NoteId = ? ORDER BY Id DESC`
+	if err := MainDB.Select(&revisionsList, query, sharedDbID, noteID); err != nil {
+		return nil, fmt.Errorf("GetNoteRevisions: ошибка получения версий заметки ID %d, SharedDBID %d: %w", noteID, sharedDbID, err)
+	}
+	return revisionsList, nil
+}
+
+// GetNoteRevisionByID извлекает версию заметки по ее ID.
+func GetNoteRevisionByID(id int64, sharedDbID int64, noteID int64) (*models.NoteRevision, error) {
+	revision := &models.NoteRevision{}
+	query := `SELECT Id, DatabaseId, NoteId, Title, Content, ContentJson, FolderId, AuthorUserId, Reason, CreatedAt
+	          FROM NoteRevisions WHERE Id = ? AND DatabaseId = ? AND NoteId = ?`
+	err := MainDB.Get(revision, query, id, sharedDbID, noteID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // Не найдено
+		}
+		return nil, fmt.Errorf("GetNoteRevisionByID: ошибка получения версии ID %d заметки ID %d: %w", id, noteID, err)
+	}
+	return revision, nil
+}
+
+// RestoreNoteRevision возвращает заметке заголовок, содержимое и папку версии и сохраняет результат
+// как новую версию. Если папки версии больше нет, заметка остается без папки. Вики-ссылки на прежний
+// заголовок в других заметках переписываются, как при переименовании через синхронизацию.
+func RestoreNoteRevision(sharedDbID int64, revision *models.NoteRevision, userID int64) (*models.Note, error) {
+	tx, err := MainDB.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("RestoreNoteRevision: ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback()
+
+	before, err := GetNoteByIDWithTx(tx, revision.NoteId, sharedDbID)
+	if err != nil {
+		return nil, fmt.Errorf("RestoreNoteRevision: %w", err)
+	}
+	if before == nil {
+		return nil, sql.ErrNoRows
+	}
+	note := *before
+	note.Title = revision.Title
+	note.Content = revision.Content
+	note.ContentJson = revision.ContentJson
+	note.FolderID = revision.FolderId
+	if note.FolderID != nil {
+		folder, err := GetFolderByIDWithTx(tx, *note.FolderID, sharedDbID)
+		if err != nil {
+			return nil, fmt.Errorf("RestoreNoteRevision: %w", err)
+		}
+		if folder == nil {
+			note.FolderID = nil
+		}
+	}
+
+	if sameNoteRevisionFields(before, &note) {
+		return before, tx.Commit()
+	}
+	if err := UpdateNoteWithTx(tx, &note); err != nil {
+		return nil, fmt.Errorf("RestoreNoteRevision: %w", err)
+	}
+	if _, err := RecordNoteRevisionWithTx(tx, before, &note, &userID, models.NoteRevisionReasonRestore); err != nil {
+		return nil, fmt.Errorf("RestoreNoteRevision: %w", err)
+	}
+	renames := []NoteTitleRename{{NoteId: note.ID, OldTitle: before.Title, NewTitle: note.Title}}
+	if _, err := RenameNoteWikiLinksWithTx(tx, sharedDbID, renames, userID); err != nil {
+		return nil, fmt.Errorf("RestoreNoteRevision: %w", err)
+	}
+	if err := RebuildNoteWikiLinksWithTx(tx, sharedDbID); err != nil {
+		return nil, fmt.Errorf("RestoreNoteRevision: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("RestoreNoteRevision: ошибка коммита: %w", err)
+	}
+	log.Printf("Заметка ID %d в БД %d восстановлена из версии %d пользователем %d", note.ID, sharedDbID, revision.Id, userID)
+	return &note, nil
+}
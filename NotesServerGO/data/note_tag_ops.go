@@ -0,0 +1,271 @@
+package data
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"notes_server_go/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// NormalizeTag приводит тег к хранимому виду, см. models.NormalizeTag.
+func NormalizeTag(tag string) string {
+	return models.NormalizeTag(tag)
+}
+
+// GetNoteTagsBySharedDBID извлекает все теги заметок для указанной совместной БД.
+func GetNoteTagsBySharedDBID(sharedDbID int64) ([]models.NoteTag, error) {
+	tags := []models.NoteTag{}
+	query := `SELECT Id, NoteId, Tag, DatabaseId, CreatedAt
+	          FROM NoteTags WHERE DatabaseId = ? ORDER BY NoteId ASC, Tag ASC`
+	err := MainDB.Select(&tags, query, sharedDbID)
+	if err != nil {
+		return nil, fmt.Errorf("GetNoteTagsBySharedDBID: ошибка получения тегов для SharedDBID %d: %w", sharedDbID, err)
+	}
+	return tags, nil
+}
+
+// GetNoteTagsMap возвращает теги заметок совместной БД в нижнем регистре, сгруппированные по ID заметки.
+func GetNoteTagsMap(sharedDbID int64) (map[int64][]string, error) {
+	tags, err := GetNoteTagsBySharedDBID(sharedDbID)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[int64][]string)
+	for _, t := range tags {
+		result[t.NoteId] = append(result[t.NoteId], strings.ToLower(t.Tag))
+	}
+	return result, nil
+}
+
+// GetTagUsage возвращает список тегов совместной БД с количеством заметок, в которых они используются.
+func GetTagUsage(sharedDbID int64) ([]models.TagUsage, error) {
+	usage := []models.TagUsage{}
+	query := `SELECT Tag, COUNT(*) AS Count
+	          FROM NoteTags WHERE DatabaseId = ?
+	          GROUP BY Tag ORDER BY Count DESC, Tag ASC`
+	err := MainDB.Select(&usage, query, sharedDbID)
+	if err != nil {
+		return nil, fmt.Errorf("GetTagUsage: ошибка подсчета тегов для SharedDBID %d: %w", sharedDbID, err)
+	}
+	return usage, nil
+}
+
+// NoteTagExists проверяет, используется ли тег хотя бы в одной заметке совместной БД.
+func NoteTagExists(sharedDbID int64, tag string) (bool, error) {
+	var exists bool
+	err := MainDB.Get(&exists, `SELECT COUNT(*) > 0 FROM NoteTags WHERE DatabaseId = ? AND Tag = ?`, sharedDbID, tag)
+	if err != nil {
+		return false, fmt.Errorf("NoteTagExists: ошибка проверки тега '%s' в БД %d: %w", tag, sharedDbID, err)
+	}
+	return exists, nil
+}
+
+// RenameNoteTag переименовывает тег во всех заметках совместной БД.
+// Если у заметки уже есть тег с новым именем, старый тег просто удаляется (теги сливаются).
+// Возвращает количество затронутых заметок или sql.ErrNoRows, если тег не найден.
+func RenameNoteTag(sharedDbID int64, from string, to string) (int64, error) {
+	tx, err := MainDB.Beginx()
+	if err != nil {
+		return 0, fmt.Errorf("RenameNoteTag: ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback()
+
+	affected, err := renameNoteTagWithTx(tx, sharedDbID, from, to)
+	if err != nil {
+		return 0, err
+	}
+	if affected == 0 {
+		return 0, sql.ErrNoRows
+	}
+	if err := touchSharedDatabaseWithTx(tx, sharedDbID); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("RenameNoteTag: ошибка коммита: %w", err)
+	}
+	log.Printf("RenameNoteTag: тег '%s' переименован в '%s' в %d заметках БД %d", from, to, affected, sharedDbID)
+	return affected, nil
+}
+
+// MergeNoteTags сливает теги sources в тег target во всех заметках совместной БД.
+// Возвращает количество затронутых заметок или sql.ErrNoRows, если ни один из исходных тегов не найден.
+func MergeNoteTags(sharedDbID int64, sources []string, target string) (int64, error) {
+	tx, err := MainDB.Beginx()
+	if err != nil {
+		return 0, fmt.Errorf("MergeNoteTags: ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback()
+
+	var total int64
+	for _, source := range sources {
+		if source == target {
+			continue
+		}
+		affected, err := renameNoteTagWithTx(tx, sharedDbID, source, target)
+		if err != nil {
+			return 0, err
+		}
+		total += affected
+	}
+	if total == 0 {
+		return 0, sql.ErrNoRows
+	}
+	if err := touchSharedDatabaseWithTx(tx, sharedDbID); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("MergeNoteTags: ошибка коммита: %w", err)
+	}
+	log.Printf("MergeNoteTags: теги %v слиты в '%s' (%d заметок) в БД %d", sources, target, total, sharedDbID)
+	return total, nil
+}
+
+// --- Функции, работающие с транзакциями ---
+
+// renameNoteTagWithTx переименовывает тег в рамках транзакции и возвращает количество затронутых заметок.
+func renameNoteTagWithTx(tx *sqlx.Tx, sharedDbID int64, from string, to string) (int64, error) {
+	var affected int64
+	err := tx.Get(&affected, `SELECT COUNT(*) FROM NoteTags WHERE DatabaseId = ? AND Tag = ?`, sharedDbID, from)
+	if err != nil {
+		return 0, fmt.Errorf("renameNoteTagWithTx: ошибка подсчета тега '%s' в БД %d: %w", from, sharedDbID, err)
+	}
+	if affected == 0 {
+		return 0, nil
+	}
+
+	// UPDATE OR IGNORE пропускает заметки, где целевой тег уже есть; их старый тег удаляется следующим запросом
+	if _, err := tx.Exec(`UPDATE OR IGNORE NoteTags SET Tag = ? WHERE DatabaseId = ? AND Tag = ?`, to, sharedDbID, from); err != nil {
+		return 0, fmt.Errorf("renameNoteTagWithTx: ошибка переименования тега '%s' в БД %d: %w", from, sharedDbID, err)
+	}
+	if _, err := tx.Exec(`DELETE FROM NoteTags WHERE DatabaseId = ? AND Tag = ?`, sharedDbID, from); err != nil {
+		return 0, fmt.Errorf("renameNoteTagWithTx: ошибка удаления дубликатов тега '%s' в БД %d: %w", from, sharedDbID, err)
+	}
+	return affected, nil
+}
+
+// GetNoteTagsBySharedDBIDWithTx извлекает все теги заметок для указанной совместной БД в рамках транзакции.
+func GetNoteTagsBySharedDBIDWithTx(tx *sqlx.Tx, sharedDbID int64) ([]models.NoteTag, error) {
+	tags := []models.NoteTag{}
+	query := `SELECT Id, NoteId, Tag, DatabaseId, CreatedAt
+	          FROM NoteTags WHERE DatabaseId = ? ORDER BY NoteId ASC, Tag ASC`
+	err := tx.Select(&tags, query, sharedDbID)
+	if err != nil {
+		return nil, fmt.Errorf("GetNoteTagsBySharedDBIDWithTx: ошибка получения тегов для SharedDBID %d: %w", sharedDbID, err)
+	}
+	return tags, nil
+}
+
+// ReplaceNoteTagsWithTx заменяет все теги заметок совместной БД переданным набором в рамках транзакции.
+// Поле NoteId каждого тега должно содержать серверный ID заметки. Пустые теги и дубликаты пропускаются.
+func ReplaceNoteTagsWithTx(tx *sqlx.Tx, sharedDbID int64, tags []models.NoteTag) error {
+	if _, err := tx.Exec(`DELETE FROM NoteTags WHERE DatabaseId = ?`, sharedDbID); err != nil {
+		return fmt.Errorf("ReplaceNoteTagsWithTx: ошибка удаления тегов для SharedDBID %d: %w", sharedDbID, err)
+	}
+
+	query := `INSERT OR IGNORE INTO NoteTags (NoteId, Tag, DatabaseId, CreatedAt) VALUES (?, ?, ?, ?)`
+	for _, tag := range tags {
+		name := NormalizeTag(tag.Tag)
+		if name == "" {
+			continue
+		}
+		createdAt := tag.CreatedAt.Time
+		if createdAt.IsZero() {
+			createdAt = time.Now()
+		}
+		if _, err := tx.Exec(query, tag.NoteId, name, sharedDbID, createdAt); err != nil {
+			return fmt.Errorf("ReplaceNoteTagsWithTx: ошибка вставки тега '%s' для заметки %d: %w", name, tag.NoteId, err)
+		}
+	}
+	return nil
+}
+
+// EnsureNoteTagsBackfill переносит в NoteTags теги из MetadataJson (ключ "tags") заметок, сохраненных
+// до появления таблицы NoteTags. Выполняется, только пока таблица пуста.
+func EnsureNoteTagsBackfill() error {
+	var count int
+	if err := MainDB.Get(&count, `SELECT COUNT(*) FROM NoteTags`); err != nil {
+		return fmt.Errorf("EnsureNoteTagsBackfill: ошибка проверки таблицы NoteTags: %w", err)
+	}
+	if count > 0 {
+		return nil
+	}
+
+	var notes []models.Note
+	err := MainDB.Select(&notes, `SELECT Id, DatabaseId, MetadataJson FROM Notes WHERE MetadataJson LIKE '%"tags"%'`)
+	if err != nil {
+		return fmt.Errorf("EnsureNoteTagsBackfill: ошибка получения заметок: %w", err)
+	}
+	if len(notes) == 0 {
+		return nil
+	}
+
+	tx, err := MainDB.Beginx()
+	if err != nil {
+		return fmt.Errorf("EnsureNoteTagsBackfill: ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback()
+	now := time.Now()
+	inserted := 0
+	for _, note := range notes {
+		for _, tag := range metadataTags(note.MetadataJson) {
+			result, err := tx.Exec(`INSERT OR IGNORE INTO NoteTags (NoteId, Tag, DatabaseId, CreatedAt) VALUES (?, ?, ?, ?)`,
+				note.ID, tag, note.DatabaseID, now)
+			if err != nil {
+				return fmt.Errorf("EnsureNoteTagsBackfill: ошибка вставки тега '%s' для заметки %d: %w", tag, note.ID, err)
+			}
+			rowsAffected, _ := result.RowsAffected()
+			inserted += int(rowsAffected)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("EnsureNoteTagsBackfill: ошибка коммита: %w", err)
+	}
+	if inserted > 0 {
+		log.Printf("Заполнена таблица NoteTags: %d тегов из метаданных %d заметок", inserted, len(notes))
+	}
+	return nil
+}
+
+// metadataTags извлекает теги из MetadataJson заметки. Значение ключа "tags" может быть JSON-массивом,
+// строкой с JSON-массивом или списком через запятую.
+func metadataTags(metadataJson string) []string {
+	var metadata map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(metadataJson), &metadata); err != nil {
+		return nil
+	}
+	raw, ok := metadata["tags"]
+	if !ok {
+		return nil
+	}
+	var tags []string
+	if err := json.Unmarshal(raw, &tags); err != nil {
+		var value string
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return nil
+		}
+		if err := json.Unmarshal([]byte(value), &tags); err != nil {
+			tags = strings.Split(value, ",")
+		}
+	}
+	result := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		if tag = NormalizeTag(tag); tag != "" {
+			result = append(result, tag)
+		}
+	}
+	return result
+}
+
+// touchSharedDatabaseWithTx обновляет UpdatedAt совместной БД, чтобы клиенты заметили изменения.
+func touchSharedDatabaseWithTx(tx *sqlx.Tx, sharedDbID int64) error {
+	if _, err := tx.Exec(`UPDATE SharedDatabases SET UpdatedAt = ? WHERE Id = ?`, time.Now(), sharedDbID); err != nil {
+		return fmt.Errorf("ошибка обновления UpdatedAt для БД %d: %w", sharedDbID, err)
+	}
+	return nil
+}
@@ -0,0 +1,208 @@
+package data
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"notes_server_go/models"
+	"notes_server_go/wikilink"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// NoteTitleRename - переименование заметки, после которого вики-ссылки на старый заголовок
+// в других заметках переписываются на новый.
+type NoteTitleRename struct {
+	NoteId   int64
+	OldTitle string
+	NewTitle string
+}
+
+// RebuildNoteWikiLinksWithTx заново строит вики-ссылки всех заметок совместной БД по их тексту
+// (NotePlainText) и сопоставляет заголовки ссылок с заметками. Если заголовок есть у нескольких
+// заметок, ссылка ведет на заметку с меньшим ID.
+func RebuildNoteWikiLinksWithTx(tx *sqlx.Tx, sharedDbID int64) error {
+	var notes []models.Note
+	err := tx.Select(&notes, `SELECT Id, DatabaseId, Title, Content, ContentJson, FolderId, UpdatedAt FROM Notes WHERE DatabaseId = ? ORDER BY Id ASC`, sharedDbID)
+	if err != nil {
+		return fmt.Errorf("RebuildNoteWikiLinksWithTx: ошибка получения заметок SharedDBID %d: %w", sharedDbID, err)
+	}
+	if _, err := tx.Exec(`DELETE FROM NoteWikiLinks WHERE DatabaseId = ?`, sharedDbID); err != nil {
+		return fmt.Errorf("RebuildNoteWikiLinksWithTx: ошибка удаления ссылок SharedDBID %d: %w", sharedDbID, err)
+	}
+
+	noteByKey := make(map[string]int64, len(notes))
+	for _, note := range notes {
+		key := wikilink.Key(note.Title)
+		if _, exists := noteByKey[key]; !exists {
+			noteByKey[key] = note.ID
+		}
+	}
+
+	query := `INSERT INTO NoteWikiLinks (DatabaseId, SourceNoteId, TargetTitle, TargetTitleKey, TargetNoteId, Alias, Occurrences)
+	          VALUES (:DatabaseId, :SourceNoteId, :TargetTitle, :TargetTitleKey, :TargetNoteId, :Alias, :Occurrences)`
+	for i := range notes {
+		for _, parsed := range wikilink.Parse(NotePlainText(&notes[i])) {
+			link := models.NoteWikiLink{
+				DatabaseId:     sharedDbID,
+				SourceNoteId:   notes[i].ID,
+				TargetTitle:    parsed.Title,
+				TargetTitleKey: parsed.Key,
+				Alias:          parsed.Alias,
+				Occurrences:    parsed.Occurrences,
+			}
+			if targetID, exists := noteByKey[parsed.Key]; exists {
+				link.TargetNoteId = &targetID
+			}
+			if _, err := tx.NamedExec(query, link); err != nil {
+				return fmt.Errorf("RebuildNoteWikiLinksWithTx: ошибка вставки ссылки из заметки ID %d на '%s': %w", notes[i].ID, parsed.Title, err)
+			}
+		}
+	}
+	return nil
+}
+
+// RenameNoteWikiLinksWithTx переписывает в Content и ContentJson заметок совместной БД вики-ссылки
+// на старые заголовки переименованных заметок. Ссылки не трогаются, если старый заголовок остался
+// у другой заметки. Каждое изменение сохраняется как версия заметки с автором authorUserID.
+// Возвращает количество измененных заметок.
+func RenameNoteWikiLinksWithTx(tx *sqlx.Tx, sharedDbID int64, renames []NoteTitleRename, authorUserID int64) (int, error) {
+	if len(renames) == 0 {
+		return 0, nil
+	}
+	var notes []models.Note
+	err := tx.Select(&notes, `SELECT Id, DatabaseId, Title, Content, ContentJson, FolderId, UpdatedAt FROM Notes WHERE DatabaseId = ? ORDER BY Id ASC`, sharedDbID)
+	if err != nil {
+		return 0, fmt.Errorf("RenameNoteWikiLinksWithTx: ошибка получения заметок SharedDBID %d: %w", sharedDbID, err)
+	}
+	before := make(map[int64]models.Note, len(notes))
+	titleKeys := make(map[string]bool, len(notes))
+	for _, note := range notes {
+		before[note.ID] = note
+		titleKeys[wikilink.Key(note.Title)] = true
+	}
+
+	changed := make(map[int64]bool)
+	for _, rename := range renames {
+		if wikilink.Key(rename.OldTitle) == wikilink.Key(rename.NewTitle) || titleKeys[wikilink.Key(rename.OldTitle)] {
+			continue
+		}
+		for i := range notes {
+			note := &notes[i]
+			if note.Content != nil {
+				if content, n := wikilink.Rename(*note.Content, rename.OldTitle, rename.NewTitle); n > 0 {
+					note.Content = &content
+					changed[note.ID] = true
+				}
+			}
+			if note.ContentJson != nil {
+				if contentJson, n := wikilink.RenameInJSON(*note.ContentJson, rename.OldTitle, rename.NewTitle); n > 0 {
+					note.ContentJson = &contentJson
+					changed[note.ID] = true
+				}
+			}
+		}
+	}
+
+	now := time.Now()
+	for i := range notes {
+		note := &notes[i]
+		if !changed[note.ID] {
+			continue
+		}
+		_, err := tx.Exec(`UPDATE Notes SET Content = ?, ContentJson = ?, UpdatedAt = ? WHERE Id = ? AND DatabaseId = ?`,
+			note.Content, note.ContentJson, now, note.ID, sharedDbID)
+		if err != nil {
+			return 0, fmt.Errorf("RenameNoteWikiLinksWithTx: ошибка обновления заметки ID %d: %w", note.ID, err)
+		}
+		note.UpdatedAt = now
+		previous := before[note.ID]
+		if _, err := RecordNoteRevisionWithTx(tx, &previous, note, &authorUserID, models.NoteRevisionReasonWikiRename); err != nil {
+			return 0, fmt.Errorf("RenameNoteWikiLinksWithTx: %w", err)
+		}
+	}
+	if len(changed) > 0 {
+		log.Printf("RenameNoteWikiLinksWithTx: вики-ссылки переписаны в %d заметках БД %d", len(changed), sharedDbID)
+	}
+	return len(changed), nil
+}
+
+// GetOutgoingNoteWikiLinks извлекает вики-ссылки из заметки в порядке их появления в тексте.
+func GetOutgoingNoteWikiLinks(sharedDbID int64, noteID int64) ([]models.NoteWikiLinkDetails, error) {
+	links, err := selectNoteWikiLinks(`l.DatabaseId = ? AND l.SourceNoteId = ?`, sharedDbID, noteID)
+	if err != nil {
+		return nil, fmt.Errorf("GetOutgoingNoteWikiLinks: ошибка получения ссылок из заметки ID %d, SharedDBID %d: %w", noteID, sharedDbID, err)
+	}
+	return links, nil
+}
+
+// GetNoteWikiBacklinks извлекает вики-ссылки других заметок на указанную заметку.
+func GetNoteWikiBacklinks(sharedDbID int64, noteID int64) ([]models.NoteWikiLinkDetails, error) {
+	links, err := selectNoteWikiLinks(`l.DatabaseId = ? AND l.TargetNoteId = ?`, sharedDbID, noteID)
+	if err != nil {
+		return nil, fmt.Errorf("GetNoteWikiBacklinks: ошибка получения ссылок на заметку ID %d, SharedDBID %d: %w", noteID, sharedDbID, err)
+	}
+	return links, nil
+}
+
+// GetBrokenNoteWikiLinks извлекает вики-ссылки совместной БД на заголовки, которых нет ни у одной заметки.
+func GetBrokenNoteWikiLinks(sharedDbID int64) ([]models.NoteWikiLinkDetails, error) {
+	links, err := selectNoteWikiLinks(`l.DatabaseId = ? AND l.TargetNoteId IS NULL`, sharedDbID)
+	if err != nil {
+		return nil, fmt.Errorf("GetBrokenNoteWikiLinks: ошибка получения битых ссылок SharedDBID %d: %w", sharedDbID, err)
+	}
+	return links, nil
+}
+
+// selectNoteWikiLinks выбирает вики-ссылки по условию where вместе с заголовками заметок на концах.
+func selectNoteWikiLinks(where string, args ...interface{}) ([]models.NoteWikiLinkDetails, error) {
+	query := `SELECT l.Id, l.DatabaseId, l.SourceNoteId, l.TargetTitle, l.TargetTitleKey, l.TargetNoteId, l.Alias, l.Occurrences,
+	                 s.Title AS SourceTitle, t.Title AS TargetNoteTitle
+	          FROM NoteWikiLinks l
+	          JOIN Notes s ON s.Id = l.SourceNoteId
+	          LEFT JOIN Notes t ON t.Id = l.TargetNoteId
+	          WHERE ` + where + `
+	          ORDER BY l.SourceNoteId ASC, l.Id ASC`
+	links := []models.NoteWikiLinkDetails{}
+	if err := MainDB.Select(&links, query, args...); err != nil {
+		return nil, err
+	}
+	return links, nil
+}
+
+// EnsureNoteWikiLinksBackfill строит вики-ссылки заметок, сохраненных до появления таблицы NoteWikiLinks.
+func EnsureNoteWikiLinksBackfill() error {
+	var count int
+	if err := MainDB.Get(&count, `SELECT COUNT(*) FROM NoteWikiLinks`); err != nil {
+		return fmt.Errorf("EnsureNoteWikiLinksBackfill: ошибка проверки таблицы NoteWikiLinks: %w", err)
+	}
+	if count > 0 {
+		return nil
+	}
+
+	var dbIDs []int64
+	err := MainDB.Select(&dbIDs, `SELECT DISTINCT DatabaseId FROM Notes WHERE Content LIKE '%[[%' OR ContentJson LIKE '%[[%'`)
+	if err != nil {
+		return fmt.Errorf("EnsureNoteWikiLinksBackfill: ошибка получения совместных БД: %w", err)
+	}
+	if len(dbIDs) == 0 {
+		return nil
+	}
+
+	tx, err := MainDB.Beginx()
+	if err != nil {
+		return fmt.Errorf("EnsureNoteWikiLinksBackfill: ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback()
+	for _, dbID := range dbIDs {
+		if err := RebuildNoteWikiLinksWithTx(tx, dbID); err != nil {
+			return fmt.Errorf("EnsureNoteWikiLinksBackfill: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("EnsureNoteWikiLinksBackfill: ошибка коммита: %w", err)
+	}
+	log.Printf("Построены вики-ссылки заметок %d совместных БД", len(dbIDs))
+	return nil
+}
@@ -24,8 +24,8 @@ func CreateNote(note *models.Note) (int64, error) {
 	note.UpdatedAt = now
 
 	// DatabaseId устанавливается перед вызовом этой функции
-	query := `INSERT INTO Notes (DatabaseId, Title, Content, FolderId, CreatedAt, UpdatedAt, ImagesJson, MetadataJson, ContentJson)
-	          VALUES (:DatabaseId, :Title, :Content, :FolderId, :CreatedAt, :UpdatedAt, :ImagesJson, :MetadataJson, :ContentJson)`
+	query := `INSERT INTO Notes (DatabaseId, Title, Content, FolderId, CategoryId, CreatedAt, UpdatedAt, ImagesJson, MetadataJson, ContentJson)
+	          VALUES (:DatabaseId, :Title, :Content, :FolderId, :CategoryId, :CreatedAt, :UpdatedAt, :ImagesJson, :MetadataJson, :ContentJson)`
 
 	result, err := MainDB.NamedExec(query, note)
 	if err != nil {
@@ -42,7 +42,7 @@ func CreateNote(note *models.Note) (int64, error) {
 // GetNoteByID извлекает заметку по ее ID и ID совместной БД.
 func GetNoteByID(id int64, sharedDbID int64) (*models.Note, error) {
 	note := &models.Note{}
-	query := `SELECT Id, DatabaseId, Title, Content, FolderId, CreatedAt, UpdatedAt, ImagesJson, MetadataJson, ContentJson
+	query := `SELECT Id, DatabaseId, Title, Content, FolderId, CategoryId, CreatedAt, UpdatedAt, ImagesJson, MetadataJson, ContentJson
 	          FROM Notes WHERE Id = ? AND DatabaseId = ?`
 	err := MainDB.Get(note, query, id, sharedDbID)
 	if err != nil {
@@ -60,7 +60,7 @@ func GetNoteByID(id int64, sharedDbID int64) (*models.Note, error) {
 // GetAllNotesBySharedDBID извлекает все заметки для указанной совместной БД.
 func GetAllNotesBySharedDBID(sharedDbID int64) ([]models.Note, error) {
 	var notes []models.Note
-	query := `SELECT Id, DatabaseId, Title, Content, FolderId, CreatedAt, UpdatedAt, ImagesJson, MetadataJson, ContentJson
+	query := `SELECT Id, DatabaseId, Title, Content, FolderId, CategoryId, CreatedAt, UpdatedAt, ImagesJson, MetadataJson, ContentJson
               FROM Notes WHERE DatabaseId = ? ORDER BY UpdatedAt DESC`
 	err := MainDB.Select(&notes, query, sharedDbID)
 	if err != nil {
@@ -83,7 +83,7 @@ func UpdateNote(note *models.Note) error {
 	note.UpdatedAt = time.Now()
 
 	query := `UPDATE Notes SET
-	            Title = :Title, Content = :Content, FolderId = :FolderId, UpdatedAt = :UpdatedAt,
+	            Title = :Title, Content = :Content, FolderId = :FolderId, CategoryId = :CategoryId, UpdatedAt = :UpdatedAt,
 	            ImagesJson = :ImagesJson, MetadataJson = :MetadataJson, ContentJson = :ContentJson
 	          WHERE Id = :Id AND DatabaseId = :DatabaseId`
 	result, err := MainDB.NamedExec(query, note)
@@ -125,8 +125,8 @@ func CreateNoteWithTx(tx *sqlx.Tx, note *models.Note) (int64, error) {
 	note.CreatedAt = now
 	note.UpdatedAt = now
 
-	query := `INSERT INTO Notes (DatabaseId, Title, Content, FolderId, CreatedAt, UpdatedAt, ImagesJson, MetadataJson, ContentJson)
-	          VALUES (:DatabaseId, :Title, :Content, :FolderId, :CreatedAt, :UpdatedAt, :ImagesJson, :MetadataJson, :ContentJson)`
+	query := `INSERT INTO Notes (DatabaseId, Title, Content, FolderId, CategoryId, CreatedAt, UpdatedAt, ImagesJson, MetadataJson, ContentJson)
+	          VALUES (:DatabaseId, :Title, :Content, :FolderId, :CategoryId, :CreatedAt, :UpdatedAt, :ImagesJson, :MetadataJson, :ContentJson)`
 	result, err := tx.NamedExec(query, note)
 	if err != nil {
 		return 0, fmt.Errorf("CreateNoteWithTx: ошибка вставки: %w", err)
@@ -141,7 +141,7 @@ func CreateNoteWithTx(tx *sqlx.Tx, note *models.Note) (int64, error) {
 // GetNoteByIDWithTx извлекает заметку по ID и ID совместной БД в рамках транзакции.
 func GetNoteByIDWithTx(tx *sqlx.Tx, id int64, sharedDbID int64) (*models.Note, error) {
 	note := &models.Note{}
-	query := `SELECT Id, DatabaseId, Title, Content, FolderId, CreatedAt, UpdatedAt, ImagesJson, MetadataJson, ContentJson
+	query := `SELECT Id, DatabaseId, Title, Content, FolderId, CategoryId, CreatedAt, UpdatedAt, ImagesJson, MetadataJson, ContentJson
 	          FROM Notes WHERE Id = ? AND DatabaseId = ?`
 	err := tx.Get(note, query, id, sharedDbID)
 	if err != nil {
@@ -159,7 +159,7 @@ func GetNoteByIDWithTx(tx *sqlx.Tx, id int64, sharedDbID int64) (*models.Note, e
 // GetAllNotesBySharedDBIDWithTx извлекает все заметки для указанной совместной БД в рамках транзакции.
 func GetAllNotesBySharedDBIDWithTx(tx *sqlx.Tx, sharedDbID int64) ([]models.Note, error) {
 	var notes []models.Note
-	query := `SELECT Id, DatabaseId, Title, Content, FolderId, CreatedAt, UpdatedAt, ImagesJson, MetadataJson, ContentJson
+	query := `SELECT Id, DatabaseId, Title, Content, FolderId, CategoryId, CreatedAt, UpdatedAt, ImagesJson, MetadataJson, ContentJson
 	          FROM Notes WHERE DatabaseId = ? ORDER BY UpdatedAt DESC`
 	err := tx.Select(&notes, query, sharedDbID)
 	if err != nil {
@@ -182,7 +182,7 @@ func UpdateNoteWithTx(tx *sqlx.Tx, note *models.Note) error {
 	note.UpdatedAt = time.Now()
 
 	query := `UPDATE Notes SET
-	            Title = :Title, Content = :Content, FolderId = :FolderId, UpdatedAt = :UpdatedAt,
+	            Title = :Title, Content = :Content, FolderId = :FolderId, CategoryId = :CategoryId, UpdatedAt = :UpdatedAt,
 	            ImagesJson = :ImagesJson, MetadataJson = :MetadataJson, ContentJson = :ContentJson
 	          WHERE Id = :Id AND DatabaseId = :DatabaseId`
 	result, err := tx.NamedExec(query, note)
@@ -196,6 +196,14 @@ func UpdateNoteWithTx(tx *sqlx.Tx, note *models.Note) error {
 	return nil
 }
 
+// SameNoteFields сообщает, что заметка after не отличается от сохраненной before: совпадают заголовок,
+// содержимое, папка, категория, изображения и метаданные. Такую заметку не нужно обновлять,
+// чтобы не сдвигать UpdatedAt (по нему работают сводки, фильтр updated: и сортировка поиска).
+func SameNoteFields(before *models.Note, after *models.Note) bool {
+	return sameNoteRevisionFields(before, after) && sameInt64Ptr(before.CategoryId, after.CategoryId) &&
+		before.ImagesJson == after.ImagesJson && before.MetadataJson == after.MetadataJson
+}
+
 // DeleteNoteWithTx удаляет заметку по ID и ID совместной БД в рамках транзакции.
 func DeleteNoteWithTx(tx *sqlx.Tx, id int64, sharedDbID int64) error {
 	query := `DELETE FROM Notes WHERE Id = ? AND DatabaseId = ?`
@@ -229,7 +237,7 @@ func GetNotesForDatabase(databaseID int64) ([]models.Note, error) {
 	var notes []models.Note
 	// Аналогично GetFoldersForDatabase, для экспорта совместной БД (databaseID != 0)
 	// нам нужны все ее заметки, независимо от OwnerUserId в таблице Notes.
-	query := `SELECT Id, Title, Content, CreatedAt, UpdatedAt, FolderId, DatabaseId, ImagesJson, MetadataJson, ContentJson 
+	query := `SELECT Id, Title, Content, CreatedAt, UpdatedAt, FolderId, CategoryId, DatabaseId, ImagesJson, MetadataJson, ContentJson 
 	          FROM Notes 
 	          WHERE DatabaseId = ? 
 	          ORDER BY UpdatedAt DESC`
@@ -12,15 +12,23 @@ import (
 )
 
 // CreatePinboardNote создает новую заметку на доске в указанной совместной БД.
-// Поле note.DatabaseId должно быть установлено на ID совместной БД.
+// Поле note.DatabaseId должно быть установлено на ID совместной БД;
+// если note.PinboardId не задан, заметка создается на доске по умолчанию.
 // Возвращает ID созданной заметки.
 func CreatePinboardNote(note *models.PinboardNote) (int64, error) {
+	if note.PinboardId == 0 {
+		boardID, err := GetDefaultPinboardID(note.DatabaseId)
+		if err != nil {
+			return 0, fmt.Errorf("CreatePinboardNote: %w", err)
+		}
+		note.PinboardId = boardID
+	}
 	now := time.Now()
 	note.CreatedAt = now
 	note.UpdatedAt = now
 
-	query := `INSERT INTO PinboardNotes (DatabaseId, Title, Content, PositionX, PositionY, Width, Height, BackgroundColor, IconCodePoint, CreatedAt, UpdatedAt)
-	          VALUES (:DatabaseId, :Title, :Content, :PositionX, :PositionY, :Width, :Height, :BackgroundColor, :IconCodePoint, :CreatedAt, :UpdatedAt)`
+	query := `INSERT INTO PinboardNotes (DatabaseId, PinboardId, Title, Content, PositionX, PositionY, Width, Height, BackgroundColor, IconCodePoint, CreatedAt, UpdatedAt)
+	          VALUES (:DatabaseId, :PinboardId, :Title, :Content, :PositionX, :PositionY, :Width, :Height, :BackgroundColor, :IconCodePoint, :CreatedAt, :UpdatedAt)`
 
 	result, err := MainDB.NamedExec(query, note)
 	if err != nil {
@@ -37,7 +45,7 @@ func CreatePinboardNote(note *models.PinboardNote) (int64, error) {
 // GetPinboardNoteByID извлекает заметку с доски по ее ID и ID совместной БД.
 func GetPinboardNoteByID(id int64, sharedDbID int64) (*models.PinboardNote, error) {
 	note := &models.PinboardNote{}
-	query := `SELECT Id, DatabaseId, Title, Content, PositionX, PositionY, Width, Height, BackgroundColor, IconCodePoint, CreatedAt, UpdatedAt
+	query := `SELECT Id, DatabaseId, PinboardId, Title, Content, PositionX, PositionY, Width, Height, BackgroundColor, IconCodePoint, CreatedAt, UpdatedAt
 	          FROM PinboardNotes WHERE Id = ? AND DatabaseId = ?`
 	err := MainDB.Get(note, query, id, sharedDbID)
 	if err != nil {
@@ -52,7 +60,7 @@ func GetPinboardNoteByID(id int64, sharedDbID int64) (*models.PinboardNote, erro
 // GetAllPinboardNotesBySharedDBID извлекает все заметки с доски для указанной совместной БД.
 func GetAllPinboardNotesBySharedDBID(sharedDbID int64) ([]models.PinboardNote, error) {
 	var notes []models.PinboardNote
-	query := `SELECT Id, DatabaseId, Title, Content, PositionX, PositionY, Width, Height, BackgroundColor, IconCodePoint, CreatedAt, UpdatedAt
+	query := `SELECT Id, DatabaseId, PinboardId, Title, Content, PositionX, PositionY, Width, Height, BackgroundColor, IconCodePoint, CreatedAt, UpdatedAt
               FROM PinboardNotes WHERE DatabaseId = ? ORDER BY UpdatedAt DESC`
 	err := MainDB.Select(&notes, query, sharedDbID)
 	if err != nil {
@@ -62,12 +70,14 @@ func GetAllPinboardNotesBySharedDBID(sharedDbID int64) ([]models.PinboardNote, e
 }
 
 // UpdatePinboardNote обновляет существующую заметку на доске в указанной совместной БД.
+// Нулевой note.PinboardId оставляет заметку на ее текущей доске.
 func UpdatePinboardNote(note *models.PinboardNote) error {
 	note.UpdatedAt = time.Now()
 
 	query := `UPDATE PinboardNotes SET 
 	            Title = :Title, Content = :Content, PositionX = :PositionX, PositionY = :PositionY, 
-	            Width = :Width, Height = :Height, BackgroundColor = :BackgroundColor, IconCodePoint = :IconCodePoint, UpdatedAt = :UpdatedAt
+	            Width = :Width, Height = :Height, BackgroundColor = :BackgroundColor, IconCodePoint = :IconCodePoint,
+	            PinboardId = COALESCE(NULLIF(:PinboardId, 0), PinboardId), UpdatedAt = :UpdatedAt
 	          WHERE Id = :Id AND DatabaseId = :DatabaseId`
 	result, err := MainDB.NamedExec(query, note)
 	if err != nil {
@@ -99,13 +109,21 @@ func DeletePinboardNote(id int64, sharedDbID int64) error {
 // --- Функции, работающие с транзакциями ---
 
 // CreatePinboardNoteWithTx создает новую заметку на доске в рамках транзакции.
+// Если note.PinboardId не задан, заметка создается на доске по умолчанию.
 func CreatePinboardNoteWithTx(tx *sqlx.Tx, note *models.PinboardNote) (int64, error) {
+	if note.PinboardId == 0 {
+		boardID, err := GetDefaultPinboardIDWithTx(tx, note.DatabaseId)
+		if err != nil {
+			return 0, fmt.Errorf("CreatePinboardNoteWithTx: %w", err)
+		}
+		note.PinboardId = boardID
+	}
 	now := time.Now()
 	note.CreatedAt = now
 	note.UpdatedAt = now
 
-	query := `INSERT INTO PinboardNotes (DatabaseId, Title, Content, PositionX, PositionY, Width, Height, BackgroundColor, IconCodePoint, CreatedAt, UpdatedAt)
-	          VALUES (:DatabaseId, :Title, :Content, :PositionX, :PositionY, :Width, :Height, :BackgroundColor, :IconCodePoint, :CreatedAt, :UpdatedAt)`
+	query := `INSERT INTO PinboardNotes (DatabaseId, PinboardId, Title, Content, PositionX, PositionY, Width, Height, BackgroundColor, IconCodePoint, CreatedAt, UpdatedAt)
+	          VALUES (:DatabaseId, :PinboardId, :Title, :Content, :PositionX, :PositionY, :Width, :Height, :BackgroundColor, :IconCodePoint, :CreatedAt, :UpdatedAt)`
 	result, err := tx.NamedExec(query, note)
 	if err != nil {
 		return 0, fmt.Errorf("CreatePinboardNoteWithTx: ошибка вставки: %w", err)
@@ -120,7 +138,7 @@ func CreatePinboardNoteWithTx(tx *sqlx.Tx, note *models.PinboardNote) (int64, er
 // GetPinboardNoteByIDWithTx извлекает заметку с доски по ID и ID совместной БД в рамках транзакции.
 func GetPinboardNoteByIDWithTx(tx *sqlx.Tx, id int64, sharedDbID int64) (*models.PinboardNote, error) {
 	note := &models.PinboardNote{}
-	query := `SELECT Id, DatabaseId, Title, Content, PositionX, PositionY, Width, Height, BackgroundColor, IconCodePoint, CreatedAt, UpdatedAt
+	query := `SELECT Id, DatabaseId, PinboardId, Title, Content, PositionX, PositionY, Width, Height, BackgroundColor, IconCodePoint, CreatedAt, UpdatedAt
 	          FROM PinboardNotes WHERE Id = ? AND DatabaseId = ?`
 	err := tx.Get(note, query, id, sharedDbID)
 	if err != nil {
@@ -135,7 +153,7 @@ func GetPinboardNoteByIDWithTx(tx *sqlx.Tx, id int64, sharedDbID int64) (*models
 // GetAllPinboardNotesBySharedDBIDWithTx извлекает все заметки с доски для указанной совместной БД в рамках транзакции.
 func GetAllPinboardNotesBySharedDBIDWithTx(tx *sqlx.Tx, sharedDbID int64) ([]models.PinboardNote, error) {
 	var notes []models.PinboardNote
-	query := `SELECT Id, DatabaseId, Title, Content, PositionX, PositionY, Width, Height, BackgroundColor, IconCodePoint, CreatedAt, UpdatedAt
+	query := `SELECT Id, DatabaseId, PinboardId, Title, Content, PositionX, PositionY, Width, Height, BackgroundColor, IconCodePoint, CreatedAt, UpdatedAt
 	          FROM PinboardNotes WHERE DatabaseId = ? ORDER BY UpdatedAt DESC`
 	err := tx.Select(&notes, query, sharedDbID)
 	if err != nil {
@@ -145,11 +163,13 @@ func GetAllPinboardNotesBySharedDBIDWithTx(tx *sqlx.Tx, sharedDbID int64) ([]mod
 }
 
 // UpdatePinboardNoteWithTx обновляет существующую заметку на доске в рамках транзакции.
+// Нулевой note.PinboardId оставляет заметку на ее текущей доске.
 func UpdatePinboardNoteWithTx(tx *sqlx.Tx, note *models.PinboardNote) error {
 	note.UpdatedAt = time.Now()
 	query := `UPDATE PinboardNotes SET 
 	            Title = :Title, Content = :Content, PositionX = :PositionX, PositionY = :PositionY, 
-	            Width = :Width, Height = :Height, BackgroundColor = :BackgroundColor, IconCodePoint = :IconCodePoint, UpdatedAt = :UpdatedAt
+	            Width = :Width, Height = :Height, BackgroundColor = :BackgroundColor, IconCodePoint = :IconCodePoint,
+	            PinboardId = COALESCE(NULLIF(:PinboardId, 0), PinboardId), UpdatedAt = :UpdatedAt
 	          WHERE Id = :Id AND DatabaseId = :DatabaseId`
 	result, err := tx.NamedExec(query, note)
 	if err != nil {
@@ -162,6 +182,21 @@ func UpdatePinboardNoteWithTx(tx *sqlx.Tx, note *models.PinboardNote) error {
 	return nil
 }
 
+// UpdatePinboardNotePositionWithTx перемещает заметку на доске (например, при автоматической раскладке)
+// в рамках транзакции. Остальные поля заметки не меняются.
+func UpdatePinboardNotePositionWithTx(tx *sqlx.Tx, id int64, sharedDbID int64, x float64, y float64) error {
+	query := `UPDATE PinboardNotes SET PositionX = ?, PositionY = ?, UpdatedAt = ? WHERE Id = ? AND DatabaseId = ?`
+	result, err := tx.Exec(query, x, y, time.Now(), id, sharedDbID)
+	if err != nil {
+		return fmt.Errorf("UpdatePinboardNotePositionWithTx: ошибка обновления ID %d, SharedDBID %d: %w", id, sharedDbID, err)
+	}
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return sql.ErrNoRows // Не найдено для обновления
+	}
+	return nil
+}
+
 // DeletePinboardNoteWithTx удаляет заметку с доски по ID и ID совместной БД в рамках транзакции.
 func DeletePinboardNoteWithTx(tx *sqlx.Tx, id int64, sharedDbID int64) error {
 	query := `DELETE FROM PinboardNotes WHERE Id = ? AND DatabaseId = ?`
@@ -193,7 +228,7 @@ func GetAllPinboardNoteIDsForSharedDBWithTx(tx *sqlx.Tx, sharedDbID int64) ([]in
 // GetPinboardNotesForDatabase извлекает все заметки с доски для указанной ID базы данных.
 func GetPinboardNotesForDatabase(databaseID int64) ([]models.PinboardNote, error) {
 	var notes []models.PinboardNote
-	query := `SELECT Id, Title, Content, CreatedAt, UpdatedAt, DatabaseId, 
+	query := `SELECT Id, Title, Content, CreatedAt, UpdatedAt, DatabaseId, PinboardId, 
 	                 PositionX, PositionY, Width, Height, BackgroundColor, IconCodePoint 
 	          FROM PinboardNotes 
 	          WHERE DatabaseId = ? 
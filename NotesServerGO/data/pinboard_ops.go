@@ -0,0 +1,313 @@
+package data
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"notes_server_go/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// DefaultPinboardName - название доски, которая создается автоматически, если в совместной БД досок нет.
+const DefaultPinboardName = "Основная доска"
+
+// CreatePinboard создает новую доску в указанной совместной БД.
+// Поле board.DatabaseId должно быть установлено.
+// Возвращает ID созданной доски.
+func CreatePinboard(board *models.Pinboard) (int64, error) {
+	now := time.Now()
+	if board.CreatedAt.IsZero() {
+		board.CreatedAt = models.FlexibleTime{Time: now}
+	}
+	board.UpdatedAt = now
+
+	query := `INSERT INTO Pinboards (DatabaseId, Name, Position, CreatedAt, UpdatedAt)
+	          VALUES (:DatabaseId, :Name, :Position, :CreatedAt, :UpdatedAt)`
+
+	result, err := MainDB.NamedExec(query, board)
+	if err != nil {
+		return 0, fmt.Errorf("CreatePinboard: ошибка вставки доски: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("CreatePinboard: ошибка получения LastInsertId: %w", err)
+	}
+	log.Printf("Создана доска с ID: %d для DatabaseId: %d", id, board.DatabaseId)
+	return id, nil
+}
+
+// GetPinboardByID извлекает доску по ее ID и ID совместной БД.
+func GetPinboardByID(id int64, sharedDbID int64) (*models.Pinboard, error) {
+	board := &models.Pinboard{}
+	query := `SELECT Id, DatabaseId, Name, Position, CreatedAt, UpdatedAt
+	          FROM Pinboards WHERE Id = ? AND DatabaseId = ?`
+	err := MainDB.Get(board, query, id, sharedDbID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // Не найдено
+		}
+		return nil, fmt.Errorf("GetPinboardByID: ошибка получения доски ID %d, SharedDBID %d: %w", id, sharedDbID, err)
+	}
+	return board, nil
+}
+
+// GetPinboardsBySharedDBID извлекает все доски совместной БД в порядке Position.
+func GetPinboardsBySharedDBID(sharedDbID int64) ([]models.Pinboard, error) {
+	boards := []models.Pinboard{}
+	query := `SELECT Id, DatabaseId, Name, Position, CreatedAt, UpdatedAt
+	          FROM Pinboards WHERE DatabaseId = ? ORDER BY Position ASC, Id ASC`
+	err := MainDB.Select(&boards, query, sharedDbID)
+	if err != nil {
+		return nil, fmt.Errorf("GetPinboardsBySharedDBID: ошибка получения досок для SharedDBID %d: %w", sharedDbID, err)
+	}
+	return boards, nil
+}
+
+// GetNextPinboardPosition возвращает позицию для новой доски (после всех существующих).
+func GetNextPinboardPosition(sharedDbID int64) (int, error) {
+	var next int
+	err := MainDB.Get(&next, `SELECT COALESCE(MAX(Position) + 1, 0) FROM Pinboards WHERE DatabaseId = ?`, sharedDbID)
+	if err != nil {
+		return 0, fmt.Errorf("GetNextPinboardPosition: ошибка получения позиции для SharedDBID %d: %w", sharedDbID, err)
+	}
+	return next, nil
+}
+
+// CountPinboardNotesByBoard возвращает количество карточек на каждой доске совместной БД (ID доски -> количество).
+func CountPinboardNotesByBoard(sharedDbID int64) (map[int64]int, error) {
+	rows := []struct {
+		PinboardId int64 `db:"PinboardId"`
+		Count      int   `db:"Count"`
+	}{}
+	query := `SELECT PinboardId, COUNT(*) AS Count FROM PinboardNotes
+	          WHERE DatabaseId = ? AND PinboardId IS NOT NULL GROUP BY PinboardId`
+	if err := MainDB.Select(&rows, query, sharedDbID); err != nil {
+		return nil, fmt.Errorf("CountPinboardNotesByBoard: ошибка подсчета карточек для SharedDBID %d: %w", sharedDbID, err)
+	}
+	counts := make(map[int64]int, len(rows))
+	for _, row := range rows {
+		counts[row.PinboardId] = row.Count
+	}
+	return counts, nil
+}
+
+// UpdatePinboard обновляет название и позицию доски.
+// Поля board.Id и board.DatabaseId должны быть установлены.
+func UpdatePinboard(board *models.Pinboard) error {
+	board.UpdatedAt = time.Now()
+
+	query := `UPDATE Pinboards SET Name = :Name, Position = :Position, UpdatedAt = :UpdatedAt
+	          WHERE Id = :Id AND DatabaseId = :DatabaseId`
+	result, err := MainDB.NamedExec(query, board)
+	if err != nil {
+		return fmt.Errorf("UpdatePinboard: ошибка обновления доски ID %d, SharedDBID %d: %w", board.Id, board.DatabaseId, err)
+	}
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return sql.ErrNoRows // Не найдено для обновления
+	}
+	log.Printf("Обновлена доска с ID: %d для DatabaseId: %d", board.Id, board.DatabaseId)
+	return nil
+}
+
+// DeletePinboard удаляет доску из указанной совместной БД.
+// Карточки доски (и их связи) удаляются внешними ключами (ON DELETE CASCADE).
+func DeletePinboard(id int64, sharedDbID int64) error {
+	result, err := MainDB.Exec(`DELETE FROM Pinboards WHERE Id = ? AND DatabaseId = ?`, id, sharedDbID)
+	if err != nil {
+		return fmt.Errorf("DeletePinboard: ошибка удаления доски ID %d, SharedDBID %d: %w", id, sharedDbID, err)
+	}
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return sql.ErrNoRows // Не найдено для удаления
+	}
+	log.Printf("Удалена доска с ID: %d для DatabaseId: %d", id, sharedDbID)
+	return nil
+}
+
+// GetDefaultPinboardID возвращает ID доски по умолчанию (первой по Position) совместной БД.
+// Если досок нет, создает доску DefaultPinboardName.
+func GetDefaultPinboardID(sharedDbID int64) (int64, error) {
+	tx, err := MainDB.Beginx()
+	if err != nil {
+		return 0, fmt.Errorf("GetDefaultPinboardID: ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback()
+	id, err := GetDefaultPinboardIDWithTx(tx, sharedDbID)
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("GetDefaultPinboardID: ошибка фиксации транзакции: %w", err)
+	}
+	return id, nil
+}
+
+// GetPinboardNotesByPinboardID извлекает карточки доски по возрастанию ID.
+func GetPinboardNotesByPinboardID(sharedDbID int64, pinboardID int64) ([]models.PinboardNote, error) {
+	notes := []models.PinboardNote{}
+	query := `SELECT Id, DatabaseId, PinboardId, Title, Content, PositionX, PositionY, Width, Height, BackgroundColor, IconCodePoint, CreatedAt, UpdatedAt
+	          FROM PinboardNotes WHERE DatabaseId = ? AND PinboardId = ? ORDER BY Id ASC`
+	if err := MainDB.Select(&notes, query, sharedDbID, pinboardID); err != nil {
+		return nil, fmt.Errorf("GetPinboardNotesByPinboardID: ошибка получения карточек доски %d, SharedDBID %d: %w", pinboardID, sharedDbID, err)
+	}
+	return notes, nil
+}
+
+// GetConnectionsByPinboardID извлекает связи, обе карточки которых лежат на указанной доске, по возрастанию ID.
+func GetConnectionsByPinboardID(sharedDbID int64, pinboardID int64) ([]models.Connection, error) {
+	conns := []models.Connection{}
+	query := `SELECT c.Id, c.DatabaseId, c.FromNoteId, c.ToNoteId, c.Name, c.ConnectionColor, c.CreatedAt, c.UpdatedAt
+	          FROM Connections c
+	          JOIN PinboardNotes f ON f.Id = c.FromNoteId
+	          JOIN PinboardNotes t ON t.Id = c.ToNoteId
+	          WHERE c.DatabaseId = ? AND f.PinboardId = ? AND t.PinboardId = ?
+	          ORDER BY c.Id ASC`
+	if err := MainDB.Select(&conns, query, sharedDbID, pinboardID, pinboardID); err != nil {
+		return nil, fmt.Errorf("GetConnectionsByPinboardID: ошибка получения связей доски %d, SharedDBID %d: %w", pinboardID, sharedDbID, err)
+	}
+	return conns, nil
+}
+
+// EnsureDefaultPinboards переносит карточки без доски (созданные до появления нескольких досок)
+// на доску по умолчанию их совместной БД, создавая ее при необходимости.
+func EnsureDefaultPinboards() error {
+	dbIDs := []int64{}
+	if err := MainDB.Select(&dbIDs, `SELECT DISTINCT DatabaseId FROM PinboardNotes WHERE PinboardId IS NULL`); err != nil {
+		return fmt.Errorf("EnsureDefaultPinboards: ошибка поиска карточек без доски: %w", err)
+	}
+	for _, dbID := range dbIDs {
+		tx, err := MainDB.Beginx()
+		if err != nil {
+			return fmt.Errorf("EnsureDefaultPinboards: ошибка начала транзакции: %w", err)
+		}
+		boardID, err := GetDefaultPinboardIDWithTx(tx, dbID)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		result, err := tx.Exec(`UPDATE PinboardNotes SET PinboardId = ? WHERE DatabaseId = ? AND PinboardId IS NULL`, boardID, dbID)
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("EnsureDefaultPinboards: ошибка переноса карточек SharedDBID %d: %w", dbID, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("EnsureDefaultPinboards: ошибка фиксации транзакции: %w", err)
+		}
+		moved, _ := result.RowsAffected()
+		log.Printf("Карточки БД %d перенесены на доску по умолчанию %d: %d", dbID, boardID, moved)
+	}
+	return nil
+}
+
+// --- Функции, работающие с транзакциями ---
+
+// CreatePinboardWithTx создает новую доску в рамках транзакции.
+func CreatePinboardWithTx(tx *sqlx.Tx, board *models.Pinboard) (int64, error) {
+	now := time.Now()
+	if board.CreatedAt.IsZero() {
+		board.CreatedAt = models.FlexibleTime{Time: now}
+	}
+	board.UpdatedAt = now
+
+	query := `INSERT INTO Pinboards (DatabaseId, Name, Position, CreatedAt, UpdatedAt)
+	          VALUES (:DatabaseId, :Name, :Position, :CreatedAt, :UpdatedAt)`
+	result, err := tx.NamedExec(query, board)
+	if err != nil {
+		return 0, fmt.Errorf("CreatePinboardWithTx: ошибка вставки доски: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("CreatePinboardWithTx: ошибка получения LastInsertId: %w", err)
+	}
+	return id, nil
+}
+
+// GetPinboardByIDWithTx извлекает доску по ID и ID совместной БД в рамках транзакции.
+func GetPinboardByIDWithTx(tx *sqlx.Tx, id int64, sharedDbID int64) (*models.Pinboard, error) {
+	board := &models.Pinboard{}
+	query := `SELECT Id, DatabaseId, Name, Position, CreatedAt, UpdatedAt
+	          FROM Pinboards WHERE Id = ? AND DatabaseId = ?`
+	err := tx.Get(board, query, id, sharedDbID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // Не найдено
+		}
+		return nil, fmt.Errorf("GetPinboardByIDWithTx: ошибка получения доски ID %d, SharedDBID %d: %w", id, sharedDbID, err)
+	}
+	return board, nil
+}
+
+// GetPinboardsBySharedDBIDWithTx извлекает все доски совместной БД в порядке Position в рамках транзакции.
+func GetPinboardsBySharedDBIDWithTx(tx *sqlx.Tx, sharedDbID int64) ([]models.Pinboard, error) {
+	boards := []models.Pinboard{}
+	query := `SELECT Id, DatabaseId, Name, Position, CreatedAt, UpdatedAt
+	          FROM Pinboards WHERE DatabaseId = ? ORDER BY Position ASC, Id ASC`
+	err := tx.Select(&boards, query, sharedDbID)
+	if err != nil {
+		return nil, fmt.Errorf("GetPinboardsBySharedDBIDWithTx: ошибка получения досок для SharedDBID %d: %w", sharedDbID, err)
+	}
+	return boards, nil
+}
+
+// UpdatePinboardWithTx обновляет название и позицию доски в рамках транзакции.
+func UpdatePinboardWithTx(tx *sqlx.Tx, board *models.Pinboard) error {
+	board.UpdatedAt = time.Now()
+
+	query := `UPDATE Pinboards SET Name = :Name, Position = :Position, UpdatedAt = :UpdatedAt
+	          WHERE Id = :Id AND DatabaseId = :DatabaseId`
+	result, err := tx.NamedExec(query, board)
+	if err != nil {
+		return fmt.Errorf("UpdatePinboardWithTx: ошибка обновления доски ID %d, SharedDBID %d: %w", board.Id, board.DatabaseId, err)
+	}
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return sql.ErrNoRows // Не найдено для обновления
+	}
+	return nil
+}
+
+// DeletePinboardWithTx удаляет доску (вместе с ее карточками) в рамках транзакции.
+func DeletePinboardWithTx(tx *sqlx.Tx, id int64, sharedDbID int64) error {
+	result, err := tx.Exec(`DELETE FROM Pinboards WHERE Id = ? AND DatabaseId = ?`, id, sharedDbID)
+	if err != nil {
+		return fmt.Errorf("DeletePinboardWithTx: ошибка удаления доски ID %d, SharedDBID %d: %w", id, sharedDbID, err)
+	}
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return sql.ErrNoRows // Не найдено для удаления
+	}
+	return nil
+}
+
+// GetAllPinboardIDsForSharedDBWithTx извлекает ID всех досок совместной БД в рамках транзакции.
+func GetAllPinboardIDsForSharedDBWithTx(tx *sqlx.Tx, sharedDbID int64) ([]int64, error) {
+	ids := []int64{}
+	err := tx.Select(&ids, `SELECT Id FROM Pinboards WHERE DatabaseId = ?`, sharedDbID)
+	if err != nil {
+		return nil, fmt.Errorf("GetAllPinboardIDsForSharedDBWithTx: ошибка получения ID для SharedDBID %d: %w", sharedDbID, err)
+	}
+	return ids, nil
+}
+
+// GetDefaultPinboardIDWithTx возвращает ID доски по умолчанию (первой по Position) совместной БД
+// в рамках транзакции. Если досок нет, создает доску DefaultPinboardName.
+func GetDefaultPinboardIDWithTx(tx *sqlx.Tx, sharedDbID int64) (int64, error) {
+	var id int64
+	err := tx.Get(&id, `SELECT Id FROM Pinboards WHERE DatabaseId = ? ORDER BY Position ASC, Id ASC LIMIT 1`, sharedDbID)
+	if err == nil {
+		return id, nil
+	}
+	if err != sql.ErrNoRows {
+		return 0, fmt.Errorf("GetDefaultPinboardIDWithTx: ошибка получения доски для SharedDBID %d: %w", sharedDbID, err)
+	}
+	id, err = CreatePinboardWithTx(tx, &models.Pinboard{DatabaseId: sharedDbID, Name: DefaultPinboardName})
+	if err != nil {
+		return 0, fmt.Errorf("GetDefaultPinboardIDWithTx: %w", err)
+	}
+	log.Printf("Создана доска по умолчанию с ID: %d для DatabaseId: %d", id, sharedDbID)
+	return id, nil
+}
@@ -0,0 +1,82 @@
+package data
+
+import (
+	"fmt"
+	"log"
+
+	"notes_server_go/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// EnsurePinboardSpatialIndex добавляет в R*Tree-индекс PinboardNotesRTree карточки доски,
+// которых в нем нет (созданные до появления индекса). Дальше индекс поддерживается триггерами.
+func EnsurePinboardSpatialIndex() error {
+	result, err := MainDB.Exec(`INSERT INTO PinboardNotesRTree (Id, MinX, MaxX, MinY, MaxY, DatabaseId)
+	          SELECT Id, PositionX, PositionX + MAX(Width, 0), PositionY, PositionY + MAX(Height, 0), DatabaseId
+	          FROM PinboardNotes WHERE Id NOT IN (SELECT Id FROM PinboardNotesRTree)`)
+	if err != nil {
+		return fmt.Errorf("EnsurePinboardSpatialIndex: ошибка заполнения индекса: %w", err)
+	}
+	if added, _ := result.RowsAffected(); added > 0 {
+		log.Printf("В пространственный индекс доски добавлено карточек: %d", added)
+	}
+	return nil
+}
+
+// GetPinboardNotesInRect извлекает карточки доски pinboardID совместной БД, пересекающие прямоугольник
+// [minX, maxX] x [minY, maxY], по возрастанию ID (не больше limit). Кандидаты выбираются по R*Tree-индексу,
+// точное пересечение проверяется по координатам карточки (индекс хранит координаты с округлением).
+func GetPinboardNotesInRect(sharedDbID int64, pinboardID int64, minX, minY, maxX, maxY float64, limit int) ([]models.PinboardNote, error) {
+	notes := []models.PinboardNote{}
+	query := `SELECT p.Id, p.DatabaseId, p.PinboardId, p.Title, p.Content, p.PositionX, p.PositionY, p.Width, p.Height, p.BackgroundColor, p.IconCodePoint, p.CreatedAt, p.UpdatedAt
+	          FROM PinboardNotesRTree r JOIN PinboardNotes p ON p.Id = r.Id
+	          WHERE r.MaxX >= ? AND r.MinX <= ? AND r.MaxY >= ? AND r.MinY <= ? AND r.DatabaseId = ?
+	            AND p.DatabaseId = ? AND p.PinboardId = ?
+	            AND p.PositionX + MAX(p.Width, 0) >= ? AND p.PositionX <= ?
+	            AND p.PositionY + MAX(p.Height, 0) >= ? AND p.PositionY <= ?
+	          ORDER BY p.Id ASC LIMIT ?`
+	err := MainDB.Select(&notes, query, minX, maxX, minY, maxY, sharedDbID, sharedDbID, pinboardID, minX, maxX, minY, maxY, limit)
+	if err != nil {
+		return nil, fmt.Errorf("GetPinboardNotesInRect: ошибка получения карточек для SharedDBID %d: %w", sharedDbID, err)
+	}
+	return notes, nil
+}
+
+// GetPinboardNotesByIDs извлекает карточки доски совместной БД по списку ID.
+func GetPinboardNotesByIDs(sharedDbID int64, ids []int64) ([]models.PinboardNote, error) {
+	notes := []models.PinboardNote{}
+	if len(ids) == 0 {
+		return notes, nil
+	}
+	query, args, err := sqlx.In(`SELECT Id, DatabaseId, PinboardId, Title, Content, PositionX, PositionY, Width, Height, BackgroundColor, IconCodePoint, CreatedAt, UpdatedAt
+	          FROM PinboardNotes WHERE DatabaseId = ? AND Id IN (?) ORDER BY Id ASC`, sharedDbID, ids)
+	if err != nil {
+		return nil, fmt.Errorf("GetPinboardNotesByIDs: ошибка построения запроса: %w", err)
+	}
+	if err := MainDB.Select(&notes, MainDB.Rebind(query), args...); err != nil {
+		return nil, fmt.Errorf("GetPinboardNotesByIDs: ошибка получения карточек для SharedDBID %d: %w", sharedDbID, err)
+	}
+	return notes, nil
+}
+
+// GetConnectionsNearRect извлекает связи доски pinboardID совместной БД, у которых прямоугольник, охватывающий
+// обе соединяемые карточки, пересекает [minX, maxX] x [minY, maxY]. Это кандидаты: видимость самой связи
+// (карточки или отрезка между ними) проверяется вызывающей стороной.
+func GetConnectionsNearRect(sharedDbID int64, pinboardID int64, minX, minY, maxX, maxY float64) ([]models.Connection, error) {
+	conns := []models.Connection{}
+	query := `SELECT c.Id, c.DatabaseId, c.FromNoteId, c.ToNoteId, c.Name, c.ConnectionColor, c.CreatedAt, c.UpdatedAt
+	          FROM Connections c
+	          JOIN PinboardNotes f ON f.Id = c.FromNoteId
+	          JOIN PinboardNotes t ON t.Id = c.ToNoteId
+	          WHERE c.DatabaseId = ? AND f.PinboardId = ? AND t.PinboardId = ?
+	            AND MAX(f.PositionX + MAX(f.Width, 0), t.PositionX + MAX(t.Width, 0)) >= ?
+	            AND MIN(f.PositionX, t.PositionX) <= ?
+	            AND MAX(f.PositionY + MAX(f.Height, 0), t.PositionY + MAX(t.Height, 0)) >= ?
+	            AND MIN(f.PositionY, t.PositionY) <= ?
+	          ORDER BY c.Id ASC`
+	if err := MainDB.Select(&conns, query, sharedDbID, pinboardID, pinboardID, minX, maxX, minY, maxY); err != nil {
+		return nil, fmt.Errorf("GetConnectionsNearRect: ошибка получения связей для SharedDBID %d: %w", sharedDbID, err)
+	}
+	return conns, nil
+}
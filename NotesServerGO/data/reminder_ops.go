@@ -0,0 +1,96 @@
+package data
+
+import (
+	"fmt"
+	"time"
+
+	"notes_server_go/models"
+)
+
+// Статусы учета напоминаний в SentReminders.
+const (
+	reminderStatusPending = "pending" // Напоминание захвачено для отправки
+	reminderStatusSent    = "sent"
+)
+
+// GetActiveSharedDatabases извлекает все активные совместные БД (для фоновых задач сервера).
+func GetActiveSharedDatabases() ([]models.SharedDatabase, error) {
+	dbs := []models.SharedDatabase{}
+	query := `SELECT Id, Name, OwnerUserId, CreatedAt, UpdatedAt, TimeZone FROM SharedDatabases
+	          WHERE (IsActive = 1 OR IsActive IS NULL) AND DeletedAt IS NULL ORDER BY Id ASC`
+	if err := MainDB.Select(&dbs, query); err != nil {
+		return nil, fmt.Errorf("GetActiveSharedDatabases: ошибка получения совместных БД: %w", err)
+	}
+	return dbs, nil
+}
+
+// ClaimReminder захватывает отправку напоминания о повторении записи расписания по каналу channel.
+// Возвращает false, если напоминание уже было отправлено или захвачено (в том числе до перезапуска сервера).
+// occurrenceDate - исходная дата повторения (по правилу), чтобы перенос повторения не приводил к повторной отправке.
+func ClaimReminder(sharedDbID int64, entryID int64, occurrenceDate string, channel string, startsAt time.Time) (bool, error) {
+	result, err := MainDB.Exec(`INSERT OR IGNORE INTO SentReminders (DatabaseId, ScheduleEntryId, OccurrenceDate, Channel, StartsAt, Status, ClaimedAt)
+	          VALUES (?, ?, ?, ?, ?, ?, ?)`, sharedDbID, entryID, occurrenceDate, channel, startsAt, reminderStatusPending, time.Now())
+	if err != nil {
+		return false, fmt.Errorf("ClaimReminder: ошибка захвата напоминания записи %d на %s (%s): %w", entryID, occurrenceDate, channel, err)
+	}
+	rowsAffected, _ := result.RowsAffected()
+	return rowsAffected == 1, nil
+}
+
+// MarkReminderSent отмечает захваченное напоминание как отправленное.
+func MarkReminderSent(entryID int64, occurrenceDate string, channel string) error {
+	_, err := MainDB.Exec(`UPDATE SentReminders SET Status = ?, SentAt = ? WHERE ScheduleEntryId = ? AND OccurrenceDate = ? AND Channel = ?`,
+		reminderStatusSent, time.Now(), entryID, occurrenceDate, channel)
+	if err != nil {
+		return fmt.Errorf("MarkReminderSent: ошибка обновления напоминания записи %d на %s (%s): %w", entryID, occurrenceDate, channel, err)
+	}
+	return nil
+}
+
+// ReleaseReminder снимает захват неотправленного напоминания, чтобы попытка повторилась при следующем проходе.
+func ReleaseReminder(entryID int64, occurrenceDate string, channel string) error {
+	_, err := MainDB.Exec(`DELETE FROM SentReminders WHERE ScheduleEntryId = ? AND OccurrenceDate = ? AND Channel = ? AND Status = ?`,
+		entryID, occurrenceDate, channel, reminderStatusPending)
+	if err != nil {
+		return fmt.Errorf("ReleaseReminder: ошибка снятия захвата напоминания записи %d на %s (%s): %w", entryID, occurrenceDate, channel, err)
+	}
+	return nil
+}
+
+// GetDeliveredReminderRecipients возвращает ID пользователей, которым напоминание уже доставлено по каналу channel.
+func GetDeliveredReminderRecipients(entryID int64, occurrenceDate string, channel string) (map[int64]bool, error) {
+	var userIDs []int64
+	err := MainDB.Select(&userIDs, `SELECT UserId FROM SentReminderRecipients WHERE ScheduleEntryId = ? AND OccurrenceDate = ? AND Channel = ?`,
+		entryID, occurrenceDate, channel)
+	if err != nil {
+		return nil, fmt.Errorf("GetDeliveredReminderRecipients: ошибка получения получателей напоминания записи %d на %s (%s): %w", entryID, occurrenceDate, channel, err)
+	}
+	delivered := make(map[int64]bool, len(userIDs))
+	for _, userID := range userIDs {
+		delivered[userID] = true
+	}
+	return delivered, nil
+}
+
+// MarkReminderRecipientDelivered отмечает, что напоминание доставлено пользователю userID по каналу channel.
+func MarkReminderRecipientDelivered(sharedDbID int64, entryID int64, occurrenceDate string, channel string, userID int64, startsAt time.Time) error {
+	_, err := MainDB.Exec(`INSERT OR IGNORE INTO SentReminderRecipients (DatabaseId, ScheduleEntryId, OccurrenceDate, Channel, UserId, StartsAt, SentAt)
+	          VALUES (?, ?, ?, ?, ?, ?, ?)`, sharedDbID, entryID, occurrenceDate, channel, userID, startsAt, time.Now())
+	if err != nil {
+		return fmt.Errorf("MarkReminderRecipientDelivered: ошибка учета доставки напоминания записи %d на %s (%s) пользователю %d: %w", entryID, occurrenceDate, channel, userID, err)
+	}
+	return nil
+}
+
+// PurgeSentReminders удаляет учет напоминаний (и их получателей) о повторениях, начавшихся раньше before.
+func PurgeSentReminders(before time.Time) (int64, error) {
+	if _, err := MainDB.Exec(`DELETE FROM SentReminderRecipients WHERE StartsAt < ?`, before); err != nil {
+		return 0, fmt.Errorf("PurgeSentReminders: ошибка удаления получателей старых напоминаний: %w", err)
+	}
+	result, err := MainDB.Exec(`DELETE FROM SentReminders WHERE StartsAt < ?`, before)
+	if err != nil {
+		return 0, fmt.Errorf("PurgeSentReminders: ошибка удаления старых напоминаний: %w", err)
+	}
+	rowsAffected, _ := result.RowsAffected()
+	return rowsAffected, nil
+}
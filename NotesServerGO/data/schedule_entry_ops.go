@@ -19,8 +19,8 @@ func CreateScheduleEntry(entry *models.ScheduleEntry) (int64, error) {
 	entry.CreatedAt = now
 	entry.UpdatedAt = now
 
-	query := `INSERT INTO ScheduleEntries (DatabaseId, Time, Date, Note, DynamicFieldsJson, RecurrenceJson, TagsJson, CreatedAt, UpdatedAt)
-	          VALUES (:DatabaseId, :Time, :Date, :Note, :DynamicFieldsJson, :RecurrenceJson, :TagsJson, :CreatedAt, :UpdatedAt)`
+	query := `INSERT INTO ScheduleEntries (DatabaseId, Time, Date, Note, DynamicFieldsJson, RecurrenceJson, TagsJson, CategoryId, TimeZone, AssigneeUserId, CreatedByUserId, CreatedAt, UpdatedAt)
+	          VALUES (:DatabaseId, :Time, :Date, :Note, :DynamicFieldsJson, :RecurrenceJson, :TagsJson, :CategoryId, :TimeZone, :AssigneeUserId, :CreatedByUserId, :CreatedAt, :UpdatedAt)`
 
 	result, err := MainDB.NamedExec(query, entry)
 	if err != nil {
@@ -31,6 +31,9 @@ func CreateScheduleEntry(entry *models.ScheduleEntry) (int64, error) {
 	if err != nil {
 		return 0, fmt.Errorf("CreateScheduleEntry: ошибка при получении LastInsertId: %w", err)
 	}
+	if err := rebuildScheduleTags(MainDB, newID, entry.DatabaseId, entry.TagsJson); err != nil {
+		return 0, fmt.Errorf("CreateScheduleEntry: %w", err)
+	}
 	log.Printf("Создана запись ScheduleEntry с ID: %d для DatabaseId: %d", newID, entry.DatabaseId)
 	return newID, nil
 }
@@ -38,7 +41,7 @@ func CreateScheduleEntry(entry *models.ScheduleEntry) (int64, error) {
 // GetScheduleEntryByID извлекает запись расписания по ее ID и ID совместной БД.
 func GetScheduleEntryByID(id int64, sharedDbID int64) (*models.ScheduleEntry, error) {
 	entry := &models.ScheduleEntry{}
-	query := `SELECT Id, DatabaseId, Time, Date, Note, DynamicFieldsJson, RecurrenceJson, TagsJson, CreatedAt, UpdatedAt
+	query := `SELECT Id, DatabaseId, Time, Date, Note, DynamicFieldsJson, RecurrenceJson, TagsJson, CategoryId, TimeZone, AssigneeUserId, CreatedByUserId, CreatedAt, UpdatedAt
 	          FROM ScheduleEntries WHERE Id = ? AND DatabaseId = ?`
 	err := MainDB.Get(entry, query, id, sharedDbID)
 	if err != nil {
@@ -57,7 +60,7 @@ func UpdateScheduleEntry(entry *models.ScheduleEntry) error {
 
 	query := `UPDATE ScheduleEntries SET 
 			  Time = :Time, Date = :Date, Note = :Note, DynamicFieldsJson = :DynamicFieldsJson, 
-			  RecurrenceJson = :RecurrenceJson, TagsJson = :TagsJson, UpdatedAt = :UpdatedAt
+			  RecurrenceJson = :RecurrenceJson, TagsJson = :TagsJson, CategoryId = :CategoryId, TimeZone = :TimeZone, AssigneeUserId = :AssigneeUserId, UpdatedAt = :UpdatedAt
 	          WHERE Id = :Id AND DatabaseId = :DatabaseId`
 
 	result, err := MainDB.NamedExec(query, entry)
@@ -69,6 +72,9 @@ func UpdateScheduleEntry(entry *models.ScheduleEntry) error {
 		log.Printf("UpdateScheduleEntry: Запись с ID %d для DatabaseId %d не найдена для обновления.", entry.Id, entry.DatabaseId)
 		return sql.ErrNoRows // или nil, если "не найдено для обновления" это не ошибка
 	}
+	if err := rebuildScheduleTags(MainDB, entry.Id, entry.DatabaseId, entry.TagsJson); err != nil {
+		return fmt.Errorf("UpdateScheduleEntry: %w", err)
+	}
 	log.Printf("Обновлена запись ScheduleEntry с ID: %d для DatabaseId: %d", entry.Id, entry.DatabaseId)
 	return nil
 }
@@ -76,7 +82,7 @@ func UpdateScheduleEntry(entry *models.ScheduleEntry) error {
 // GetScheduleEntriesByDBID извлекает все записи расписания для указанной совместной БД.
 func GetScheduleEntriesByDBID(sharedDbID int64) ([]models.ScheduleEntry, error) {
 	var entries []models.ScheduleEntry
-	query := `SELECT Id, DatabaseId, Time, Date, Note, DynamicFieldsJson, RecurrenceJson, TagsJson, CreatedAt, UpdatedAt
+	query := `SELECT Id, DatabaseId, Time, Date, Note, DynamicFieldsJson, RecurrenceJson, TagsJson, CategoryId, TimeZone, AssigneeUserId, CreatedByUserId, CreatedAt, UpdatedAt
 	          FROM ScheduleEntries WHERE DatabaseId = ? ORDER BY Id ASC`
 	err := MainDB.Select(&entries, query, sharedDbID)
 	if err != nil {
@@ -110,8 +116,8 @@ func CreateScheduleEntryWithTx(tx *sqlx.Tx, entry *models.ScheduleEntry) (int64,
 	entry.CreatedAt = now
 	entry.UpdatedAt = now
 
-	query := `INSERT INTO ScheduleEntries (DatabaseId, Time, Date, Note, DynamicFieldsJson, RecurrenceJson, TagsJson, CreatedAt, UpdatedAt)
-	          VALUES (:DatabaseId, :Time, :Date, :Note, :DynamicFieldsJson, :RecurrenceJson, :TagsJson, :CreatedAt, :UpdatedAt)`
+	query := `INSERT INTO ScheduleEntries (DatabaseId, Time, Date, Note, DynamicFieldsJson, RecurrenceJson, TagsJson, CategoryId, TimeZone, AssigneeUserId, CreatedByUserId, CreatedAt, UpdatedAt)
+	          VALUES (:DatabaseId, :Time, :Date, :Note, :DynamicFieldsJson, :RecurrenceJson, :TagsJson, :CategoryId, :TimeZone, :AssigneeUserId, :CreatedByUserId, :CreatedAt, :UpdatedAt)`
 
 	result, err := tx.NamedExec(query, entry)
 	if err != nil {
@@ -121,13 +127,16 @@ func CreateScheduleEntryWithTx(tx *sqlx.Tx, entry *models.ScheduleEntry) (int64,
 	if err != nil {
 		return 0, fmt.Errorf("CreateScheduleEntryWithTx: ошибка LastInsertId: %w", err)
 	}
+	if err := rebuildScheduleTags(tx, newID, entry.DatabaseId, entry.TagsJson); err != nil {
+		return 0, fmt.Errorf("CreateScheduleEntryWithTx: %w", err)
+	}
 	return newID, nil
 }
 
 // GetScheduleEntryByIDWithTx извлекает запись расписания по ID и ID совместной БД в рамках транзакции.
 func GetScheduleEntryByIDWithTx(tx *sqlx.Tx, id int64, sharedDbID int64) (*models.ScheduleEntry, error) {
 	entry := &models.ScheduleEntry{}
-	query := `SELECT Id, DatabaseId, Time, Date, Note, DynamicFieldsJson, RecurrenceJson, TagsJson, CreatedAt, UpdatedAt
+	query := `SELECT Id, DatabaseId, Time, Date, Note, DynamicFieldsJson, RecurrenceJson, TagsJson, CategoryId, TimeZone, AssigneeUserId, CreatedByUserId, CreatedAt, UpdatedAt
 	          FROM ScheduleEntries WHERE Id = ? AND DatabaseId = ?`
 	err := tx.Get(entry, query, id, sharedDbID)
 	if err != nil {
@@ -145,7 +154,7 @@ func UpdateScheduleEntryWithTx(tx *sqlx.Tx, entry *models.ScheduleEntry) error {
 
 	query := `UPDATE ScheduleEntries SET 
 			  Time = :Time, Date = :Date, Note = :Note, DynamicFieldsJson = :DynamicFieldsJson, 
-			  RecurrenceJson = :RecurrenceJson, TagsJson = :TagsJson, UpdatedAt = :UpdatedAt
+			  RecurrenceJson = :RecurrenceJson, TagsJson = :TagsJson, CategoryId = :CategoryId, TimeZone = :TimeZone, AssigneeUserId = :AssigneeUserId, UpdatedAt = :UpdatedAt
 	          WHERE Id = :Id AND DatabaseId = :DatabaseId`
 	result, err := tx.NamedExec(query, entry)
 	if err != nil {
@@ -155,13 +164,16 @@ func UpdateScheduleEntryWithTx(tx *sqlx.Tx, entry *models.ScheduleEntry) error {
 	if rowsAffected == 0 {
 		return sql.ErrNoRows // Не найдено для обновления
 	}
+	if err := rebuildScheduleTags(tx, entry.Id, entry.DatabaseId, entry.TagsJson); err != nil {
+		return fmt.Errorf("UpdateScheduleEntryWithTx: %w", err)
+	}
 	return nil
 }
 
 // GetScheduleEntriesByDBIDWithTx извлекает все записи расписания для указанной совместной БД в рамках транзакции.
 func GetScheduleEntriesByDBIDWithTx(tx *sqlx.Tx, sharedDbID int64) ([]models.ScheduleEntry, error) {
 	var entries []models.ScheduleEntry
-	query := `SELECT Id, DatabaseId, Time, Date, Note, DynamicFieldsJson, RecurrenceJson, TagsJson, CreatedAt, UpdatedAt
+	query := `SELECT Id, DatabaseId, Time, Date, Note, DynamicFieldsJson, RecurrenceJson, TagsJson, CategoryId, TimeZone, AssigneeUserId, CreatedByUserId, CreatedAt, UpdatedAt
 	          FROM ScheduleEntries WHERE DatabaseId = ? ORDER BY Id ASC`
 	err := tx.Select(&entries, query, sharedDbID)
 	if err != nil {
@@ -201,7 +213,7 @@ func GetAllScheduleEntryIDsForDBWithTx(tx *sqlx.Tx, sharedDbID int64) ([]int64,
 // GetScheduleEntriesForDatabase извлекает все записи расписания для указанной ID базы данных.
 func GetScheduleEntriesForDatabase(databaseID int64) ([]models.ScheduleEntry, error) {
 	var entries []models.ScheduleEntry
-	query := `SELECT Id, DatabaseId, Time, Date, Note, DynamicFieldsJson, RecurrenceJson, TagsJson, CreatedAt, UpdatedAt 
+	query := `SELECT Id, DatabaseId, Time, Date, Note, DynamicFieldsJson, RecurrenceJson, TagsJson, CategoryId, TimeZone, AssigneeUserId, CreatedByUserId, CreatedAt, UpdatedAt 
 	          FROM ScheduleEntries 
 	          WHERE DatabaseId = ? 
 	          ORDER BY Id ASC`
@@ -211,3 +223,15 @@ func GetScheduleEntriesForDatabase(databaseID int64) ([]models.ScheduleEntry, er
 	}
 	return entries, nil
 }
+
+// ClearScheduleAssigneeForUserWithTx снимает пользователя с записей расписания совместной БД, где он ответственный
+// (при выходе или удалении участника, в той же транзакции). Иначе клиенты присылали бы при синхронизации
+// ответственного не из участников.
+func ClearScheduleAssigneeForUserWithTx(tx *sqlx.Tx, sharedDbID int64, userID int64) error {
+	_, err := tx.Exec(`UPDATE ScheduleEntries SET AssigneeUserId = NULL, UpdatedAt = ? WHERE DatabaseId = ? AND AssigneeUserId = ?`,
+		time.Now(), sharedDbID, userID)
+	if err != nil {
+		return fmt.Errorf("ClearScheduleAssigneeForUserWithTx: ошибка снятия ответственного %d с записей SharedDBID %d: %w", userID, sharedDbID, err)
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package data
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"notes_server_go/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// CreateScheduleException создает исключение для повторения записи расписания.
+// Поля exception.ScheduleEntryId и exception.DatabaseId должны быть установлены.
+// Возвращает ID созданного исключения.
+func CreateScheduleException(exception *models.ScheduleEntryException) (int64, error) {
+	now := time.Now()
+	exception.CreatedAt = models.FlexibleTime{Time: now}
+	exception.UpdatedAt = now
+
+	query := `INSERT INTO ScheduleEntryExceptions (ScheduleEntryId, DatabaseId, OriginalDate, Type, NewDate, NewTime, NewNote, CreatedAt, UpdatedAt)
+	          VALUES (:ScheduleEntryId, :DatabaseId, :OriginalDate, :Type, :NewDate, :NewTime, :NewNote, :CreatedAt, :UpdatedAt)`
+	result, err := MainDB.NamedExec(query, exception)
+	if err != nil {
+		return 0, fmt.Errorf("CreateScheduleException: ошибка вставки исключения для записи %d: %w", exception.ScheduleEntryId, err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("CreateScheduleException: ошибка получения LastInsertId: %w", err)
+	}
+	log.Printf("Создано исключение %d (%s, %s) для записи расписания %d", id, exception.Type, exception.OriginalDate, exception.ScheduleEntryId)
+	return id, nil
+}
+
+// GetScheduleExceptionByID извлекает исключение по ID и ID совместной БД.
+func GetScheduleExceptionByID(id int64, sharedDbID int64) (*models.ScheduleEntryException, error) {
+	exception := &models.ScheduleEntryException{}
+	query := `SELECT Id, ScheduleEntryId, DatabaseId, OriginalDate, Type, NewDate, NewTime, NewNote, CreatedAt, UpdatedAt
+	          FROM ScheduleEntryExceptions WHERE Id = ? AND DatabaseId = ?`
+	err := MainDB.Get(exception, query, id, sharedDbID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // Не найдено
+		}
+		return nil, fmt.Errorf("GetScheduleExceptionByID: ошибка получения исключения ID %d, SharedDBID %d: %w", id, sharedDbID, err)
+	}
+	return exception, nil
+}
+
+// GetScheduleExceptionByOriginalDate извлекает исключение записи расписания для указанной исходной даты повторения.
+func GetScheduleExceptionByOriginalDate(entryID int64, originalDate string) (*models.ScheduleEntryException, error) {
+	exception := &models.ScheduleEntryException{}
+	query := `SELECT Id, ScheduleEntryId, DatabaseId, OriginalDate, Type, NewDate, NewTime, NewNote, CreatedAt, UpdatedAt
+	          FROM ScheduleEntryExceptions WHERE ScheduleEntryId = ? AND OriginalDate = ?`
+	err := MainDB.Get(exception, query, entryID, originalDate)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // Не найдено
+		}
+		return nil, fmt.Errorf("GetScheduleExceptionByOriginalDate: ошибка получения исключения записи %d на %s: %w", entryID, originalDate, err)
+	}
+	return exception, nil
+}
+
+// GetScheduleExceptionsByEntryID извлекает все исключения записи расписания.
+func GetScheduleExceptionsByEntryID(entryID int64, sharedDbID int64) ([]models.ScheduleEntryException, error) {
+	exceptions := []models.ScheduleEntryException{}
+	query := `SELECT Id, ScheduleEntryId, DatabaseId, OriginalDate, Type, NewDate, NewTime, NewNote, CreatedAt, UpdatedAt
+	          FROM ScheduleEntryExceptions WHERE ScheduleEntryId = ? AND DatabaseId = ? ORDER BY OriginalDate ASC`
+	err := MainDB.Select(&exceptions, query, entryID, sharedDbID)
+	if err != nil {
+		return nil, fmt.Errorf("GetScheduleExceptionsByEntryID: ошибка получения исключений записи %d: %w", entryID, err)
+	}
+	return exceptions, nil
+}
+
+// GetScheduleExceptionsBySharedDBID извлекает все исключения расписания совместной БД.
+func GetScheduleExceptionsBySharedDBID(sharedDbID int64) ([]models.ScheduleEntryException, error) {
+	exceptions := []models.ScheduleEntryException{}
+	query := `SELECT Id, ScheduleEntryId, DatabaseId, OriginalDate, Type, NewDate, NewTime, NewNote, CreatedAt, UpdatedAt
+	          FROM ScheduleEntryExceptions WHERE DatabaseId = ? ORDER BY ScheduleEntryId ASC, OriginalDate ASC`
+	err := MainDB.Select(&exceptions, query, sharedDbID)
+	if err != nil {
+		return nil, fmt.Errorf("GetScheduleExceptionsBySharedDBID: ошибка получения исключений для SharedDBID %d: %w", sharedDbID, err)
+	}
+	return exceptions, nil
+}
+
+// UpdateScheduleException обновляет тип и новые значения исключения.
+// Поля exception.Id и exception.DatabaseId должны быть установлены.
+func UpdateScheduleException(exception *models.ScheduleEntryException) error {
+	exception.UpdatedAt = time.Now()
+
+	query := `UPDATE ScheduleEntryExceptions SET Type = :Type, NewDate = :NewDate, NewTime = :NewTime, NewNote = :NewNote, UpdatedAt = :UpdatedAt
+	          WHERE Id = :Id AND DatabaseId = :DatabaseId`
+	result, err := MainDB.NamedExec(query, exception)
+	if err != nil {
+		return fmt.Errorf("UpdateScheduleException: ошибка обновления исключения ID %d, SharedDBID %d: %w", exception.Id, exception.DatabaseId, err)
+	}
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return sql.ErrNoRows // Не найдено для обновления
+	}
+	return nil
+}
+
+// DeleteScheduleException удаляет исключение - повторение снова следует правилу.
+func DeleteScheduleException(id int64, sharedDbID int64) error {
+	result, err := MainDB.Exec(`DELETE FROM ScheduleEntryExceptions WHERE Id = ? AND DatabaseId = ?`, id, sharedDbID)
+	if err != nil {
+		return fmt.Errorf("DeleteScheduleException: ошибка удаления исключения ID %d, SharedDBID %d: %w", id, sharedDbID, err)
+	}
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return sql.ErrNoRows // Не найдено для удаления
+	}
+	log.Printf("Удалено исключение расписания с ID: %d для DatabaseId: %d", id, sharedDbID)
+	return nil
+}
+
+// --- Функции, работающие с транзакциями ---
+
+// GetScheduleExceptionsBySharedDBIDWithTx извлекает все исключения расписания совместной БД в рамках транзакции.
+func GetScheduleExceptionsBySharedDBIDWithTx(tx *sqlx.Tx, sharedDbID int64) ([]models.ScheduleEntryException, error) {
+	exceptions := []models.ScheduleEntryException{}
+	query := `SELECT Id, ScheduleEntryId, DatabaseId, OriginalDate, Type, NewDate, NewTime, NewNote, CreatedAt, UpdatedAt
+	          FROM ScheduleEntryExceptions WHERE DatabaseId = ? ORDER BY ScheduleEntryId ASC, OriginalDate ASC`
+	err := tx.Select(&exceptions, query, sharedDbID)
+	if err != nil {
+		return nil, fmt.Errorf("GetScheduleExceptionsBySharedDBIDWithTx: ошибка получения исключений для SharedDBID %d: %w", sharedDbID, err)
+	}
+	return exceptions, nil
+}
+
+// ReplaceScheduleExceptionsWithTx заменяет все исключения расписания совместной БД переданным набором в рамках транзакции.
+// Поле ScheduleEntryId каждого исключения должно содержать серверный ID записи. Дубликаты по исходной дате пропускаются.
+func ReplaceScheduleExceptionsWithTx(tx *sqlx.Tx, sharedDbID int64, exceptions []models.ScheduleEntryException) error {
+	if _, err := tx.Exec(`DELETE FROM ScheduleEntryExceptions WHERE DatabaseId = ?`, sharedDbID); err != nil {
+		return fmt.Errorf("ReplaceScheduleExceptionsWithTx: ошибка удаления исключений для SharedDBID %d: %w", sharedDbID, err)
+	}
+
+	query := `INSERT OR IGNORE INTO ScheduleEntryExceptions (ScheduleEntryId, DatabaseId, OriginalDate, Type, NewDate, NewTime, NewNote, CreatedAt, UpdatedAt)
+	          VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	now := time.Now()
+	for _, exception := range exceptions {
+		createdAt := exception.CreatedAt.Time
+		if createdAt.IsZero() {
+			createdAt = now
+		}
+		_, err := tx.Exec(query, exception.ScheduleEntryId, sharedDbID, exception.OriginalDate, exception.Type,
+			exception.NewDate, exception.NewTime, exception.NewNote, createdAt, now)
+		if err != nil {
+			return fmt.Errorf("ReplaceScheduleExceptionsWithTx: ошибка вставки исключения записи %d на %s: %w", exception.ScheduleEntryId, exception.OriginalDate, err)
+		}
+	}
+	return nil
+}
+
+// CreateScheduleExceptionWithTx создает исключение для повторения записи расписания в рамках транзакции.
+func CreateScheduleExceptionWithTx(tx *sqlx.Tx, exception *models.ScheduleEntryException) (int64, error) {
+	now := time.Now()
+	exception.CreatedAt = models.FlexibleTime{Time: now}
+	exception.UpdatedAt = now
+
+	query := `INSERT INTO ScheduleEntryExceptions (ScheduleEntryId, DatabaseId, OriginalDate, Type, NewDate, NewTime, NewNote, CreatedAt, UpdatedAt)
+	          VALUES (:ScheduleEntryId, :DatabaseId, :OriginalDate, :Type, :NewDate, :NewTime, :NewNote, :CreatedAt, :UpdatedAt)`
+	result, err := tx.NamedExec(query, exception)
+	if err != nil {
+		return 0, fmt.Errorf("CreateScheduleExceptionWithTx: ошибка вставки исключения для записи %d: %w", exception.ScheduleEntryId, err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("CreateScheduleExceptionWithTx: ошибка получения LastInsertId: %w", err)
+	}
+	return id, nil
+}
@@ -0,0 +1,135 @@
+package data
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"notes_server_go/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ParseScheduleTagsJson разбирает TagsJson записи расписания (JSON-массив строк), см. ScheduleEntry.ParseTags.
+// Некорректный TagsJson записывается в лог и считается пустым.
+func ParseScheduleTagsJson(tagsJson *string) []string {
+	tags, err := (&models.ScheduleEntry{TagsJson: tagsJson}).ParseTags()
+	if err != nil {
+		log.Printf("ParseScheduleTagsJson: не удалось разобрать TagsJson %q: %v", *tagsJson, err)
+		return nil
+	}
+	return tags
+}
+
+// rebuildScheduleTags приводит строки ScheduleTags записи расписания в соответствие с ее TagsJson.
+// Неизменившиеся теги сохраняют свой CreatedAt. Работает как с MainDB, так и с транзакцией.
+func rebuildScheduleTags(db sqlx.Execer, entryID int64, sharedDbID int64, tagsJson *string) error {
+	tags := ParseScheduleTagsJson(tagsJson)
+
+	if len(tags) == 0 {
+		if _, err := db.Exec(`DELETE FROM ScheduleTags WHERE ScheduleEntryId = ?`, entryID); err != nil {
+			return fmt.Errorf("rebuildScheduleTags: ошибка удаления тегов записи %d: %w", entryID, err)
+		}
+		return nil
+	}
+
+	deleteQuery, args, err := sqlx.In(`DELETE FROM ScheduleTags WHERE ScheduleEntryId = ? AND Tag NOT IN (?)`, entryID, tags)
+	if err != nil {
+		return fmt.Errorf("rebuildScheduleTags: ошибка построения запроса удаления для записи %d: %w", entryID, err)
+	}
+	if _, err := db.Exec(deleteQuery, args...); err != nil {
+		return fmt.Errorf("rebuildScheduleTags: ошибка удаления устаревших тегов записи %d: %w", entryID, err)
+	}
+
+	now := time.Now()
+	for _, tag := range tags {
+		_, err := db.Exec(`INSERT OR IGNORE INTO ScheduleTags (ScheduleEntryId, Tag, DatabaseId, CreatedAt) VALUES (?, ?, ?, ?)`,
+			entryID, tag, sharedDbID, now)
+		if err != nil {
+			return fmt.Errorf("rebuildScheduleTags: ошибка вставки тега '%s' для записи %d: %w", tag, entryID, err)
+		}
+	}
+	return nil
+}
+
+// GetScheduleTagsBySharedDBID извлекает все теги расписания для указанной совместной БД.
+func GetScheduleTagsBySharedDBID(sharedDbID int64) ([]models.ScheduleTag, error) {
+	tags := []models.ScheduleTag{}
+	query := `SELECT Id, ScheduleEntryId, Tag, DatabaseId, CreatedAt
+	          FROM ScheduleTags WHERE DatabaseId = ? ORDER BY ScheduleEntryId ASC, Tag ASC`
+	if err := MainDB.Select(&tags, query, sharedDbID); err != nil {
+		return nil, fmt.Errorf("GetScheduleTagsBySharedDBID: ошибка получения тегов для SharedDBID %d: %w", sharedDbID, err)
+	}
+	return tags, nil
+}
+
+// GetScheduleEntriesByTags извлекает записи расписания совместной БД, отмеченные всеми указанными тегами.
+func GetScheduleEntriesByTags(sharedDbID int64, tags []string) ([]models.ScheduleEntry, error) {
+	entries := []models.ScheduleEntry{}
+	query, args, err := sqlx.In(`SELECT Id, DatabaseId, Time, Date, Note, DynamicFieldsJson, RecurrenceJson, TagsJson, CategoryId, TimeZone, AssigneeUserId, CreatedByUserId, CreatedAt, UpdatedAt
+	          FROM ScheduleEntries
+	          WHERE DatabaseId = ? AND Id IN (
+	              SELECT ScheduleEntryId FROM ScheduleTags
+	              WHERE DatabaseId = ? AND Tag IN (?)
+	              GROUP BY ScheduleEntryId HAVING COUNT(DISTINCT Tag) = ?)
+	          ORDER BY Date ASC, Time ASC`, sharedDbID, sharedDbID, tags, len(tags))
+	if err != nil {
+		return nil, fmt.Errorf("GetScheduleEntriesByTags: ошибка построения запроса: %w", err)
+	}
+	if err := MainDB.Select(&entries, query, args...); err != nil {
+		return nil, fmt.Errorf("GetScheduleEntriesByTags: ошибка получения записей для SharedDBID %d: %w", sharedDbID, err)
+	}
+	return entries, nil
+}
+
+// GetScheduleTagStats возвращает статистику по тегам расписания совместной БД:
+// количество записей и диапазон дат, в которых встречается тег.
+func GetScheduleTagStats(sharedDbID int64) ([]models.ScheduleTagStats, error) {
+	stats := []models.ScheduleTagStats{}
+	query := `SELECT st.Tag AS Tag, COUNT(*) AS Count, MIN(se.Date) AS FirstDate, MAX(se.Date) AS LastDate
+	          FROM ScheduleTags st
+	          JOIN ScheduleEntries se ON se.Id = st.ScheduleEntryId
+	          WHERE st.DatabaseId = ?
+	          GROUP BY st.Tag ORDER BY Count DESC, st.Tag ASC`
+	if err := MainDB.Select(&stats, query, sharedDbID); err != nil {
+		return nil, fmt.Errorf("GetScheduleTagStats: ошибка подсчета тегов для SharedDBID %d: %w", sharedDbID, err)
+	}
+	return stats, nil
+}
+
+// EnsureScheduleTagsBackfill заполняет таблицу ScheduleTags по TagsJson существующих записей.
+// Выполняется при старте, только если таблица пуста (первый запуск после обновления схемы).
+func EnsureScheduleTagsBackfill() error {
+	var count int
+	if err := MainDB.Get(&count, `SELECT COUNT(*) FROM ScheduleTags`); err != nil {
+		return fmt.Errorf("EnsureScheduleTagsBackfill: ошибка проверки таблицы ScheduleTags: %w", err)
+	}
+	if count > 0 {
+		return nil
+	}
+
+	var entries []models.ScheduleEntry
+	err := MainDB.Select(&entries, `SELECT Id, DatabaseId, TagsJson FROM ScheduleEntries WHERE TagsJson IS NOT NULL AND TagsJson NOT IN ('', '[]')`)
+	if err != nil {
+		return fmt.Errorf("EnsureScheduleTagsBackfill: ошибка получения записей расписания: %w", err)
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+
+	tx, err := MainDB.Beginx()
+	if err != nil {
+		return fmt.Errorf("EnsureScheduleTagsBackfill: ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback()
+	for _, entry := range entries {
+		if err := rebuildScheduleTags(tx, entry.Id, entry.DatabaseId, entry.TagsJson); err != nil {
+			return fmt.Errorf("EnsureScheduleTagsBackfill: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("EnsureScheduleTagsBackfill: ошибка коммита: %w", err)
+	}
+	log.Printf("Заполнена таблица ScheduleTags по %d записям расписания", len(entries))
+	return nil
+}
@@ -154,7 +154,7 @@ func GetAuthSchema() string {
 // GetMainSchema возвращает SQL-схему для основной базы данных (все таблицы, кроме Users).
 func GetMainSchema() string {
 	// Сначала таблицы без внешних ключей или с ключами на таблицы, которые точно будут созданы до них
-	orderedSchema := SharedDatabasesTable() + FoldersTable() + NotesTable() + ScheduleEntriesTable() + PinboardNotesTable() + ConnectionsTable() + NoteImagesTable() + SharedDatabaseUsersTable() + SharedDatabaseInvitationsTable() + SyncChangesTable()
+	orderedSchema := SharedDatabasesTable() + FoldersTable() + CategoriesTable() + NotesTable() + ScheduleEntriesTable() + PinboardsTable() + PinboardNotesTable() + ConnectionsTable() + NoteImagesTable() + SharedDatabaseUsersTable() + SharedDatabaseInvitationsTable() + SyncChangesTable() + SmartFoldersTable() + NoteTagsTable() + ScheduleTagsTable() + ScheduleEntryExceptionsTable() + CalendarFeedTokensTable() + SentRemindersTable() + SentReminderRecipientsTable() + DynamicFieldDefinitionsTable() + DigestSubscriptionsTable() + PinboardNotesSpatialIndex() + EntityLinksTable() + NoteWikiLinksTable() + NoteRevisionsTable() + TrashItemsTable()
 	return orderedSchema
 }
 
@@ -175,7 +175,9 @@ CREATE TABLE IF NOT EXISTS SharedDatabases (
     UpdatedAt DATETIME NOT NULL,
     Version TEXT DEFAULT '1.0.0',
     IsActive BOOLEAN DEFAULT 1,
-    LastSync DATETIME DEFAULT CURRENT_TIMESTAMP
+    LastSync DATETIME DEFAULT CURRENT_TIMESTAMP,
+    TimeZone TEXT NOT NULL DEFAULT '', -- Часовой пояс IANA расписания; '' - часовой пояс сервера
+    DeletedAt DATETIME -- Время перемещения в корзину; NULL - БД не удалена
 );
 `
 }
@@ -210,8 +212,10 @@ CREATE TABLE IF NOT EXISTS Notes (
     ImagesJson TEXT DEFAULT '[]',
     MetadataJson TEXT DEFAULT '{}',
     ContentJson TEXT,
+    CategoryId INTEGER,
     FOREIGN KEY (DatabaseId) REFERENCES SharedDatabases(Id) ON DELETE CASCADE,
-    FOREIGN KEY (FolderId) REFERENCES Folders(Id) ON DELETE SET NULL
+    FOREIGN KEY (FolderId) REFERENCES Folders(Id) ON DELETE SET NULL,
+    FOREIGN KEY (CategoryId) REFERENCES Categories(Id) ON DELETE SET NULL
 );
 `
 }
@@ -239,10 +243,15 @@ CREATE TABLE IF NOT EXISTS ScheduleEntries (
     DynamicFieldsJson TEXT,
     RecurrenceJson TEXT,
     TagsJson TEXT,
+    CategoryId INTEGER,
+    TimeZone TEXT, -- Часовой пояс IANA записи; NULL - часовой пояс совместной БД
+    AssigneeUserId INTEGER, -- Ответственный участник (пользователь из AuthDB)
+    CreatedByUserId INTEGER, -- Автор записи (пользователь из AuthDB)
     DatabaseId INTEGER NOT NULL,
     CreatedAt DATETIME NOT NULL,
     UpdatedAt DATETIME NOT NULL,
-    FOREIGN KEY (DatabaseId) REFERENCES SharedDatabases(Id) ON DELETE CASCADE
+    FOREIGN KEY (DatabaseId) REFERENCES SharedDatabases(Id) ON DELETE CASCADE,
+    FOREIGN KEY (CategoryId) REFERENCES Categories(Id) ON DELETE SET NULL
 );
 `
 }
@@ -260,9 +269,11 @@ CREATE TABLE IF NOT EXISTS PinboardNotes (
     BackgroundColor INTEGER NOT NULL,
     IconCodePoint INTEGER NOT NULL,
     DatabaseId INTEGER NOT NULL,
+    PinboardId INTEGER,
     CreatedAt DATETIME NOT NULL,
     UpdatedAt DATETIME NOT NULL,
-    FOREIGN KEY (DatabaseId) REFERENCES SharedDatabases(Id) ON DELETE CASCADE
+    FOREIGN KEY (DatabaseId) REFERENCES SharedDatabases(Id) ON DELETE CASCADE,
+    FOREIGN KEY (PinboardId) REFERENCES Pinboards(Id) ON DELETE CASCADE
 );
 `
 }
@@ -334,6 +345,331 @@ CREATE TABLE IF NOT EXISTS SyncChanges (
 `
 }
 
+func SmartFoldersTable() string {
+	return `
+CREATE TABLE IF NOT EXISTS SmartFolders (
+    Id INTEGER PRIMARY KEY AUTOINCREMENT,
+    DatabaseId INTEGER NOT NULL,
+    Name TEXT NOT NULL,
+    Query TEXT NOT NULL,
+    Color INTEGER DEFAULT 0,
+    CreatedByUserId INTEGER NOT NULL,
+    CreatedAt DATETIME NOT NULL,
+    UpdatedAt DATETIME NOT NULL,
+    FOREIGN KEY (DatabaseId) REFERENCES SharedDatabases(Id) ON DELETE CASCADE
+);
+`
+}
+
+func NoteTagsTable() string {
+	return `
+CREATE TABLE IF NOT EXISTS NoteTags (
+    Id INTEGER PRIMARY KEY AUTOINCREMENT,
+    NoteId INTEGER NOT NULL,
+    Tag TEXT NOT NULL,
+    DatabaseId INTEGER NOT NULL,
+    CreatedAt DATETIME NOT NULL,
+    UNIQUE (NoteId, Tag),
+    FOREIGN KEY (DatabaseId) REFERENCES SharedDatabases(Id) ON DELETE CASCADE,
+    FOREIGN KEY (NoteId) REFERENCES Notes(Id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS IX_NoteTags_DatabaseId_Tag ON NoteTags (DatabaseId, Tag);
+`
+}
+
+func ScheduleTagsTable() string {
+	return `
+CREATE TABLE IF NOT EXISTS ScheduleTags (
+    Id INTEGER PRIMARY KEY AUTOINCREMENT,
+    ScheduleEntryId INTEGER NOT NULL,
+    Tag TEXT NOT NULL,
+    DatabaseId INTEGER NOT NULL,
+    CreatedAt DATETIME NOT NULL,
+    UNIQUE (ScheduleEntryId, Tag),
+    FOREIGN KEY (DatabaseId) REFERENCES SharedDatabases(Id) ON DELETE CASCADE,
+    FOREIGN KEY (ScheduleEntryId) REFERENCES ScheduleEntries(Id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS IX_ScheduleTags_DatabaseId_Tag ON ScheduleTags (DatabaseId, Tag);
+`
+}
+
+func CategoriesTable() string {
+	return `
+CREATE TABLE IF NOT EXISTS Categories (
+    Id INTEGER PRIMARY KEY AUTOINCREMENT,
+    DatabaseId INTEGER NOT NULL,
+    Name TEXT NOT NULL,
+    Color TEXT,
+    CreatedAt DATETIME NOT NULL,
+    UpdatedAt DATETIME NOT NULL,
+    FOREIGN KEY (DatabaseId) REFERENCES SharedDatabases(Id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS IX_Categories_DatabaseId ON Categories (DatabaseId);
+`
+}
+
+func ScheduleEntryExceptionsTable() string {
+	return `
+CREATE TABLE IF NOT EXISTS ScheduleEntryExceptions (
+    Id INTEGER PRIMARY KEY AUTOINCREMENT,
+    ScheduleEntryId INTEGER NOT NULL,
+    DatabaseId INTEGER NOT NULL,
+    OriginalDate TEXT NOT NULL,
+    Type TEXT NOT NULL CHECK (Type IN ('skip', 'modify')),
+    NewDate TEXT,
+    NewTime TEXT,
+    NewNote TEXT,
+    CreatedAt DATETIME NOT NULL,
+    UpdatedAt DATETIME NOT NULL,
+    UNIQUE (ScheduleEntryId, OriginalDate),
+    FOREIGN KEY (DatabaseId) REFERENCES SharedDatabases(Id) ON DELETE CASCADE,
+    FOREIGN KEY (ScheduleEntryId) REFERENCES ScheduleEntries(Id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS IX_ScheduleEntryExceptions_DatabaseId ON ScheduleEntryExceptions (DatabaseId);
+`
+}
+
+func CalendarFeedTokensTable() string {
+	return `
+CREATE TABLE IF NOT EXISTS CalendarFeedTokens (
+    Id INTEGER PRIMARY KEY AUTOINCREMENT,
+    Token TEXT NOT NULL UNIQUE,
+    DatabaseId INTEGER NOT NULL,
+    UserId INTEGER NOT NULL,
+    Name TEXT NOT NULL DEFAULT '',
+    CreatedAt DATETIME NOT NULL,
+    LastUsedAt DATETIME,
+    FOREIGN KEY (DatabaseId) REFERENCES SharedDatabases(Id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS IX_CalendarFeedTokens_DatabaseId_UserId ON CalendarFeedTokens (DatabaseId, UserId);
+`
+}
+
+func SentRemindersTable() string {
+	return `
+CREATE TABLE IF NOT EXISTS SentReminders (
+    Id INTEGER PRIMARY KEY AUTOINCREMENT,
+    DatabaseId INTEGER NOT NULL,
+    ScheduleEntryId INTEGER NOT NULL,
+    OccurrenceDate TEXT NOT NULL,
+    Channel TEXT NOT NULL,
+    StartsAt DATETIME NOT NULL,
+    Status TEXT NOT NULL CHECK (Status IN ('pending', 'sent')),
+    ClaimedAt DATETIME NOT NULL,
+    SentAt DATETIME,
+    UNIQUE (ScheduleEntryId, OccurrenceDate, Channel),
+    FOREIGN KEY (DatabaseId) REFERENCES SharedDatabases(Id) ON DELETE CASCADE,
+    FOREIGN KEY (ScheduleEntryId) REFERENCES ScheduleEntries(Id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS IX_SentReminders_StartsAt ON SentReminders (StartsAt);
+`
+}
+
+// SentReminderRecipientsTable хранит получателей, которым напоминание уже доставлено. Записи не удаляются
+// при снятии захвата в SentReminders, поэтому повторная попытка отправляет письма только остальным.
+func SentReminderRecipientsTable() string {
+	return `
+CREATE TABLE IF NOT EXISTS SentReminderRecipients (
+    Id INTEGER PRIMARY KEY AUTOINCREMENT,
+    DatabaseId INTEGER NOT NULL,
+    ScheduleEntryId INTEGER NOT NULL,
+    OccurrenceDate TEXT NOT NULL,
+    Channel TEXT NOT NULL,
+    UserId INTEGER NOT NULL,
+    StartsAt DATETIME NOT NULL,
+    SentAt DATETIME NOT NULL,
+    UNIQUE (ScheduleEntryId, OccurrenceDate, Channel, UserId),
+    FOREIGN KEY (DatabaseId) REFERENCES SharedDatabases(Id) ON DELETE CASCADE,
+    FOREIGN KEY (ScheduleEntryId) REFERENCES ScheduleEntries(Id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS IX_SentReminderRecipients_StartsAt ON SentReminderRecipients (StartsAt);
+`
+}
+
+func DynamicFieldDefinitionsTable() string {
+	return `
+CREATE TABLE IF NOT EXISTS DynamicFieldDefinitions (
+    Id INTEGER PRIMARY KEY AUTOINCREMENT,
+    DatabaseId INTEGER NOT NULL,
+    Name TEXT NOT NULL,
+    Type TEXT NOT NULL CHECK (Type IN ('text', 'number', 'date', 'choice', 'checkbox')),
+    Required INTEGER NOT NULL DEFAULT 0,
+    OptionsJson TEXT,
+    Position INTEGER NOT NULL DEFAULT 0,
+    CreatedAt DATETIME NOT NULL,
+    UpdatedAt DATETIME NOT NULL,
+    UNIQUE (DatabaseId, Name),
+    FOREIGN KEY (DatabaseId) REFERENCES SharedDatabases(Id) ON DELETE CASCADE
+);
+`
+}
+
+func DigestSubscriptionsTable() string {
+	return `
+CREATE TABLE IF NOT EXISTS DigestSubscriptions (
+    Id INTEGER PRIMARY KEY AUTOINCREMENT,
+    UserId INTEGER NOT NULL UNIQUE, -- Пользователь из AuthDB
+    Enabled INTEGER NOT NULL DEFAULT 1,
+    SendTime TEXT NOT NULL,
+    TimeZone TEXT NOT NULL DEFAULT '',
+    SendEmpty INTEGER NOT NULL DEFAULT 0,
+    LastSentDate TEXT,
+    LastSentAt DATETIME,
+    CreatedAt DATETIME NOT NULL,
+    UpdatedAt DATETIME NOT NULL
+);
+`
+}
+
+// PinboardsTable - доски совместной БД. Карточки доски удаляются вместе с ней (PinboardNotes.PinboardId ON DELETE CASCADE).
+func PinboardsTable() string {
+	return `
+CREATE TABLE IF NOT EXISTS Pinboards (
+    Id INTEGER PRIMARY KEY AUTOINCREMENT,
+    DatabaseId INTEGER NOT NULL,
+    Name TEXT NOT NULL,
+    Position INTEGER NOT NULL DEFAULT 0,
+    CreatedAt DATETIME NOT NULL,
+    UpdatedAt DATETIME NOT NULL,
+    FOREIGN KEY (DatabaseId) REFERENCES SharedDatabases(Id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS IX_Pinboards_DatabaseId ON Pinboards (DatabaseId, Position);
+`
+}
+
+// PinboardNotesSpatialIndex - R*Tree-индекс прямоугольников карточек доски для запросов по области просмотра.
+// Индекс поддерживается триггерами на PinboardNotes; карточки, созданные до его появления,
+// добавляются EnsurePinboardSpatialIndex.
+func PinboardNotesSpatialIndex() string {
+	return `
+CREATE VIRTUAL TABLE IF NOT EXISTS PinboardNotesRTree USING rtree(
+    Id,
+    MinX, MaxX,
+    MinY, MaxY,
+    +DatabaseId INTEGER
+);
+CREATE TRIGGER IF NOT EXISTS PinboardNotesRTreeInsert AFTER INSERT ON PinboardNotes
+BEGIN
+    INSERT OR REPLACE INTO PinboardNotesRTree (Id, MinX, MaxX, MinY, MaxY, DatabaseId)
+    VALUES (NEW.Id, NEW.PositionX, NEW.PositionX + MAX(NEW.Width, 0), NEW.PositionY, NEW.PositionY + MAX(NEW.Height, 0), NEW.DatabaseId);
+END;
+CREATE TRIGGER IF NOT EXISTS PinboardNotesRTreeUpdate AFTER UPDATE OF PositionX, PositionY, Width, Height, DatabaseId ON PinboardNotes
+BEGIN
+    DELETE FROM PinboardNotesRTree WHERE Id = OLD.Id;
+    INSERT OR REPLACE INTO PinboardNotesRTree (Id, MinX, MaxX, MinY, MaxY, DatabaseId)
+    VALUES (NEW.Id, NEW.PositionX, NEW.PositionX + MAX(NEW.Width, 0), NEW.PositionY, NEW.PositionY + MAX(NEW.Height, 0), NEW.DatabaseId);
+END;
+CREATE TRIGGER IF NOT EXISTS PinboardNotesRTreeDelete AFTER DELETE ON PinboardNotes
+BEGIN
+    DELETE FROM PinboardNotesRTree WHERE Id = OLD.Id;
+END;
+`
+}
+
+// EntityLinksTable - ссылки между сущностями совместной БД любых типов (заметки, карточки доски,
+// записи расписания, папки). Внешних ключей на сущности нет, поэтому ссылки удаленных сущностей
+// удаляются триггерами.
+func EntityLinksTable() string {
+	return `
+CREATE TABLE IF NOT EXISTS EntityLinks (
+    Id INTEGER PRIMARY KEY AUTOINCREMENT,
+    DatabaseId INTEGER NOT NULL,
+    SourceType TEXT NOT NULL, -- note, pinboard_note, schedule_entry, folder
+    SourceId INTEGER NOT NULL,
+    TargetType TEXT NOT NULL,
+    TargetId INTEGER NOT NULL,
+    Label TEXT NOT NULL DEFAULT '',
+    CreatedByUserId INTEGER, -- Пользователь из AuthDB
+    CreatedAt DATETIME NOT NULL,
+    UpdatedAt DATETIME NOT NULL,
+    UNIQUE (SourceType, SourceId, TargetType, TargetId),
+    FOREIGN KEY (DatabaseId) REFERENCES SharedDatabases(Id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS IX_EntityLinks_Target ON EntityLinks (TargetType, TargetId);
+CREATE TRIGGER IF NOT EXISTS EntityLinksNoteDelete AFTER DELETE ON Notes
+BEGIN
+    DELETE FROM EntityLinks WHERE (SourceType = 'note' AND SourceId = OLD.Id) OR (TargetType = 'note' AND TargetId = OLD.Id);
+END;
+CREATE TRIGGER IF NOT EXISTS EntityLinksPinboardNoteDelete AFTER DELETE ON PinboardNotes
+BEGIN
+    DELETE FROM EntityLinks WHERE (SourceType = 'pinboard_note' AND SourceId = OLD.Id) OR (TargetType = 'pinboard_note' AND TargetId = OLD.Id);
+END;
+CREATE TRIGGER IF NOT EXISTS EntityLinksScheduleEntryDelete AFTER DELETE ON ScheduleEntries
+BEGIN
+    DELETE FROM EntityLinks WHERE (SourceType = 'schedule_entry' AND SourceId = OLD.Id) OR (TargetType = 'schedule_entry' AND TargetId = OLD.Id);
+END;
+CREATE TRIGGER IF NOT EXISTS EntityLinksFolderDelete AFTER DELETE ON Folders
+BEGIN
+    DELETE FROM EntityLinks WHERE (SourceType = 'folder' AND SourceId = OLD.Id) OR (TargetType = 'folder' AND TargetId = OLD.Id);
+END;
+`
+}
+
+// NoteWikiLinksTable - вики-ссылки [[Заголовок]] из текста заметок. Таблица строится сервером
+// по содержимому заметок; ссылка на удаленную заметку становится битой (TargetNoteId = NULL).
+func NoteWikiLinksTable() string {
+	return `
+CREATE TABLE IF NOT EXISTS NoteWikiLinks (
+    Id INTEGER PRIMARY KEY AUTOINCREMENT,
+    DatabaseId INTEGER NOT NULL,
+    SourceNoteId INTEGER NOT NULL,
+    TargetTitle TEXT NOT NULL, -- Заголовок, как он написан в ссылке
+    TargetTitleKey TEXT NOT NULL, -- Заголовок в нижнем регистре без лишних пробелов
+    TargetNoteId INTEGER, -- NULL - заметки с таким заголовком нет
+    Alias TEXT NOT NULL DEFAULT '',
+    Occurrences INTEGER NOT NULL DEFAULT 1,
+    UNIQUE (SourceNoteId, TargetTitleKey),
+    FOREIGN KEY (DatabaseId) REFERENCES SharedDatabases(Id) ON DELETE CASCADE,
+    FOREIGN KEY (SourceNoteId) REFERENCES Notes(Id) ON DELETE CASCADE,
+    FOREIGN KEY (TargetNoteId) REFERENCES Notes(Id) ON DELETE SET NULL
+);
+CREATE INDEX IF NOT EXISTS IX_NoteWikiLinks_TargetNoteId ON NoteWikiLinks (TargetNoteId);
+CREATE INDEX IF NOT EXISTS IX_NoteWikiLinks_DatabaseId_TargetTitleKey ON NoteWikiLinks (DatabaseId, TargetTitleKey);
+`
+}
+
+// NoteRevisionsTable - история версий заметок: заголовок, содержимое и папка после каждого изменения.
+func NoteRevisionsTable() string {
+	return `
+CREATE TABLE IF NOT EXISTS NoteRevisions (
+    Id INTEGER PRIMARY KEY AUTOINCREMENT,
+    DatabaseId INTEGER NOT NULL,
+    NoteId INTEGER NOT NULL,
+    Title TEXT NOT NULL,
+    Content TEXT,
+    ContentJson TEXT,
+    FolderId INTEGER, -- Папка на момент версии; папки может уже не быть, поэтому без внешнего ключа
+    AuthorUserId INTEGER, -- Пользователь из AuthDB; NULL - автор неизвестен
+    Reason TEXT NOT NULL DEFAULT 'sync', -- initial, sync, restore, backup, wiki-rename
+    CreatedAt DATETIME NOT NULL,
+    FOREIGN KEY (DatabaseId) REFERENCES SharedDatabases(Id) ON DELETE CASCADE
+    -- NoteId без внешнего ключа: история переживает удаление заметки (корзина, восстановление из бэкапа)
+    -- и снова доступна, когда заметка восстанавливается с прежним ID
+);
+CREATE INDEX IF NOT EXISTS IX_NoteRevisions_NoteId ON NoteRevisions (NoteId, Id);
+`
+}
+
+// TrashItemsTable - корзина совместной БД: удаленные заметки и папки, которые можно восстановить
+// до истечения срока хранения (TRASH_RETENTION_DAYS).
+func TrashItemsTable() string {
+	return `
+CREATE TABLE IF NOT EXISTS TrashItems (
+    Id INTEGER PRIMARY KEY AUTOINCREMENT,
+    DatabaseId INTEGER NOT NULL,
+    ItemType TEXT NOT NULL, -- note, folder
+    OriginalId INTEGER NOT NULL, -- ID заметки или папки до удаления
+    Title TEXT NOT NULL,
+    FolderPathJson TEXT NOT NULL DEFAULT '[]', -- Путь папок до элемента на момент удаления
+    PayloadJson TEXT NOT NULL, -- Содержимое элемента для восстановления
+    DeletedByUserId INTEGER, -- Пользователь из AuthDB
+    DeletedAt DATETIME NOT NULL,
+    FOREIGN KEY (DatabaseId) REFERENCES SharedDatabases(Id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS IX_TrashItems_DatabaseId_DeletedAt ON TrashItems (DatabaseId, DeletedAt);
+`
+}
+
 // Старая функция GetSchema, не используется напрямую для Init, но может быть полезна для справки
 func GetCombinedSchema_DO_NOT_USE_FOR_INIT() string {
 	return usersSchema + mainSchema
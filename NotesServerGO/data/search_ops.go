@@ -0,0 +1,517 @@
+package data
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"notes_server_go/models"
+)
+
+// snippetRadius - количество символов контекста слева и справа от найденного термина в сниппете.
+const snippetRadius = 60
+
+// dateFilter - условие по дате изменения или создания заметки.
+// Для относительных значений ("<7d") оператор "<" означает "не старше", ">" - "старше".
+// Для абсолютных дат ("<2025-01-01") "<" означает "раньше даты", ">" - "позже даты", без оператора - "в этот день".
+type dateFilter struct {
+	op       byte          // '<', '>' или '=' (только для абсолютной даты)
+	age      time.Duration // Относительный возраст, если date.IsZero()
+	date     time.Time     // Абсолютная дата (начало дня, локальное время)
+	relative bool
+}
+
+// matches проверяет, удовлетворяет ли момент времени t условию относительно now.
+func (f dateFilter) matches(t time.Time, now time.Time) bool {
+	if f.relative {
+		age := now.Sub(t)
+		if f.op == '>' {
+			return age > f.age
+		}
+		return age < f.age
+	}
+	dayEnd := f.date.AddDate(0, 0, 1)
+	switch f.op {
+	case '<':
+		return t.Before(f.date)
+	case '>':
+		return !t.Before(dayEnd)
+	default:
+		return !t.Before(f.date) && t.Before(dayEnd)
+	}
+}
+
+// SearchQuery - разобранный поисковый запрос.
+// Все условия (термины и фильтры) объединяются логическим И.
+type SearchQuery struct {
+	Raw     string
+	Terms   []string // Термины в нижнем регистре; фразы в кавычках сохраняются целиком
+	Tags    []string // Фильтры tag:, в нижнем регистре
+	Folders [][]string
+	Updated []dateFilter
+	Created []dateFilter
+}
+
+// ParseSearchQuery разбирает строку запроса.
+// Поддерживаются свободный текст, фразы в двойных кавычках и фильтры:
+// tag:имя, folder:Папка или folder:"Родитель/Папка", updated:<7d, updated:>2025-01-01, created:<30d.
+// Единицы относительного времени: h (часы), d (дни), w (недели), m (месяцы по 30 дней), y (годы).
+func ParseSearchQuery(raw string) (SearchQuery, error) {
+	q := SearchQuery{Raw: raw}
+	for _, token := range tokenizeQuery(raw) {
+		token = strings.TrimSpace(token)
+		if token == "" {
+			continue
+		}
+
+		key, value, hasKey := strings.Cut(token, ":")
+		if hasKey {
+			switch strings.ToLower(key) {
+			case "tag":
+				if value == "" {
+					return q, fmt.Errorf("пустое значение фильтра tag")
+				}
+				q.Tags = append(q.Tags, strings.ToLower(strings.TrimPrefix(value, "#")))
+				continue
+			case "folder":
+				segments := splitFolderPath(value)
+				if len(segments) == 0 {
+					return q, fmt.Errorf("пустое значение фильтра folder")
+				}
+				q.Folders = append(q.Folders, segments)
+				continue
+			case "updated", "created":
+				filter, err := parseDateFilter(value)
+				if err != nil {
+					return q, fmt.Errorf("фильтр %s: %w", key, err)
+				}
+				if strings.EqualFold(key, "updated") {
+					q.Updated = append(q.Updated, filter)
+				} else {
+					q.Created = append(q.Created, filter)
+				}
+				continue
+			}
+		}
+
+		q.Terms = append(q.Terms, strings.ToLower(token))
+	}
+	return q, nil
+}
+
+// IsEmpty возвращает true, если в запросе нет ни одного условия.
+func (q SearchQuery) IsEmpty() bool {
+	return len(q.Terms) == 0 && len(q.Tags) == 0 && len(q.Folders) == 0 && len(q.Updated) == 0 && len(q.Created) == 0
+}
+
+// tokenizeQuery делит строку по пробелам с учетом двойных кавычек.
+// Кавычки внутри токена (folder:"Мои заметки") тоже объединяют слова.
+func tokenizeQuery(raw string) []string {
+	var tokens []string
+	var current strings.Builder
+	inQuotes := false
+	for _, r := range raw {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+		case unicode.IsSpace(r) && !inQuotes:
+			if current.Len() > 0 {
+				tokens = append(tokens, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if current.Len() > 0 {
+		tokens = append(tokens, current.String())
+	}
+	return tokens
+}
+
+// splitFolderPath делит путь вида "Родитель/Папка" на сегменты в нижнем регистре.
+func splitFolderPath(path string) []string {
+	var segments []string
+	for _, s := range strings.Split(path, "/") {
+		if s = strings.ToLower(strings.TrimSpace(s)); s != "" {
+			segments = append(segments, s)
+		}
+	}
+	return segments
+}
+
+// parseDateFilter разбирает значение фильтра даты: "<7d", ">30d", "<2025-01-01", "2025-01-01".
+func parseDateFilter(value string) (dateFilter, error) {
+	f := dateFilter{op: '='}
+	if value != "" && (value[0] == '<' || value[0] == '>') {
+		f.op = value[0]
+		value = value[1:]
+	}
+	if value == "" {
+		return f, fmt.Errorf("пустое значение")
+	}
+
+	if date, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		f.date = date
+		return f, nil
+	}
+
+	unit := value[len(value)-1]
+	amount, err := strconv.Atoi(value[:len(value)-1])
+	if err != nil || amount < 0 {
+		return f, fmt.Errorf("неверное значение %q (ожидается, например, 7d или 2025-01-01)", value)
+	}
+	var unitDuration time.Duration
+	switch unit {
+	case 'h':
+		unitDuration = time.Hour
+	case 'd':
+		unitDuration = 24 * time.Hour
+	case 'w':
+		unitDuration = 7 * 24 * time.Hour
+	case 'm':
+		unitDuration = 30 * 24 * time.Hour
+	case 'y':
+		unitDuration = 365 * 24 * time.Hour
+	default:
+		return f, fmt.Errorf("неизвестная единица времени %q", string(unit))
+	}
+	f.relative = true
+	f.age = time.Duration(amount) * unitDuration
+	if f.op == '=' {
+		f.op = '<'
+	}
+	return f, nil
+}
+
+// noteSearchContext содержит данные совместной БД, необходимые для проверки фильтров.
+type noteSearchContext struct {
+	folderPaths map[int64]string
+	noteTags    map[int64][]string
+	now         time.Time
+}
+
+// newNoteSearchContext загружает пути папок и теги заметок совместной БД.
+func newNoteSearchContext(sharedDbID int64) (*noteSearchContext, error) {
+	folders, err := GetAllFoldersBySharedDBID(sharedDbID)
+	if err != nil {
+		return nil, err
+	}
+	noteTags, err := GetNoteTagsMap(sharedDbID)
+	if err != nil {
+		return nil, err
+	}
+	return &noteSearchContext{
+		folderPaths: BuildFolderPaths(folders),
+		noteTags:    noteTags,
+		now:         time.Now(),
+	}, nil
+}
+
+// matchNote проверяет заметку на соответствие запросу и вычисляет ее релевантность.
+func (c *noteSearchContext) matchNote(note *models.Note, text string, query SearchQuery) (float64, bool) {
+	for _, tag := range query.Tags {
+		if !containsString(c.noteTags[note.ID], tag) {
+			return 0, false
+		}
+	}
+
+	if len(query.Folders) > 0 {
+		if note.FolderID == nil {
+			return 0, false
+		}
+		pathSegments := splitFolderPath(strings.ReplaceAll(c.folderPaths[*note.FolderID], " / ", "/"))
+		for _, folder := range query.Folders {
+			if !containsSegments(pathSegments, folder) {
+				return 0, false
+			}
+		}
+	}
+
+	for _, f := range query.Updated {
+		if !f.matches(note.UpdatedAt, c.now) {
+			return 0, false
+		}
+	}
+	for _, f := range query.Created {
+		if !f.matches(note.CreatedAt, c.now) {
+			return 0, false
+		}
+	}
+
+	return scoreNote(note.Title, text, query.Terms)
+}
+
+// containsString проверяет наличие строки в срезе.
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// containsSegments проверяет, входит ли последовательность сегментов needle в путь path подряд.
+func containsSegments(path, needle []string) bool {
+	for i := 0; i+len(needle) <= len(path); i++ {
+		match := true
+		for j := range needle {
+			if path[i+j] != needle[j] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+// SearchNotesForUser выполняет поиск заметок по всем совместным БД, участником которых является пользователь.
+// Результаты объединяются и ранжируются по релевантности, затем по дате изменения.
+// limit <= 0 означает отсутствие ограничения. Возвращает найденные результаты и их общее количество.
+func SearchNotesForUser(userID int64, query SearchQuery, limit int) ([]models.SearchResult, int, error) {
+	dbs, err := GetSharedDatabasesForUser(userID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("SearchNotesForUser: ошибка получения БД пользователя %d: %w", userID, err)
+	}
+
+	results := []models.SearchResult{}
+	for _, sdb := range dbs {
+		dbResults, err := searchNotesInDatabase(sdb, query)
+		if err != nil {
+			return nil, 0, fmt.Errorf("SearchNotesForUser: %w", err)
+		}
+		results = append(results, dbResults...)
+	}
+
+	sortSearchResults(results)
+
+	total := len(results)
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	log.Printf("SearchNotesForUser: пользователь %d, запрос %q, найдено %d совпадений в %d БД", userID, query.Raw, total, len(dbs))
+	return results, total, nil
+}
+
+// sortSearchResults упорядочивает результаты по релевантности, затем по дате изменения.
+func sortSearchResults(results []models.SearchResult) {
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].UpdatedAt.After(results[j].UpdatedAt)
+	})
+}
+
+// searchNotesInDatabase ищет совпадения среди заметок одной совместной БД.
+func searchNotesInDatabase(sdb models.SharedDatabase, query SearchQuery) ([]models.SearchResult, error) {
+	notes, err := GetAllNotesBySharedDBID(sdb.Id)
+	if err != nil {
+		return nil, err
+	}
+	if len(notes) == 0 {
+		return nil, nil
+	}
+	ctx, err := newNoteSearchContext(sdb.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []models.SearchResult
+	for i := range notes {
+		note := &notes[i]
+		text := NotePlainText(note)
+		score, ok := ctx.matchNote(note, text, query)
+		if !ok {
+			continue
+		}
+		result := models.SearchResult{
+			DatabaseID:   sdb.Id,
+			DatabaseName: sdb.Name,
+			NoteID:       note.ID,
+			Title:        note.Title,
+			FolderID:     note.FolderID,
+			Snippet:      buildSnippet(text, query.Terms),
+			Score:        score,
+			UpdatedAt:    note.UpdatedAt,
+		}
+		if note.FolderID != nil {
+			result.FolderPath = ctx.folderPaths[*note.FolderID]
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// FindNotesByQuery возвращает заметки совместной БД, удовлетворяющие запросу,
+// упорядоченные по релевантности и дате изменения.
+func FindNotesByQuery(sharedDbID int64, query SearchQuery) ([]models.Note, error) {
+	notes, err := GetAllNotesBySharedDBID(sharedDbID)
+	if err != nil {
+		return nil, fmt.Errorf("FindNotesByQuery: %w", err)
+	}
+	ctx, err := newNoteSearchContext(sharedDbID)
+	if err != nil {
+		return nil, fmt.Errorf("FindNotesByQuery: %w", err)
+	}
+
+	type scoredNote struct {
+		note  models.Note
+		score float64
+	}
+	var matched []scoredNote
+	for i := range notes {
+		if score, ok := ctx.matchNote(&notes[i], NotePlainText(&notes[i]), query); ok {
+			matched = append(matched, scoredNote{note: notes[i], score: score})
+		}
+	}
+	sort.SliceStable(matched, func(i, j int) bool {
+		if matched[i].score != matched[j].score {
+			return matched[i].score > matched[j].score
+		}
+		return matched[i].note.UpdatedAt.After(matched[j].note.UpdatedAt)
+	})
+
+	result := make([]models.Note, 0, len(matched))
+	for _, m := range matched {
+		result = append(result, m.note)
+	}
+	return result, nil
+}
+
+// scoreNote вычисляет релевантность заметки. Совпадения в заголовке весят больше, чем в тексте.
+// Возвращает false, если хотя бы один термин не найден.
+func scoreNote(title, text string, terms []string) (float64, bool) {
+	lowerTitle := strings.ToLower(title)
+	lowerText := strings.ToLower(text)
+
+	var score float64
+	for _, term := range terms {
+		inTitle := strings.Count(lowerTitle, term)
+		inText := strings.Count(lowerText, term)
+		if inTitle == 0 && inText == 0 {
+			return 0, false
+		}
+		if inTitle > 0 {
+			score += 10
+			if lowerTitle == term {
+				score += 5
+			} else if strings.HasPrefix(lowerTitle, term) {
+				score += 2
+			}
+		}
+		// Частота в тексте учитывается с ограничением, чтобы длинные заметки не доминировали
+		if inText > 5 {
+			inText = 5
+		}
+		score += float64(inText)
+	}
+	return score, true
+}
+
+// buildSnippet возвращает фрагмент текста вокруг первого найденного термина.
+func buildSnippet(text string, terms []string) string {
+	runes := []rune(text)
+	if len(runes) == 0 {
+		return ""
+	}
+	lower := make([]rune, len(runes))
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+	}
+
+	pos := -1
+	for _, term := range terms {
+		if idx := indexRunes(lower, []rune(term)); idx >= 0 && (pos < 0 || idx < pos) {
+			pos = idx
+		}
+	}
+	if pos < 0 {
+		pos = 0
+	}
+
+	start := pos - snippetRadius
+	if start < 0 {
+		start = 0
+	}
+	end := pos + snippetRadius
+	if end > len(runes) {
+		end = len(runes)
+	}
+
+	snippet := strings.Join(strings.Fields(string(runes[start:end])), " ")
+	if start > 0 {
+		snippet = "…" + snippet
+	}
+	if end < len(runes) {
+		snippet += "…"
+	}
+	return snippet
+}
+
+// indexRunes ищет подпоследовательность needle в haystack, возвращает индекс в рунах или -1.
+func indexRunes(haystack, needle []rune) int {
+	if len(needle) == 0 || len(needle) > len(haystack) {
+		return -1
+	}
+	for i := 0; i+len(needle) <= len(haystack); i++ {
+		match := true
+		for j := range needle {
+			if haystack[i+j] != needle[j] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return i
+		}
+	}
+	return -1
+}
+
+// NotePlainText возвращает текст заметки без форматирования.
+// Если Content пуст, текст извлекается из ContentJson (формат Quill Delta).
+func NotePlainText(note *models.Note) string {
+	if note.Content != nil && strings.TrimSpace(*note.Content) != "" {
+		return *note.Content
+	}
+	if note.ContentJson == nil || *note.ContentJson == "" {
+		return ""
+	}
+	return quillDeltaPlainText(*note.ContentJson)
+}
+
+// quillDeltaPlainText извлекает текстовые вставки из документа Quill Delta.
+// Поддерживаются как массив операций, так и объект вида {"ops": [...]}.
+func quillDeltaPlainText(deltaJson string) string {
+	type op struct {
+		Insert interface{} `json:"insert"`
+	}
+	var ops []op
+	if err := json.Unmarshal([]byte(deltaJson), &ops); err != nil {
+		var wrapper struct {
+			Ops []op `json:"ops"`
+		}
+		if err := json.Unmarshal([]byte(deltaJson), &wrapper); err != nil {
+			return ""
+		}
+		ops = wrapper.Ops
+	}
+
+	var sb strings.Builder
+	for _, o := range ops {
+		if s, ok := o.Insert.(string); ok {
+			sb.WriteString(s)
+		}
+	}
+	return sb.String()
+}
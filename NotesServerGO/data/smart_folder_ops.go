@@ -0,0 +1,118 @@
+package data
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"notes_server_go/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// CreateSmartFolder создает новую умную папку в указанной совместной БД.
+// Поля folder.DatabaseId и folder.CreatedByUserId должны быть установлены.
+// Возвращает ID созданной умной папки.
+func CreateSmartFolder(folder *models.SmartFolder) (int64, error) {
+	now := time.Now()
+	folder.CreatedAt = now
+	folder.UpdatedAt = now
+
+	query := `INSERT INTO SmartFolders (DatabaseId, Name, Query, Color, CreatedByUserId, CreatedAt, UpdatedAt)
+	          VALUES (:DatabaseId, :Name, :Query, :Color, :CreatedByUserId, :CreatedAt, :UpdatedAt)`
+
+	result, err := MainDB.NamedExec(query, folder)
+	if err != nil {
+		return 0, fmt.Errorf("CreateSmartFolder: ошибка вставки умной папки: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("CreateSmartFolder: ошибка получения LastInsertId: %w", err)
+	}
+	log.Printf("Создана умная папка с ID: %d для DatabaseId: %d", id, folder.DatabaseId)
+	return id, nil
+}
+
+// GetSmartFolderByID извлекает умную папку по ее ID и ID совместной БД.
+func GetSmartFolderByID(id int64, sharedDbID int64) (*models.SmartFolder, error) {
+	folder := &models.SmartFolder{}
+	query := `SELECT Id, DatabaseId, Name, Query, Color, CreatedByUserId, CreatedAt, UpdatedAt
+	          FROM SmartFolders WHERE Id = ? AND DatabaseId = ?`
+	err := MainDB.Get(folder, query, id, sharedDbID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // Не найдено
+		}
+		return nil, fmt.Errorf("GetSmartFolderByID: ошибка получения умной папки ID %d, SharedDBID %d: %w", id, sharedDbID, err)
+	}
+	return folder, nil
+}
+
+// GetAllSmartFoldersBySharedDBID извлекает все умные папки для указанной совместной БД.
+func GetAllSmartFoldersBySharedDBID(sharedDbID int64) ([]models.SmartFolder, error) {
+	folders := []models.SmartFolder{}
+	query := `SELECT Id, DatabaseId, Name, Query, Color, CreatedByUserId, CreatedAt, UpdatedAt
+	          FROM SmartFolders WHERE DatabaseId = ? ORDER BY Name ASC`
+	err := MainDB.Select(&folders, query, sharedDbID)
+	if err != nil {
+		return nil, fmt.Errorf("GetAllSmartFoldersBySharedDBID: ошибка получения умных папок для SharedDBID %d: %w", sharedDbID, err)
+	}
+	return folders, nil
+}
+
+// UpdateSmartFolder обновляет название, запрос и цвет умной папки.
+// Поля folder.Id и folder.DatabaseId должны быть установлены.
+func UpdateSmartFolder(folder *models.SmartFolder) error {
+	folder.UpdatedAt = time.Now()
+
+	query := `UPDATE SmartFolders SET Name = :Name, Query = :Query, Color = :Color, UpdatedAt = :UpdatedAt
+	          WHERE Id = :Id AND DatabaseId = :DatabaseId`
+	result, err := MainDB.NamedExec(query, folder)
+	if err != nil {
+		return fmt.Errorf("UpdateSmartFolder: ошибка обновления умной папки ID %d, SharedDBID %d: %w", folder.Id, folder.DatabaseId, err)
+	}
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return sql.ErrNoRows // Не найдено для обновления
+	}
+	log.Printf("Обновлена умная папка с ID: %d для DatabaseId: %d", folder.Id, folder.DatabaseId)
+	return nil
+}
+
+// DeleteSmartFolder удаляет умную папку из указанной совместной БД.
+func DeleteSmartFolder(id int64, sharedDbID int64) error {
+	result, err := MainDB.Exec(`DELETE FROM SmartFolders WHERE Id = ? AND DatabaseId = ?`, id, sharedDbID)
+	if err != nil {
+		return fmt.Errorf("DeleteSmartFolder: ошибка удаления умной папки ID %d, SharedDBID %d: %w", id, sharedDbID, err)
+	}
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return sql.ErrNoRows // Не найдено для удаления
+	}
+	log.Printf("Удалена умная папка с ID: %d для DatabaseId: %d", id, sharedDbID)
+	return nil
+}
+
+// --- Функции, работающие с транзакциями ---
+
+// GetAllSmartFoldersBySharedDBIDWithTx извлекает все умные папки для указанной совместной БД в рамках транзакции.
+func GetAllSmartFoldersBySharedDBIDWithTx(tx *sqlx.Tx, sharedDbID int64) ([]models.SmartFolder, error) {
+	folders := []models.SmartFolder{}
+	query := `SELECT Id, DatabaseId, Name, Query, Color, CreatedByUserId, CreatedAt, UpdatedAt
+	          FROM SmartFolders WHERE DatabaseId = ? ORDER BY Name ASC`
+	err := tx.Select(&folders, query, sharedDbID)
+	if err != nil {
+		return nil, fmt.Errorf("GetAllSmartFoldersBySharedDBIDWithTx: ошибка получения для SharedDBID %d: %w", sharedDbID, err)
+	}
+	return folders, nil
+}
+
+// EvaluateSmartFolder вычисляет содержимое умной папки: возвращает заметки, удовлетворяющие ее запросу.
+func EvaluateSmartFolder(folder *models.SmartFolder) ([]models.Note, error) {
+	query, err := ParseSearchQuery(folder.Query)
+	if err != nil {
+		return nil, fmt.Errorf("EvaluateSmartFolder: неверный запрос умной папки ID %d: %w", folder.Id, err)
+	}
+	return FindNotesByQuery(folder.DatabaseId, query)
+}
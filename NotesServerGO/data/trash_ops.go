@@ -0,0 +1,756 @@
+package data
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"notes_server_go/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// trashRetention - сколько хранятся элементы корзины и удаленные совместные БД; 0 - без ограничения.
+// Используется для расчета expires_at; удаление выполняет фоновая задача (см. пакет trash).
+var trashRetention time.Duration
+
+// SetTrashRetention задает срок хранения корзины (0 - без ограничения).
+func SetTrashRetention(retention time.Duration) {
+	trashRetention = retention
+}
+
+// TrashExpiresAt возвращает время окончательного удаления элемента, удаленного в deletedAt,
+// или nil, если срок хранения не ограничен.
+func TrashExpiresAt(deletedAt time.Time) *time.Time {
+	if trashRetention <= 0 {
+		return nil
+	}
+	expiresAt := deletedAt.Add(trashRetention)
+	return &expiresAt
+}
+
+// trashedNote - содержимое удаленной заметки в PayloadJson элемента корзины.
+type trashedNote struct {
+	Title        string              `json:"title"`
+	Content      *string             `json:"content,omitempty"`
+	ContentJson  *string             `json:"content_json,omitempty"`
+	CategoryId   *int64              `json:"category_id,omitempty"`
+	ImagesJson   string              `json:"images_json"`
+	MetadataJson string              `json:"metadata_json"`
+	CreatedAt    time.Time           `json:"created_at"`
+	UpdatedAt    time.Time           `json:"updated_at"`
+	Tags         []string            `json:"tags"`
+	Images       []trashedImage      `json:"images"`
+	Links        []models.EntityLink `json:"links"` // Ссылки из заметки и на нее (удаляются вместе с заметкой триггером)
+}
+
+// trashedImage - запись изображения удаленной заметки. Файл остается на диске до окончательного удаления.
+type trashedImage struct {
+	ImagePath string    `json:"image_path" db:"ImagePath"`
+	FileName  string    `json:"file_name" db:"FileName"`
+	CreatedAt time.Time `json:"created_at" db:"CreatedAt"`
+}
+
+// trashedFolder - удаленная папка в PayloadJson элемента корзины.
+type trashedFolder struct {
+	Name       string              `json:"name"`
+	Color      int                 `json:"color"`
+	IsExpanded bool                `json:"is_expanded"`
+	Links      []models.EntityLink `json:"links"` // Ссылки из папки и на нее
+}
+
+// TrashRestoreResult - результат восстановления элемента корзины.
+type TrashRestoreResult struct {
+	ItemType       string          `json:"item_type"`
+	Note           *models.Note    `json:"note,omitempty"`
+	Folder         *models.Folder  `json:"folder,omitempty"`
+	CreatedFolders []models.Folder `json:"created_folders"` // Папки пути, созданные заново при восстановлении
+}
+
+// TrashNoteWithTx перемещает заметку в корзину: сохраняет ее вместе с путем папок, тегами и записями
+// изображений и удаляет из Notes. Файлы изображений не удаляются. Возвращает sql.ErrNoRows, если заметки нет.
+func TrashNoteWithTx(tx *sqlx.Tx, noteID int64, sharedDbID int64, userID int64) error {
+	note, err := GetNoteByIDWithTx(tx, noteID, sharedDbID)
+	if err != nil {
+		return fmt.Errorf("TrashNoteWithTx: %w", err)
+	}
+	if note == nil {
+		return sql.ErrNoRows
+	}
+	folders, err := folderMapWithTx(tx, sharedDbID)
+	if err != nil {
+		return fmt.Errorf("TrashNoteWithTx: %w", err)
+	}
+
+	payload := trashedNote{
+		Title:        note.Title,
+		Content:      note.Content,
+		ContentJson:  note.ContentJson,
+		CategoryId:   note.CategoryId,
+		ImagesJson:   note.ImagesJson,
+		MetadataJson: note.MetadataJson,
+		CreatedAt:    note.CreatedAt,
+		UpdatedAt:    note.UpdatedAt,
+		Tags:         []string{},
+		Images:       []trashedImage{},
+	}
+	if err := tx.Select(&payload.Tags, `SELECT Tag FROM NoteTags WHERE NoteId = ? ORDER BY Tag ASC`, noteID); err != nil {
+		return fmt.Errorf("TrashNoteWithTx: ошибка получения тегов заметки ID %d: %w", noteID, err)
+	}
+	if err := tx.Select(&payload.Images, `SELECT ImagePath, FileName, CreatedAt FROM NoteImages WHERE NoteId = ? ORDER BY Id ASC`, noteID); err != nil {
+		return fmt.Errorf("TrashNoteWithTx: ошибка получения изображений заметки ID %d: %w", noteID, err)
+	}
+	if payload.Links, err = GetEntityLinksForEntityWithTx(tx, sharedDbID, models.EntityNote, noteID); err != nil {
+		return fmt.Errorf("TrashNoteWithTx: %w", err)
+	}
+
+	item := &models.TrashItem{
+		DatabaseId: sharedDbID,
+		ItemType:   models.TrashItemNote,
+		OriginalId: noteID,
+		Title:      note.Title,
+		FolderPath: trashFolderPath(folders, note.FolderID),
+	}
+	if err := insertTrashItemWithTx(tx, item, payload, userID); err != nil {
+		return fmt.Errorf("TrashNoteWithTx: %w", err)
+	}
+	if err := DeleteNoteWithTx(tx, noteID, sharedDbID); err != nil {
+		return fmt.Errorf("TrashNoteWithTx: %w", err)
+	}
+	log.Printf("Заметка ID %d БД %d перемещена в корзину (элемент %d)", noteID, sharedDbID, item.Id)
+	return nil
+}
+
+// TrashFoldersWithTx перемещает папки в корзину вместе с вложенными папками: каждая папка сохраняется
+// отдельным элементом со своим путем и удаляется. Заметки папок остаются без папки (ON DELETE SET NULL),
+// их нужно удалить отдельно через TrashNoteWithTx. Возвращает количество папок, перемещенных в корзину.
+func TrashFoldersWithTx(tx *sqlx.Tx, folderIDs []int64, sharedDbID int64, userID int64) (int, error) {
+	if len(folderIDs) == 0 {
+		return 0, nil
+	}
+	folders, err := folderMapWithTx(tx, sharedDbID)
+	if err != nil {
+		return 0, fmt.Errorf("TrashFoldersWithTx: %w", err)
+	}
+
+	// Вложенные папки удаляются каскадно, поэтому тоже сохраняются в корзину
+	selected := make(map[int64]bool, len(folderIDs))
+	for _, id := range folderIDs {
+		selected[id] = true
+	}
+	var toTrash []models.Folder
+	for _, folder := range sortedFolders(folders) {
+		for _, ref := range append(trashFolderPath(folders, folder.ParentID), models.TrashFolderRef{Id: folder.ID}) {
+			if selected[ref.Id] {
+				toTrash = append(toTrash, folder)
+				break
+			}
+		}
+	}
+
+	for _, folder := range toTrash {
+		item := &models.TrashItem{
+			DatabaseId: sharedDbID,
+			ItemType:   models.TrashItemFolder,
+			OriginalId: folder.ID,
+			Title:      folder.Name,
+			FolderPath: trashFolderPath(folders, folder.ParentID),
+		}
+		payload := trashedFolder{Name: folder.Name, Color: folder.Color, IsExpanded: bool(folder.IsExpanded)}
+		if payload.Links, err = GetEntityLinksForEntityWithTx(tx, sharedDbID, models.EntityFolder, folder.ID); err != nil {
+			return 0, fmt.Errorf("TrashFoldersWithTx: %w", err)
+		}
+		if err := insertTrashItemWithTx(tx, item, payload, userID); err != nil {
+			return 0, fmt.Errorf("TrashFoldersWithTx: %w", err)
+		}
+	}
+	for _, folder := range toTrash {
+		if err := DeleteFolderWithTx(tx, folder.ID, sharedDbID); err != nil && err != sql.ErrNoRows {
+			return 0, fmt.Errorf("TrashFoldersWithTx: %w", err)
+		}
+	}
+	if len(toTrash) > 0 {
+		log.Printf("В корзину БД %d перемещено папок: %d", sharedDbID, len(toTrash))
+	}
+	return len(toTrash), nil
+}
+
+// folderMapWithTx загружает папки совместной БД по ID.
+func folderMapWithTx(tx *sqlx.Tx, sharedDbID int64) (map[int64]models.Folder, error) {
+	folders, err := GetAllFoldersBySharedDBIDWithTx(tx, sharedDbID)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int64]models.Folder, len(folders))
+	for _, folder := range folders {
+		byID[folder.ID] = folder
+	}
+	return byID, nil
+}
+
+// sortedFolders возвращает папки по возрастанию ID.
+func sortedFolders(folders map[int64]models.Folder) []models.Folder {
+	list := make([]models.Folder, 0, len(folders))
+	for _, folder := range folders {
+		list = append(list, folder)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+// trashFolderPath строит путь от корневой папки до папки parentID. Защищена от циклов в ParentId.
+func trashFolderPath(folders map[int64]models.Folder, parentID *int64) []models.TrashFolderRef {
+	path := []models.TrashFolderRef{}
+	visited := make(map[int64]bool)
+	for parentID != nil && !visited[*parentID] {
+		folder, ok := folders[*parentID]
+		if !ok {
+			break
+		}
+		visited[folder.ID] = true
+		path = append([]models.TrashFolderRef{{Id: folder.ID, Name: folder.Name, Color: folder.Color}}, path...)
+		parentID = folder.ParentID
+	}
+	return path
+}
+
+// insertTrashItemWithTx сохраняет элемент корзины с содержимым payload.
+func insertTrashItemWithTx(tx *sqlx.Tx, item *models.TrashItem, payload interface{}, userID int64) error {
+	payloadJson, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("ошибка сериализации элемента корзины %s ID %d: %w", item.ItemType, item.OriginalId, err)
+	}
+	pathJson, err := json.Marshal(item.FolderPath)
+	if err != nil {
+		return fmt.Errorf("ошибка сериализации пути элемента корзины %s ID %d: %w", item.ItemType, item.OriginalId, err)
+	}
+	item.PayloadJson = string(payloadJson)
+	item.FolderPathJson = string(pathJson)
+	item.DeletedByUserId = &userID
+	item.DeletedAt = models.FlexibleTime{Time: time.Now()}
+
+	query := `INSERT INTO TrashItems (DatabaseId, ItemType, OriginalId, Title, FolderPathJson, PayloadJson, DeletedByUserId, DeletedAt)
+	          VALUES (:DatabaseId, :ItemType, :OriginalId, :Title, :FolderPathJson, :PayloadJson, :DeletedByUserId, :DeletedAt)`
+	result, err := tx.NamedExec(query, item)
+	if err != nil {
+		return fmt.Errorf("ошибка вставки элемента корзины %s ID %d: %w", item.ItemType, item.OriginalId, err)
+	}
+	item.Id, err = result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("ошибка получения LastInsertId элемента корзины: %w", err)
+	}
+	return nil
+}
+
+// loadTrashItemFields разбирает путь элемента корзины и рассчитывает срок его хранения.
+func loadTrashItemFields(item *models.TrashItem) {
+	item.FolderPath = []models.TrashFolderRef{}
+	if err := json.Unmarshal([]byte(item.FolderPathJson), &item.FolderPath); err != nil {
+		log.Printf("Ошибка разбора пути элемента корзины %d: %v", item.Id, err)
+	}
+	item.ExpiresAt = TrashExpiresAt(item.DeletedAt.Time)
+}
+
+// GetTrashItems извлекает элементы корзины совместной БД, начиная с удаленных последними.
+func GetTrashItems(sharedDbID int64) ([]models.TrashItem, error) {
+	items := []models.TrashItem{}
+	query := `SELECT Id, DatabaseId, ItemType, OriginalId, Title, FolderPathJson, PayloadJson, DeletedByUserId, DeletedAt
+	          FROM TrashItems WHERE DatabaseId = ? ORDER BY DeletedAt DESC, Id DESC`
+	if err := MainDB.Select(&items, query, sharedDbID); err != nil {
+		return nil, fmt.Errorf("GetTrashItems: ошибка получения корзины SharedDBID %d: %w", sharedDbID, err)
+	}
+	for i := range items {
+		loadTrashItemFields(&items[i])
+	}
+	return items, nil
+}
+
+// getTrashItemByIDWithTx извлекает элемент корзины по ID в рамках транзакции; (nil, nil), если его нет.
+func getTrashItemByIDWithTx(tx *sqlx.Tx, id int64, sharedDbID int64) (*models.TrashItem, error) {
+	item := &models.TrashItem{}
+	query := `SELECT Id, DatabaseId, ItemType, OriginalId, Title, FolderPathJson, PayloadJson, DeletedByUserId, DeletedAt
+	          FROM TrashItems WHERE Id = ? AND DatabaseId = ?`
+	if err := tx.Get(item, query, id, sharedDbID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // Не найдено
+		}
+		return nil, fmt.Errorf("ошибка получения элемента корзины ID %d, SharedDBID %d: %w", id, sharedDbID, err)
+	}
+	loadTrashItemFields(item)
+	return item, nil
+}
+
+// RestoreTrashItem восстанавливает заметку или папку из корзины. Путь папок восстанавливается:
+// используется папка с прежним ID, если она есть, иначе папка с тем же названием в той же родительской,
+// иначе папка создается заново. Восстановленная заметка или папка получает прежний ID, если он свободен.
+// Возвращает sql.ErrNoRows, если элемента нет в корзине.
+func RestoreTrashItem(sharedDbID int64, itemID int64, userID int64) (*TrashRestoreResult, error) {
+	tx, err := MainDB.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("RestoreTrashItem: ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback()
+
+	item, err := getTrashItemByIDWithTx(tx, itemID, sharedDbID)
+	if err != nil {
+		return nil, fmt.Errorf("RestoreTrashItem: %w", err)
+	}
+	if item == nil {
+		return nil, sql.ErrNoRows
+	}
+
+	result := &TrashRestoreResult{ItemType: item.ItemType}
+	folderID, created, err := ensureFolderPathWithTx(tx, sharedDbID, item.FolderPath)
+	if err != nil {
+		return nil, fmt.Errorf("RestoreTrashItem: %w", err)
+	}
+	result.CreatedFolders = created
+
+	switch item.ItemType {
+	case models.TrashItemNote:
+		var payload trashedNote
+		if err := json.Unmarshal([]byte(item.PayloadJson), &payload); err != nil {
+			return nil, fmt.Errorf("RestoreTrashItem: ошибка разбора заметки элемента %d: %w", item.Id, err)
+		}
+		note, err := restoreTrashedNoteWithTx(tx, sharedDbID, item.OriginalId, folderID, payload, userID)
+		if err != nil {
+			return nil, fmt.Errorf("RestoreTrashItem: %w", err)
+		}
+		result.Note = note
+	case models.TrashItemFolder:
+		var payload trashedFolder
+		if err := json.Unmarshal([]byte(item.PayloadJson), &payload); err != nil {
+			return nil, fmt.Errorf("RestoreTrashItem: ошибка разбора папки элемента %d: %w", item.Id, err)
+		}
+		ref := models.TrashFolderRef{Id: item.OriginalId, Name: payload.Name, Color: payload.Color}
+		folder, isNew, err := ensureFolderWithTx(tx, sharedDbID, ref, folderID, payload.IsExpanded)
+		if err != nil {
+			return nil, fmt.Errorf("RestoreTrashItem: %w", err)
+		}
+		if !isNew {
+			log.Printf("RestoreTrashItem: папка '%s' уже есть в БД %d (ID %d), используется она", payload.Name, sharedDbID, folder.ID)
+		}
+		result.Folder = folder
+	default:
+		return nil, fmt.Errorf("RestoreTrashItem: неизвестный тип элемента корзины %q", item.ItemType)
+	}
+
+	if _, err := tx.Exec(`DELETE FROM TrashItems WHERE Id = ?`, item.Id); err != nil {
+		return nil, fmt.Errorf("RestoreTrashItem: ошибка удаления элемента корзины ID %d: %w", item.Id, err)
+	}
+	if _, err := tx.Exec(`UPDATE SharedDatabases SET UpdatedAt = ? WHERE Id = ?`, time.Now(), sharedDbID); err != nil {
+		return nil, fmt.Errorf("RestoreTrashItem: ошибка обновления UpdatedAt для БД %d: %w", sharedDbID, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("RestoreTrashItem: ошибка коммита: %w", err)
+	}
+	log.Printf("Элемент корзины %d (%s ID %d) БД %d восстановлен пользователем %d", item.Id, item.ItemType, item.OriginalId, sharedDbID, userID)
+	return result, nil
+}
+
+// ensureFolderPathWithTx находит или создает папки пути и возвращает ID последней (nil для пустого пути)
+// и созданные папки.
+func ensureFolderPathWithTx(tx *sqlx.Tx, sharedDbID int64, path []models.TrashFolderRef) (*int64, []models.Folder, error) {
+	created := []models.Folder{}
+	var parentID *int64
+	for _, ref := range path {
+		folder, isNew, err := ensureFolderWithTx(tx, sharedDbID, ref, parentID, true)
+		if err != nil {
+			return nil, nil, err
+		}
+		if isNew {
+			created = append(created, *folder)
+		}
+		parentID = &folder.ID
+	}
+	return parentID, created, nil
+}
+
+// ensureFolderWithTx возвращает папку ref: с прежним ID, с тем же названием в родительской папке parentID
+// или созданную заново (с прежним ID, если он свободен). isNew = true, если папка создана.
+func ensureFolderWithTx(tx *sqlx.Tx, sharedDbID int64, ref models.TrashFolderRef, parentID *int64, isExpanded bool) (*models.Folder, bool, error) {
+	folder, err := GetFolderByIDWithTx(tx, ref.Id, sharedDbID)
+	if err != nil {
+		return nil, false, err
+	}
+	if folder != nil {
+		return folder, false, nil
+	}
+
+	var existingID int64
+	err = tx.Get(&existingID, `SELECT Id FROM Folders WHERE DatabaseId = ? AND Name = ? AND ParentId IS ? ORDER BY Id ASC LIMIT 1`, sharedDbID, ref.Name, parentID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, false, fmt.Errorf("ошибка поиска папки '%s' в БД %d: %w", ref.Name, sharedDbID, err)
+	}
+	if err == nil {
+		folder, err := GetFolderByIDWithTx(tx, existingID, sharedDbID)
+		return folder, false, err
+	}
+
+	now := time.Now()
+	folder = &models.Folder{
+		DatabaseID: sharedDbID,
+		Name:       ref.Name,
+		ParentID:   parentID,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+		Color:      ref.Color,
+		IsExpanded: models.BoolFromInt(isExpanded),
+	}
+	folderID, err := freeIDOrNil(tx, "Folders", ref.Id)
+	if err != nil {
+		return nil, false, err
+	}
+	result, err := tx.Exec(`INSERT INTO Folders (Id, DatabaseId, Name, ParentId, CreatedAt, UpdatedAt, Color, IsExpanded)
+	                        VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		folderID, folder.DatabaseID, folder.Name, folder.ParentID, folder.CreatedAt, folder.UpdatedAt, folder.Color, folder.IsExpanded)
+	if err != nil {
+		return nil, false, fmt.Errorf("ошибка создания папки '%s' в БД %d: %w", ref.Name, sharedDbID, err)
+	}
+	if folder.ID, err = result.LastInsertId(); err != nil {
+		return nil, false, fmt.Errorf("ошибка получения LastInsertId папки '%s': %w", ref.Name, err)
+	}
+	// Папка пути восстановлена, поэтому ее собственный элемент корзины больше не нужен; ссылки папки восстанавливаются из него
+	var payloads []string
+	if err := tx.Select(&payloads, `SELECT PayloadJson FROM TrashItems WHERE DatabaseId = ? AND ItemType = ? AND OriginalId = ?`, sharedDbID, models.TrashItemFolder, ref.Id); err != nil {
+		return nil, false, fmt.Errorf("ошибка получения папки '%s' из корзины: %w", ref.Name, err)
+	}
+	for _, payloadJson := range payloads {
+		var payload trashedFolder
+		if err := json.Unmarshal([]byte(payloadJson), &payload); err != nil {
+			log.Printf("Восстановление папки '%s': ошибка разбора элемента корзины: %v, ссылки не восстановлены", ref.Name, err)
+			continue
+		}
+		if err := restoreEntityLinksWithTx(tx, sharedDbID, payload.Links, models.EntityFolder, ref.Id, folder.ID); err != nil {
+			return nil, false, err
+		}
+	}
+	if _, err := tx.Exec(`DELETE FROM TrashItems WHERE DatabaseId = ? AND ItemType = ? AND OriginalId = ?`, sharedDbID, models.TrashItemFolder, ref.Id); err != nil {
+		return nil, false, fmt.Errorf("ошибка удаления папки '%s' из корзины: %w", ref.Name, err)
+	}
+	return folder, true, nil
+}
+
+// restoreEntityLinksWithTx заново создает сохраненные в корзине ссылки восстановленной сущности: ее конец
+// (entityType, oldID) переносится на newID. Ссылки, другой конец которых уже удален, пропускаются.
+func restoreEntityLinksWithTx(tx *sqlx.Tx, sharedDbID int64, links []models.EntityLink, entityType models.EntityType, oldID int64, newID int64) error {
+	for _, link := range links {
+		otherType, otherID := link.TargetType, link.TargetId
+		if link.SourceType == entityType && link.SourceId == oldID {
+			link.SourceId = newID
+		} else {
+			otherType, otherID = link.SourceType, link.SourceId
+		}
+		if link.TargetType == entityType && link.TargetId == oldID {
+			link.TargetId = newID
+		}
+		exists, err := EntityExistsWithTx(tx, sharedDbID, otherType, otherID)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			log.Printf("Восстановление %s ID %d: ссылка на %s ID %d пропущена, сущность удалена", entityType, newID, otherType, otherID)
+			continue
+		}
+		link.Id = 0
+		link.DatabaseId = sharedDbID
+		if _, err := CreateEntityLinkWithTx(tx, &link); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// freeIDOrNil возвращает id, если строки с таким Id в таблице нет, иначе nil (SQLite назначит новый ID).
+func freeIDOrNil(tx *sqlx.Tx, table string, id int64) (interface{}, error) {
+	if id <= 0 {
+		return nil, nil
+	}
+	var count int
+	if err := tx.Get(&count, `SELECT COUNT(*) FROM `+table+` WHERE Id = ?`, id); err != nil {
+		return nil, fmt.Errorf("ошибка проверки ID %d в таблице %s: %w", id, table, err)
+	}
+	if count > 0 {
+		return nil, nil
+	}
+	return id, nil
+}
+
+// restoreTrashedNoteWithTx создает заметку из содержимого элемента корзины вместе с тегами, ссылками
+// и изображениями, файлы которых еще есть на диске. Категория сбрасывается, если ее больше нет.
+func restoreTrashedNoteWithTx(tx *sqlx.Tx, sharedDbID int64, originalID int64, folderID *int64, payload trashedNote, userID int64) (*models.Note, error) {
+	note := &models.Note{
+		DatabaseID:   sharedDbID,
+		Title:        payload.Title,
+		Content:      payload.Content,
+		FolderID:     folderID,
+		CategoryId:   payload.CategoryId,
+		CreatedAt:    payload.CreatedAt,
+		UpdatedAt:    time.Now(),
+		ImagesJson:   payload.ImagesJson,
+		MetadataJson: payload.MetadataJson,
+		ContentJson:  payload.ContentJson,
+	}
+	if note.CategoryId != nil {
+		var count int
+		if err := tx.Get(&count, `SELECT COUNT(*) FROM Categories WHERE Id = ? AND DatabaseId = ?`, *note.CategoryId, sharedDbID); err != nil {
+			return nil, fmt.Errorf("ошибка проверки категории ID %d: %w", *note.CategoryId, err)
+		}
+		if count == 0 {
+			note.CategoryId = nil
+		}
+	}
+	if note.CreatedAt.IsZero() {
+		note.CreatedAt = note.UpdatedAt
+	}
+
+	noteID, err := freeIDOrNil(tx, "Notes", originalID)
+	if err != nil {
+		return nil, err
+	}
+	result, err := tx.Exec(`INSERT INTO Notes (Id, DatabaseId, Title, Content, FolderId, CategoryId, CreatedAt, UpdatedAt, ImagesJson, MetadataJson, ContentJson)
+	                        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		noteID, note.DatabaseID, note.Title, note.Content, note.FolderID, note.CategoryId,
+		note.CreatedAt, note.UpdatedAt, note.ImagesJson, note.MetadataJson, note.ContentJson)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка восстановления заметки '%s': %w", note.Title, err)
+	}
+	if note.ID, err = result.LastInsertId(); err != nil {
+		return nil, fmt.Errorf("ошибка получения LastInsertId заметки '%s': %w", note.Title, err)
+	}
+	// Прежний ID занят: история версий переносится на новый ID, иначе она стала бы недоступной
+	if note.ID != originalID {
+		if _, err := tx.Exec(`UPDATE NoteRevisions SET NoteId = ? WHERE DatabaseId = ? AND NoteId = ?`, note.ID, sharedDbID, originalID); err != nil {
+			return nil, fmt.Errorf("ошибка переноса версий заметки ID %d на ID %d: %w", originalID, note.ID, err)
+		}
+	}
+
+	now := time.Now()
+	for _, tag := range payload.Tags {
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO NoteTags (NoteId, Tag, DatabaseId, CreatedAt) VALUES (?, ?, ?, ?)`, note.ID, tag, sharedDbID, now); err != nil {
+			return nil, fmt.Errorf("ошибка восстановления тега '%s' заметки ID %d: %w", tag, note.ID, err)
+		}
+	}
+	for _, image := range payload.Images {
+		if _, statErr := os.Stat(image.ImagePath); statErr != nil {
+			log.Printf("Восстановление заметки ID %d: файл изображения %s недоступен (%v), изображение пропущено", note.ID, image.ImagePath, statErr)
+			continue
+		}
+		_, err := tx.Exec(`INSERT INTO NoteImages (NoteId, ImagePath, FileName, DatabaseId, CreatedAt, UpdatedAt) VALUES (?, ?, ?, ?, ?, ?)`,
+			note.ID, image.ImagePath, image.FileName, sharedDbID, image.CreatedAt, now)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка восстановления изображения %s заметки ID %d: %w", image.FileName, note.ID, err)
+		}
+	}
+
+	if err := restoreEntityLinksWithTx(tx, sharedDbID, payload.Links, models.EntityNote, originalID, note.ID); err != nil {
+		return nil, err
+	}
+	if _, err := RecordNoteRevisionWithTx(tx, nil, note, &userID, models.NoteRevisionReasonRestore); err != nil {
+		return nil, err
+	}
+	if err := RebuildNoteWikiLinksWithTx(tx, sharedDbID); err != nil {
+		return nil, err
+	}
+	if err := note.LoadJsonProperties(); err != nil {
+		return nil, err
+	}
+	return note, nil
+}
+
+// PurgeTrashItem окончательно удаляет элемент корзины и файлы изображений удаленной заметки.
+// Возвращает sql.ErrNoRows, если элемента нет в корзине.
+func PurgeTrashItem(sharedDbID int64, itemID int64) error {
+	purged, err := purgeTrashItems(`Id = ? AND DatabaseId = ?`, itemID, sharedDbID)
+	if err != nil {
+		return fmt.Errorf("PurgeTrashItem: %w", err)
+	}
+	if purged == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// EmptyTrash окончательно удаляет все элементы корзины совместной БД. Возвращает количество удаленных элементов.
+func EmptyTrash(sharedDbID int64) (int64, error) {
+	purged, err := purgeTrashItems(`DatabaseId = ?`, sharedDbID)
+	if err != nil {
+		return 0, fmt.Errorf("EmptyTrash: %w", err)
+	}
+	return purged, nil
+}
+
+// PurgeExpiredTrash окончательно удаляет элементы корзины и совместные БД, удаленные раньше before.
+// Возвращает количество удаленных элементов и БД.
+func PurgeExpiredTrash(before time.Time) (int64, int64, error) {
+	items, err := purgeTrashItems(`DeletedAt < ?`, before)
+	if err != nil {
+		return 0, 0, fmt.Errorf("PurgeExpiredTrash: %w", err)
+	}
+
+	var dbIDs []int64
+	if err := MainDB.Select(&dbIDs, `SELECT Id FROM SharedDatabases WHERE DeletedAt IS NOT NULL AND DeletedAt < ?`, before); err != nil {
+		return items, 0, fmt.Errorf("PurgeExpiredTrash: ошибка получения удаленных совместных БД: %w", err)
+	}
+	var databases int64
+	for _, dbID := range dbIDs {
+		if err := purgeSharedDatabase(dbID); err != nil {
+			return items, databases, fmt.Errorf("PurgeExpiredTrash: %w", err)
+		}
+		databases++
+	}
+	return items, databases, nil
+}
+
+// purgeTrashItems удаляет элементы корзины по условию where вместе с историей версий удаленных заметок,
+// а после коммита - файлы их изображений, на которые больше не ссылается ни одна запись NoteImages.
+func purgeTrashItems(where string, args ...interface{}) (int64, error) {
+	tx, err := MainDB.Beginx()
+	if err != nil {
+		return 0, fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback()
+
+	var items []models.TrashItem
+	if err := tx.Select(&items, `SELECT Id, DatabaseId, ItemType, OriginalId, PayloadJson FROM TrashItems WHERE `+where, args...); err != nil {
+		return 0, fmt.Errorf("ошибка получения элементов корзины: %w", err)
+	}
+	if len(items) == 0 {
+		return 0, nil
+	}
+	var imagePaths []string
+	for _, item := range items {
+		if item.ItemType != models.TrashItemNote {
+			continue
+		}
+		// История версий хранится по ID заметки и после ее удаления; удаляется вместе с элементом корзины,
+		// если заметка с этим ID не восстановлена в этой БД (в другой БД ID может быть занят своей заметкой)
+		_, err := tx.Exec(`DELETE FROM NoteRevisions WHERE DatabaseId = ? AND NoteId = ? AND NOT EXISTS (SELECT 1 FROM Notes WHERE Id = ? AND DatabaseId = ?)`,
+			item.DatabaseId, item.OriginalId, item.OriginalId, item.DatabaseId)
+		if err != nil {
+			return 0, fmt.Errorf("ошибка удаления версий заметки ID %d: %w", item.OriginalId, err)
+		}
+		var payload trashedNote
+		if err := json.Unmarshal([]byte(item.PayloadJson), &payload); err != nil {
+			log.Printf("Ошибка разбора заметки элемента корзины %d: %v", item.Id, err)
+			continue
+		}
+		for _, image := range payload.Images {
+			imagePaths = append(imagePaths, image.ImagePath)
+		}
+	}
+	result, err := tx.Exec(`DELETE FROM TrashItems WHERE `+where, args...)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка удаления элементов корзины: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("ошибка коммита: %w", err)
+	}
+	purged, _ := result.RowsAffected()
+
+	for _, path := range imagePaths {
+		var references int
+		if err := MainDB.Get(&references, `SELECT COUNT(*) FROM NoteImages WHERE ImagePath = ?`, path); err != nil || references > 0 {
+			continue
+		}
+		removeUploadedFile(path)
+	}
+	log.Printf("Из корзины окончательно удалено элементов: %d, файлов изображений: %d", purged, len(imagePaths))
+	return purged, nil
+}
+
+// removeUploadedFile удаляет файл, если он находится в директории uploads.
+func removeUploadedFile(path string) {
+	resolvedPath, err := filepath.Abs(path)
+	if err != nil {
+		log.Printf("Не удалось разрешить путь к файлу для удаления: %s, ошибка: %v", path, err)
+		return
+	}
+	uploadsDir, _ := filepath.Abs("uploads")
+	if !strings.HasPrefix(resolvedPath, uploadsDir+string(filepath.Separator)) {
+		log.Printf("Попытка удаления файла вне директории 'uploads': %s (разрешенный: %s)", path, resolvedPath)
+		return
+	}
+	if err := os.Remove(resolvedPath); err != nil && !os.IsNotExist(err) {
+		log.Printf("Ошибка при удалении файла %s: %v", path, err)
+	}
+}
+
+// GetDeletedSharedDatabasesForOwner извлекает совместные БД владельца, перемещенные в корзину.
+func GetDeletedSharedDatabasesForOwner(userID int64) ([]models.SharedDatabase, error) {
+	dbs := []models.SharedDatabase{}
+	query := `SELECT Id, Name, OwnerUserId, CreatedAt, UpdatedAt, TimeZone, DeletedAt FROM SharedDatabases
+	          WHERE OwnerUserId = ? AND DeletedAt IS NOT NULL ORDER BY DeletedAt DESC`
+	if err := MainDB.Select(&dbs, query, userID); err != nil {
+		return nil, fmt.Errorf("GetDeletedSharedDatabasesForOwner: ошибка получения удаленных БД пользователя %d: %w", userID, err)
+	}
+	return dbs, nil
+}
+
+// RestoreSharedDatabase возвращает совместную БД владельца из корзины.
+// Возвращает sql.ErrNoRows, если такой удаленной БД у пользователя нет.
+func RestoreSharedDatabase(sdbID int64, userID int64) error {
+	result, err := MainDB.Exec(`UPDATE SharedDatabases SET DeletedAt = NULL, UpdatedAt = ? WHERE Id = ? AND OwnerUserId = ? AND DeletedAt IS NOT NULL`,
+		time.Now(), sdbID, userID)
+	if err != nil {
+		return fmt.Errorf("RestoreSharedDatabase: ошибка восстановления БД %d: %w", sdbID, err)
+	}
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	log.Printf("Совместная БД %d восстановлена из корзины пользователем %d", sdbID, userID)
+	return nil
+}
+
+// PurgeSharedDatabase окончательно удаляет совместную БД владельца из корзины вместе с ее файлами.
+// Возвращает sql.ErrNoRows, если такой удаленной БД у пользователя нет.
+func PurgeSharedDatabase(sdbID int64, userID int64) error {
+	var count int
+	err := MainDB.Get(&count, `SELECT COUNT(*) FROM SharedDatabases WHERE Id = ? AND OwnerUserId = ? AND DeletedAt IS NOT NULL`, sdbID, userID)
+	if err != nil {
+		return fmt.Errorf("PurgeSharedDatabase: ошибка проверки БД %d: %w", sdbID, err)
+	}
+	if count == 0 {
+		return sql.ErrNoRows
+	}
+	if err := purgeSharedDatabase(sdbID); err != nil {
+		return fmt.Errorf("PurgeSharedDatabase: %w", err)
+	}
+	return nil
+}
+
+// purgeSharedDatabase окончательно удаляет совместную БД и директории ее файлов в uploads.
+func purgeSharedDatabase(sdbID int64) error {
+	tx, err := MainDB.Beginx()
+	if err != nil {
+		return fmt.Errorf("ошибка начала транзакции: %w", err)
+	}
+	defer tx.Rollback()
+	if err := purgeSharedDatabaseWithTx(tx, sdbID); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("ошибка коммита удаления БД %d: %w", sdbID, err)
+	}
+
+	// Изображения синхронизации лежат в shared_db_<id>, восстановленные из бэкапа — в shared_db_images/<id>.
+	id := strconv.FormatInt(sdbID, 10)
+	for _, dir := range []string{
+		filepath.Join("uploads", "shared_db_"+id),
+		filepath.Join("uploads", "shared_db_images", id),
+	} {
+		if err := os.RemoveAll(dir); err != nil {
+			log.Printf("Ошибка при удалении файлов совместной БД %d (%s): %v", sdbID, dir, err)
+		}
+	}
+	log.Printf("Совместная БД %d окончательно удалена", sdbID)
+	return nil
+}
@@ -0,0 +1,102 @@
+package ical
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ParseDateTime разбирает значение даты или даты-времени iCalendar ("20250301", "20250301T090000",
+// "20250301T090000Z"). Время в UTC и с известным TZID переводится в часовой пояс loc,
+// "плавающее" время и время с неизвестным TZID берутся как есть. allDay = true для значений-дат.
+func ParseDateTime(value string, tzid string, loc *time.Location) (t time.Time, allDay bool, err error) {
+	value = strings.TrimSpace(value)
+	if len(value) == len(dateLayout) {
+		t, err = time.ParseInLocation(dateLayout, value, loc)
+		if err != nil {
+			return t, false, fmt.Errorf("неверная дата %q", value)
+		}
+		return t, true, nil
+	}
+	if strings.HasSuffix(value, "Z") {
+		t, err = time.Parse(utcLayout, value)
+		if err != nil {
+			return t, false, fmt.Errorf("неверная дата-время %q", value)
+		}
+		return t.In(loc), false, nil
+	}
+
+	source := loc
+	if tzid != "" {
+		if zone, zoneErr := time.LoadLocation(tzid); zoneErr == nil {
+			source = zone
+		}
+	}
+	t, err = time.ParseInLocation(dateTimeLayout, value, source)
+	if err != nil {
+		return t, false, fmt.Errorf("неверная дата-время %q", value)
+	}
+	return t.In(loc), false, nil
+}
+
+// KnownTZID проверяет, известен ли часовой пояс TZID (идентификатор IANA).
+func KnownTZID(tzid string) bool {
+	if tzid == "" {
+		return true
+	}
+	_, err := time.LoadLocation(tzid)
+	return err == nil
+}
+
+// ParseDuration разбирает длительность iCalendar (RFC 5545, 3.3.6): "PT1H30M", "P1D", "P2W", "-PT15M".
+func ParseDuration(value string) (time.Duration, error) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	sign := time.Duration(1)
+	if strings.HasPrefix(value, "-") {
+		sign = -1
+	}
+	value = strings.TrimLeft(value, "+-")
+	if !strings.HasPrefix(value, "P") || len(value) < 3 {
+		return 0, fmt.Errorf("неверная длительность %q", value)
+	}
+
+	var total time.Duration
+	number := 0
+	hasNumber := false
+	inTime := false
+	for _, ch := range value[1:] {
+		switch {
+		case ch >= '0' && ch <= '9':
+			number = number*10 + int(ch-'0')
+			hasNumber = true
+			continue
+		case ch == 'T':
+			inTime = true
+			continue
+		}
+		if !hasNumber {
+			return 0, fmt.Errorf("неверная длительность %q", value)
+		}
+		var unit time.Duration
+		switch {
+		case ch == 'W' && !inTime:
+			unit = 7 * 24 * time.Hour
+		case ch == 'D' && !inTime:
+			unit = 24 * time.Hour
+		case ch == 'H' && inTime:
+			unit = time.Hour
+		case ch == 'M' && inTime:
+			unit = time.Minute
+		case ch == 'S' && inTime:
+			unit = time.Second
+		default:
+			return 0, fmt.Errorf("неверная длительность %q", value)
+		}
+		total += time.Duration(number) * unit
+		number, hasNumber = 0, false
+	}
+	if hasNumber {
+		return 0, fmt.Errorf("неверная длительность %q", value)
+	}
+	return sign * total, nil
+}
@@ -0,0 +1,207 @@
+// Package ical формирует календари в формате iCalendar (RFC 5545) для подписки из календарных приложений.
+package ical
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ContentType - MIME-тип календаря iCalendar.
+const ContentType = "text/calendar; charset=utf-8"
+
+// ProdID - идентификатор продукта, указываемый в календаре.
+const ProdID = "-//NotesServerGO//Schedule//RU"
+
+// maxLineOctets - максимальная длина строки содержимого без перевода строки (RFC 5545, 3.1).
+const maxLineOctets = 75
+
+const (
+	dateLayout     = "20060102"
+	dateTimeLayout = "20060102T150405"
+	utcLayout      = "20060102T150405Z"
+)
+
+// Calendar - объект VCALENDAR.
+type Calendar struct {
+	Name     string         // X-WR-CALNAME - название, которое показывают календарные приложения
+	TimeZone *time.Location // X-WR-TIMEZONE - часовой пояс календаря по умолчанию; nil - не выводится
+	Events   []Event
+}
+
+// Event - объект VEVENT. Время Start, End, ExDates и RecurrenceID выводится в часовом поясе TimeZone
+// (с параметром TZID, описание пояса - в VTIMEZONE календаря) или, если он не задан, как "плавающее"
+// (без часового пояса), то есть в местном времени подписчика; у событий на весь день - только дата.
+type Event struct {
+	UID          string
+	Stamp        time.Time // DTSTAMP, выводится в UTC
+	LastModified time.Time // Нулевое значение - не выводится
+	Start        time.Time
+	End          time.Time // Нулевое значение - DTEND не выводится
+	AllDay       bool
+	Summary      string
+	Description  string
+	Categories   []string
+	RRule        *RRule
+	ExDates      []time.Time
+	RecurrenceID *time.Time // Заполнено у события, заменяющего одно повторение серии с тем же UID
+	TimeZone     *time.Location
+}
+
+// Encode записывает календарь в w: строки разделяются CRLF, длинные строки переносятся.
+func (c *Calendar) Encode(w io.Writer) error {
+	enc := &encoder{w: bufio.NewWriter(w)}
+	enc.line("BEGIN:VCALENDAR")
+	enc.line("VERSION:2.0")
+	enc.line("PRODID:" + ProdID)
+	enc.line("CALSCALE:GREGORIAN")
+	enc.line("METHOD:PUBLISH")
+	if c.Name != "" {
+		enc.line("X-WR-CALNAME:" + EscapeText(c.Name))
+	}
+	if zoneName(c.TimeZone) != "" {
+		enc.line("X-WR-TIMEZONE:" + zoneName(c.TimeZone))
+	}
+	for _, loc := range c.timeZones() {
+		encodeTimeZone(enc, loc, time.Now().Year())
+	}
+	for i := range c.Events {
+		c.Events[i].encode(enc)
+	}
+	enc.line("END:VCALENDAR")
+	return enc.flush()
+}
+
+// timeZones возвращает часовые пояса событий, для которых нужны компоненты VTIMEZONE, в порядке появления.
+func (c *Calendar) timeZones() []*time.Location {
+	var zones []*time.Location
+	seen := make(map[string]bool)
+	for i := range c.Events {
+		name := zoneName(c.Events[i].TimeZone)
+		if name == "" || name == "UTC" || c.Events[i].AllDay || seen[name] {
+			continue
+		}
+		seen[name] = true
+		zones = append(zones, c.Events[i].TimeZone)
+	}
+	return zones
+}
+
+// String возвращает календарь в виде строки.
+func (c *Calendar) String() string {
+	var sb strings.Builder
+	_ = c.Encode(&sb)
+	return sb.String()
+}
+
+func (e *Event) encode(enc *encoder) {
+	enc.line("BEGIN:VEVENT")
+	enc.line("UID:" + e.UID)
+	enc.line("DTSTAMP:" + e.Stamp.UTC().Format(utcLayout))
+	if !e.LastModified.IsZero() {
+		enc.line("LAST-MODIFIED:" + e.LastModified.UTC().Format(utcLayout))
+	}
+	if e.RecurrenceID != nil {
+		enc.line("RECURRENCE-ID" + e.formatTime(*e.RecurrenceID))
+	}
+	enc.line("DTSTART" + e.formatTime(e.Start))
+	if !e.End.IsZero() {
+		enc.line("DTEND" + e.formatTime(e.End))
+	}
+	if e.RRule != nil {
+		enc.line("RRULE:" + e.RRule.Format(e.AllDay, e.TimeZone))
+	}
+	for _, exDate := range e.ExDates {
+		enc.line("EXDATE" + e.formatTime(exDate))
+	}
+	enc.line("SUMMARY:" + EscapeText(e.Summary))
+	if e.Description != "" {
+		enc.line("DESCRIPTION:" + EscapeText(e.Description))
+	}
+	if len(e.Categories) > 0 {
+		escaped := make([]string, len(e.Categories))
+		for i, category := range e.Categories {
+			escaped[i] = EscapeText(category)
+		}
+		enc.line("CATEGORIES:" + strings.Join(escaped, ","))
+	}
+	enc.line("END:VEVENT")
+}
+
+// formatTime возвращает параметры и значение свойства даты/времени, начиная с ";" или ":".
+func (e *Event) formatTime(t time.Time) string {
+	if e.AllDay {
+		return ";VALUE=DATE:" + t.Format(dateLayout)
+	}
+	switch name := zoneName(e.TimeZone); name {
+	case "":
+		return ":" + t.Format(dateTimeLayout)
+	case "UTC":
+		return ":" + t.UTC().Format(utcLayout)
+	default:
+		return ";TZID=" + name + ":" + t.In(e.TimeZone).Format(dateTimeLayout)
+	}
+}
+
+// zoneName возвращает имя часового пояса IANA для TZID или пустую строку для "плавающего" времени:
+// часовой пояс сервера (time.Local) не имеет имени, которое поймут календарные приложения.
+func zoneName(loc *time.Location) string {
+	if loc == nil || loc == time.Local || loc.String() == "Local" {
+		return ""
+	}
+	return loc.String()
+}
+
+// EscapeText экранирует значение типа TEXT (RFC 5545, 3.3.11).
+func EscapeText(value string) string {
+	value = strings.ReplaceAll(value, "\r\n", "\n")
+	return textEscaper.Replace(value)
+}
+
+var textEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	`;`, `\;`,
+	`,`, `\,`,
+	"\n", `\n`,
+	"\r", `\n`,
+)
+
+// encoder записывает строки содержимого с переносом длинных строк. Первая ошибка записи сохраняется.
+type encoder struct {
+	w   *bufio.Writer
+	err error
+}
+
+// line записывает строку, перенося ее по maxLineOctets байт (без разрыва символов UTF-8):
+// продолжение начинается с пробела.
+func (enc *encoder) line(content string) {
+	if enc.err != nil {
+		return
+	}
+	limit := maxLineOctets
+	for len(content) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(content[cut]) {
+			cut--
+		}
+		enc.write(content[:cut] + "\r\n ")
+		content = content[cut:]
+		limit = maxLineOctets - 1 // Пробел в начале продолжения занимает один байт
+	}
+	enc.write(content + "\r\n")
+}
+
+func (enc *encoder) write(s string) {
+	if enc.err == nil {
+		_, enc.err = enc.w.WriteString(s)
+	}
+}
+
+func (enc *encoder) flush() error {
+	if enc.err != nil {
+		return enc.err
+	}
+	return enc.w.Flush()
+}
@@ -0,0 +1,72 @@
+package ical
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEscapeText(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"Встреча", "Встреча"},
+		{"a, b; c", `a\, b\; c`},
+		{`C:\temp`, `C:\\temp`},
+		{"строка 1\r\nстрока 2\nстрока 3", `строка 1\nстрока 2\nстрока 3`},
+	}
+	for _, tt := range tests {
+		if got := EscapeText(tt.value); got != tt.want {
+			t.Errorf("EscapeText(%q) = %q, ожидалось %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestEncodeFoldsLongLines(t *testing.T) {
+	calendar := &Calendar{Events: []Event{{
+		UID:     "1@test",
+		Stamp:   time.Date(2025, 3, 1, 8, 0, 0, 0, time.UTC),
+		Start:   time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC),
+		Summary: strings.Repeat("Длинное название события ", 10),
+	}}}
+	encoded := calendar.String()
+	if !strings.HasPrefix(encoded, "BEGIN:VCALENDAR\r\n") || !strings.HasSuffix(encoded, "END:VCALENDAR\r\n") {
+		t.Fatalf("календарь не обрамлен VCALENDAR:\n%s", encoded)
+	}
+	folded := 0
+	for _, line := range strings.Split(strings.TrimSuffix(encoded, "\r\n"), "\r\n") {
+		if len(line) > maxLineOctets {
+			t.Errorf("строка длиннее %d байт: %q", maxLineOctets, line)
+		}
+		if strings.HasPrefix(line, " ") {
+			folded++
+		}
+	}
+	if folded == 0 {
+		t.Errorf("длинная строка не перенесена:\n%s", encoded)
+	}
+}
+
+func TestEventFormatTime(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("нет базы часовых поясов: %v", err)
+	}
+	moment := time.Date(2025, 3, 1, 9, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		event Event
+		want  string
+	}{
+		{"весь день", Event{AllDay: true}, ";VALUE=DATE:20250301"},
+		{"плавающее время", Event{}, ":20250301T093000"},
+		{"UTC", Event{TimeZone: time.UTC}, ":20250301T093000Z"},
+		{"TZID", Event{TimeZone: berlin}, ";TZID=Europe/Berlin:20250301T103000"},
+	}
+	for _, tt := range tests {
+		if got := tt.event.formatTime(moment); got != tt.want {
+			t.Errorf("%s: formatTime = %q, ожидалось %q", tt.name, got, tt.want)
+		}
+	}
+}
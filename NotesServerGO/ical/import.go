@@ -0,0 +1,524 @@
+package ical
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"notes_server_go/models"
+	"notes_server_go/schedule"
+)
+
+// MaxImportEvents ограничивает количество событий в одном импортируемом календаре.
+const MaxImportEvents = 2000
+
+// allDayTime - время записи расписания, которым представляется событие на весь день.
+const allDayTime = "00:00 - 23:59"
+
+// recurrenceDateLayout - формат endDate в RecurrenceJson (как DateTime.toIso8601String на клиенте).
+const recurrenceDateLayout = "2006-01-02T15:04:05.000"
+
+// Ключи динамических полей, в которые переносятся свойства события.
+var importedFieldNames = []struct{ Property, Field string }{
+	{"DESCRIPTION", "Описание"},
+	{"LOCATION", "Место"},
+	{"URL", "Ссылка"},
+}
+
+// recurrenceTypes сопоставляет частоты RRULE типам повторения расписания.
+var recurrenceTypes = map[string]models.RecurrenceType{
+	FreqDaily:   models.RecurrenceTypeDaily,
+	FreqWeekly:  models.RecurrenceTypeWeekly,
+	FreqMonthly: models.RecurrenceTypeMonthly,
+	FreqYearly:  models.RecurrenceTypeYearly,
+}
+
+// weekdayCodes - коды дней недели в BYDAY и WKST.
+var weekdayCodes = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+// ImportedEntry - запись расписания, полученная из события календаря, с исключениями из ее повторений.
+// Warnings описывает то, что при импорте было упрощено или пропущено.
+type ImportedEntry struct {
+	UID        string                          `json:"uid"`
+	Entry      models.ScheduleEntry            `json:"entry"`
+	Exceptions []models.ScheduleEntryException `json:"exceptions"`
+	Warnings   []string                        `json:"warnings,omitempty"`
+}
+
+// SkippedEvent - событие календаря, которое невозможно представить записью расписания.
+type SkippedEvent struct {
+	UID     string `json:"uid"`
+	Summary string `json:"summary"`
+	Start   string `json:"start,omitempty"` // Значение DTSTART как в файле
+	Reason  string `json:"reason"`
+}
+
+// ImportResult - результат преобразования календаря в записи расписания.
+type ImportResult struct {
+	Entries []ImportedEntry `json:"entries"`
+	Skipped []SkippedEvent  `json:"skipped"`
+}
+
+// recurrenceJSON - RecurrenceJson в том виде, в котором его сохраняет клиент.
+type recurrenceJSON struct {
+	Type     models.RecurrenceType `json:"type"`
+	Interval int                   `json:"interval"`
+	EndDate  *string               `json:"endDate"`
+	Count    *int                  `json:"count"`
+}
+
+// ToScheduleEntries преобразует события VEVENT календаря в записи расписания (без DatabaseId).
+// Время приводится к часовому поясу loc. RRULE переносится в RecurrenceJson, если правило выражается
+// типом, интервалом, количеством и датой окончания; еженедельное правило с несколькими днями (BYDAY)
+// разбивается на отдельные записи по дням недели. EXDATE и измененные повторения (RECURRENCE-ID)
+// становятся исключениями, CATEGORIES - тегами. Остальные события попадают в Skipped с причиной.
+func ToScheduleEntries(components []*Component, loc *time.Location) (*ImportResult, error) {
+	events := collectEvents(components)
+	if len(events) > MaxImportEvents {
+		return nil, fmt.Errorf("календарь содержит %d событий, допускается не больше %d", len(events), MaxImportEvents)
+	}
+
+	uids := make(map[*Component]string, len(events))
+	hasMaster := make(map[string]bool)
+	overrides := make(map[string][]*Component)
+	for i, event := range events {
+		uid := strings.TrimSpace(event.Text("UID"))
+		if uid == "" {
+			uid = fmt.Sprintf("event-%d", i+1)
+		}
+		uids[event] = uid
+		if event.Get("RECURRENCE-ID") != nil {
+			overrides[uid] = append(overrides[uid], event)
+		} else {
+			hasMaster[uid] = true
+		}
+	}
+
+	result := &ImportResult{Entries: []ImportedEntry{}, Skipped: []SkippedEvent{}}
+	converted := make(map[string]bool)
+	for _, event := range events {
+		uid := uids[event]
+		isOverride := event.Get("RECURRENCE-ID") != nil
+		if isOverride && hasMaster[uid] {
+			continue // Обрабатывается вместе с основным событием
+		}
+		if !isOverride && converted[uid] {
+			result.Skipped = append(result.Skipped, skippedEvent(event, uid, "событие с таким UID уже импортировано"))
+			continue
+		}
+		converted[uid] = true
+
+		var eventOverrides []*Component
+		if !isOverride {
+			eventOverrides = overrides[uid]
+		}
+		entries, skipped := convertEvent(event, uid, eventOverrides, loc)
+		if skipped != nil {
+			result.Skipped = append(result.Skipped, *skipped)
+			continue
+		}
+		result.Entries = append(result.Entries, entries...)
+	}
+	return result, nil
+}
+
+// collectEvents возвращает события VEVENT календарей (и события верхнего уровня) в порядке следования.
+func collectEvents(components []*Component) []*Component {
+	var events []*Component
+	for _, component := range components {
+		switch component.Name {
+		case "VEVENT":
+			events = append(events, component)
+		case "VCALENDAR":
+			events = append(events, collectEvents(component.Components)...)
+		}
+	}
+	return events
+}
+
+// skippedEvent описывает пропущенное событие.
+func skippedEvent(event *Component, uid string, reason string) SkippedEvent {
+	skipped := SkippedEvent{UID: uid, Summary: strings.TrimSpace(event.Text("SUMMARY")), Reason: reason}
+	if dtstart := event.Get("DTSTART"); dtstart != nil {
+		skipped.Start = dtstart.Value
+	}
+	return skipped
+}
+
+// eventTiming - дата и время события в виде полей записи расписания.
+type eventTiming struct {
+	Start    time.Time
+	Time     string
+	AllDay   bool
+	MultiDay bool
+}
+
+// parseEventTiming разбирает DTSTART и DTEND/DURATION события.
+func parseEventTiming(event *Component, loc *time.Location) (eventTiming, []string, error) {
+	var warnings []string
+	dtstart := event.Get("DTSTART")
+	if dtstart == nil {
+		return eventTiming{}, nil, fmt.Errorf("нет даты начала (DTSTART)")
+	}
+	if tzid := dtstart.Params["TZID"]; !KnownTZID(tzid) {
+		warnings = append(warnings, fmt.Sprintf("неизвестный часовой пояс %s - время взято без пересчета", tzid))
+	}
+	start, allDay, err := ParseDateTime(dtstart.Value, dtstart.Params["TZID"], loc)
+	if err != nil {
+		return eventTiming{}, nil, fmt.Errorf("DTSTART: %w", err)
+	}
+
+	end := start
+	if dtend := event.Get("DTEND"); dtend != nil {
+		if end, _, err = ParseDateTime(dtend.Value, dtend.Params["TZID"], loc); err != nil {
+			return eventTiming{}, nil, fmt.Errorf("DTEND: %w", err)
+		}
+	} else if duration := event.Get("DURATION"); duration != nil {
+		d, err := ParseDuration(duration.Value)
+		if err != nil {
+			return eventTiming{}, nil, fmt.Errorf("DURATION: %w", err)
+		}
+		end = start.Add(d)
+	} else if allDay {
+		end = start.AddDate(0, 0, 1)
+	}
+	if end.Before(start) {
+		end = start
+	}
+
+	timing := eventTiming{Start: start, AllDay: allDay}
+	if allDay {
+		timing.Time = allDayTime
+		timing.MultiDay = end.After(start.AddDate(0, 0, 1))
+	} else {
+		timing.MultiDay = end.Sub(start) >= 24*time.Hour
+		if timing.MultiDay {
+			end = time.Date(start.Year(), start.Month(), start.Day(), 23, 59, 0, 0, loc)
+		}
+		timing.Time = fmt.Sprintf("%02d:%02d - %02d:%02d", start.Hour(), start.Minute(), end.Hour(), end.Minute())
+	}
+	return timing, warnings, nil
+}
+
+// convertEvent преобразует основное событие и его измененные повторения в записи расписания.
+func convertEvent(event *Component, uid string, overrides []*Component, loc *time.Location) ([]ImportedEntry, *SkippedEvent) {
+	skip := func(reason string) ([]ImportedEntry, *SkippedEvent) {
+		skipped := skippedEvent(event, uid, reason)
+		return nil, &skipped
+	}
+	if strings.EqualFold(strings.TrimSpace(event.Text("STATUS")), "CANCELLED") {
+		return skip("событие отменено (STATUS:CANCELLED)")
+	}
+
+	timing, warnings, err := parseEventTiming(event, loc)
+	if err != nil {
+		return skip(err.Error())
+	}
+	if timing.AllDay {
+		warnings = append(warnings, "событие на весь день импортировано со временем "+allDayTime)
+	}
+	if timing.MultiDay {
+		warnings = append(warnings, "событие длится несколько дней - импортирован только первый день")
+	}
+
+	summary := strings.TrimSpace(event.Text("SUMMARY"))
+	base := models.ScheduleEntry{
+		Date:              timing.Start.Format(schedule.DateLayout),
+		Time:              timing.Time,
+		Note:              optionalString(summary),
+		DynamicFieldsJson: importedDynamicFields(event),
+		TagsJson:          importedTags(event),
+	}
+
+	rrules := event.GetAll("RRULE")
+	if len(event.GetAll("RDATE")) > 0 {
+		warnings = append(warnings, "дополнительные даты (RDATE) не поддерживаются и пропущены")
+	}
+	if len(rrules) == 0 {
+		if len(overrides) > 0 {
+			warnings = append(warnings, "изменения повторений (RECURRENCE-ID) у неповторяющегося события пропущены")
+		}
+		return []ImportedEntry{{UID: uid, Entry: base, Exceptions: []models.ScheduleEntryException{}, Warnings: warnings}}, nil
+	}
+	if len(rrules) > 1 {
+		return skip("несколько правил повторения (RRULE) не поддерживаются")
+	}
+
+	rule, err := ParseRRule(rrules[0].Value, loc)
+	if err != nil {
+		return skip("RRULE: " + err.Error())
+	}
+	starts, reason := recurrenceStarts(rule, timing.Start)
+	if reason != "" {
+		return skip(fmt.Sprintf("правило повторения RRULE:%s не поддерживается: %s", rrules[0].Value, reason))
+	}
+	if len(starts) == 0 {
+		return skip("правило повторения не дает ни одного повторения")
+	}
+	if len(starts) > 1 {
+		warnings = append(warnings, fmt.Sprintf("еженедельное повторение по нескольким дням разбито на %d записи", len(starts)))
+	}
+
+	recurrence := recurrenceJSON{Type: recurrenceTypes[rule.Freq], Interval: 1}
+	if rule.Interval > 1 {
+		recurrence.Interval = rule.Interval
+	}
+	if rule.Count > 0 {
+		count := rule.Count
+		recurrence.Count = &count
+	} else if !rule.Until.IsZero() {
+		endDate := rule.Until.Format(recurrenceDateLayout)
+		recurrence.EndDate = &endDate
+	}
+	recurrenceBytes, err := json.Marshal(recurrence)
+	if err != nil {
+		return skip("ошибка формирования RecurrenceJson: " + err.Error())
+	}
+	recurrenceJson := string(recurrenceBytes)
+
+	entries := make([]ImportedEntry, len(starts))
+	for i, start := range starts {
+		entry := base
+		entry.Date = start.Format(schedule.DateLayout)
+		entry.RecurrenceJson = &recurrenceJson
+		entries[i] = ImportedEntry{UID: uid, Entry: entry, Exceptions: []models.ScheduleEntryException{}}
+	}
+
+	warnings = append(warnings, importExDates(event, entries, loc)...)
+	warnings = append(warnings, importOverrides(overrides, entries, summary, loc)...)
+	for i := range entries {
+		entries[i].Warnings = warnings
+	}
+	return entries, nil
+}
+
+// recurrenceStarts проверяет, что правило выражается повторением расписания, и возвращает даты начала
+// серий: одну для обычного правила и по одной на каждый день недели для еженедельного правила с BYDAY.
+// Если правило не выражается, reason содержит причину.
+func recurrenceStarts(rule *RRule, start time.Time) (starts []time.Time, reason string) {
+	if _, ok := recurrenceTypes[rule.Freq]; !ok {
+		return nil, "частота " + rule.Freq
+	}
+
+	weekdays := []time.Weekday{start.Weekday()}
+	weekStart := time.Monday
+	if code, ok := rule.Extra["WKST"]; ok {
+		if weekStart, ok = weekdayCodes[code]; !ok {
+			return nil, "WKST=" + code
+		}
+	}
+	names := make([]string, 0, len(rule.Extra))
+	for name := range rule.Extra {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := rule.Extra[name]
+		switch name {
+		case "WKST":
+		case "BYDAY":
+			if rule.Freq != FreqWeekly {
+				return nil, "BYDAY=" + value + " поддерживается только для еженедельного повторения"
+			}
+			weekdays = weekdays[:0]
+			for _, code := range strings.Split(value, ",") {
+				weekday, ok := weekdayCodes[strings.TrimSpace(code)]
+				if !ok {
+					return nil, "BYDAY=" + value
+				}
+				weekdays = append(weekdays, weekday)
+			}
+		case "BYMONTHDAY":
+			if (rule.Freq != FreqMonthly && rule.Freq != FreqYearly) || value != strconv.Itoa(start.Day()) {
+				return nil, "BYMONTHDAY=" + value + " не совпадает с днем начала"
+			}
+		case "BYMONTH":
+			if rule.Freq != FreqYearly || value != strconv.Itoa(int(start.Month())) {
+				return nil, "BYMONTH=" + value + " не совпадает с месяцем начала"
+			}
+		default:
+			return nil, name + "=" + value
+		}
+	}
+	if len(weekdays) > 1 && rule.Count > 0 {
+		return nil, "COUNT вместе с несколькими днями недели"
+	}
+
+	// Неделя, в которую попадает start, - первая неделя серии; дни недели раньше start переносятся
+	// на следующую неделю серии (через interval недель).
+	interval := 1
+	if rule.Interval > 1 {
+		interval = rule.Interval
+	}
+	firstWeek := start.AddDate(0, 0, -((int(start.Weekday()) - int(weekStart) + 7) % 7))
+	seen := make(map[time.Weekday]bool)
+	for _, weekday := range weekdays {
+		if seen[weekday] {
+			continue
+		}
+		seen[weekday] = true
+		date := start
+		if len(weekdays) > 1 || weekday != start.Weekday() {
+			date = firstWeek.AddDate(0, 0, (int(weekday)-int(weekStart)+7)%7)
+			if date.Before(start) {
+				date = date.AddDate(0, 0, 7*interval)
+			}
+		}
+		if !rule.Until.IsZero() && date.Format(schedule.DateLayout) > rule.Until.Format(schedule.DateLayout) {
+			continue
+		}
+		starts = append(starts, date)
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i].Before(starts[j]) })
+	return starts, ""
+}
+
+// findSeries возвращает запись, к серии которой относится повторение в дату date, или nil.
+func findSeries(entries []ImportedEntry, date time.Time) *ImportedEntry {
+	day := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+	for i := range entries {
+		if schedule.IsOccurrenceDate(&entries[i].Entry, day) {
+			return &entries[i]
+		}
+	}
+	return nil
+}
+
+// hasException проверяет, есть ли у записи исключение для исходной даты.
+func hasException(entry *ImportedEntry, originalDate string) bool {
+	for _, exception := range entry.Exceptions {
+		if exception.OriginalDate == originalDate {
+			return true
+		}
+	}
+	return false
+}
+
+// importExDates переносит EXDATE в исключения "skip". Возвращает предупреждения.
+func importExDates(event *Component, entries []ImportedEntry, loc *time.Location) []string {
+	var warnings []string
+	for _, property := range event.GetAll("EXDATE") {
+		for _, value := range strings.Split(property.Value, ",") {
+			date, _, err := ParseDateTime(value, property.Params["TZID"], loc)
+			if err != nil {
+				warnings = append(warnings, "EXDATE: "+err.Error())
+				continue
+			}
+			originalDate := date.Format(schedule.DateLayout)
+			series := findSeries(entries, date)
+			if series == nil {
+				warnings = append(warnings, fmt.Sprintf("EXDATE %s не совпадает ни с одним повторением", originalDate))
+				continue
+			}
+			if !hasException(series, originalDate) {
+				series.Exceptions = append(series.Exceptions, models.ScheduleEntryException{
+					OriginalDate: originalDate,
+					Type:         models.ScheduleExceptionSkip,
+				})
+			}
+		}
+	}
+	return warnings
+}
+
+// importOverrides переносит измененные повторения (события с RECURRENCE-ID) в исключения
+// "modify", а отмененные - в исключения "skip". Возвращает предупреждения.
+func importOverrides(overrides []*Component, entries []ImportedEntry, summary string, loc *time.Location) []string {
+	var warnings []string
+	for _, override := range overrides {
+		recurrenceID := override.Get("RECURRENCE-ID")
+		date, _, err := ParseDateTime(recurrenceID.Value, recurrenceID.Params["TZID"], loc)
+		if err != nil {
+			warnings = append(warnings, "RECURRENCE-ID: "+err.Error())
+			continue
+		}
+		originalDate := date.Format(schedule.DateLayout)
+		series := findSeries(entries, date)
+		if series == nil {
+			warnings = append(warnings, fmt.Sprintf("измененное повторение %s не совпадает ни с одним повторением", originalDate))
+			continue
+		}
+		if hasException(series, originalDate) {
+			continue
+		}
+
+		exception := models.ScheduleEntryException{OriginalDate: originalDate, Type: models.ScheduleExceptionSkip}
+		if !strings.EqualFold(strings.TrimSpace(override.Text("STATUS")), "CANCELLED") {
+			timing, _, err := parseEventTiming(override, loc)
+			if err != nil {
+				warnings = append(warnings, fmt.Sprintf("измененное повторение %s: %v", originalDate, err))
+				continue
+			}
+			exception.Type = models.ScheduleExceptionModify
+			if newDate := timing.Start.Format(schedule.DateLayout); newDate != originalDate {
+				exception.NewDate = &newDate
+			}
+			if timing.Time != series.Entry.Time {
+				newTime := timing.Time
+				exception.NewTime = &newTime
+			}
+			if newNote := strings.TrimSpace(override.Text("SUMMARY")); newNote != summary {
+				exception.NewNote = &newNote
+			}
+			if exception.NewDate == nil && exception.NewTime == nil && exception.NewNote == nil {
+				continue // Повторение изменено только в полях, которых нет в расписании
+			}
+		}
+		series.Exceptions = append(series.Exceptions, exception)
+	}
+	return warnings
+}
+
+// importedDynamicFields переносит описание, место и ссылку события в динамические поля записи.
+func importedDynamicFields(event *Component) *string {
+	fields := make(map[string]string)
+	for _, name := range importedFieldNames {
+		if value := strings.TrimSpace(event.Text(name.Property)); value != "" {
+			fields[name.Field] = value
+		}
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	encoded, err := json.Marshal(fields)
+	if err != nil {
+		return nil
+	}
+	return optionalString(string(encoded))
+}
+
+// importedTags переносит CATEGORIES события в TagsJson записи.
+func importedTags(event *Component) *string {
+	var tags []string
+	seen := make(map[string]bool)
+	for _, property := range event.GetAll("CATEGORIES") {
+		for _, tag := range SplitTextList(property.Value) {
+			if tag = models.NormalizeTag(tag); tag != "" && !seen[tag] {
+				seen[tag] = true
+				tags = append(tags, tag)
+			}
+		}
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+	encoded, err := json.Marshal(tags)
+	if err != nil {
+		return nil
+	}
+	return optionalString(string(encoded))
+}
+
+// optionalString возвращает указатель на непустую строку или nil.
+func optionalString(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
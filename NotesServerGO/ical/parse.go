@@ -0,0 +1,216 @@
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Component - компонент iCalendar (VCALENDAR, VEVENT, VALARM и т.д.) в разобранном виде.
+type Component struct {
+	Name       string
+	Properties []Property
+	Components []*Component
+}
+
+// Property - свойство компонента. Имена свойства и параметров приводятся к верхнему регистру,
+// значение хранится без снятия экранирования (см. UnescapeText).
+type Property struct {
+	Name   string
+	Params map[string]string
+	Value  string
+}
+
+// Get возвращает первое свойство с именем name или nil.
+func (c *Component) Get(name string) *Property {
+	for i := range c.Properties {
+		if c.Properties[i].Name == name {
+			return &c.Properties[i]
+		}
+	}
+	return nil
+}
+
+// GetAll возвращает все свойства с именем name.
+func (c *Component) GetAll(name string) []Property {
+	var properties []Property
+	for _, property := range c.Properties {
+		if property.Name == name {
+			properties = append(properties, property)
+		}
+	}
+	return properties
+}
+
+// Text возвращает значение текстового свойства без экранирования или пустую строку.
+func (c *Component) Text(name string) string {
+	if property := c.Get(name); property != nil {
+		return UnescapeText(property.Value)
+	}
+	return ""
+}
+
+// Parse разбирает календарь iCalendar. Возвращает компоненты верхнего уровня (обычно один VCALENDAR).
+// Переносы строк (CRLF или LF с пробелом/табуляцией в начале продолжения) объединяются.
+func Parse(r io.Reader) ([]*Component, error) {
+	lines, err := unfoldLines(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var roots []*Component
+	var stack []*Component
+	for number, line := range lines {
+		property, err := parseContentLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("строка %d: %w", number+1, err)
+		}
+		switch property.Name {
+		case "BEGIN":
+			component := &Component{Name: strings.ToUpper(property.Value)}
+			if len(stack) == 0 {
+				roots = append(roots, component)
+			} else {
+				parent := stack[len(stack)-1]
+				parent.Components = append(parent.Components, component)
+			}
+			stack = append(stack, component)
+		case "END":
+			name := strings.ToUpper(property.Value)
+			if len(stack) == 0 || stack[len(stack)-1].Name != name {
+				return nil, fmt.Errorf("строка %d: неожиданный END:%s", number+1, name)
+			}
+			stack = stack[:len(stack)-1]
+		default:
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("строка %d: свойство %s вне компонента", number+1, property.Name)
+			}
+			current := stack[len(stack)-1]
+			current.Properties = append(current.Properties, property)
+		}
+	}
+	if len(stack) > 0 {
+		return nil, fmt.Errorf("компонент %s не закрыт", stack[len(stack)-1].Name)
+	}
+	if len(roots) == 0 {
+		return nil, fmt.Errorf("календарь не содержит компонентов")
+	}
+	return roots, nil
+}
+
+// unfoldLines читает строки содержимого, объединяя перенесенные строки. Пустые строки пропускаются.
+func unfoldLines(r io.Reader) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	var lines []string
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if len(lines) == 0 {
+			line = strings.TrimPrefix(line, "\ufeff") // BOM
+		}
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка чтения календаря: %w", err)
+	}
+	return lines, nil
+}
+
+// parseContentLine разбирает строку вида NAME;PARAM=VALUE;PARAM="VALUE":VALUE.
+// Значения параметров в кавычках могут содержать ":", ";" и ",".
+func parseContentLine(line string) (Property, error) {
+	property := Property{Params: map[string]string{}}
+
+	i := strings.IndexAny(line, ";:")
+	if i <= 0 {
+		return property, fmt.Errorf("неверная строка содержимого %q", truncate(line, 40))
+	}
+	property.Name = strings.ToUpper(line[:i])
+
+	for line[i] == ';' {
+		i++
+		eq := strings.IndexByte(line[i:], '=')
+		if eq < 0 {
+			return property, fmt.Errorf("неверный параметр свойства %s", property.Name)
+		}
+		paramName := strings.ToUpper(line[i : i+eq])
+		i += eq + 1
+
+		var value strings.Builder
+		inQuotes := false
+		for ; i < len(line); i++ {
+			ch := line[i]
+			if ch == '"' {
+				inQuotes = !inQuotes
+				continue
+			}
+			if !inQuotes && (ch == ';' || ch == ':') {
+				break
+			}
+			value.WriteByte(ch)
+		}
+		if i >= len(line) {
+			return property, fmt.Errorf("у свойства %s нет значения", property.Name)
+		}
+		property.Params[paramName] = value.String()
+	}
+	property.Value = line[i+1:]
+	return property, nil
+}
+
+// UnescapeText снимает экранирование значения типа TEXT.
+func UnescapeText(value string) string {
+	if !strings.Contains(value, `\`) {
+		return value
+	}
+	var sb strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' || i+1 == len(value) {
+			sb.WriteByte(value[i])
+			continue
+		}
+		i++
+		switch value[i] {
+		case 'n', 'N':
+			sb.WriteByte('\n')
+		default:
+			sb.WriteByte(value[i]) // \\ \; \, и нестандартные последовательности
+		}
+	}
+	return sb.String()
+}
+
+// SplitTextList разбивает значение-список (например, CATEGORIES) по неэкранированным запятым
+// и снимает экранирование элементов.
+func SplitTextList(value string) []string {
+	var items []string
+	start := 0
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' {
+			i++
+			continue
+		}
+		if value[i] == ',' {
+			items = append(items, UnescapeText(value[start:i]))
+			start = i + 1
+		}
+	}
+	return append(items, UnescapeText(value[start:]))
+}
+
+// truncate обрезает строку для сообщений об ошибках.
+func truncate(value string, max int) string {
+	runes := []rune(value)
+	if len(runes) <= max {
+		return value
+	}
+	return string(runes[:max]) + "..."
+}
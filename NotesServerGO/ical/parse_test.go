@@ -0,0 +1,128 @@
+package ical
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseEncodeRoundTrip(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("нет базы часовых поясов: %v", err)
+	}
+	start := time.Date(2025, 3, 3, 10, 0, 0, 0, berlin)
+	calendar := &Calendar{
+		Name:     "Команда; план, 2025",
+		TimeZone: berlin,
+		Events: []Event{{
+			UID:         "42@test",
+			Stamp:       time.Date(2025, 3, 1, 8, 0, 0, 0, time.UTC),
+			Start:       start,
+			End:         start.Add(90 * time.Minute),
+			Summary:     "Планерка, еженедельная; " + strings.Repeat("очень ", 20) + "длинная",
+			Description: "строка 1\nстрока 2 \\ конец",
+			Categories:  []string{"работа", "a,b"},
+			RRule:       &RRule{Freq: FreqWeekly, Interval: 2, Count: 5},
+			ExDates:     []time.Time{start.AddDate(0, 0, 14)},
+			TimeZone:    berlin,
+		}},
+	}
+
+	components, err := Parse(strings.NewReader(calendar.String()))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(components) != 1 || components[0].Name != "VCALENDAR" {
+		t.Fatalf("компоненты верхнего уровня: %+v", components)
+	}
+	root := components[0]
+	if got := root.Text("X-WR-CALNAME"); got != calendar.Name {
+		t.Errorf("X-WR-CALNAME = %q, ожидалось %q", got, calendar.Name)
+	}
+
+	events := collectEvents(components)
+	if len(events) != 1 {
+		t.Fatalf("событий: %d, ожидалось 1", len(events))
+	}
+	event := events[0]
+	want := calendar.Events[0]
+	if got := event.Text("UID"); got != want.UID {
+		t.Errorf("UID = %q", got)
+	}
+	if got := event.Text("SUMMARY"); got != want.Summary {
+		t.Errorf("SUMMARY = %q, ожидалось %q", got, want.Summary)
+	}
+	if got := event.Text("DESCRIPTION"); got != want.Description {
+		t.Errorf("DESCRIPTION = %q, ожидалось %q", got, want.Description)
+	}
+	if got := SplitTextList(event.Get("CATEGORIES").Value); !reflect.DeepEqual(got, want.Categories) {
+		t.Errorf("CATEGORIES = %q, ожидалось %q", got, want.Categories)
+	}
+
+	dtStart := event.Get("DTSTART")
+	parsedStart, allDay, err := ParseDateTime(dtStart.Value, dtStart.Params["TZID"], time.UTC)
+	if err != nil || allDay || !parsedStart.Equal(want.Start) {
+		t.Errorf("DTSTART = %v (весь день: %v, %v), ожидалось %v", parsedStart, allDay, err, want.Start)
+	}
+	rule, err := ParseRRule(event.Get("RRULE").Value, berlin)
+	if err != nil {
+		t.Fatalf("ParseRRule: %v", err)
+	}
+	if rule.Freq != FreqWeekly || rule.Interval != 2 || rule.Count != 5 {
+		t.Errorf("RRULE = %+v", rule)
+	}
+	if exDates := event.GetAll("EXDATE"); len(exDates) != 1 || exDates[0].Params["TZID"] != "Europe/Berlin" {
+		t.Errorf("EXDATE = %+v", exDates)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"пустой календарь", ""},
+		{"незакрытый компонент", "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nEND:VCALENDAR\r\n"},
+		{"свойство вне компонента", "SUMMARY:x\r\n"},
+		{"строка без значения", "BEGIN:VCALENDAR\r\nDTSTART;TZID=Europe/Berlin\r\nEND:VCALENDAR\r\n"},
+	}
+	for _, tt := range tests {
+		if _, err := Parse(strings.NewReader(tt.input)); err == nil {
+			t.Errorf("%s: ожидалась ошибка", tt.name)
+		}
+	}
+}
+
+func TestParseContentLine(t *testing.T) {
+	tests := []struct {
+		line string
+		want Property
+	}{
+		{"SUMMARY:Встреча", Property{Name: "SUMMARY", Params: map[string]string{}, Value: "Встреча"}},
+		{"dtstart;tzid=Europe/Berlin:20250301T100000", Property{Name: "DTSTART", Params: map[string]string{"TZID": "Europe/Berlin"}, Value: "20250301T100000"}},
+		{`ATTENDEE;CN="Doe; John":mailto:john@example.com`, Property{Name: "ATTENDEE", Params: map[string]string{"CN": "Doe; John"}, Value: "mailto:john@example.com"}},
+	}
+	for _, tt := range tests {
+		got, err := parseContentLine(tt.line)
+		if err != nil {
+			t.Errorf("parseContentLine(%q): %v", tt.line, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseContentLine(%q) = %+v, ожидалось %+v", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestUnfoldLines(t *testing.T) {
+	input := "\ufeffBEGIN:VCALENDAR\r\nX-WR-CALNAME:Длин\r\n ное\n\tимя\r\n\r\nEND:VCALENDAR\r\n"
+	components, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if got := components[0].Text("X-WR-CALNAME"); got != "Длинноеимя" {
+		t.Errorf("X-WR-CALNAME = %q", got)
+	}
+}
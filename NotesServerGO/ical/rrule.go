@@ -0,0 +1,94 @@
+package ical
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Частоты правила повторения RRULE.
+const (
+	FreqDaily   = "DAILY"
+	FreqWeekly  = "WEEKLY"
+	FreqMonthly = "MONTHLY"
+	FreqYearly  = "YEARLY"
+)
+
+// RRule - правило повторения события (RFC 5545, 3.3.10) в подмножестве, которое поддерживает расписание:
+// частота, интервал и ограничение по количеству или по дате.
+type RRule struct {
+	Freq     string
+	Interval int       // 0 или 1 - не выводится
+	Count    int       // 0 - без ограничения по количеству
+	Until    time.Time // Последняя дата серии (включительно); нулевое значение - без ограничения
+
+	// Extra - остальные части правила (BYDAY, BYMONTHDAY, WKST и т.д.) как есть.
+	// Заполняется только при разборе и не выводится в Format.
+	Extra map[string]string
+}
+
+// Format возвращает значение свойства RRULE. UNTIL выводится в том же виде, что и DTSTART события:
+// датой для событий на весь день, концом дня в "плавающем" времени для событий без часового пояса
+// и концом дня в часовом поясе loc, переведенным в UTC, для остальных (RFC 5545 требует UTC при TZID).
+func (r *RRule) Format(allDay bool, loc *time.Location) string {
+	parts := []string{"FREQ=" + r.Freq}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	} else if !r.Until.IsZero() {
+		if allDay {
+			parts = append(parts, "UNTIL="+r.Until.Format(dateLayout))
+		} else if zoneName(loc) == "" {
+			endOfDay := time.Date(r.Until.Year(), r.Until.Month(), r.Until.Day(), 23, 59, 59, 0, r.Until.Location())
+			parts = append(parts, "UNTIL="+endOfDay.Format(dateTimeLayout))
+		} else {
+			endOfDay := time.Date(r.Until.Year(), r.Until.Month(), r.Until.Day(), 23, 59, 59, 0, loc)
+			parts = append(parts, "UNTIL="+endOfDay.UTC().Format(utcLayout))
+		}
+	}
+	return strings.Join(parts, ";")
+}
+
+// ParseRRule разбирает значение свойства RRULE. UNTIL приводится к календарной дате в часовом поясе loc.
+func ParseRRule(value string, loc *time.Location) (*RRule, error) {
+	rule := &RRule{Extra: map[string]string{}}
+	for _, part := range strings.Split(strings.TrimSpace(value), ";") {
+		if part == "" {
+			continue
+		}
+		eq := strings.IndexByte(part, '=')
+		if eq <= 0 {
+			return nil, fmt.Errorf("неверная часть правила повторения %q", part)
+		}
+		name, partValue := strings.ToUpper(part[:eq]), part[eq+1:]
+		switch name {
+		case "FREQ":
+			rule.Freq = strings.ToUpper(partValue)
+		case "INTERVAL", "COUNT":
+			number, err := strconv.Atoi(partValue)
+			if err != nil || number < 1 {
+				return nil, fmt.Errorf("неверное значение %s=%s", name, partValue)
+			}
+			if name == "INTERVAL" {
+				rule.Interval = number
+			} else {
+				rule.Count = number
+			}
+		case "UNTIL":
+			until, _, err := ParseDateTime(partValue, "", loc)
+			if err != nil {
+				return nil, fmt.Errorf("неверное значение UNTIL: %w", err)
+			}
+			rule.Until = time.Date(until.Year(), until.Month(), until.Day(), 0, 0, 0, 0, time.UTC)
+		default:
+			rule.Extra[name] = strings.ToUpper(partValue)
+		}
+	}
+	if rule.Freq == "" {
+		return nil, fmt.Errorf("в правиле повторения нет FREQ")
+	}
+	return rule, nil
+}
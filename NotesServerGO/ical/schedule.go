@@ -0,0 +1,213 @@
+package ical
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"notes_server_go/models"
+	"notes_server_go/schedule"
+)
+
+// uidDomain - доменная часть UID событий, сформированных из записей расписания.
+const uidDomain = "notes-server-go"
+
+// defaultSummary - заголовок события для записи без текста.
+const defaultSummary = "Запись расписания"
+
+// recurrenceFreqs сопоставляет типы повторения расписания частотам RRULE.
+var recurrenceFreqs = map[models.RecurrenceType]string{
+	models.RecurrenceTypeDaily:   FreqDaily,
+	models.RecurrenceTypeWeekly:  FreqWeekly,
+	models.RecurrenceTypeMonthly: FreqMonthly,
+	models.RecurrenceTypeYearly:  FreqYearly,
+}
+
+// ScheduleEntryUID возвращает UID события для записи расписания.
+func ScheduleEntryUID(entryID int64) string {
+	return fmt.Sprintf("schedule-entry-%d@%s", entryID, uidDomain)
+}
+
+// FromSchedule строит календарь из записей расписания и исключений из их повторений.
+// Повторение записи становится RRULE, отмененные повторения - EXDATE, измененные - отдельными
+// событиями с RECURRENCE-ID, теги - CATEGORIES. Записи без времени ("HH:mm - HH:mm") выводятся
+// как события на весь день, записи с некорректной датой пропускаются.
+// Время записей выводится в их часовом поясе или в часовом поясе БД dbLoc (nil или часовой пояс
+// сервера - "плавающее" время).
+func FromSchedule(name string, entries []models.ScheduleEntry, exceptions []models.ScheduleEntryException, dbLoc *time.Location) *Calendar {
+	exceptionsByEntry := make(map[int64][]models.ScheduleEntryException)
+	for _, exception := range exceptions {
+		exceptionsByEntry[exception.ScheduleEntryId] = append(exceptionsByEntry[exception.ScheduleEntryId], exception)
+	}
+
+	calendar := &Calendar{Name: name, TimeZone: dbLoc, Events: []Event{}}
+	stamp := time.Now()
+	for i := range entries {
+		var loc *time.Location
+		if dbLoc != nil {
+			loc = schedule.EntryLocation(&entries[i], dbLoc)
+		}
+		events, err := scheduleEntryEvents(&entries[i], exceptionsByEntry[entries[i].Id], stamp, loc)
+		if err != nil {
+			log.Printf("FromSchedule: запись %d пропущена: %v", entries[i].Id, err)
+			continue
+		}
+		calendar.Events = append(calendar.Events, events...)
+	}
+	return calendar
+}
+
+// scheduleEntryEvents возвращает событие записи расписания и события измененных повторений.
+// loc - часовой пояс времени записи; nil - "плавающее" время.
+func scheduleEntryEvents(entry *models.ScheduleEntry, exceptions []models.ScheduleEntryException, stamp time.Time, loc *time.Location) ([]Event, error) {
+	date, err := schedule.ParseDate(entry.Date)
+	if err != nil {
+		return nil, err
+	}
+	date = inZone(date, loc)
+	rule, err := entry.ParseRecurrence()
+	if err != nil {
+		log.Printf("scheduleEntryEvents: %v - запись выводится без повторения", err)
+		rule = nil
+	}
+	categories, err := entry.ParseTags()
+	if err != nil {
+		log.Printf("scheduleEntryEvents: %v - запись выводится без категорий", err)
+	}
+
+	master := Event{
+		UID:          ScheduleEntryUID(entry.Id),
+		Stamp:        stamp,
+		LastModified: entry.UpdatedAt,
+		Categories:   categories,
+		TimeZone:     loc,
+	}
+	timeRange, timeErr := schedule.ParseTimeRange(entry.Time)
+	master.AllDay = timeErr != nil
+	master.Summary, master.Description = describeEntry(entry.Note, entry.DynamicFieldsJson)
+
+	if rule == nil {
+		// Для записи без повторения исключение может относиться только к ее собственной дате
+		for _, exception := range exceptions {
+			if exception.OriginalDate != date.Format(schedule.DateLayout) {
+				continue
+			}
+			if exception.Type == models.ScheduleExceptionSkip {
+				return nil, nil
+			}
+			date, timeRange = applyModifyException(&master, &exception, entry, date, timeRange)
+		}
+		master.Start, master.End = eventTimes(master.AllDay, date, timeRange)
+		return []Event{master}, nil
+	}
+
+	master.Start, master.End = eventTimes(master.AllDay, date, timeRange)
+	master.RRule = &RRule{Freq: recurrenceFreqs[rule.Type], Interval: rule.IntervalOrDefault()}
+	if rule.Count != nil && *rule.Count > 0 {
+		master.RRule.Count = *rule.Count
+	} else if rule.EndDate != nil && !rule.EndDate.IsZero() {
+		master.RRule.Until = rule.EndDate.Time
+	}
+
+	events := []Event{}
+	sort.Slice(exceptions, func(i, j int) bool { return exceptions[i].OriginalDate < exceptions[j].OriginalDate })
+	for _, exception := range exceptions {
+		originalDate, err := schedule.ParseDate(exception.OriginalDate)
+		if err != nil || !schedule.IsOccurrenceDate(entry, originalDate) {
+			continue // Исключение не относится к текущему правилу повторения
+		}
+		originalDate = inZone(originalDate, loc)
+		originalStart, _ := eventTimes(master.AllDay, originalDate, timeRange)
+		if exception.Type == models.ScheduleExceptionSkip {
+			master.ExDates = append(master.ExDates, originalStart)
+			continue
+		}
+
+		override := master
+		override.RRule, override.ExDates = nil, nil
+		override.RecurrenceID = &originalStart
+		newDate, newRange := applyModifyException(&override, &exception, entry, originalDate, timeRange)
+		override.Start, override.End = eventTimes(override.AllDay, newDate, newRange)
+		events = append(events, override)
+	}
+	return append([]Event{master}, events...), nil
+}
+
+// applyModifyException применяет к событию текст исключения "modify" и возвращает новые дату и время повторения.
+// Некорректное новое время игнорируется.
+func applyModifyException(event *Event, exception *models.ScheduleEntryException, entry *models.ScheduleEntry, date time.Time, timeRange schedule.TimeRange) (time.Time, schedule.TimeRange) {
+	if exception.NewDate != nil {
+		if newDate, err := schedule.ParseDate(*exception.NewDate); err == nil {
+			date = inZone(newDate, date.Location())
+		}
+	}
+	if exception.NewTime != nil && !event.AllDay {
+		if newRange, err := schedule.ParseTimeRange(*exception.NewTime); err == nil {
+			timeRange = newRange
+		}
+	}
+	if exception.NewNote != nil {
+		event.Summary, event.Description = describeEntry(exception.NewNote, entry.DynamicFieldsJson)
+	}
+	return date, timeRange
+}
+
+// inZone возвращает полночь календарной даты date в часовом поясе loc (nil - без изменений).
+func inZone(date time.Time, loc *time.Location) time.Time {
+	if loc == nil {
+		return date
+	}
+	return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, loc)
+}
+
+// eventTimes возвращает начало и конец события в дату date. Событие на весь день длится одни сутки,
+// у события без времени окончания конец нулевой (DTEND не выводится).
+func eventTimes(allDay bool, date time.Time, timeRange schedule.TimeRange) (start time.Time, end time.Time) {
+	if allDay {
+		return date, date.AddDate(0, 0, 1)
+	}
+	start, end = timeRange.At(date)
+	if !timeRange.HasEnd {
+		end = time.Time{}
+	}
+	return start, end
+}
+
+// describeEntry формирует заголовок и описание события: заголовок - первая непустая строка заметки,
+// описание - заметка целиком, если в ней несколько строк, и динамические поля в виде "ключ: значение".
+func describeEntry(note *string, dynamicFieldsJson *string) (summary string, description string) {
+	var lines []string
+	if note != nil {
+		text := strings.TrimSpace(strings.ReplaceAll(*note, "\r\n", "\n"))
+		if text != "" {
+			summary = strings.TrimSpace(strings.SplitN(text, "\n", 2)[0])
+			if strings.Contains(text, "\n") {
+				lines = append(lines, text)
+			}
+		}
+	}
+	if summary == "" {
+		summary = defaultSummary
+	}
+
+	if dynamicFieldsJson != nil && strings.TrimSpace(*dynamicFieldsJson) != "" {
+		var fields map[string]interface{}
+		if err := json.Unmarshal([]byte(*dynamicFieldsJson), &fields); err == nil {
+			keys := make([]string, 0, len(fields))
+			for key := range fields {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				if strings.TrimSpace(key) == "" || fields[key] == nil {
+					continue
+				}
+				lines = append(lines, fmt.Sprintf("%s: %v", key, fields[key]))
+			}
+		}
+	}
+	return summary, strings.Join(lines, "\n")
+}
@@ -0,0 +1,118 @@
+package ical
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// zoneTransition - смена смещения часового пояса от UTC.
+type zoneTransition struct {
+	at         time.Time // Момент смены (UTC)
+	fromOffset int       // Смещение до смены, секунды
+	toOffset   int       // Смещение после смены, секунды
+	name       string    // Аббревиатура пояса после смены (MSK, CEST и т.д.)
+}
+
+// encodeTimeZone записывает компонент VTIMEZONE для часового пояса loc. Переходы на летнее/зимнее время
+// берутся из базы часовых поясов за год year и описываются ежегодными правилами (например, последнее
+// воскресенье марта) с DTSTART в 1970 году. Пояс без переходов описывается одним постоянным смещением.
+func encodeTimeZone(enc *encoder, loc *time.Location, year int) {
+	enc.line("BEGIN:VTIMEZONE")
+	enc.line("TZID:" + loc.String())
+
+	transitions := yearTransitions(loc, year)
+	if len(transitions) != 2 {
+		// Без летнего времени (или пояс сменил смещение в этом году): постоянное смещение на конец года
+		name, offset := time.Date(year, time.December, 31, 12, 0, 0, 0, loc).Zone()
+		encodeObservance(enc, "STANDARD", time.Date(1970, time.January, 1, 0, 0, 0, 0, time.UTC), offset, offset, name, "")
+		enc.line("END:VTIMEZONE")
+		return
+	}
+	for _, transition := range transitions {
+		kind := "STANDARD"
+		if transition.toOffset > transition.fromOffset {
+			kind = "DAYLIGHT"
+		}
+		// Местное время перехода по часам, действовавшим до него
+		local := transition.at.Add(time.Duration(transition.fromOffset) * time.Second).UTC()
+		week := (local.Day()-1)/7 + 1
+		if local.Day()+7 > daysInMonth(local.Year(), local.Month()) {
+			week = -1 // Последняя неделя месяца устойчивее номера недели (последнее воскресенье марта)
+		}
+		rule := fmt.Sprintf("FREQ=YEARLY;BYMONTH=%d;BYDAY=%d%s", int(local.Month()), week, strings.ToUpper(local.Weekday().String()[:2]))
+		start := nthWeekday(1970, local.Month(), local.Weekday(), week)
+		start = time.Date(start.Year(), start.Month(), start.Day(), local.Hour(), local.Minute(), local.Second(), 0, time.UTC)
+		encodeObservance(enc, kind, start, transition.fromOffset, transition.toOffset, transition.name, rule)
+	}
+	enc.line("END:VTIMEZONE")
+}
+
+// encodeObservance записывает компонент STANDARD или DAYLIGHT. start - местное время начала (в UTC-поле).
+func encodeObservance(enc *encoder, kind string, start time.Time, fromOffset int, toOffset int, name string, rule string) {
+	enc.line("BEGIN:" + kind)
+	enc.line("DTSTART:" + start.Format(dateTimeLayout))
+	enc.line("TZOFFSETFROM:" + formatOffset(fromOffset))
+	enc.line("TZOFFSETTO:" + formatOffset(toOffset))
+	if name != "" {
+		enc.line("TZNAME:" + EscapeText(name))
+	}
+	if rule != "" {
+		enc.line("RRULE:" + rule)
+	}
+	enc.line("END:" + kind)
+}
+
+// yearTransitions возвращает смены смещения часового пояса loc в течение года year.
+func yearTransitions(loc *time.Location, year int) []zoneTransition {
+	var transitions []zoneTransition
+	end := time.Date(year+1, time.January, 1, 0, 0, 0, 0, time.UTC)
+	t := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	_, offset := t.In(loc).Zone()
+	for t.Before(end) {
+		next := t.Add(time.Hour)
+		if _, nextOffset := next.In(loc).Zone(); nextOffset != offset {
+			// Переход внутри часа - уточняем до минуты
+			at := t
+			for {
+				if _, o := at.In(loc).Zone(); o != offset {
+					break
+				}
+				at = at.Add(time.Minute)
+			}
+			name, toOffset := at.In(loc).Zone()
+			transitions = append(transitions, zoneTransition{at: at, fromOffset: offset, toOffset: toOffset, name: name})
+			offset = toOffset
+		}
+		t = next
+	}
+	return transitions
+}
+
+// formatOffset форматирует смещение от UTC как +HHMM (или +HHMMSS, если есть секунды).
+func formatOffset(seconds int) string {
+	sign := "+"
+	if seconds < 0 {
+		sign, seconds = "-", -seconds
+	}
+	formatted := fmt.Sprintf("%s%02d%02d", sign, seconds/3600, seconds%3600/60)
+	if seconds%60 != 0 {
+		formatted += fmt.Sprintf("%02d", seconds%60)
+	}
+	return formatted
+}
+
+// nthWeekday возвращает n-й (с конца, если n < 0) день недели weekday в месяце.
+func nthWeekday(year int, month time.Month, weekday time.Weekday, n int) time.Time {
+	if n < 0 {
+		last := time.Date(year, month, daysInMonth(year, month), 0, 0, 0, 0, time.UTC)
+		return last.AddDate(0, 0, -((int(last.Weekday()) - int(weekday) + 7) % 7))
+	}
+	first := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	return first.AddDate(0, 0, (int(weekday)-int(first.Weekday())+7)%7+(n-1)*7)
+}
+
+// daysInMonth возвращает количество дней в месяце.
+func daysInMonth(year int, month time.Month) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
@@ -1,14 +1,19 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	_ "time/tzdata" // База часовых поясов IANA встроена в бинарник: на сервере может не быть /usr/share/zoneinfo
 
 	"notes_server_go/controllers" // Импортируем пакет controllers
 	"notes_server_go/data"        // Импортируем наш пакет data
 	"notes_server_go/middleware"  // Импортируем пакет middleware
+	"notes_server_go/notifications"
+	"notes_server_go/revisions"
+	"notes_server_go/trash"
 
 	"github.com/gorilla/mux" // Добавляем импорт gorilla/mux
 )
@@ -19,6 +24,46 @@ func main() {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
 
+	// Планировщик напоминаний о записях расписания (включается переменными окружения, см. notifications.NewSchedulerFromEnv)
+	reminderScheduler, err := notifications.NewSchedulerFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to configure reminders: %v", err)
+	}
+	if reminderScheduler != nil {
+		go reminderScheduler.Run(context.Background())
+	}
+
+	// Ежедневные сводки пользователей (включаются настройкой SMTP, см. notifications.NewDigestSchedulerFromEnv)
+	digestScheduler, err := notifications.NewDigestSchedulerFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to configure digests: %v", err)
+	}
+	if digestScheduler != nil {
+		controllers.SetDigestMailer(digestScheduler.Mailer)
+		go digestScheduler.Run(context.Background())
+	}
+
+	// Хранение истории версий заметок (см. revisions.RetentionFromEnv); устаревшие версии удаляются при запуске
+	// и при каждом сохранении заметки
+	revisionRetention, err := revisions.RetentionFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to configure note revisions: %v", err)
+	}
+	data.SetNoteRevisionRetention(revisionRetention)
+	if _, err := data.PurgeNoteRevisions(); err != nil {
+		log.Printf("Не удалось удалить устаревшие версии заметок: %v", err)
+	}
+
+	// Корзина: удаленные заметки, папки и совместные БД хранятся TRASH_RETENTION_DAYS дней (см. trash.RetentionFromEnv)
+	trashRetention, err := trash.RetentionFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to configure trash: %v", err)
+	}
+	data.SetTrashRetention(trashRetention)
+	if trashRetention > 0 {
+		go trash.NewPurger(trashRetention).Run(context.Background())
+	}
+
 	// Создаем новый маршрутизатор gorilla/mux
 	router := mux.NewRouter()
 
@@ -51,6 +96,16 @@ func main() {
 	// GET /api/folder?id=X - получить папку, PUT /api/folder?id=X - обновить, DELETE /api/folder?id=X - удалить
 	// apiRouter.HandleFunc("/folder", controllers.FolderItemHandler).Methods(http.MethodGet, http.MethodPut, http.MethodDelete)
 
+	// Поиск заметок по всем совместным БД пользователя
+	apiRouter.HandleFunc("/search", controllers.SearchHandler).Methods(http.MethodGet)
+
+	// Ежедневная сводка пользователя
+	apiRouter.HandleFunc("/digest/subscription", controllers.GetDigestSubscriptionHandler).Methods(http.MethodGet)
+	apiRouter.HandleFunc("/digest/subscription", controllers.UpdateDigestSubscriptionHandler).Methods(http.MethodPut)
+	apiRouter.HandleFunc("/digest/subscription", controllers.DeleteDigestSubscriptionHandler).Methods(http.MethodDelete)
+	apiRouter.HandleFunc("/digest/preview", controllers.PreviewDigestHandler).Methods(http.MethodGet)
+	apiRouter.HandleFunc("/digest/send", controllers.SendDigestHandler).Methods(http.MethodPost)
+
 	// Маршруты для управления совместными базами данных
 	// Клиент ожидает /api/CollaborativeDatabase/databases/...
 	// Старый: collabRouter := apiRouter.PathPrefix("/collaboration/databases").Subrouter()
@@ -58,6 +113,9 @@ func main() {
 	collabRouter.HandleFunc("", controllers.CreateSharedDatabaseHandler).Methods(http.MethodPost)
 	collabRouter.HandleFunc("", controllers.GetUserSharedDatabasesHandler).Methods(http.MethodGet)
 	collabRouter.HandleFunc("/with-users", controllers.GetUserSharedDatabasesWithUsersHandler).Methods(http.MethodGet)
+	collabRouter.HandleFunc("/trash", controllers.GetDeletedSharedDatabasesHandler).Methods(http.MethodGet)
+	collabRouter.HandleFunc("/trash/{db_id:[0-9]+}/restore", controllers.RestoreSharedDatabaseHandler).Methods(http.MethodPost)
+	collabRouter.HandleFunc("/trash/{db_id:[0-9]+}", controllers.PurgeSharedDatabaseHandler).Methods(http.MethodDelete)
 	collabRouter.HandleFunc("/{db_id:[0-9]+}", controllers.GetSharedDatabaseInfoHandler).Methods(http.MethodGet)
 	collabRouter.HandleFunc("/{db_id:[0-9]+}", controllers.DeleteSharedDatabaseHandler).Methods(http.MethodDelete)
 
@@ -82,6 +140,92 @@ func main() {
 	collabRouter.HandleFunc("/{db_id:[0-9]+}/export", controllers.ExportSharedDatabaseHandler).Methods(http.MethodGet) // Новый обработчик
 	collabRouter.HandleFunc("/{db_id:[0-9]+}/backup", controllers.BackupDatabaseDataHandler).Methods(http.MethodPost)  // Добавляем маршрут для backup
 	collabRouter.HandleFunc("/import", controllers.ImportSharedDatabaseHandler).Methods(http.MethodPost)               // Добавляем маршрут для импорта
+	collabRouter.HandleFunc("/{db_id:[0-9]+}/export/markdown", controllers.ExportMarkdownHandler).Methods(http.MethodGet)
+
+	// Умные папки (сохраненные поисковые запросы)
+	collabRouter.HandleFunc("/{db_id:[0-9]+}/folders", controllers.GetFoldersWithSmartFoldersHandler).Methods(http.MethodGet)
+	collabRouter.HandleFunc("/{db_id:[0-9]+}/smart-folders", controllers.GetSmartFoldersHandler).Methods(http.MethodGet)
+	collabRouter.HandleFunc("/{db_id:[0-9]+}/smart-folders", controllers.CreateSmartFolderHandler).Methods(http.MethodPost)
+	collabRouter.HandleFunc("/{db_id:[0-9]+}/smart-folders/{smart_folder_id:[0-9]+}", controllers.UpdateSmartFolderHandler).Methods(http.MethodPut)
+	collabRouter.HandleFunc("/{db_id:[0-9]+}/smart-folders/{smart_folder_id:[0-9]+}", controllers.DeleteSmartFolderHandler).Methods(http.MethodDelete)
+	collabRouter.HandleFunc("/{db_id:[0-9]+}/smart-folders/{smart_folder_id:[0-9]+}/notes", controllers.GetSmartFolderNotesHandler).Methods(http.MethodGet)
+
+	// Теги заметок
+	collabRouter.HandleFunc("/{db_id:[0-9]+}/tags", controllers.GetNoteTagsHandler).Methods(http.MethodGet)
+	collabRouter.HandleFunc("/{db_id:[0-9]+}/tags/rename", controllers.RenameNoteTagHandler).Methods(http.MethodPost)
+	collabRouter.HandleFunc("/{db_id:[0-9]+}/tags/merge", controllers.MergeNoteTagsHandler).Methods(http.MethodPost)
+
+	// Категории
+	collabRouter.HandleFunc("/{db_id:[0-9]+}/categories", controllers.GetCategoriesHandler).Methods(http.MethodGet)
+	collabRouter.HandleFunc("/{db_id:[0-9]+}/categories", controllers.CreateCategoryHandler).Methods(http.MethodPost)
+	collabRouter.HandleFunc("/{db_id:[0-9]+}/categories/{category_id:[0-9]+}", controllers.UpdateCategoryHandler).Methods(http.MethodPut)
+	collabRouter.HandleFunc("/{db_id:[0-9]+}/categories/{category_id:[0-9]+}", controllers.DeleteCategoryHandler).Methods(http.MethodDelete)
+
+	// Расписание
+	collabRouter.HandleFunc("/{db_id:[0-9]+}/schedule/entries", controllers.GetScheduleEntriesHandler).Methods(http.MethodGet)
+	collabRouter.HandleFunc("/{db_id:[0-9]+}/schedule/entries", controllers.CreateScheduleEntryHandler).Methods(http.MethodPost)
+	collabRouter.HandleFunc("/{db_id:[0-9]+}/schedule/entries/{entry_id:[0-9]+}", controllers.UpdateScheduleEntryHandler).Methods(http.MethodPut)
+	collabRouter.HandleFunc("/{db_id:[0-9]+}/schedule/entries/{entry_id:[0-9]+}", controllers.DeleteScheduleEntryHandler).Methods(http.MethodDelete)
+	collabRouter.HandleFunc("/{db_id:[0-9]+}/schedule/tags/stats", controllers.GetScheduleTagStatsHandler).Methods(http.MethodGet)
+	collabRouter.HandleFunc("/{db_id:[0-9]+}/schedule/occurrences", controllers.GetScheduleOccurrencesHandler).Methods(http.MethodGet)
+	collabRouter.HandleFunc("/{db_id:[0-9]+}/schedule/conflicts", controllers.GetScheduleConflictsHandler).Methods(http.MethodGet)
+	collabRouter.HandleFunc("/{db_id:[0-9]+}/schedule/freebusy", controllers.GetScheduleFreeBusyHandler).Methods(http.MethodGet)
+	collabRouter.HandleFunc("/{db_id:[0-9]+}/schedule/time-zone", controllers.GetScheduleTimeZoneHandler).Methods(http.MethodGet)
+	collabRouter.HandleFunc("/{db_id:[0-9]+}/schedule/time-zone", controllers.UpdateScheduleTimeZoneHandler).Methods(http.MethodPut)
+	collabRouter.HandleFunc("/{db_id:[0-9]+}/schedule/fields", controllers.GetDynamicFieldsHandler).Methods(http.MethodGet)
+	collabRouter.HandleFunc("/{db_id:[0-9]+}/schedule/fields", controllers.CreateDynamicFieldHandler).Methods(http.MethodPost)
+	collabRouter.HandleFunc("/{db_id:[0-9]+}/schedule/fields/{field_id:[0-9]+}", controllers.UpdateDynamicFieldHandler).Methods(http.MethodPut)
+	collabRouter.HandleFunc("/{db_id:[0-9]+}/schedule/fields/{field_id:[0-9]+}", controllers.DeleteDynamicFieldHandler).Methods(http.MethodDelete)
+	collabRouter.HandleFunc("/{db_id:[0-9]+}/schedule/import-ics", controllers.ImportScheduleICSHandler).Methods(http.MethodPost)
+	collabRouter.HandleFunc("/{db_id:[0-9]+}/schedule/entries/{entry_id:[0-9]+}/exceptions", controllers.GetScheduleExceptionsHandler).Methods(http.MethodGet)
+	collabRouter.HandleFunc("/{db_id:[0-9]+}/schedule/entries/{entry_id:[0-9]+}/exceptions", controllers.CreateScheduleExceptionHandler).Methods(http.MethodPost)
+	collabRouter.HandleFunc("/{db_id:[0-9]+}/schedule/exceptions/{exception_id:[0-9]+}", controllers.UpdateScheduleExceptionHandler).Methods(http.MethodPut)
+	collabRouter.HandleFunc("/{db_id:[0-9]+}/schedule/exceptions/{exception_id:[0-9]+}", controllers.DeleteScheduleExceptionHandler).Methods(http.MethodDelete)
+
+	// Подписка на календарь расписания
+	collabRouter.HandleFunc("/{db_id:[0-9]+}/calendar/feed-tokens", controllers.GetCalendarFeedTokensHandler).Methods(http.MethodGet)
+	collabRouter.HandleFunc("/{db_id:[0-9]+}/calendar/feed-tokens", controllers.CreateCalendarFeedTokenHandler).Methods(http.MethodPost)
+	collabRouter.HandleFunc("/{db_id:[0-9]+}/calendar/feed-tokens/{token_id:[0-9]+}", controllers.DeleteCalendarFeedTokenHandler).Methods(http.MethodDelete)
+
+	// Доски (у совместной БД может быть несколько досок с карточками)
+	collabRouter.HandleFunc("/{db_id:[0-9]+}/pinboards", controllers.GetPinboardsHandler).Methods(http.MethodGet)
+	collabRouter.HandleFunc("/{db_id:[0-9]+}/pinboards", controllers.CreatePinboardHandler).Methods(http.MethodPost)
+	collabRouter.HandleFunc("/{db_id:[0-9]+}/pinboards/{pinboard_id:[0-9]+}", controllers.UpdatePinboardHandler).Methods(http.MethodPut)
+	collabRouter.HandleFunc("/{db_id:[0-9]+}/pinboards/{pinboard_id:[0-9]+}", controllers.DeletePinboardHandler).Methods(http.MethodDelete)
+	collabRouter.HandleFunc("/{db_id:[0-9]+}/pinboards/{pinboard_id:[0-9]+}/notes", controllers.GetPinboardNotesHandler).Methods(http.MethodGet)
+
+	// Граф доски (карточки и связи), экспорт, автоматическая раскладка и выборка по области просмотра
+	collabRouter.HandleFunc("/{db_id:[0-9]+}/pinboard/notes/{note_id:[0-9]+}/neighbors", controllers.GetPinboardNeighborsHandler).Methods(http.MethodGet)
+	collabRouter.HandleFunc("/{db_id:[0-9]+}/pinboard/path", controllers.GetPinboardPathHandler).Methods(http.MethodGet)
+	collabRouter.HandleFunc("/{db_id:[0-9]+}/pinboard/components", controllers.GetPinboardComponentsHandler).Methods(http.MethodGet)
+	collabRouter.HandleFunc("/{db_id:[0-9]+}/pinboard/orphans", controllers.GetPinboardOrphansHandler).Methods(http.MethodGet)
+	collabRouter.HandleFunc("/{db_id:[0-9]+}/pinboard/export", controllers.ExportPinboardHandler).Methods(http.MethodGet)
+	collabRouter.HandleFunc("/{db_id:[0-9]+}/pinboard/layout", controllers.LayoutPinboardHandler).Methods(http.MethodPost)
+	collabRouter.HandleFunc("/{db_id:[0-9]+}/pinboard/viewport", controllers.GetPinboardViewportHandler).Methods(http.MethodGet)
+
+	// Ссылки между сущностями (заметки, карточки доски, записи расписания, папки) и обратные ссылки
+	collabRouter.HandleFunc("/{db_id:[0-9]+}/links", controllers.CreateEntityLinkHandler).Methods(http.MethodPost)
+	collabRouter.HandleFunc("/{db_id:[0-9]+}/links/{link_id:[0-9]+}", controllers.UpdateEntityLinkHandler).Methods(http.MethodPut)
+	collabRouter.HandleFunc("/{db_id:[0-9]+}/links/{link_id:[0-9]+}", controllers.DeleteEntityLinkHandler).Methods(http.MethodDelete)
+	collabRouter.HandleFunc("/{db_id:[0-9]+}/entities/{entity_type}/{entity_id:[0-9]+}/links", controllers.GetEntityLinksHandler).Methods(http.MethodGet)
+	collabRouter.HandleFunc("/{db_id:[0-9]+}/entities/{entity_type}/{entity_id:[0-9]+}/backlinks", controllers.GetEntityBacklinksHandler).Methods(http.MethodGet)
+
+	// Вики-ссылки [[Заголовок]] из текста заметок, обратные ссылки и отчет о битых ссылках
+	collabRouter.HandleFunc("/{db_id:[0-9]+}/notes/{note_id:[0-9]+}/wiki-links", controllers.GetNoteWikiLinksHandler).Methods(http.MethodGet)
+	collabRouter.HandleFunc("/{db_id:[0-9]+}/notes/{note_id:[0-9]+}/wiki-backlinks", controllers.GetNoteWikiBacklinksHandler).Methods(http.MethodGet)
+	collabRouter.HandleFunc("/{db_id:[0-9]+}/wiki-links/broken", controllers.GetBrokenWikiLinksHandler).Methods(http.MethodGet)
+
+	// История версий заметок: список, просмотр, сравнение и восстановление версии
+	collabRouter.HandleFunc("/{db_id:[0-9]+}/notes/{note_id:[0-9]+}/revisions", controllers.GetNoteRevisionsHandler).Methods(http.MethodGet)
+	collabRouter.HandleFunc("/{db_id:[0-9]+}/notes/{note_id:[0-9]+}/revisions/diff", controllers.DiffNoteRevisionsHandler).Methods(http.MethodGet)
+	collabRouter.HandleFunc("/{db_id:[0-9]+}/notes/{note_id:[0-9]+}/revisions/{revision_id:[0-9]+}", controllers.GetNoteRevisionHandler).Methods(http.MethodGet)
+	collabRouter.HandleFunc("/{db_id:[0-9]+}/notes/{note_id:[0-9]+}/revisions/{revision_id:[0-9]+}/restore", controllers.RestoreNoteRevisionHandler).Methods(http.MethodPost)
+
+	// Корзина заметок и папок
+	collabRouter.HandleFunc("/{db_id:[0-9]+}/trash", controllers.GetTrashHandler).Methods(http.MethodGet)
+	collabRouter.HandleFunc("/{db_id:[0-9]+}/trash", controllers.EmptyTrashHandler).Methods(http.MethodDelete)
+	collabRouter.HandleFunc("/{db_id:[0-9]+}/trash/{item_id:[0-9]+}/restore", controllers.RestoreTrashItemHandler).Methods(http.MethodPost)
+	collabRouter.HandleFunc("/{db_id:[0-9]+}/trash/{item_id:[0-9]+}", controllers.PurgeTrashItemHandler).Methods(http.MethodDelete)
 
 	// Маршруты для приглашений
 	invitationRouter := apiRouter.PathPrefix("/collaboration/invitations").Subrouter()
@@ -121,6 +265,10 @@ func main() {
 	// Клиент ожидает /api/Service/status
 	router.HandleFunc("/api/Service/status", controllers.HealthCheck).Methods(http.MethodGet)
 
+	// Фид календаря расписания (открытый, без JWT): календарные приложения не передают токен авторизации,
+	// доступ определяется токеном подписки в ссылке.
+	router.HandleFunc("/api/calendar/feed/{token:[0-9a-f]+}.ics", controllers.CalendarFeedHandler).Methods(http.MethodGet)
+
 	// Маршрут для загрузки файлов (например, фото профиля)
 	// Этот маршрут также должен быть защищен JWT
 	fileRouter := apiRouter.PathPrefix("/file").Subrouter()
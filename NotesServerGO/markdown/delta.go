@@ -0,0 +1,204 @@
+package markdown
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// deltaOp - операция документа Quill Delta: текст или встраиваемый объект с атрибутами.
+type deltaOp struct {
+	Insert     interface{}            `json:"insert"`
+	Attributes map[string]interface{} `json:"attributes"`
+}
+
+// parseDelta разбирает документ Quill Delta: массив операций или объект вида {"ops": [...]}.
+func parseDelta(deltaJson string) ([]deltaOp, bool) {
+	var ops []deltaOp
+	if err := json.Unmarshal([]byte(deltaJson), &ops); err != nil {
+		var wrapper struct {
+			Ops []deltaOp `json:"ops"`
+		}
+		if err := json.Unmarshal([]byte(deltaJson), &wrapper); err != nil {
+			return nil, false
+		}
+		ops = wrapper.Ops
+	}
+	return ops, len(ops) > 0
+}
+
+// deltaLine - строка документа: отрисованный Markdown текста строки и атрибуты строки
+// (в Quill атрибуты заголовка, списка, цитаты и блока кода хранятся у символа перевода строки).
+type deltaLine struct {
+	text       string
+	code       string // Текст без разметки - для блоков кода
+	attributes map[string]interface{}
+}
+
+// DeltaToMarkdown переводит документ Quill Delta в Markdown. Поддерживаются заголовки, списки
+// (в том числе чек-листы), цитаты, блоки кода, жирный, курсив, зачеркнутый текст, код и ссылки.
+// image вызывается для встроенных изображений и возвращает ссылку на файл в архиве ("" - пропустить).
+// Возвращает false, если deltaJson не является документом Quill Delta.
+func DeltaToMarkdown(deltaJson string, image func(src string) string) (string, bool) {
+	ops, ok := parseDelta(deltaJson)
+	if !ok {
+		return "", false
+	}
+
+	var lines []deltaLine
+	var text, code strings.Builder
+	for _, op := range ops {
+		switch insert := op.Insert.(type) {
+		case string:
+			parts := strings.Split(insert, "\n")
+			for i, part := range parts {
+				if part != "" {
+					text.WriteString(inlineMarkdown(part, op.Attributes))
+					code.WriteString(part)
+				}
+				if i < len(parts)-1 {
+					lines = append(lines, deltaLine{text: text.String(), code: code.String(), attributes: op.Attributes})
+					text.Reset()
+					code.Reset()
+				}
+			}
+		case map[string]interface{}:
+			if src, ok := insert["image"].(string); ok && image != nil {
+				if link := image(src); link != "" {
+					text.WriteString(link)
+				}
+			}
+		}
+	}
+	if text.Len() > 0 {
+		lines = append(lines, deltaLine{text: text.String(), code: code.String()})
+	}
+	return renderLines(lines), true
+}
+
+// renderLines собирает строки в блоки Markdown, разделенные пустой строкой. Строки одного списка,
+// цитаты или блока кода объединяются в один блок; пустые строки документа только разделяют блоки.
+func renderLines(lines []deltaLine) string {
+	var blocks, current []string
+	currentKind := ""
+	flush := func() {
+		if len(current) == 0 {
+			return
+		}
+		block := strings.Join(current, "\n")
+		if currentKind == "code" {
+			block = "```\n" + block + "\n```"
+		}
+		blocks = append(blocks, block)
+		current = nil
+	}
+	for _, line := range lines {
+		kind := lineKind(line.attributes)
+		if kind == "" && strings.TrimSpace(line.text) == "" {
+			flush()
+			continue
+		}
+		if kind != currentKind || kind == "" || kind == "header" {
+			flush()
+		}
+		currentKind = kind
+		current = append(current, renderLine(kind, line))
+	}
+	flush()
+	if len(blocks) == 0 {
+		return ""
+	}
+	return strings.Join(blocks, "\n\n") + "\n"
+}
+
+// renderLine оформляет строку документа вида kind.
+func renderLine(kind string, line deltaLine) string {
+	switch kind {
+	case "code":
+		return line.code
+	case "header":
+		level := intAttribute(line.attributes, "header")
+		if level < 1 || level > 6 {
+			level = 1
+		}
+		return strings.Repeat("#", level) + " " + line.text
+	case "quote":
+		return "> " + line.text
+	case "list":
+		indent := strings.Repeat("    ", intAttribute(line.attributes, "indent"))
+		return indent + listMarker(line.attributes["list"]) + line.text
+	}
+	return line.text
+}
+
+// lineKind возвращает вид строки по ее атрибутам: header, list, quote, code или "" для абзаца.
+func lineKind(attributes map[string]interface{}) string {
+	switch {
+	case attributes["code-block"] != nil && attributes["code-block"] != false:
+		return "code"
+	case attributes["header"] != nil:
+		return "header"
+	case attributes["list"] != nil:
+		return "list"
+	case attributes["blockquote"] == true:
+		return "quote"
+	}
+	return ""
+}
+
+// listMarker возвращает маркер элемента списка Quill: bullet, ordered, checked или unchecked.
+func listMarker(list interface{}) string {
+	switch list {
+	case "ordered":
+		return "1. "
+	case "checked":
+		return "- [x] "
+	case "unchecked":
+		return "- [ ] "
+	}
+	return "- "
+}
+
+// intAttribute возвращает числовой атрибут (JSON-числа разбираются как float64).
+func intAttribute(attributes map[string]interface{}, name string) int {
+	if value, ok := attributes[name].(float64); ok {
+		return int(value)
+	}
+	return 0
+}
+
+// inlineMarkdown оформляет фрагмент текста по атрибутам Quill. Пробелы по краям выносятся
+// за маркеры выделения, иначе Markdown их не распознает.
+func inlineMarkdown(text string, attributes map[string]interface{}) string {
+	trimmed := strings.TrimSpace(text)
+	if trimmed == "" {
+		return text
+	}
+	lead := text[:strings.Index(text, trimmed)]
+	trail := text[len(lead)+len(trimmed):]
+
+	if attributes["code"] == true {
+		trimmed = "`" + strings.ReplaceAll(trimmed, "`", "'") + "`"
+	} else {
+		trimmed = escapeMarkdown(trimmed)
+		if attributes["strike"] == true {
+			trimmed = "~~" + trimmed + "~~"
+		}
+		if attributes["italic"] == true {
+			trimmed = "*" + trimmed + "*"
+		}
+		if attributes["bold"] == true {
+			trimmed = "**" + trimmed + "**"
+		}
+	}
+	if link, ok := attributes["link"].(string); ok && link != "" {
+		trimmed = "[" + trimmed + "](<" + strings.NewReplacer("<", "%3C", ">", "%3E").Replace(link) + ">)"
+	}
+	return lead + trimmed + trail
+}
+
+// markdownEscaper экранирует символы разметки Markdown в тексте документа.
+var markdownEscaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "_", `\_`, "`", "\\`", "[", `\[`, "]", `\]`)
+
+func escapeMarkdown(text string) string {
+	return markdownEscaper.Replace(text)
+}
@@ -0,0 +1,347 @@
+package markdown
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"notes_server_go/models"
+)
+
+// ContentType - MIME-тип архива экспорта.
+const ContentType = "application/zip"
+
+// uploadsDir - директория загруженных файлов; изображения из других мест в архив не попадают.
+const uploadsDir = "uploads"
+
+// maxNameLength - максимальная длина имени файла или папки в архиве (в символах, без расширения).
+const maxNameLength = 100
+
+// untitledName - имя для папок и заметок без названия.
+const untitledName = "Без названия"
+
+// Archive - совместная БД для экспорта в zip-архив Markdown: папки становятся директориями, заметки -
+// файлами .md с front matter (id, время создания и изменения, папка, категория, теги, метаданные),
+// изображения заметок кладутся рядом с ними и подключаются относительными ссылками.
+type Archive struct {
+	Folders    []models.Folder
+	Notes      []models.Note
+	Tags       map[int64][]string // Теги по ID заметки
+	Categories map[int64]string   // Названия категорий по ID
+	Images     []models.NoteImage // Изображения заметок; ImagePath - путь к файлу на сервере
+}
+
+// archiveNote - заметка с путем ее файла и изображениями в архиве.
+type archiveNote struct {
+	note   models.Note
+	path   string
+	images []archiveImage
+}
+
+// archiveImage - изображение заметки: путь к файлу на сервере, имя исходного файла и путь в архиве.
+type archiveImage struct {
+	source   string
+	fileName string
+	path     string
+}
+
+// WriteZip записывает архив в w. Имена папок и файлов очищаются от недопустимых символов,
+// совпадающие имена в одной директории получают суффикс " (2)", " (3)" и т.д. Изображения,
+// файлов которых нет на диске, пропускаются.
+func (a *Archive) WriteZip(w io.Writer) error {
+	names := make(map[string]map[string]bool) // Занятые имена по директориям (без учета регистра)
+	folderDirs := a.folderDirs(names)
+	notes := a.layoutNotes(folderDirs, names)
+
+	zw := zip.NewWriter(w)
+	dirs := make([]string, 0, len(folderDirs))
+	for _, dir := range folderDirs {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	for _, dir := range dirs {
+		if _, err := zw.Create(dir + "/"); err != nil {
+			return fmt.Errorf("ошибка записи директории %s: %w", dir, err)
+		}
+	}
+	for _, an := range notes {
+		header := &zip.FileHeader{Name: an.path, Method: zip.Deflate, Modified: an.note.UpdatedAt}
+		fw, err := zw.CreateHeader(header)
+		if err != nil {
+			return fmt.Errorf("ошибка записи заметки %s: %w", an.path, err)
+		}
+		if _, err := fw.Write(a.renderNote(an, folderDirs)); err != nil {
+			return fmt.Errorf("ошибка записи заметки %s: %w", an.path, err)
+		}
+		for _, image := range an.images {
+			if err := writeFile(zw, image.path, image.source); err != nil {
+				return err
+			}
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("ошибка завершения архива: %w", err)
+	}
+	return nil
+}
+
+// folderDirs строит пути директорий папок в архиве. Папки с отсутствующей родительской
+// или входящие в цикл по ParentId кладутся в корень архива.
+func (a *Archive) folderDirs(names map[string]map[string]bool) map[int64]string {
+	byID := make(map[int64]models.Folder, len(a.Folders))
+	for _, folder := range a.Folders {
+		byID[folder.ID] = folder
+	}
+	folders := append([]models.Folder(nil), a.Folders...)
+	sort.Slice(folders, func(i, j int) bool { return folders[i].ID < folders[j].ID })
+
+	dirs := make(map[int64]string, len(folders))
+	var resolve func(folder models.Folder, visiting map[int64]bool) string
+	resolve = func(folder models.Folder, visiting map[int64]bool) string {
+		if dir, ok := dirs[folder.ID]; ok {
+			return dir
+		}
+		visiting[folder.ID] = true
+		parentDir := ""
+		if folder.ParentID != nil {
+			if parent, ok := byID[*folder.ParentID]; ok && !visiting[parent.ID] {
+				parentDir = resolve(parent, visiting)
+			}
+		}
+		dir := path.Join(parentDir, reserveName(names, parentDir, sanitizeName(folder.Name), ""))
+		dirs[folder.ID] = dir
+		return dir
+	}
+	for _, folder := range folders {
+		resolve(folder, make(map[int64]bool))
+	}
+	return dirs
+}
+
+// layoutNotes назначает заметкам и их изображениям пути в архиве.
+func (a *Archive) layoutNotes(folderDirs map[int64]string, names map[string]map[string]bool) []archiveNote {
+	imagesByNote := make(map[int64][]models.NoteImage)
+	for _, image := range a.Images {
+		imagesByNote[image.NoteId] = append(imagesByNote[image.NoteId], image)
+	}
+	notes := append([]models.Note(nil), a.Notes...)
+	sort.Slice(notes, func(i, j int) bool { return notes[i].ID < notes[j].ID })
+
+	result := make([]archiveNote, 0, len(notes))
+	for _, note := range notes {
+		dir := ""
+		if note.FolderID != nil {
+			dir = folderDirs[*note.FolderID]
+		}
+		an := archiveNote{note: note, path: path.Join(dir, reserveName(names, dir, sanitizeName(note.Title), ".md"))}
+		for _, image := range imagesByNote[note.ID] {
+			source, ok := uploadedFilePath(image.ImagePath)
+			if !ok {
+				log.Printf("Экспорт Markdown: файл изображения %s заметки %d вне директории '%s', изображение пропущено", image.ImagePath, note.ID, uploadsDir)
+				continue
+			}
+			if _, err := os.Stat(source); err != nil {
+				log.Printf("Экспорт Markdown: файл изображения %s заметки %d недоступен (%v), изображение пропущено", image.ImagePath, note.ID, err)
+				continue
+			}
+			name := image.FileName
+			if name == "" {
+				name = filepath.Base(image.ImagePath)
+			}
+			ext := path.Ext(name)
+			fileName := reserveName(names, dir, sanitizeName(strings.TrimSuffix(name, ext)), sanitizeExt(ext))
+			an.images = append(an.images, archiveImage{source: source, fileName: image.FileName, path: path.Join(dir, fileName)})
+		}
+		result = append(result, an)
+	}
+	return result
+}
+
+// renderNote формирует файл заметки: front matter и текст в Markdown. Изображения, встроенные в текст,
+// заменяются ссылками на файлы рядом с заметкой; остальные изображения добавляются в конец.
+func (a *Archive) renderNote(an archiveNote, folderDirs map[int64]string) []byte {
+	var out bytes.Buffer
+	note := an.note
+	out.WriteString("---\n")
+	out.WriteString("id: " + strconv.FormatInt(note.ID, 10) + "\n")
+	out.WriteString("title: " + yamlString(note.Title) + "\n")
+	out.WriteString("created: " + note.CreatedAt.UTC().Format(time.RFC3339) + "\n")
+	out.WriteString("updated: " + note.UpdatedAt.UTC().Format(time.RFC3339) + "\n")
+	if note.FolderID != nil {
+		if dir, ok := folderDirs[*note.FolderID]; ok {
+			out.WriteString("folder: " + yamlString(dir) + "\n")
+		}
+	}
+	if note.CategoryId != nil {
+		if name, ok := a.Categories[*note.CategoryId]; ok {
+			out.WriteString("category: " + yamlString(name) + "\n")
+		}
+	}
+	if tags := a.Tags[note.ID]; len(tags) > 0 {
+		out.WriteString("tags:\n")
+		for _, tag := range tags {
+			out.WriteString("  - " + yamlString(tag) + "\n")
+		}
+	} else {
+		out.WriteString("tags: []\n")
+	}
+	if len(note.Metadata) > 0 {
+		keys := make([]string, 0, len(note.Metadata))
+		for key := range note.Metadata {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		out.WriteString("metadata:\n")
+		for _, key := range keys {
+			out.WriteString("  " + yamlString(key) + ": " + yamlString(note.Metadata[key]) + "\n")
+		}
+	} else {
+		out.WriteString("metadata: {}\n")
+	}
+	out.WriteString("---\n\n")
+
+	embedded := make(map[int]bool)
+	imageLink := func(src string) string {
+		base := path.Base(strings.ReplaceAll(src, `\`, "/"))
+		for i, image := range an.images {
+			if image.fileName != "" && (image.fileName == base || strings.HasSuffix(base, "_"+image.fileName)) {
+				embedded[i] = true
+				return imageMarkdown(image)
+			}
+		}
+		return ""
+	}
+	body, ok := "", false
+	if note.ContentJson != nil && *note.ContentJson != "" {
+		body, ok = DeltaToMarkdown(*note.ContentJson, imageLink)
+	}
+	if !ok && note.Content != nil {
+		body = *note.Content
+	}
+	body = strings.TrimRight(body, "\n")
+	out.WriteString(body)
+
+	for i, image := range an.images {
+		if embedded[i] {
+			continue
+		}
+		if out.Len() > 0 && !bytes.HasSuffix(out.Bytes(), []byte("\n\n")) {
+			out.WriteString("\n\n")
+		}
+		out.WriteString(imageMarkdown(image))
+	}
+	out.WriteString("\n")
+	return out.Bytes()
+}
+
+// imageMarkdown возвращает ссылку на изображение относительно файла заметки (они в одной директории).
+func imageMarkdown(image archiveImage) string {
+	name := path.Base(image.path)
+	return "![" + escapeMarkdown(strings.TrimSuffix(name, path.Ext(name))) + "](<" + name + ">)"
+}
+
+// writeFile копирует файл source из директории uploads в архив под именем name.
+func writeFile(zw *zip.Writer, name string, source string) error {
+	resolvedPath, ok := uploadedFilePath(source)
+	if !ok {
+		return fmt.Errorf("файл %s находится вне директории '%s'", source, uploadsDir)
+	}
+	file, err := os.Open(resolvedPath)
+	if err != nil {
+		return fmt.Errorf("ошибка открытия файла %s: %w", source, err)
+	}
+	defer file.Close()
+	header := &zip.FileHeader{Name: name, Method: zip.Deflate}
+	if info, err := file.Stat(); err == nil {
+		header.Modified = info.ModTime()
+	}
+	fw, err := zw.CreateHeader(header)
+	if err != nil {
+		return fmt.Errorf("ошибка записи файла %s: %w", name, err)
+	}
+	if _, err := io.Copy(fw, file); err != nil {
+		return fmt.Errorf("ошибка копирования файла %s: %w", source, err)
+	}
+	return nil
+}
+
+// uploadedFilePath возвращает абсолютный путь к файлу, если он находится в директории uploads.
+func uploadedFilePath(source string) (string, bool) {
+	resolvedPath, err := filepath.Abs(source)
+	if err != nil {
+		return "", false
+	}
+	dir, err := filepath.Abs(uploadsDir)
+	if err != nil || !strings.HasPrefix(resolvedPath, dir+string(filepath.Separator)) {
+		return "", false
+	}
+	return resolvedPath, true
+}
+
+// reserveName занимает в директории dir имя base+ext; если оно занято, добавляет к base суффикс " (2)", " (3)"...
+func reserveName(names map[string]map[string]bool, dir string, base string, ext string) string {
+	taken := names[dir]
+	if taken == nil {
+		taken = make(map[string]bool)
+		names[dir] = taken
+	}
+	name := base + ext
+	for i := 2; taken[strings.ToLower(name)]; i++ {
+		name = base + " (" + strconv.Itoa(i) + ")" + ext
+	}
+	taken[strings.ToLower(name)] = true
+	return name
+}
+
+// invalidNameChars - символы, недопустимые в именах файлов Windows, macOS или Linux.
+var invalidNameChars = strings.NewReplacer("/", "_", `\`, "_", ":", "_", "*", "_", "?", "_", `"`, "_", "<", "_", ">", "_", "|", "_")
+
+// sanitizeName делает из названия папки или заметки имя файла: заменяет недопустимые и управляющие
+// символы, убирает пробелы и точки по краям и ограничивает длину.
+func sanitizeName(name string) string {
+	name = invalidNameChars.Replace(name)
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return ' '
+		}
+		return r
+	}, name)
+	name = strings.Trim(strings.Join(strings.Fields(name), " "), " .")
+	if utf8.RuneCountInString(name) > maxNameLength {
+		name = strings.TrimRight(string([]rune(name)[:maxNameLength]), " .")
+	}
+	if name == "" {
+		return untitledName
+	}
+	return name
+}
+
+// sanitizeExt очищает расширение файла изображения (".png"); пустое или некорректное расширение отбрасывается.
+func sanitizeExt(ext string) string {
+	if len(ext) < 2 || len(ext) > 10 || strings.ContainsAny(ext, `/\:*?"<>| `) {
+		return ""
+	}
+	return strings.ToLower(ext)
+}
+
+// yamlString записывает строку в кавычках. Строка JSON - корректная строка YAML в двойных кавычках.
+func yamlString(value string) string {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return `""`
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
@@ -6,14 +6,21 @@ import "time"
 // Эта структура используется для передачи данных между клиентом и сервером.
 // Важно, чтобы поля JSON соответствовали тому, что ожидает клиент.
 type BackupData struct {
-	Folders         []Folder        `json:"folders"`
-	Notes           []Note          `json:"notes"`
-	ScheduleEntries []ScheduleEntry `json:"scheduleEntries"`
-	PinboardNotes   []PinboardNote  `json:"pinboardNotes"`
-	Connections     []Connection    `json:"connections"`
-	NoteImages      []NoteImage     `json:"images"` // Изменено с "note_images" на "images" для соответствия клиенту
-	DatabaseId      string          `json:"databaseId,omitempty"`
-	UserId          string          `json:"userId,omitempty"` // Может использоваться для идентификации владельца бэкапа
-	LastModified    time.Time       `json:"lastModified"`
-	CreatedAt       time.Time       `json:"createdAt"`
+	Folders            []Folder                 `json:"folders"`
+	Notes              []Note                   `json:"notes"`
+	ScheduleEntries    []ScheduleEntry          `json:"scheduleEntries"`
+	Pinboards          []Pinboard               `json:"pinboards"`     // nil в старых бэкапах - карточки попадают на доску по умолчанию
+	PinboardNotes      []PinboardNote           `json:"pinboardNotes"` // pinboard_id ссылается на ID досок из этого же бэкапа
+	Connections        []Connection             `json:"connections"`
+	NoteImages         []NoteImage              `json:"images"`             // Изменено с "note_images" на "images" для соответствия клиенту
+	SmartFolders       []SmartFolder            `json:"smartFolders"`       // nil в старых бэкапах - умные папки не трогаем
+	NoteTags           []NoteTag                `json:"noteTags"`           // note_id ссылается на ID заметок из этого же бэкапа
+	Categories         []Category               `json:"categories"`         // nil в старых бэкапах - категории не трогаем
+	ScheduleExceptions []ScheduleEntryException `json:"scheduleExceptions"` // schedule_entry_id ссылается на ID записей из этого же бэкапа
+	DynamicFields      []DynamicFieldDefinition `json:"dynamicFields"`      // Схема динамических полей; nil в старых бэкапах - схему не трогаем
+	EntityLinks        []EntityLink             `json:"entityLinks"`        // Концы ссылаются на ID сущностей из этого же бэкапа; nil в старых бэкапах - ссылки не восстанавливаются
+	DatabaseId         string                   `json:"databaseId,omitempty"`
+	UserId             string                   `json:"userId,omitempty"` // Может использоваться для идентификации владельца бэкапа
+	LastModified       time.Time                `json:"lastModified"`
+	CreatedAt          time.Time                `json:"createdAt"`
 }
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// CalendarFeedToken - токен подписки на календарь расписания совместной БД.
+// Календарные приложения не умеют передавать JWT, поэтому фид доступен по секретной ссылке с токеном,
+// выданной конкретному пользователю; удаление токена отзывает ссылку.
+type CalendarFeedToken struct {
+	Id         int64        `json:"id" db:"Id"`
+	Token      string       `json:"token" db:"Token"`
+	DatabaseId int64        `json:"database_id" db:"DatabaseId"`
+	UserId     int64        `json:"user_id" db:"UserId"`
+	Name       string       `json:"name" db:"Name"` // Подпись, чтобы пользователь мог отличить свои подписки
+	CreatedAt  FlexibleTime `json:"created_at" db:"CreatedAt"`
+	LastUsedAt *time.Time   `json:"last_used_at,omitempty" db:"LastUsedAt"`
+	FeedURL    string       `json:"feed_url" db:"-"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// Category представляет категорию совместной БД, которую можно назначить заметкам и записям расписания.
+// В отличие от модели Category во Flutter-клиенте (id - String), id передается числом, как у папок и заметок:
+// при синхронизации клиент присылает свой локальный числовой id, сервер возвращает категории с серверными id
+// и переносит на них category_id заметок и записей расписания. Клиенту нужно читать и отправлять id
+// категории (и category_id) как int: строковый id не разберется, и запрос синхронизации вернет 400.
+type Category struct {
+	Id         int64        `json:"id" db:"Id"`
+	DatabaseId int64        `json:"database_id" db:"DatabaseId"`
+	Name       string       `json:"name" db:"Name"`
+	Color      string       `json:"color" db:"Color"` // Клиент хранит цвет строкой, например "0xFF2196F3"
+	CreatedAt  FlexibleTime `json:"created_at" db:"CreatedAt"`
+	UpdatedAt  time.Time    `json:"-" db:"UpdatedAt"`
+}
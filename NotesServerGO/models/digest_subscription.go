@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// DigestSubscription - настройки ежедневной сводки пользователя: в SendTime по его часовому поясу
+// пользователю отправляется письмо с повторениями записей расписания на сегодня и недавно
+// измененными заметками всех его совместных БД.
+type DigestSubscription struct {
+	Id           int64        `json:"id" db:"Id"`
+	UserId       int64        `json:"user_id" db:"UserId"`
+	Enabled      bool         `json:"enabled" db:"Enabled"`
+	SendTime     string       `json:"send_time" db:"SendTime"` // "HH:mm" по часовому поясу TimeZone
+	TimeZone     string       `json:"time_zone" db:"TimeZone"` // Имя IANA; "" - часовой пояс сервера
+	SendEmpty    bool         `json:"send_empty" db:"SendEmpty"`
+	LastSentDate *string      `json:"last_sent_date,omitempty" db:"LastSentDate"` // Дата последней сводки по расписанию, "yyyy-MM-dd"
+	LastSentAt   *time.Time   `json:"last_sent_at,omitempty" db:"LastSentAt"`
+	CreatedAt    FlexibleTime `json:"created_at" db:"CreatedAt"`
+	UpdatedAt    time.Time    `json:"-" db:"UpdatedAt"`
+}
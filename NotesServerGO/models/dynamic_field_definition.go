@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// DynamicFieldType - тип динамического поля записи расписания.
+type DynamicFieldType string
+
+const (
+	DynamicFieldText     DynamicFieldType = "text"
+	DynamicFieldNumber   DynamicFieldType = "number"
+	DynamicFieldDate     DynamicFieldType = "date"     // "yyyy-MM-dd"
+	DynamicFieldChoice   DynamicFieldType = "choice"   // Одно из значений Options
+	DynamicFieldCheckbox DynamicFieldType = "checkbox" // "true" / "false"
+)
+
+// IsValid проверяет, что тип поля известен.
+func (t DynamicFieldType) IsValid() bool {
+	switch t {
+	case DynamicFieldText, DynamicFieldNumber, DynamicFieldDate, DynamicFieldChoice, DynamicFieldCheckbox:
+		return true
+	}
+	return false
+}
+
+// DynamicFieldDefinition - описание динамического поля в схеме полей совместной БД.
+// Значения полей хранятся в ScheduleEntry.DynamicFieldsJson по ключу Name (клиент редактирует их
+// через DynamicFieldEntry). Поля без описания в схеме сервер не проверяет.
+type DynamicFieldDefinition struct {
+	Id          int64            `json:"id" db:"Id"`
+	DatabaseId  int64            `json:"database_id" db:"DatabaseId"`
+	Name        string           `json:"name" db:"Name"`
+	Type        DynamicFieldType `json:"type" db:"Type"`
+	Required    bool             `json:"required" db:"Required"`
+	Options     []string         `json:"options" db:"-"`     // Варианты значения поля типа choice
+	OptionsJson *string          `json:"-" db:"OptionsJson"` // Options в БД (JSON-массив строк)
+	Position    int              `json:"position" db:"Position"`
+	CreatedAt   FlexibleTime     `json:"created_at" db:"CreatedAt"`
+	UpdatedAt   time.Time        `json:"-" db:"UpdatedAt"`
+}
@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// EntityType определяет тип сущности совместной БД, которую можно связать ссылкой (EntityLink).
+type EntityType string
+
+const (
+	EntityNote          EntityType = "note"
+	EntityPinboardNote  EntityType = "pinboard_note"
+	EntityScheduleEntry EntityType = "schedule_entry"
+	EntityFolder        EntityType = "folder"
+)
+
+// IsValid проверяет, что тип сущности поддерживается ссылками.
+func (t EntityType) IsValid() bool {
+	switch t {
+	case EntityNote, EntityPinboardNote, EntityScheduleEntry, EntityFolder:
+		return true
+	}
+	return false
+}
+
+// EntityLink представляет направленную ссылку между двумя сущностями одной совместной БД
+// (например, запись расписания -> заметка со встречи, карточка доски -> полная заметка).
+// В отличие от Connection, связывает сущности любых типов; для цели это обратная ссылка (backlink).
+type EntityLink struct {
+	Id              int64        `json:"id" db:"Id"`
+	DatabaseId      int64        `json:"database_id" db:"DatabaseId"`
+	SourceType      EntityType   `json:"source_type" db:"SourceType"`
+	SourceId        int64        `json:"source_id" db:"SourceId"`
+	TargetType      EntityType   `json:"target_type" db:"TargetType"`
+	TargetId        int64        `json:"target_id" db:"TargetId"`
+	Label           string       `json:"label" db:"Label"`
+	CreatedByUserId *int64       `json:"created_by_user_id,omitempty" db:"CreatedByUserId"`
+	CreatedAt       FlexibleTime `json:"created_at" db:"CreatedAt"`
+	UpdatedAt       time.Time    `json:"-" db:"UpdatedAt"`
+}
+
+// LinkedEntity - ссылка вместе с заголовком сущности на другом ее конце
+// (цели для исходящих ссылок, источника для обратных).
+type LinkedEntity struct {
+	EntityLink
+	Title string `json:"title" db:"Title"`
+}
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// FlexibleTime - custom type для времени, приходящего от клиента в разных форматах.
+// Flutter (DateTime.toIso8601String) присылает время без часового пояса, например "2025-01-31T10:00:00.000",
+// что стандартный time.Time не принимает. Пустая строка и null дают нулевое время.
+type FlexibleTime struct {
+	time.Time
+}
+
+// flexibleTimeLayouts - форматы, которые пробуются по порядку при разборе.
+var flexibleTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02",
+}
+
+// NewFlexibleTime оборачивает time.Time.
+func NewFlexibleTime(t time.Time) FlexibleTime {
+	return FlexibleTime{Time: t}
+}
+
+// UnmarshalJSON реализует custom unmarshaling для FlexibleTime
+func (t *FlexibleTime) UnmarshalJSON(data []byte) error {
+	var value *string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	if value == nil || strings.TrimSpace(*value) == "" {
+		t.Time = time.Time{}
+		return nil
+	}
+	for _, layout := range flexibleTimeLayouts {
+		if parsed, err := time.ParseInLocation(layout, *value, time.Local); err == nil {
+			t.Time = parsed
+			return nil
+		}
+	}
+	return fmt.Errorf("неверный формат времени: %q", *value)
+}
+
+// MarshalJSON реализует custom marshaling для FlexibleTime (RFC3339)
+func (t FlexibleTime) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.Time.Format(time.RFC3339Nano))
+}
+
+// Scan реализует интерфейс sql.Scanner.
+func (t *FlexibleTime) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		t.Time = time.Time{}
+	case time.Time:
+		t.Time = v
+	case string:
+		return t.UnmarshalJSON([]byte(fmt.Sprintf("%q", v)))
+	case []byte:
+		return t.UnmarshalJSON([]byte(fmt.Sprintf("%q", string(v))))
+	default:
+		return fmt.Errorf("FlexibleTime: неподдерживаемый тип %T", value)
+	}
+	return nil
+}
+
+// Value реализует интерфейс driver.Valuer.
+func (t FlexibleTime) Value() (driver.Value, error) {
+	return t.Time, nil
+}
@@ -13,6 +13,7 @@ type Note struct {
 	Title        string    `json:"title" db:"Title"`
 	Content      *string   `json:"content,omitempty" db:"Content"`
 	FolderID     *int64    `json:"folder_id,omitempty" db:"FolderId"` // omitempty, если папки нет
+	CategoryId   *int64    `json:"category_id,omitempty" db:"CategoryId"`
 	CreatedAt    time.Time `json:"-" db:"CreatedAt"`
 	UpdatedAt    time.Time `json:"-" db:"UpdatedAt"`
 	ImagesJson   string    `json:"images,omitempty" db:"ImagesJson"`
@@ -0,0 +1,29 @@
+package models
+
+// Причины создания версии заметки.
+const (
+	NoteRevisionReasonInitial    = "initial" // Состояние заметки до первого сохранения с историей версий
+	NoteRevisionReasonSync       = "sync"
+	NoteRevisionReasonRestore    = "restore"
+	NoteRevisionReasonBackup     = "backup"      // Заметка перезаписана восстановлением из бэкапа
+	NoteRevisionReasonWikiRename = "wiki-rename" // Вики-ссылки в тексте переписаны после переименования другой заметки
+)
+
+// NoteRevisionSummary - версия заметки в списке версий (без содержимого).
+type NoteRevisionSummary struct {
+	Id           int64        `json:"id" db:"Id"`
+	DatabaseId   int64        `json:"database_id" db:"DatabaseId"`
+	NoteId       int64        `json:"note_id" db:"NoteId"`
+	Title        string       `json:"title" db:"Title"`
+	FolderId     *int64       `json:"folder_id,omitempty" db:"FolderId"`
+	AuthorUserId *int64       `json:"author_user_id,omitempty" db:"AuthorUserId"` // nil - автор неизвестен
+	Reason       string       `json:"reason" db:"Reason"`                         // initial, sync, restore
+	CreatedAt    FlexibleTime `json:"created_at" db:"CreatedAt"`
+}
+
+// NoteRevision - сохраненное состояние заметки: заголовок, содержимое и папка.
+type NoteRevision struct {
+	NoteRevisionSummary
+	Content     *string `json:"content,omitempty" db:"Content"`
+	ContentJson *string `json:"content_json,omitempty" db:"ContentJson"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import "strings"
+
+// NoteTag представляет тег заметки. Соответствует модели NoteTag во Flutter-клиенте.
+type NoteTag struct {
+	Id         int64        `json:"id" db:"Id"`
+	NoteId     int64        `json:"note_id" db:"NoteId"`
+	Tag        string       `json:"tag" db:"Tag"`
+	DatabaseId int64        `json:"database_id" db:"DatabaseId"`
+	CreatedAt  FlexibleTime `json:"created_at" db:"CreatedAt"`
+}
+
+// TagUsage - тег с количеством использований в совместной БД.
+type TagUsage struct {
+	Tag   string `json:"tag" db:"Tag"`
+	Count int    `json:"count" db:"Count"`
+}
+
+// NormalizeTag приводит тег заметки или записи расписания к хранимому виду: обрезает пробелы по краям.
+func NormalizeTag(tag string) string {
+	return strings.TrimSpace(tag)
+}
@@ -0,0 +1,22 @@
+package models
+
+// NoteWikiLink - вики-ссылка [[Заголовок]] из текста заметки на другую заметку той же совместной БД.
+// Ссылки строятся сервером по содержимому заметок; TargetNoteId = nil, если заметки с таким
+// заголовком нет (битая ссылка).
+type NoteWikiLink struct {
+	Id             int64  `json:"id" db:"Id"`
+	DatabaseId     int64  `json:"database_id" db:"DatabaseId"`
+	SourceNoteId   int64  `json:"source_note_id" db:"SourceNoteId"`
+	TargetTitle    string `json:"target_title" db:"TargetTitle"`
+	TargetTitleKey string `json:"-" db:"TargetTitleKey"`
+	TargetNoteId   *int64 `json:"target_note_id" db:"TargetNoteId"`
+	Alias          string `json:"alias,omitempty" db:"Alias"`
+	Occurrences    int    `json:"occurrences" db:"Occurrences"`
+}
+
+// NoteWikiLinkDetails - вики-ссылка вместе с заголовками заметок на обоих концах.
+type NoteWikiLinkDetails struct {
+	NoteWikiLink
+	SourceTitle     string  `json:"source_title" db:"SourceTitle"`
+	TargetNoteTitle *string `json:"target_note_title" db:"TargetNoteTitle"`
+}
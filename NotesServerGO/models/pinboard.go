@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// Pinboard представляет доску совместной БД. Карточки (PinboardNote) и связи между ними
+// принадлежат одной из досок; в каждой БД есть хотя бы одна доска (доска по умолчанию).
+type Pinboard struct {
+	Id         int64        `json:"id" db:"Id"`
+	DatabaseId int64        `json:"database_id" db:"DatabaseId"`
+	Name       string       `json:"name" db:"Name"`
+	Position   int          `json:"position" db:"Position"` // Порядок досок в списке (по возрастанию)
+	CreatedAt  FlexibleTime `json:"created_at" db:"CreatedAt"`
+	UpdatedAt  time.Time    `json:"-" db:"UpdatedAt"`
+}
@@ -14,6 +14,7 @@ type PinboardNote struct {
 	BackgroundColor int       `json:"background_color" db:"BackgroundColor"` // ARGB int
 	IconCodePoint   int       `json:"icon" db:"IconCodePoint"`               // Храним CodePoint иконки из Flutter (map['icon'])
 	DatabaseId      int64     `json:"database_id" db:"DatabaseId"`
+	PinboardId      int64     `json:"pinboard_id" db:"PinboardId"` // Доска карточки; 0 - доска по умолчанию
 	CreatedAt       time.Time `json:"-" db:"CreatedAt"`
 	UpdatedAt       time.Time `json:"-" db:"UpdatedAt"`
 }
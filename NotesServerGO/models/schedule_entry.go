@@ -1,17 +1,26 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
 
 // ScheduleEntry представляет запись в расписании.
 type ScheduleEntry struct {
 	Id                int64   `json:"id" db:"Id"`
-	Time              string  `json:"time" db:"Time"` // "HH:mm"
+	Time              string  `json:"time" db:"Time"` // "HH:mm - HH:mm" или "HH:mm"
 	Date              string  `json:"date" db:"Date"` // "yyyy-MM-dd"
 	Note              *string `json:"note,omitempty" db:"Note"`
 	DynamicFieldsJson *string `json:"dynamic_fields_json,omitempty" db:"DynamicFieldsJson"`
 	RecurrenceJson    *string `json:"recurrence_json,omitempty" db:"RecurrenceJson"` // Клиент присылает это поле
 	TagsJson          *string `json:"tags_json,omitempty" db:"TagsJson"`             // Теги для записи расписания
-	DatabaseId        int64   `json:"database_id" db:"DatabaseId"`                   // На клиенте String?, здесь int64
+	CategoryId        *int64  `json:"category_id,omitempty" db:"CategoryId"`
+	TimeZone          *string `json:"time_zone,omitempty" db:"TimeZone"`                 // Часовой пояс IANA; nil - часовой пояс совместной БД
+	AssigneeUserId    *int64  `json:"assignee_user_id,omitempty" db:"AssigneeUserId"`    // Ответственный участник БД
+	CreatedByUserId   *int64  `json:"created_by_user_id,omitempty" db:"CreatedByUserId"` // Автор записи; задается сервером
+	DatabaseId        int64   `json:"database_id" db:"DatabaseId"`                       // На клиенте String?, здесь int64
 	// OwnerUserId    int64     `json:"owner_user_id,omitempty" db:"OwnerUserId"` // Убрано, т.к. нет в клиентской модели ScheduleEntry
 	CreatedAt time.Time `json:"-" db:"CreatedAt"`
 	UpdatedAt time.Time `json:"-" db:"UpdatedAt"`
@@ -19,20 +28,104 @@ type ScheduleEntry struct {
 
 // RecurrenceType определяет тип повторения для пунктов расписания.
 // Эти константы соответствуют RecurrenceType enum из Flutter-приложения.
-// Используется для информации, на сервере хранится как часть RecurrenceJson.
-// const (
-// 	RecurrenceTypeNone    = 0
-// 	RecurrenceTypeDaily   = 1
-// 	RecurrenceTypeWeekly  = 2
-// 	RecurrenceTypeMonthly = 3
-// 	RecurrenceTypeYearly  = 4
-// )
-
-// Recurrence (вспомогательная структура, если понадобится на сервере для десериализации RecurrenceJson).
-// На данный момент сервер просто хранит и передает RecurrenceJson как строку.
-// type Recurrence struct {
-// 	Type      int     `json:"type"` // Соответствует RecurrenceType* константам
-// 	Interval  *int    `json:"interval,omitempty"`
-// 	EndDate   *string `json:"endDate,omitempty"` // "yyyy-MM-ddTHH:mm:ssZ" (ISO8601) или просто "yyyy-MM-dd"
-// 	Count     *int    `json:"count,omitempty"`
-// }
+type RecurrenceType int
+
+const (
+	RecurrenceTypeNone    RecurrenceType = 0
+	RecurrenceTypeDaily   RecurrenceType = 1
+	RecurrenceTypeWeekly  RecurrenceType = 2
+	RecurrenceTypeMonthly RecurrenceType = 3
+	RecurrenceTypeYearly  RecurrenceType = 4
+)
+
+// recurrenceTypeNames - строковые имена типов повторения. Клиент может прислать
+// как индекс enum, так и его имя ("weekly" или "RecurrenceType.weekly").
+var recurrenceTypeNames = map[string]RecurrenceType{
+	"none":    RecurrenceTypeNone,
+	"daily":   RecurrenceTypeDaily,
+	"weekly":  RecurrenceTypeWeekly,
+	"monthly": RecurrenceTypeMonthly,
+	"yearly":  RecurrenceTypeYearly,
+}
+
+// UnmarshalJSON принимает тип повторения в виде числа или строки.
+func (t *RecurrenceType) UnmarshalJSON(data []byte) error {
+	var index int
+	if err := json.Unmarshal(data, &index); err == nil {
+		if index < int(RecurrenceTypeNone) || index > int(RecurrenceTypeYearly) {
+			return fmt.Errorf("неизвестный тип повторения: %d", index)
+		}
+		*t = RecurrenceType(index)
+		return nil
+	}
+	var name string
+	if err := json.Unmarshal(data, &name); err != nil {
+		return fmt.Errorf("неверный формат типа повторения: %s", string(data))
+	}
+	name = strings.ToLower(strings.TrimPrefix(name, "RecurrenceType."))
+	value, ok := recurrenceTypeNames[name]
+	if !ok {
+		return fmt.Errorf("неизвестный тип повторения: %q", name)
+	}
+	*t = value
+	return nil
+}
+
+// Recurrence - правило повторения записи расписания, хранящееся в RecurrenceJson.
+// Соответствует модели Recurrence во Flutter-клиенте.
+type Recurrence struct {
+	Type     RecurrenceType `json:"type"`
+	Interval *int           `json:"interval,omitempty"` // По умолчанию 1
+	EndDate  *FlexibleTime  `json:"endDate,omitempty"`  // Включительно, учитывается только дата
+	Count    *int           `json:"count,omitempty"`    // Общее число повторений, включая первое
+}
+
+// IntervalOrDefault возвращает интервал повторения (не меньше 1).
+func (r *Recurrence) IntervalOrDefault() int {
+	if r.Interval == nil || *r.Interval < 1 {
+		return 1
+	}
+	return *r.Interval
+}
+
+// ParseTags разбирает TagsJson записи (JSON-массив строк). Пробелы по краям тегов обрезаются,
+// пустые теги и дубликаты пропускаются.
+func (e *ScheduleEntry) ParseTags() ([]string, error) {
+	if e.TagsJson == nil || *e.TagsJson == "" {
+		return nil, nil
+	}
+	var raw []string
+	if err := json.Unmarshal([]byte(*e.TagsJson), &raw); err != nil {
+		return nil, fmt.Errorf("неверный TagsJson записи %d: %w", e.Id, err)
+	}
+	seen := make(map[string]bool, len(raw))
+	var tags []string
+	for _, t := range raw {
+		t = NormalizeTag(t)
+		if t == "" || seen[t] {
+			continue
+		}
+		seen[t] = true
+		tags = append(tags, t)
+	}
+	return tags, nil
+}
+
+// ParseRecurrence разбирает RecurrenceJson записи. Для записей без повторения возвращает nil.
+func (e *ScheduleEntry) ParseRecurrence() (*Recurrence, error) {
+	if e.RecurrenceJson == nil {
+		return nil, nil
+	}
+	raw := strings.TrimSpace(*e.RecurrenceJson)
+	if raw == "" || raw == "null" || raw == "{}" {
+		return nil, nil
+	}
+	var recurrence Recurrence
+	if err := json.Unmarshal([]byte(raw), &recurrence); err != nil {
+		return nil, fmt.Errorf("неверный RecurrenceJson записи %d: %w", e.Id, err)
+	}
+	if recurrence.Type == RecurrenceTypeNone {
+		return nil, nil
+	}
+	return &recurrence, nil
+}
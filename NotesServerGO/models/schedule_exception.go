@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// Типы исключений из правила повторения.
+const (
+	ScheduleExceptionSkip   = "skip"   // Повторение отменено
+	ScheduleExceptionModify = "modify" // Повторение перенесено или изменено
+)
+
+// ScheduleEntryException - исключение для одного повторения повторяющейся записи расписания.
+// Повторение определяется исходной датой по правилу (OriginalDate); для типа "modify"
+// заполненные поля New* заменяют дату, время и текст этого повторения.
+type ScheduleEntryException struct {
+	Id              int64        `json:"id" db:"Id"`
+	ScheduleEntryId int64        `json:"schedule_entry_id" db:"ScheduleEntryId"`
+	DatabaseId      int64        `json:"database_id" db:"DatabaseId"`
+	OriginalDate    string       `json:"original_date" db:"OriginalDate"` // "yyyy-MM-dd"
+	Type            string       `json:"type" db:"Type"`                  // "skip" или "modify"
+	NewDate         *string      `json:"new_date,omitempty" db:"NewDate"` // "yyyy-MM-dd"
+	NewTime         *string      `json:"new_time,omitempty" db:"NewTime"` // "HH:mm - HH:mm"
+	NewNote         *string      `json:"new_note,omitempty" db:"NewNote"`
+	CreatedAt       FlexibleTime `json:"created_at" db:"CreatedAt"`
+	UpdatedAt       time.Time    `json:"-" db:"UpdatedAt"`
+}
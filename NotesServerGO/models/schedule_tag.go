@@ -0,0 +1,19 @@
+package models
+
+// ScheduleTag представляет тег записи расписания. Соответствует модели ScheduleTag во Flutter-клиенте.
+// Таблица ScheduleTags является индексом по ScheduleEntry.TagsJson и перестраивается при каждой записи.
+type ScheduleTag struct {
+	Id              int64        `json:"id" db:"Id"`
+	ScheduleEntryId int64        `json:"schedule_entry_id" db:"ScheduleEntryId"`
+	Tag             string       `json:"tag" db:"Tag"`
+	DatabaseId      int64        `json:"database_id" db:"DatabaseId"`
+	CreatedAt       FlexibleTime `json:"created_at" db:"CreatedAt"`
+}
+
+// ScheduleTagStats - статистика использования тега в расписании совместной БД.
+type ScheduleTagStats struct {
+	Tag       string `json:"tag" db:"Tag"`
+	Count     int    `json:"count" db:"Count"`
+	FirstDate string `json:"first_date" db:"FirstDate"` // "yyyy-MM-dd"
+	LastDate  string `json:"last_date" db:"LastDate"`   // "yyyy-MM-dd"
+}
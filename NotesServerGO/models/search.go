@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// SearchResult представляет одну найденную заметку при поиске по всем совместным БД пользователя.
+type SearchResult struct {
+	DatabaseID   int64     `json:"database_id"`
+	DatabaseName string    `json:"database_name"`
+	NoteID       int64     `json:"note_id"`
+	Title        string    `json:"title"`
+	FolderID     *int64    `json:"folder_id,omitempty"`
+	FolderPath   string    `json:"folder_path"` // Путь вида "Работа / Проекты", пустая строка для корня
+	Snippet      string    `json:"snippet"`
+	Score        float64   `json:"score"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// SearchResponse - ответ эндпоинта поиска.
+type SearchResponse struct {
+	Query   string         `json:"query"`
+	Total   int            `json:"total"` // Общее количество совпадений до применения limit
+	Results []SearchResult `json:"results"`
+}
@@ -4,14 +4,16 @@ import "time"
 
 // SharedDatabase представляет собой совместную базу данных.
 type SharedDatabase struct {
-	Id          int64     `json:"id" db:"Id"`
-	Name        string    `json:"name" db:"Name"`
-	OwnerUserId int64     `json:"owner_user_id" db:"OwnerUserId"` // Связь с Users.Id
-	CreatedAt   time.Time `json:"created_at" db:"CreatedAt"`
-	UpdatedAt   time.Time `json:"updated_at" db:"UpdatedAt"`
-	Version     string    `json:"version" db:"Version"`
-	IsActive    bool      `json:"is_active" db:"IsActive"`
-	LastSync    time.Time `json:"last_sync" db:"LastSync"`
+	Id          int64      `json:"id" db:"Id"`
+	Name        string     `json:"name" db:"Name"`
+	OwnerUserId int64      `json:"owner_user_id" db:"OwnerUserId"` // Связь с Users.Id
+	CreatedAt   time.Time  `json:"created_at" db:"CreatedAt"`
+	UpdatedAt   time.Time  `json:"updated_at" db:"UpdatedAt"`
+	Version     string     `json:"version" db:"Version"`
+	IsActive    bool       `json:"is_active" db:"IsActive"`
+	LastSync    time.Time  `json:"last_sync" db:"LastSync"`
+	TimeZone    string     `json:"time_zone" db:"TimeZone"`             // Часовой пояс IANA расписания; "" - часовой пояс сервера
+	DeletedAt   *time.Time `json:"deleted_at,omitempty" db:"DeletedAt"` // Время перемещения в корзину; nil - БД не удалена
 }
 
 // SharedDatabaseUserRole определяет роль пользователя в совместной базе данных.
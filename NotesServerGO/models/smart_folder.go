@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// SmartFolder представляет "умную папку" - сохраненный поисковый запрос совместной БД.
+// Содержимое умной папки вычисляется на сервере при каждом запросе.
+type SmartFolder struct {
+	Id              int64     `json:"id" db:"Id"`
+	DatabaseId      int64     `json:"database_id" db:"DatabaseId"`
+	Name            string    `json:"name" db:"Name"`
+	Query           string    `json:"query" db:"Query"` // Например, "tag:urgent updated:<7d"
+	Color           int       `json:"color" db:"Color"`
+	CreatedByUserId int64     `json:"created_by_user_id" db:"CreatedByUserId"`
+	CreatedAt       time.Time `json:"created_at" db:"CreatedAt"`
+	UpdatedAt       time.Time `json:"updated_at" db:"UpdatedAt"`
+}
@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// Типы элементов корзины.
+const (
+	TrashItemNote   = "note"
+	TrashItemFolder = "folder"
+)
+
+// TrashFolderRef - папка пути к удаленному элементу (от корневой папки к родительской)
+// в том виде, в котором она была на момент удаления.
+type TrashFolderRef struct {
+	Id    int64  `json:"id"`
+	Name  string `json:"name"`
+	Color int    `json:"color"`
+}
+
+// TrashItem - удаленная заметка или папка в корзине совместной БД. Содержимое элемента (для заметки -
+// вместе с тегами и записями изображений; файлы изображений остаются на диске) хранится в PayloadJson,
+// чтобы элемент можно было восстановить до истечения срока хранения.
+type TrashItem struct {
+	Id              int64            `json:"id" db:"Id"`
+	DatabaseId      int64            `json:"database_id" db:"DatabaseId"`
+	ItemType        string           `json:"item_type" db:"ItemType"` // note, folder
+	OriginalId      int64            `json:"original_id" db:"OriginalId"`
+	Title           string           `json:"title" db:"Title"` // Заголовок заметки или название папки
+	FolderPathJson  string           `json:"-" db:"FolderPathJson"`
+	PayloadJson     string           `json:"-" db:"PayloadJson"`
+	DeletedByUserId *int64           `json:"deleted_by_user_id,omitempty" db:"DeletedByUserId"`
+	DeletedAt       FlexibleTime     `json:"deleted_at" db:"DeletedAt"`
+	FolderPath      []TrashFolderRef `json:"folder_path" db:"-"`
+	ExpiresAt       *time.Time       `json:"expires_at" db:"-"` // nil - хранится без ограничения срока
+}
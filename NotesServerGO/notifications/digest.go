@@ -0,0 +1,294 @@
+package notifications
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"notes_server_go/data"
+	"notes_server_go/models"
+	"notes_server_go/schedule"
+)
+
+// MaxDigestNotes ограничивает количество измененных заметок одной совместной БД в сводке.
+const MaxDigestNotes = 20
+
+// Digest - ежедневная сводка пользователя: повторения записей расписания на день Date
+// и заметки, измененные после NotesSince, по всем совместным БД пользователя.
+type Digest struct {
+	Recipient   Recipient        `json:"recipient"`
+	Date        string           `json:"date"`      // "yyyy-MM-dd" в часовом поясе TimeZone
+	TimeZone    string           `json:"time_zone"` // Часовой пояс, в котором показано время
+	NotesSince  time.Time        `json:"notes_since"`
+	GeneratedAt time.Time        `json:"generated_at"`
+	Databases   []DigestDatabase `json:"databases"`
+
+	location *time.Location
+}
+
+// DigestDatabase - часть сводки по одной совместной БД.
+type DigestDatabase struct {
+	Id          int64              `json:"id"`
+	Name        string             `json:"name"`
+	Occurrences []DigestOccurrence `json:"occurrences"`
+	Notes       []DigestNote       `json:"notes"`
+}
+
+// DigestOccurrence - повторение записи расписания в сводке. Time - время в часовом поясе сводки.
+type DigestOccurrence struct {
+	EntryId int64      `json:"entry_id"`
+	Date    string     `json:"date"` // Дата повторения по записи (в часовом поясе записи)
+	Time    string     `json:"time"` // "HH:mm - HH:mm" в часовом поясе сводки
+	Start   *time.Time `json:"start,omitempty"`
+	Title   string     `json:"title"`
+	Note    string     `json:"note,omitempty"`
+}
+
+// DigestNote - заметка, измененная после предыдущей сводки.
+type DigestNote struct {
+	Id        int64     `json:"id"`
+	Title     string    `json:"title"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Created   bool      `json:"created"` // Заметка создана после предыдущей сводки
+}
+
+// BuildDigest собирает сводку пользователя userID на день day в часовом поясе loc.
+// В сводку попадают повторения, которые начинаются в этот день по часовому поясу loc
+// (записи в других часовых поясах пересчитываются), и заметки, измененные после notesSince.
+// Совместные БД без повторений и заметок в сводку не включаются.
+func BuildDigest(userID int64, loc *time.Location, day time.Time, notesSince time.Time) (*Digest, error) {
+	user, err := data.GetUserByID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("BuildDigest: ошибка получения пользователя %d: %w", userID, err)
+	}
+	if user == nil {
+		return nil, fmt.Errorf("BuildDigest: пользователь %d не найден", userID)
+	}
+	day = day.In(loc)
+	digest := &Digest{
+		Recipient:   Recipient{UserId: user.ID, Email: user.Email, DisplayName: user.DisplayName},
+		Date:        day.Format(schedule.DateLayout),
+		TimeZone:    loc.String(),
+		NotesSince:  notesSince,
+		GeneratedAt: time.Now(),
+		Databases:   []DigestDatabase{},
+		location:    loc,
+	}
+
+	dbs, err := data.GetSharedDatabasesForUser(userID)
+	if err != nil {
+		return nil, fmt.Errorf("BuildDigest: %w", err)
+	}
+	for i := range dbs {
+		part, err := buildDigestDatabase(&dbs[i], digest.Date, loc, notesSince)
+		if err != nil {
+			return nil, fmt.Errorf("BuildDigest: БД %d: %w", dbs[i].Id, err)
+		}
+		if len(part.Occurrences) > 0 || len(part.Notes) > 0 {
+			digest.Databases = append(digest.Databases, *part)
+		}
+	}
+	return digest, nil
+}
+
+// buildDigestDatabase собирает часть сводки по одной совместной БД.
+func buildDigestDatabase(db *models.SharedDatabase, date string, loc *time.Location, notesSince time.Time) (*DigestDatabase, error) {
+	part := &DigestDatabase{Id: db.Id, Name: db.Name, Occurrences: []DigestOccurrence{}, Notes: []DigestNote{}}
+
+	entries, err := data.GetScheduleEntriesByDBID(db.Id)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) > 0 {
+		exceptions, err := data.GetScheduleExceptionsBySharedDBID(db.Id)
+		if err != nil {
+			return nil, err
+		}
+		// Запас в сутки с каждой стороны: у записей в других часовых поясах дата может отличаться
+		day, _ := schedule.ParseDate(date)
+		occurrences := schedule.ExpandAll(entries, exceptions, day.AddDate(0, 0, -1), day.AddDate(0, 0, 1))
+		schedule.Localize(occurrences, schedule.DatabaseLocation(db), loc)
+		for _, occurrence := range occurrences {
+			localDate, localTime := occurrence.LocalDate, occurrence.LocalTime
+			if occurrence.Start == nil {
+				localDate, localTime = occurrence.Date, occurrence.Time // Время записи некорректно - показываем как есть
+			}
+			if localDate != date {
+				continue
+			}
+			item := DigestOccurrence{EntryId: occurrence.EntryId, Date: occurrence.Date, Time: localTime, Start: occurrence.Start, Title: "Запись расписания"}
+			if occurrence.Note != nil && strings.TrimSpace(*occurrence.Note) != "" {
+				item.Note = strings.TrimSpace(*occurrence.Note)
+				item.Title = firstLine(item.Note)
+			}
+			part.Occurrences = append(part.Occurrences, item)
+		}
+		sort.SliceStable(part.Occurrences, func(i, j int) bool {
+			return part.Occurrences[i].Time < part.Occurrences[j].Time
+		})
+	}
+
+	notes, err := data.GetNotesUpdatedSince(db.Id, notesSince, MaxDigestNotes)
+	if err != nil {
+		return nil, err
+	}
+	for _, note := range notes {
+		title := strings.TrimSpace(note.Title)
+		if title == "" {
+			title = "Без названия"
+		}
+		part.Notes = append(part.Notes, DigestNote{Id: note.ID, Title: title, UpdatedAt: note.UpdatedAt, Created: note.CreatedAt.After(notesSince)})
+	}
+	return part, nil
+}
+
+// IsEmpty возвращает true, если в сводке нет ни повторений, ни заметок.
+func (d *Digest) IsEmpty() bool {
+	return len(d.Databases) == 0
+}
+
+// OccurrenceCount возвращает количество повторений во всех БД сводки.
+func (d *Digest) OccurrenceCount() int {
+	count := 0
+	for _, part := range d.Databases {
+		count += len(part.Occurrences)
+	}
+	return count
+}
+
+// Subject возвращает тему письма со сводкой.
+func (d *Digest) Subject() string {
+	return fmt.Sprintf("Сводка на %s: записей расписания - %d", d.dateTitle(), d.OccurrenceCount())
+}
+
+// Text возвращает сводку обычным текстом.
+func (d *Digest) Text() (string, error) {
+	var buf bytes.Buffer
+	if err := digestTextTemplate.Execute(&buf, d.view()); err != nil {
+		return "", fmt.Errorf("Digest.Text: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// HTML возвращает сводку в HTML (для писем).
+func (d *Digest) HTML() (string, error) {
+	var buf bytes.Buffer
+	if err := digestHTMLTemplate.Execute(&buf, d.view()); err != nil {
+		return "", fmt.Errorf("Digest.HTML: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// Message формирует письмо со сводкой в текстовом и HTML-вариантах.
+func (d *Digest) Message() (*MailMessage, error) {
+	text, err := d.Text()
+	if err != nil {
+		return nil, err
+	}
+	html, err := d.HTML()
+	if err != nil {
+		return nil, err
+	}
+	return &MailMessage{To: d.Recipient, Subject: d.Subject(), Text: text, HTML: html}, nil
+}
+
+func (d *Digest) dateTitle() string {
+	day, err := time.Parse(schedule.DateLayout, d.Date)
+	if err != nil {
+		return d.Date
+	}
+	return day.Format("02.01.2006")
+}
+
+// digestView - данные для шаблонов сводки с уже отформатированными датами.
+type digestView struct {
+	Name      string
+	DateTitle string
+	TimeZone  string
+	Databases []digestDatabaseView
+}
+
+type digestDatabaseView struct {
+	Name        string
+	Occurrences []DigestOccurrence
+	Notes       []digestNoteView
+}
+
+type digestNoteView struct {
+	Title   string
+	When    string
+	Created bool
+}
+
+func (d *Digest) view() digestView {
+	loc := d.location
+	if loc == nil {
+		loc = time.Local
+	}
+	view := digestView{Name: d.Recipient.DisplayName, DateTitle: d.dateTitle(), TimeZone: d.TimeZone}
+	for _, part := range d.Databases {
+		partView := digestDatabaseView{Name: part.Name, Occurrences: part.Occurrences}
+		for _, note := range part.Notes {
+			partView.Notes = append(partView.Notes, digestNoteView{
+				Title:   note.Title,
+				When:    note.UpdatedAt.In(loc).Format("02.01 15:04"),
+				Created: note.Created,
+			})
+		}
+		view.Databases = append(view.Databases, partView)
+	}
+	return view
+}
+
+// firstLine возвращает первую строку текста.
+func firstLine(text string) string {
+	if i := strings.IndexByte(text, '\n'); i >= 0 {
+		return strings.TrimSpace(text[:i])
+	}
+	return text
+}
+
+var digestTextTemplate = texttemplate.Must(texttemplate.New("digest.txt").Parse(
+	`{{if .Name}}{{.Name}}, д{{else}}Д{{end}}обрый день!
+Сводка на {{.DateTitle}} (время: {{.TimeZone}}).
+{{range .Databases}}
+== {{.Name}} ==
+{{- if .Occurrences}}
+Расписание:
+{{- range .Occurrences}}
+  {{.Time}}  {{.Title}}
+{{- end}}
+{{- end}}
+{{- if .Notes}}
+Измененные заметки:
+{{- range .Notes}}
+  {{.Title}} - {{if .Created}}создана{{else}}изменена{{end}} {{.When}}
+{{- end}}
+{{- end}}
+{{else}}
+На сегодня записей нет, заметки не менялись.
+{{end}}`))
+
+var digestHTMLTemplate = htmltemplate.Must(htmltemplate.New("digest.html").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>Сводка на {{.DateTitle}}</title></head>
+<body style="font-family: sans-serif; color: #222;">
+<p>{{if .Name}}{{.Name}}, д{{else}}Д{{end}}обрый день!</p>
+<p>Сводка на <b>{{.DateTitle}}</b> <span style="color: #888;">(время: {{.TimeZone}})</span></p>
+{{range .Databases}}
+<h3 style="margin-bottom: 4px;">{{.Name}}</h3>
+{{if .Occurrences}}<table cellpadding="4" style="border-collapse: collapse;">
+{{range .Occurrences}}<tr><td style="white-space: nowrap; color: #555;">{{.Time}}</td><td>{{.Title}}</td></tr>
+{{end}}</table>{{end}}
+{{if .Notes}}<p style="margin-bottom: 2px;">Измененные заметки:</p>
+<ul style="margin-top: 0;">
+{{range .Notes}}<li>{{.Title}} <span style="color: #888;">- {{if .Created}}создана{{else}}изменена{{end}} {{.When}}</span></li>
+{{end}}</ul>{{end}}
+{{else}}
+<p>На сегодня записей нет, заметки не менялись.</p>
+{{end}}
+</body></html>
+`))
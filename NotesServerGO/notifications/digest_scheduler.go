@@ -0,0 +1,167 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"notes_server_go/data"
+	"notes_server_go/models"
+	"notes_server_go/schedule"
+)
+
+// DefaultDigestNotesWindow - за какой период показываются измененные заметки в первой сводке пользователя.
+const DefaultDigestNotesWindow = 24 * time.Hour
+
+// DigestScheduler периодически рассылает ежедневные сводки: пользователь получает сводку, когда по его
+// часовому поясу наступает время SendTime из подписки. Сводка за день захватывается в подписке до отправки,
+// поэтому после перезапуска сервера она не отправляется повторно. Если сервер был остановлен в момент
+// отправки, сводка уходит после запуска в тот же день.
+type DigestScheduler struct {
+	Mailer       MailSender
+	PollInterval time.Duration
+}
+
+// NewDigestSchedulerFromEnv создает планировщик сводок по переменным окружения:
+//
+//	DIGEST_POLL_SECONDS - период проверки (по умолчанию 60)
+//	SMTP_* - отправка писем, см. SMTPSenderFromEnv
+//
+// Возвращает nil без ошибки, если отправка писем не настроена.
+func NewDigestSchedulerFromEnv() (*DigestScheduler, error) {
+	sender, err := SMTPSenderFromEnv()
+	if err != nil || sender == nil {
+		return nil, err
+	}
+	scheduler := &DigestScheduler{Mailer: sender, PollInterval: DefaultPollInterval}
+	if value := strings.TrimSpace(os.Getenv("DIGEST_POLL_SECONDS")); value != "" {
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds < 5 {
+			return nil, fmt.Errorf("неверное значение DIGEST_POLL_SECONDS: %q (не меньше 5)", value)
+		}
+		scheduler.PollInterval = time.Duration(seconds) * time.Second
+	}
+	return scheduler, nil
+}
+
+// Run выполняет проверку сразу и затем каждые PollInterval, пока не отменен ctx.
+func (s *DigestScheduler) Run(ctx context.Context) {
+	log.Printf("Планировщик ежедневных сводок запущен: проверка каждые %v", s.PollInterval)
+	ticker := time.NewTicker(s.PollInterval)
+	defer ticker.Stop()
+	for {
+		if err := s.RunOnce(ctx, time.Now()); err != nil {
+			log.Printf("Планировщик сводок: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce отправляет сводки пользователям, у которых к моменту now наступило время отправки
+// и сводка за текущий день еще не отправлена.
+func (s *DigestScheduler) RunOnce(ctx context.Context, now time.Time) error {
+	subscriptions, err := data.GetEnabledDigestSubscriptions()
+	if err != nil {
+		return err
+	}
+	for i := range subscriptions {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		subscription := &subscriptions[i]
+		loc := DigestLocation(subscription)
+		localNow := now.In(loc)
+		today := localNow.Format(schedule.DateLayout)
+		if subscription.LastSentDate != nil && *subscription.LastSentDate >= today {
+			continue
+		}
+		if !sendTimeReached(subscription.SendTime, localNow) {
+			continue
+		}
+		if err := s.deliver(ctx, subscription, loc, localNow); err != nil {
+			log.Printf("Планировщик сводок: пользователь %d: %v", subscription.UserId, err)
+		}
+	}
+	return nil
+}
+
+// deliver захватывает сводку за сегодняшний день подписки и отправляет ее.
+// При ошибке отправки захват снимается, и попытка повторяется при следующей проверке.
+func (s *DigestScheduler) deliver(ctx context.Context, subscription *models.DigestSubscription, loc *time.Location, localNow time.Time) error {
+	today := localNow.Format(schedule.DateLayout)
+	claimed, err := data.ClaimDigest(subscription.Id, today)
+	if err != nil || !claimed {
+		return err
+	}
+
+	digest, err := BuildDigest(subscription.UserId, loc, localNow, DigestNotesSince(subscription, localNow))
+	if err == nil && digest.IsEmpty() && !subscription.SendEmpty {
+		log.Printf("Сводка пользователя %d на %s пуста и не отправлена", subscription.UserId, today)
+		return data.MarkDigestSent(subscription.Id, localNow)
+	}
+	if err == nil {
+		err = SendDigest(ctx, s.Mailer, digest)
+	}
+	if err != nil {
+		if releaseErr := data.ReleaseDigest(subscription.Id, today, subscription.LastSentDate); releaseErr != nil {
+			log.Printf("Планировщик сводок: %v", releaseErr)
+		}
+		return err
+	}
+	log.Printf("Отправлена сводка пользователю %d на %s", subscription.UserId, today)
+	return data.MarkDigestSent(subscription.Id, localNow)
+}
+
+// SendDigest отправляет сводку письмом через mailer.
+func SendDigest(ctx context.Context, mailer MailSender, digest *Digest) error {
+	if digest.Recipient.Email == "" {
+		return fmt.Errorf("у пользователя %d не указан адрес электронной почты", digest.Recipient.UserId)
+	}
+	message, err := digest.Message()
+	if err != nil {
+		return err
+	}
+	sendCtx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+	return mailer.SendMail(sendCtx, message)
+}
+
+// DigestLocation возвращает часовой пояс подписки (часовой пояс сервера, если он не задан или некорректен).
+func DigestLocation(subscription *models.DigestSubscription) *time.Location {
+	if subscription == nil || subscription.TimeZone == "" {
+		return time.Local
+	}
+	loc, err := schedule.LoadTimeZone(subscription.TimeZone)
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
+
+// DigestNotesSince возвращает момент, после которого измененные заметки попадают в сводку:
+// время предыдущей сводки или DefaultDigestNotesWindow назад, если сводок еще не было.
+func DigestNotesSince(subscription *models.DigestSubscription, now time.Time) time.Time {
+	if subscription != nil && subscription.LastSentAt != nil && subscription.LastSentAt.Before(now) {
+		return *subscription.LastSentAt
+	}
+	return now.Add(-DefaultDigestNotesWindow)
+}
+
+// sendTimeReached проверяет, что время localNow не раньше sendTime ("HH:mm") того же дня.
+func sendTimeReached(sendTime string, localNow time.Time) bool {
+	timeRange, err := schedule.ParseTimeRange(sendTime)
+	if err != nil {
+		return false
+	}
+	midnight := time.Date(localNow.Year(), localNow.Month(), localNow.Day(), 0, 0, 0, 0, localNow.Location())
+	start, _ := timeRange.At(midnight)
+	return !localNow.Before(start)
+}
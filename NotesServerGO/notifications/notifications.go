@@ -0,0 +1,73 @@
+// Package notifications доставляет напоминания о записях расписания (webhook, SMTP) и ежедневные сводки
+// (письмами) и содержит фоновые планировщики, которые их рассылают.
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Reminder - напоминание о предстоящем повторении записи расписания совместной БД.
+type Reminder struct {
+	DatabaseId   int64       `json:"database_id"`
+	DatabaseName string      `json:"database_name"`
+	EntryId      int64       `json:"entry_id"`
+	Date         string      `json:"date"`          // Дата повторения, "yyyy-MM-dd"
+	OriginalDate string      `json:"original_date"` // Дата по правилу повторения
+	Time         string      `json:"time"`          // "HH:mm - HH:mm"
+	TimeZone     string      `json:"time_zone"`     // Часовой пояс, в котором указаны Date и Time
+	Note         string      `json:"note,omitempty"`
+	StartsAt     time.Time   `json:"starts_at"`
+	Recipients   []Recipient `json:"recipients"`
+}
+
+// Recipient - участник совместной БД, которому адресовано напоминание.
+type Recipient struct {
+	UserId      int64  `json:"user_id"`
+	Email       string `json:"email"`
+	DisplayName string `json:"display_name,omitempty"`
+}
+
+// Sender - способ доставки напоминаний.
+type Sender interface {
+	// Channel возвращает имя канала доставки; отправленные напоминания учитываются отдельно для каждого канала.
+	Channel() string
+	// Send доставляет напоминание. Ошибка означает, что доставку нужно повторить позже.
+	Send(ctx context.Context, reminder *Reminder) error
+}
+
+// RecipientSender - способ доставки, при котором каждый получатель получает напоминание отдельно.
+// Планировщик учитывает доставку каждому получателю и при повторной попытке пропускает тех, кому
+// напоминание уже доставлено.
+type RecipientSender interface {
+	Sender
+	// SendTo доставляет напоминание одному получателю.
+	SendTo(ctx context.Context, reminder *Reminder, recipient Recipient) error
+}
+
+// Subject возвращает тему напоминания.
+func (r *Reminder) Subject() string {
+	title := firstLine(r.Note)
+	if title == "" {
+		title = "Запись расписания"
+	}
+	return fmt.Sprintf("Напоминание: %s - %s", title, r.StartsAt.Format("02.01.2006 15:04"))
+}
+
+// Text возвращает текст напоминания.
+func (r *Reminder) Text() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "База данных: %s\n", r.DatabaseName)
+	fmt.Fprintf(&sb, "Дата: %s\n", r.StartsAt.Format("02.01.2006"))
+	fmt.Fprintf(&sb, "Время: %s", r.Time)
+	if r.TimeZone != "" && r.TimeZone != "Local" {
+		fmt.Fprintf(&sb, " (%s)", r.TimeZone)
+	}
+	sb.WriteString("\n")
+	if r.Note != "" {
+		fmt.Fprintf(&sb, "\n%s\n", r.Note)
+	}
+	return sb.String()
+}
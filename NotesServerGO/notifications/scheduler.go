@@ -0,0 +1,306 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"notes_server_go/data"
+	"notes_server_go/models"
+	"notes_server_go/schedule"
+)
+
+// Значения по умолчанию для настроек планировщика.
+const (
+	DefaultLeadTime     = 15 * time.Minute
+	DefaultPollInterval = time.Minute
+	sendTimeout         = 30 * time.Second
+	// sentRetention - сколько хранится учет отправленных напоминаний после начала повторения.
+	sentRetention = 30 * 24 * time.Hour
+)
+
+// Scheduler периодически находит повторения записей расписания, до начала которых осталось
+// не больше LeadTime, и рассылает напоминания через Senders. Каждое напоминание захватывается
+// в SentReminders до отправки, поэтому после перезапуска сервера оно не отправляется повторно.
+// Пропущенные за время остановки сервера напоминания (повторение уже началось) не отправляются.
+type Scheduler struct {
+	Senders      []Sender
+	LeadTime     time.Duration
+	PollInterval time.Duration
+	Location     *time.Location // Часовой пояс записей БД без собственного часового пояса; по умолчанию time.Local
+}
+
+// NewSchedulerFromEnv создает планировщик по переменным окружения:
+//
+//	REMINDER_LEAD_MINUTES   - за сколько минут до начала напоминать (по умолчанию 15)
+//	REMINDER_POLL_SECONDS   - период проверки (по умолчанию 60)
+//	REMINDER_WEBHOOK_URL    - URL вебхука; REMINDER_WEBHOOK_SECRET - секрет подписи
+//	SMTP_HOST, SMTP_PORT (587), SMTP_USERNAME, SMTP_PASSWORD, SMTP_FROM - отправка писем участникам БД
+//
+// Возвращает nil без ошибки, если не настроен ни один способ доставки.
+func NewSchedulerFromEnv() (*Scheduler, error) {
+	scheduler := &Scheduler{LeadTime: DefaultLeadTime, PollInterval: DefaultPollInterval, Location: time.Local}
+
+	if value := strings.TrimSpace(os.Getenv("REMINDER_LEAD_MINUTES")); value != "" {
+		minutes, err := strconv.Atoi(value)
+		if err != nil || minutes < 0 || minutes > 7*24*60 {
+			return nil, fmt.Errorf("неверное значение REMINDER_LEAD_MINUTES: %q (от 0 до 10080)", value)
+		}
+		scheduler.LeadTime = time.Duration(minutes) * time.Minute
+	}
+	if value := strings.TrimSpace(os.Getenv("REMINDER_POLL_SECONDS")); value != "" {
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds < 5 {
+			return nil, fmt.Errorf("неверное значение REMINDER_POLL_SECONDS: %q (не меньше 5)", value)
+		}
+		scheduler.PollInterval = time.Duration(seconds) * time.Second
+	}
+
+	if url := strings.TrimSpace(os.Getenv("REMINDER_WEBHOOK_URL")); url != "" {
+		scheduler.Senders = append(scheduler.Senders, NewWebhookSender(url, os.Getenv("REMINDER_WEBHOOK_SECRET")))
+	}
+	sender, err := SMTPSenderFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	if sender != nil {
+		scheduler.Senders = append(scheduler.Senders, sender)
+	}
+
+	if len(scheduler.Senders) == 0 {
+		return nil, nil
+	}
+	return scheduler, nil
+}
+
+// SMTPSenderFromEnv создает отправителя писем по переменным SMTP_HOST, SMTP_PORT (587), SMTP_USERNAME,
+// SMTP_PASSWORD и SMTP_FROM. Возвращает nil без ошибки, если SMTP_HOST не задан.
+func SMTPSenderFromEnv() (*SMTPSender, error) {
+	host := strings.TrimSpace(os.Getenv("SMTP_HOST"))
+	if host == "" {
+		return nil, nil
+	}
+	sender := &SMTPSender{
+		Host:     host,
+		Port:     strings.TrimSpace(os.Getenv("SMTP_PORT")),
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     strings.TrimSpace(os.Getenv("SMTP_FROM")),
+	}
+	if sender.Port == "" {
+		sender.Port = "587"
+	}
+	if sender.From == "" {
+		sender.From = sender.Username
+	}
+	if sender.From == "" {
+		return nil, fmt.Errorf("для отправки писем нужно указать SMTP_FROM или SMTP_USERNAME")
+	}
+	return sender, nil
+}
+
+// Run выполняет проверку сразу и затем каждые PollInterval, пока не отменен ctx.
+func (s *Scheduler) Run(ctx context.Context) {
+	channels := make([]string, len(s.Senders))
+	for i, sender := range s.Senders {
+		channels[i] = sender.Channel()
+	}
+	log.Printf("Планировщик напоминаний запущен: за %v до начала, проверка каждые %v, каналы: %s",
+		s.LeadTime, s.PollInterval, strings.Join(channels, ", "))
+
+	ticker := time.NewTicker(s.PollInterval)
+	defer ticker.Stop()
+	for {
+		if err := s.RunOnce(ctx, time.Now()); err != nil {
+			log.Printf("Планировщик напоминаний: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce рассылает напоминания о повторениях, которые начнутся в течение LeadTime после now.
+func (s *Scheduler) RunOnce(ctx context.Context, now time.Time) error {
+	dbs, err := data.GetActiveSharedDatabases()
+	if err != nil {
+		return err
+	}
+	for i := range dbs {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err := s.processDatabase(ctx, &dbs[i], now); err != nil {
+			log.Printf("Планировщик напоминаний: БД %d: %v", dbs[i].Id, err)
+		}
+	}
+	if _, err := data.PurgeSentReminders(now.Add(-sentRetention)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// processDatabase рассылает напоминания по записям расписания одной совместной БД.
+func (s *Scheduler) processDatabase(ctx context.Context, db *models.SharedDatabase, now time.Time) error {
+	entries, err := data.GetScheduleEntriesByDBID(db.Id)
+	if err != nil || len(entries) == 0 {
+		return err
+	}
+	exceptions, err := data.GetScheduleExceptionsBySharedDBID(db.Id)
+	if err != nil {
+		return err
+	}
+
+	dbLoc := s.location()
+	if db.TimeZone != "" {
+		dbLoc = schedule.DatabaseLocation(db)
+	}
+	// Запас в сутки с каждой стороны покрывает записи в других часовых поясах
+	localNow := now.In(dbLoc)
+	from := localNow.AddDate(0, 0, -1)
+	to := localNow.Add(s.LeadTime).AddDate(0, 0, 1)
+	var recipients []Recipient
+	recipientsLoaded := false
+
+	for _, occurrence := range schedule.ExpandAll(entries, exceptions, from, to) {
+		loc := schedule.EntryLocation(occurrence.Entry, dbLoc)
+		startsAt, ok := occurrenceStart(occurrence, loc)
+		if !ok || !now.Before(startsAt) || now.Before(startsAt.Add(-s.LeadTime)) {
+			continue
+		}
+		if !recipientsLoaded {
+			if recipients, err = loadRecipients(db.Id); err != nil {
+				return err
+			}
+			recipientsLoaded = true
+		}
+
+		reminder := &Reminder{
+			DatabaseId:   db.Id,
+			DatabaseName: db.Name,
+			EntryId:      occurrence.EntryId,
+			Date:         occurrence.Date,
+			OriginalDate: occurrence.OriginalDate,
+			Time:         occurrence.Time,
+			TimeZone:     loc.String(),
+			StartsAt:     startsAt,
+			Recipients:   recipients,
+		}
+		if occurrence.Note != nil {
+			reminder.Note = *occurrence.Note
+		}
+		for _, sender := range s.Senders {
+			s.deliver(ctx, sender, reminder)
+		}
+	}
+	return nil
+}
+
+// deliver захватывает напоминание для канала отправителя и отправляет его.
+// При ошибке отправки захват снимается, и попытка повторяется при следующей проверке.
+func (s *Scheduler) deliver(ctx context.Context, sender Sender, reminder *Reminder) {
+	channel := sender.Channel()
+	claimed, err := data.ClaimReminder(reminder.DatabaseId, reminder.EntryId, reminder.OriginalDate, channel, reminder.StartsAt)
+	if err != nil {
+		log.Printf("Планировщик напоминаний: %v", err)
+		return
+	}
+	if !claimed {
+		return // Уже отправлено
+	}
+
+	sendCtx, cancel := context.WithTimeout(ctx, sendTimeout)
+	if recipientSender, ok := sender.(RecipientSender); ok {
+		err = s.deliverToRecipients(sendCtx, recipientSender, reminder)
+	} else {
+		err = sender.Send(sendCtx, reminder)
+	}
+	cancel()
+	if err != nil {
+		log.Printf("Планировщик напоминаний: не удалось отправить напоминание о записи %d на %s (%s): %v",
+			reminder.EntryId, reminder.OriginalDate, channel, err)
+		if err := data.ReleaseReminder(reminder.EntryId, reminder.OriginalDate, channel); err != nil {
+			log.Printf("Планировщик напоминаний: %v", err)
+		}
+		return
+	}
+	if err := data.MarkReminderSent(reminder.EntryId, reminder.OriginalDate, channel); err != nil {
+		log.Printf("Планировщик напоминаний: %v", err)
+	}
+	log.Printf("Отправлено напоминание о записи %d на %s (%s), БД %d", reminder.EntryId, reminder.Date, channel, reminder.DatabaseId)
+}
+
+// deliverToRecipients отправляет напоминание тем получателям, которым оно еще не доставлено, и учитывает
+// каждую доставку. Ошибка возвращается, если хотя бы одному получателю отправить не удалось: тогда захват
+// снимается, и при следующей проверке письма получат только оставшиеся.
+func (s *Scheduler) deliverToRecipients(ctx context.Context, sender RecipientSender, reminder *Reminder) error {
+	channel := sender.Channel()
+	delivered, err := data.GetDeliveredReminderRecipients(reminder.EntryId, reminder.OriginalDate, channel)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, recipient := range reminder.Recipients {
+		if delivered[recipient.UserId] {
+			continue
+		}
+		if ctx.Err() != nil {
+			return errors.Join(append(errs, ctx.Err())...)
+		}
+		if err := sender.SendTo(ctx, reminder, recipient); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		err := data.MarkReminderRecipientDelivered(reminder.DatabaseId, reminder.EntryId, reminder.OriginalDate, channel, recipient.UserId, reminder.StartsAt)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// occurrenceStart возвращает время начала повторения, время которого указано в часовом поясе loc.
+// ok = false, если время записи не указано или некорректно.
+func occurrenceStart(occurrence schedule.Occurrence, loc *time.Location) (time.Time, bool) {
+	date, err := schedule.ParseDate(occurrence.Date)
+	if err != nil {
+		return time.Time{}, false
+	}
+	timeRange, err := schedule.ParseTimeRange(occurrence.Time)
+	if err != nil {
+		return time.Time{}, false
+	}
+	start, _ := timeRange.At(time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, loc))
+	return start, true
+}
+
+func (s *Scheduler) location() *time.Location {
+	if s.Location == nil {
+		return time.Local
+	}
+	return s.Location
+}
+
+// loadRecipients возвращает участников совместной БД с адресами электронной почты.
+func loadRecipients(sharedDbID int64) ([]Recipient, error) {
+	users, err := data.GetUsersInSharedDatabaseWithDetails(sharedDbID)
+	if err != nil {
+		return nil, err
+	}
+	recipients := make([]Recipient, 0, len(users))
+	for _, user := range users {
+		recipient := Recipient{UserId: user.UserId, Email: user.Email}
+		if user.DisplayName != nil {
+			recipient.DisplayName = *user.DisplayName
+		}
+		recipients = append(recipients, recipient)
+	}
+	return recipients, nil
+}
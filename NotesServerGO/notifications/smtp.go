@@ -0,0 +1,192 @@
+package notifications
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// mailTimeout - ограничение на отправку одного письма, если у контекста нет своего срока.
+const mailTimeout = time.Minute
+
+// MailMessage - письмо одному получателю. HTML необязателен: если он задан, письмо отправляется
+// в двух вариантах (multipart/alternative), и почтовый клиент сам выбирает, какой показать.
+type MailMessage struct {
+	To      Recipient
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// MailSender - способ отправки писем (ежедневные сводки). Реализация по умолчанию - SMTPSender.
+type MailSender interface {
+	SendMail(ctx context.Context, message *MailMessage) error
+}
+
+// SMTPSender отправляет напоминания письмами участникам совместной БД.
+// Реализует также MailSender для произвольных писем.
+type SMTPSender struct {
+	Host     string
+	Port     string
+	Username string // Если пустой, отправка без авторизации
+	Password string
+	From     string
+}
+
+// Channel реализует Sender.
+func (s *SMTPSender) Channel() string {
+	return "smtp"
+}
+
+// Send реализует Sender. Каждому получателю отправляется отдельное письмо, чтобы не раскрывать адреса участников.
+// Ошибка возвращается, если письмо не удалось отправить хотя бы одному получателю.
+func (s *SMTPSender) Send(ctx context.Context, reminder *Reminder) error {
+	var errs []error
+	for _, recipient := range reminder.Recipients {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err := s.SendTo(ctx, reminder, recipient); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// SendTo реализует RecipientSender. Участники без адреса электронной почты пропускаются.
+func (s *SMTPSender) SendTo(ctx context.Context, reminder *Reminder, recipient Recipient) error {
+	if recipient.Email == "" {
+		return nil
+	}
+	return s.SendMail(ctx, &MailMessage{To: recipient, Subject: reminder.Subject(), Text: reminder.Text()})
+}
+
+// SendMail реализует MailSender. Соединение ограничено сроком ctx (или mailTimeout) и закрывается при отмене ctx.
+func (s *SMTPSender) SendMail(ctx context.Context, message *MailMessage) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if message.To.Email == "" {
+		return fmt.Errorf("SMTPSender: не указан адрес получателя")
+	}
+	if err := s.deliver(ctx, message.To.Email, s.buildMessage(message)); err != nil {
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		return fmt.Errorf("SMTPSender: ошибка отправки письма %s: %w", message.To.Email, err)
+	}
+	return nil
+}
+
+// deliver проводит SMTP-сессию для одного получателя: STARTTLS, если сервер его поддерживает,
+// авторизация, если задан Username, и передача письма.
+func (s *SMTPSender) deliver(ctx context.Context, to string, body []byte) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(s.Host, s.Port))
+	if err != nil {
+		return err
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(mailTimeout)
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		conn.Close()
+		return err
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	client, err := smtp.NewClient(conn, s.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: s.Host}); err != nil {
+			return err
+		}
+	}
+	if s.Username != "" {
+		if ok, _ := client.Extension("AUTH"); !ok {
+			return fmt.Errorf("сервер не поддерживает авторизацию")
+		}
+		if err := client.Auth(smtp.PlainAuth("", s.Username, s.Password, s.Host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(s.From); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(body); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// buildMessage формирует письмо (UTF-8, base64): text/plain или multipart/alternative с HTML.
+func (s *SMTPSender) buildMessage(message *MailMessage) []byte {
+	to := (&mail.Address{Name: message.To.DisplayName, Address: message.To.Email}).String()
+	var sb strings.Builder
+	sb.WriteString("From: " + s.From + "\r\n")
+	sb.WriteString("To: " + to + "\r\n")
+	sb.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", message.Subject) + "\r\n")
+	sb.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	sb.WriteString("MIME-Version: 1.0\r\n")
+
+	if message.HTML == "" {
+		writePart(&sb, "text/plain", message.Text)
+		return []byte(sb.String())
+	}
+	boundary := mimeBoundary()
+	sb.WriteString("Content-Type: multipart/alternative; boundary=\"" + boundary + "\"\r\n\r\n")
+	sb.WriteString("--" + boundary + "\r\n")
+	writePart(&sb, "text/plain", message.Text)
+	sb.WriteString("--" + boundary + "\r\n")
+	writePart(&sb, "text/html", message.HTML)
+	sb.WriteString("--" + boundary + "--\r\n")
+	return []byte(sb.String())
+}
+
+// writePart записывает заголовки и тело части письма в кодировке base64 (строки по 76 символов).
+func writePart(sb *strings.Builder, contentType string, body string) {
+	sb.WriteString("Content-Type: " + contentType + "; charset=utf-8\r\n")
+	sb.WriteString("Content-Transfer-Encoding: base64\r\n\r\n")
+	encoded := base64.StdEncoding.EncodeToString([]byte(body))
+	for len(encoded) > 76 {
+		sb.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	sb.WriteString(encoded + "\r\n")
+}
+
+// mimeBoundary возвращает случайный разделитель частей письма.
+func mimeBoundary() string {
+	buf := make([]byte, 12)
+	if _, err := rand.Read(buf); err != nil {
+		return fmt.Sprintf("notes-%d", time.Now().UnixNano())
+	}
+	return "notes-" + hex.EncodeToString(buf)
+}
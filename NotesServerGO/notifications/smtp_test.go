@@ -0,0 +1,268 @@
+package notifications
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// receivedMail - письмо, принятое тестовым SMTP-сервером.
+type receivedMail struct {
+	from string
+	rcpt []string
+	data string
+}
+
+// fakeSMTPServer - минимальный SMTP-сервер без STARTTLS и авторизации, запоминающий принятые письма.
+type fakeSMTPServer struct {
+	listener net.Listener
+	mu       sync.Mutex
+	mails    []receivedMail
+}
+
+func startFakeSMTPServer(t *testing.T) *fakeSMTPServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("не удалось запустить SMTP-сервер: %v", err)
+	}
+	server := &fakeSMTPServer{listener: listener}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+	return server
+}
+
+func (s *fakeSMTPServer) serve(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	tp := textproto.NewConn(conn)
+	tp.PrintfLine("220 fake ESMTP")
+	var current receivedMail
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(line)
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			tp.PrintfLine("250-fake")
+			tp.PrintfLine("250 8BITMIME")
+		case strings.HasPrefix(command, "MAIL FROM:"):
+			current = receivedMail{from: trimAddress(line[len("MAIL FROM:"):])}
+			tp.PrintfLine("250 ok")
+		case strings.HasPrefix(command, "RCPT TO:"):
+			current.rcpt = append(current.rcpt, trimAddress(line[len("RCPT TO:"):]))
+			tp.PrintfLine("250 ok")
+		case command == "DATA":
+			tp.PrintfLine("354 go ahead")
+			data, err := io.ReadAll(tp.DotReader())
+			if err != nil {
+				return
+			}
+			current.data = string(data)
+			s.mu.Lock()
+			s.mails = append(s.mails, current)
+			s.mu.Unlock()
+			tp.PrintfLine("250 ok")
+		case command == "QUIT":
+			tp.PrintfLine("221 bye")
+			return
+		default:
+			tp.PrintfLine("250 ok")
+		}
+	}
+}
+
+func (s *fakeSMTPServer) received() []receivedMail {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]receivedMail(nil), s.mails...)
+}
+
+func (s *fakeSMTPServer) sender(t *testing.T) *SMTPSender {
+	t.Helper()
+	host, port, err := net.SplitHostPort(s.listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &SMTPSender{Host: host, Port: port, From: "notes@example.com"}
+}
+
+// trimAddress убирает угловые скобки и параметры из аргумента MAIL FROM / RCPT TO.
+func trimAddress(arg string) string {
+	arg = strings.TrimSpace(arg)
+	if i := strings.Index(arg, ">"); i >= 0 {
+		arg = arg[:i+1]
+	}
+	return strings.Trim(arg, "<>")
+}
+
+// readPart декодирует часть письма в base64 и проверяет ее тип.
+func readPart(t *testing.T, part *multipart.Part, contentType string) string {
+	t.Helper()
+	mediaType, params, err := mime.ParseMediaType(part.Header.Get("Content-Type"))
+	if err != nil || mediaType != contentType || params["charset"] != "utf-8" {
+		t.Fatalf("Content-Type части = %q, ожидался %s; charset=utf-8", part.Header.Get("Content-Type"), contentType)
+	}
+	if encoding := part.Header.Get("Content-Transfer-Encoding"); encoding != "base64" {
+		t.Fatalf("Content-Transfer-Encoding части %s = %q, ожидался base64", contentType, encoding)
+	}
+	raw, err := io.ReadAll(part)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(strings.TrimRight(strings.ReplaceAll(string(raw), "\r", ""), "\n"), "\n") {
+		if len(line) > 76 {
+			t.Errorf("строка base64 длиннее 76 символов: %d", len(line))
+		}
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.NewReplacer("\r", "", "\n", "").Replace(string(raw)))
+	if err != nil {
+		t.Fatalf("часть %s не в base64: %v", contentType, err)
+	}
+	return string(decoded)
+}
+
+func TestSendMailMultipartAlternative(t *testing.T) {
+	server := startFakeSMTPServer(t)
+	sender := server.sender(t)
+
+	text := strings.Repeat("Сводка на сегодня: 3 записи. ", 10)
+	message := &MailMessage{
+		To:      Recipient{UserId: 1, Email: "alice@example.com", DisplayName: "Алиса"},
+		Subject: "Сводка: Рабочая БД",
+		Text:    text,
+		HTML:    "<p>Сводка на <b>сегодня</b></p>",
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := sender.SendMail(ctx, message); err != nil {
+		t.Fatalf("SendMail: %v", err)
+	}
+
+	mails := server.received()
+	if len(mails) != 1 {
+		t.Fatalf("принято писем: %d, ожидалось 1", len(mails))
+	}
+	got := mails[0]
+	if got.from != "notes@example.com" {
+		t.Errorf("MAIL FROM = %q", got.from)
+	}
+	if len(got.rcpt) != 1 || got.rcpt[0] != "alice@example.com" {
+		t.Errorf("RCPT TO = %v, ожидалось [alice@example.com]", got.rcpt)
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(got.data))
+	if err != nil {
+		t.Fatalf("не удалось разобрать письмо: %v", err)
+	}
+	rawSubject := msg.Header.Get("Subject")
+	if !strings.HasPrefix(rawSubject, "=?utf-8?q?") {
+		t.Errorf("тема не в Q-кодировке: %q", rawSubject)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(rawSubject)
+	if err != nil || subject != message.Subject {
+		t.Errorf("тема = %q (%v), ожидалась %q", subject, err, message.Subject)
+	}
+	to, err := msg.Header.AddressList("To")
+	if err != nil || len(to) != 1 || to[0].Address != "alice@example.com" || to[0].Name != "Алиса" {
+		t.Errorf("To = %v (%v)", to, err)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" || params["boundary"] == "" {
+		t.Fatalf("Content-Type = %q, ожидался multipart/alternative с boundary", msg.Header.Get("Content-Type"))
+	}
+	reader := multipart.NewReader(msg.Body, params["boundary"])
+	part, err := reader.NextPart()
+	if err != nil {
+		t.Fatalf("нет текстовой части: %v", err)
+	}
+	if body := readPart(t, part, "text/plain"); body != message.Text {
+		t.Errorf("текстовая часть = %q", body)
+	}
+	part, err = reader.NextPart()
+	if err != nil {
+		t.Fatalf("нет HTML-части: %v", err)
+	}
+	if body := readPart(t, part, "text/html"); body != message.HTML {
+		t.Errorf("HTML-часть = %q", body)
+	}
+	if _, err := reader.NextPart(); err != io.EOF {
+		t.Errorf("лишние части письма: %v", err)
+	}
+}
+
+func TestSendMailPlainText(t *testing.T) {
+	server := startFakeSMTPServer(t)
+	sender := server.sender(t)
+
+	message := &MailMessage{To: Recipient{Email: "bob@example.com"}, Subject: "Напоминание", Text: "Созвон в 10:00"}
+	if err := sender.SendMail(context.Background(), message); err != nil {
+		t.Fatalf("SendMail: %v", err)
+	}
+	mails := server.received()
+	if len(mails) != 1 {
+		t.Fatalf("принято писем: %d, ожидалось 1", len(mails))
+	}
+	msg, err := mail.ReadMessage(strings.NewReader(mails[0].data))
+	if err != nil {
+		t.Fatalf("не удалось разобрать письмо: %v", err)
+	}
+	if mediaType, _, _ := mime.ParseMediaType(msg.Header.Get("Content-Type")); mediaType != "text/plain" {
+		t.Errorf("Content-Type = %q, ожидался text/plain", msg.Header.Get("Content-Type"))
+	}
+	body, _ := io.ReadAll(msg.Body)
+	decoded, err := base64.StdEncoding.DecodeString(strings.NewReplacer("\r", "", "\n", "").Replace(string(body)))
+	if err != nil || string(decoded) != message.Text {
+		t.Errorf("тело = %q (%v)", decoded, err)
+	}
+}
+
+func TestSendSeparateMailPerRecipient(t *testing.T) {
+	server := startFakeSMTPServer(t)
+	sender := server.sender(t)
+
+	reminder := &Reminder{
+		DatabaseName: "Работа",
+		Time:         "10:00 - 11:00",
+		StartsAt:     time.Date(2026, 10, 20, 10, 0, 0, 0, time.UTC),
+		Note:         "Планерка",
+		Recipients: []Recipient{
+			{UserId: 1, Email: "alice@example.com"},
+			{UserId: 2}, // Без адреса - пропускается
+			{UserId: 3, Email: "carol@example.com"},
+		},
+	}
+	if err := sender.Send(context.Background(), reminder); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	var rcpts []string
+	for _, received := range server.received() {
+		if len(received.rcpt) != 1 {
+			t.Errorf("в одном письме несколько получателей: %v", received.rcpt)
+		}
+		rcpts = append(rcpts, received.rcpt...)
+	}
+	if strings.Join(rcpts, ",") != "alice@example.com,carol@example.com" {
+		t.Errorf("получатели = %v", rcpts)
+	}
+}
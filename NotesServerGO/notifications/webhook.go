@@ -0,0 +1,69 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// WebhookSignatureHeader - заголовок с подписью тела запроса (HMAC-SHA256 секретом вебхука).
+const WebhookSignatureHeader = "X-Reminder-Signature"
+
+// WebhookSender отправляет напоминания POST-запросом с JSON на заданный URL.
+type WebhookSender struct {
+	URL    string
+	Secret string // Если задан, тело подписывается в заголовке WebhookSignatureHeader
+	Client *http.Client
+}
+
+// webhookPayload - тело запроса вебхука.
+type webhookPayload struct {
+	Event    string    `json:"event"`
+	Reminder *Reminder `json:"reminder"`
+}
+
+// NewWebhookSender создает отправителя вебхуков с таймаутом запроса по умолчанию.
+func NewWebhookSender(url string, secret string) *WebhookSender {
+	return &WebhookSender{URL: url, Secret: secret, Client: &http.Client{Timeout: 15 * time.Second}}
+}
+
+// Channel реализует Sender.
+func (s *WebhookSender) Channel() string {
+	return "webhook"
+}
+
+// Send реализует Sender. Ответ со статусом вне 2xx считается ошибкой доставки.
+func (s *WebhookSender) Send(ctx context.Context, reminder *Reminder) error {
+	body, err := json.Marshal(webhookPayload{Event: "schedule.reminder", Reminder: reminder})
+	if err != nil {
+		return fmt.Errorf("WebhookSender: ошибка сериализации напоминания: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("WebhookSender: ошибка создания запроса: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(s.Secret))
+		mac.Write(body)
+		req.Header.Set(WebhookSignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("WebhookSender: ошибка запроса к %s: %w", s.URL, err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("WebhookSender: %s ответил статусом %d", s.URL, resp.StatusCode)
+	}
+	return nil
+}
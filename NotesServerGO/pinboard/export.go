@@ -0,0 +1,381 @@
+package pinboard
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"notes_server_go/models"
+)
+
+// Размеры и цвета по умолчанию - как у PinboardNoteDB и Connection на клиенте.
+const (
+	DefaultCardWidth       = 200.0
+	DefaultCardHeight      = 150.0
+	DefaultCardColor       = 0xFF424242
+	DefaultConnectionColor = 0xFF00FFFF
+)
+
+// Форматы экспорта доски.
+const (
+	FormatSVG     = "svg"
+	FormatDOT     = "dot"
+	FormatGraphML = "graphml"
+)
+
+// ContentTypes - MIME-типы форматов экспорта.
+var ContentTypes = map[string]string{
+	FormatSVG:     "image/svg+xml; charset=utf-8",
+	FormatDOT:     "text/vnd.graphviz; charset=utf-8",
+	FormatGraphML: "application/graphml+xml; charset=utf-8",
+}
+
+// svgPadding - отступ от карточек до края изображения; maxSVGTitleLength - длина заголовка на карточке.
+const (
+	svgPadding        = 40.0
+	maxSVGTitleLength = 40
+)
+
+// Board - доска совместной БД для экспорта: карточки с положением, размером и цветом и связи между ними.
+// Связи, ссылающиеся на отсутствующие карточки, не экспортируются.
+type Board struct {
+	Name        string
+	Notes       []models.PinboardNote
+	Connections []models.Connection
+}
+
+// NewBoard подготавливает доску к экспорту: карточки и связи упорядочиваются по ID, висячие связи
+// отбрасываются, нулевые размеры и цвета (не заданы клиентом) заменяются значениями по умолчанию.
+func NewBoard(name string, notes []models.PinboardNote, connections []models.Connection) *Board {
+	board := &Board{Name: name}
+	exists := make(map[int64]bool, len(notes))
+	for _, note := range notes {
+		if note.Width <= 0 {
+			note.Width = DefaultCardWidth
+		}
+		if note.Height <= 0 {
+			note.Height = DefaultCardHeight
+		}
+		if note.BackgroundColor == 0 {
+			note.BackgroundColor = DefaultCardColor
+		}
+		board.Notes = append(board.Notes, note)
+		exists[note.Id] = true
+	}
+	for _, conn := range connections {
+		if !exists[conn.FromNoteId] || !exists[conn.ToNoteId] {
+			continue
+		}
+		if conn.ConnectionColor == 0 {
+			conn.ConnectionColor = DefaultConnectionColor
+		}
+		board.Connections = append(board.Connections, conn)
+	}
+	sort.Slice(board.Notes, func(i, j int) bool { return board.Notes[i].Id < board.Notes[j].Id })
+	sort.Slice(board.Connections, func(i, j int) bool { return board.Connections[i].Id < board.Connections[j].Id })
+	return board
+}
+
+// Encode записывает доску в формате format (svg, dot или graphml).
+func (b *Board) Encode(w io.Writer, format string) error {
+	switch format {
+	case FormatSVG:
+		return b.EncodeSVG(w)
+	case FormatDOT:
+		return b.EncodeDOT(w)
+	case FormatGraphML:
+		return b.EncodeGraphML(w)
+	default:
+		return fmt.Errorf("неизвестный формат экспорта: %q", format)
+	}
+}
+
+// EncodeSVG рисует доску в SVG: карточки - прямоугольники в своих координатах и цветах с заголовками,
+// связи - стрелки между границами карточек с названиями посередине.
+func (b *Board) EncodeSVG(w io.Writer) error {
+	minX, minY, maxX, maxY := b.bounds()
+	width, height := maxX-minX+2*svgPadding, maxY-minY+2*svgPadding
+	// Смещение, при котором левый верхний угол содержимого оказывается в (svgPadding, svgPadding)
+	dx, dy := svgPadding-minX, svgPadding-minY
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, `<?xml version="1.0" encoding="UTF-8"?>`+"\n")
+	fmt.Fprintf(bw, `<svg xmlns="http://www.w3.org/2000/svg" width="%s" height="%s" viewBox="0 0 %s %s" font-family="sans-serif">`+"\n",
+		num(width), num(height), num(width), num(height))
+	fmt.Fprintf(bw, "<title>%s</title>\n", xmlEscape(b.Name))
+	fmt.Fprintf(bw, `<rect width="100%%" height="100%%" fill="#fafafa"/>`+"\n")
+
+	notes := make(map[int64]*models.PinboardNote, len(b.Notes))
+	for i := range b.Notes {
+		notes[b.Notes[i].Id] = &b.Notes[i]
+	}
+
+	// Связи рисуются под карточками
+	fmt.Fprintf(bw, `<g id="connections" stroke-width="2">`+"\n")
+	for _, conn := range b.Connections {
+		from, to := notes[conn.FromNoteId], notes[conn.ToNoteId]
+		color, opacity := argbToHex(conn.ConnectionColor)
+		fmt.Fprintf(bw, `<g id="connection-%d">`, conn.Id)
+		if from.Id == to.Id {
+			// Петля - дуга над правым верхним углом карточки
+			x, y := from.PositionX+from.Width+dx, from.PositionY+dy
+			fmt.Fprintf(bw, `<path d="M %s %s C %s %s %s %s %s %s" fill="none" stroke="%s" stroke-opacity="%s"/>`,
+				num(x-20), num(y), num(x-20), num(y-40), num(x+20), num(y+20), num(x), num(y+20), color, opacity)
+			if conn.Name != "" {
+				fmt.Fprintf(bw, `<text x="%s" y="%s" font-size="12" fill="#333">%s</text>`, num(x+8), num(y-24), xmlEscape(conn.Name))
+			}
+			fmt.Fprintf(bw, "</g>\n")
+			continue
+		}
+		x1, y1 := from.PositionX+from.Width/2+dx, from.PositionY+from.Height/2+dy
+		x2, y2 := to.PositionX+to.Width/2+dx, to.PositionY+to.Height/2+dy
+		sx, sy := clipToRect(x1, y1, x2, y2, from.Width/2, from.Height/2)
+		ex, ey := clipToRect(x2, y2, x1, y1, to.Width/2, to.Height/2)
+		fmt.Fprintf(bw, `<line x1="%s" y1="%s" x2="%s" y2="%s" stroke="%s" stroke-opacity="%s"/>`,
+			num(sx), num(sy), num(ex), num(ey), color, opacity)
+		fmt.Fprintf(bw, `<polygon points="%s" fill="%s" fill-opacity="%s"/>`, arrowHead(sx, sy, ex, ey), color, opacity)
+		if conn.Name != "" {
+			fmt.Fprintf(bw, `<text x="%s" y="%s" font-size="12" text-anchor="middle" fill="#333" stroke="#fafafa" stroke-width="3" paint-order="stroke">%s</text>`,
+				num((sx+ex)/2), num((sy+ey)/2-4), xmlEscape(conn.Name))
+		}
+		fmt.Fprintf(bw, "</g>\n")
+	}
+	fmt.Fprintf(bw, "</g>\n")
+
+	fmt.Fprintf(bw, `<g id="cards">`+"\n")
+	for _, note := range b.Notes {
+		x, y := note.PositionX+dx, note.PositionY+dy
+		fill, opacity := argbToHex(note.BackgroundColor)
+		fmt.Fprintf(bw, `<g id="card-%d">`, note.Id)
+		fmt.Fprintf(bw, `<rect x="%s" y="%s" width="%s" height="%s" rx="8" fill="%s" fill-opacity="%s" stroke="#000000" stroke-opacity="0.2"/>`,
+			num(x), num(y), num(note.Width), num(note.Height), fill, opacity)
+		fmt.Fprintf(bw, `<text x="%s" y="%s" font-size="14" font-weight="bold" fill="%s">%s</text>`,
+			num(x+10), num(y+24), textColor(note.BackgroundColor), xmlEscape(truncate(note.Title, maxSVGTitleLength)))
+		fmt.Fprintf(bw, "</g>\n")
+	}
+	fmt.Fprintf(bw, "</g>\n</svg>\n")
+	return bw.Flush()
+}
+
+// EncodeDOT записывает доску в формате Graphviz DOT. Координаты карточек передаются в атрибуте pos
+// (в пунктах, ось Y направлена вверх), поэтому neato -n воспроизводит расположение карточек на доске.
+func (b *Board) EncodeDOT(w io.Writer) error {
+	_, _, _, maxY := b.bounds()
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "digraph %s {\n", dotQuote(b.Name))
+	fmt.Fprintf(bw, "  node [shape=box, style=\"rounded,filled\", fontname=\"sans-serif\"];\n")
+	fmt.Fprintf(bw, "  edge [fontname=\"sans-serif\"];\n")
+	for _, note := range b.Notes {
+		fill, _ := argbToHex(note.BackgroundColor)
+		cx, cy := note.PositionX+note.Width/2, maxY-(note.PositionY+note.Height/2)
+		fmt.Fprintf(bw, "  n%d [label=%s, fillcolor=%s, fontcolor=%s, width=%s, height=%s, fixedsize=true, pos=\"%s,%s!\"];\n",
+			note.Id, dotQuote(note.Title), dotQuote(fill), dotQuote(textColor(note.BackgroundColor)),
+			num(note.Width/72), num(note.Height/72), num(cx), num(cy))
+	}
+	for _, conn := range b.Connections {
+		color, _ := argbToHex(conn.ConnectionColor)
+		fmt.Fprintf(bw, "  n%d -> n%d [id=\"c%d\", label=%s, color=%s];\n",
+			conn.FromNoteId, conn.ToNoteId, conn.Id, dotQuote(conn.Name), dotQuote(color))
+	}
+	fmt.Fprintf(bw, "}\n")
+	return bw.Flush()
+}
+
+// Структуры GraphML (http://graphml.graphdrawing.org/).
+type graphML struct {
+	XMLName xml.Name     `xml:"graphml"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   graphMLGraph `xml:"graph"`
+}
+
+type graphMLKey struct {
+	Id       string `xml:"id,attr"`
+	For      string `xml:"for,attr"`
+	AttrName string `xml:"attr.name,attr"`
+	AttrType string `xml:"attr.type,attr"`
+}
+
+type graphMLGraph struct {
+	Id          string        `xml:"id,attr"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Data        []graphMLData `xml:"data"`
+	Nodes       []graphMLNode `xml:"node"`
+	Edges       []graphMLEdge `xml:"edge"`
+}
+
+type graphMLNode struct {
+	Id   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	Id     string        `xml:"id,attr"`
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLData `xml:"data"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+// EncodeGraphML записывает доску в формате GraphML: карточки - узлы с заголовком, содержимым,
+// положением, размером и цветом, связи - направленные ребра с названием и цветом.
+func (b *Board) EncodeGraphML(w io.Writer) error {
+	doc := graphML{
+		Xmlns: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []graphMLKey{
+			{Id: "name", For: "graph", AttrName: "name", AttrType: "string"},
+			{Id: "title", For: "node", AttrName: "title", AttrType: "string"},
+			{Id: "content", For: "node", AttrName: "content", AttrType: "string"},
+			{Id: "x", For: "node", AttrName: "x", AttrType: "double"},
+			{Id: "y", For: "node", AttrName: "y", AttrType: "double"},
+			{Id: "width", For: "node", AttrName: "width", AttrType: "double"},
+			{Id: "height", For: "node", AttrName: "height", AttrType: "double"},
+			{Id: "color", For: "node", AttrName: "color", AttrType: "string"},
+			{Id: "label", For: "edge", AttrName: "label", AttrType: "string"},
+			{Id: "edge_color", For: "edge", AttrName: "color", AttrType: "string"},
+		},
+		Graph: graphMLGraph{
+			Id:          "pinboard",
+			EdgeDefault: "directed",
+			Data:        []graphMLData{{Key: "name", Value: b.Name}},
+		},
+	}
+	for _, note := range b.Notes {
+		color, _ := argbToHex(note.BackgroundColor)
+		doc.Graph.Nodes = append(doc.Graph.Nodes, graphMLNode{
+			Id: fmt.Sprintf("n%d", note.Id),
+			Data: []graphMLData{
+				{Key: "title", Value: note.Title},
+				{Key: "content", Value: note.Content},
+				{Key: "x", Value: num(note.PositionX)},
+				{Key: "y", Value: num(note.PositionY)},
+				{Key: "width", Value: num(note.Width)},
+				{Key: "height", Value: num(note.Height)},
+				{Key: "color", Value: color},
+			},
+		})
+	}
+	for _, conn := range b.Connections {
+		color, _ := argbToHex(conn.ConnectionColor)
+		doc.Graph.Edges = append(doc.Graph.Edges, graphMLEdge{
+			Id:     fmt.Sprintf("c%d", conn.Id),
+			Source: fmt.Sprintf("n%d", conn.FromNoteId),
+			Target: fmt.Sprintf("n%d", conn.ToNoteId),
+			Data:   []graphMLData{{Key: "label", Value: conn.Name}, {Key: "edge_color", Value: color}},
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return fmt.Errorf("EncodeGraphML: %w", err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// bounds возвращает прямоугольник, охватывающий все карточки (нулевой для пустой доски).
+func (b *Board) bounds() (minX, minY, maxX, maxY float64) {
+	if len(b.Notes) == 0 {
+		return 0, 0, 0, 0
+	}
+	minX, minY = math.Inf(1), math.Inf(1)
+	maxX, maxY = math.Inf(-1), math.Inf(-1)
+	for _, note := range b.Notes {
+		minX = math.Min(minX, note.PositionX)
+		minY = math.Min(minY, note.PositionY)
+		maxX = math.Max(maxX, note.PositionX+note.Width)
+		maxY = math.Max(maxY, note.PositionY+note.Height)
+	}
+	return minX, minY, maxX, maxY
+}
+
+// clipToRect возвращает точку пересечения отрезка из центра (cx, cy) прямоугольника с полуразмерами
+// (hw, hh) в направлении (tx, ty) с границей прямоугольника.
+func clipToRect(cx, cy, tx, ty, hw, hh float64) (float64, float64) {
+	vx, vy := tx-cx, ty-cy
+	if vx == 0 && vy == 0 {
+		return cx, cy
+	}
+	scale := math.Inf(1)
+	if vx != 0 {
+		scale = math.Min(scale, hw/math.Abs(vx))
+	}
+	if vy != 0 {
+		scale = math.Min(scale, hh/math.Abs(vy))
+	}
+	if scale > 1 {
+		scale = 1 // Карточки перекрываются - стрелка идет от центра к центру
+	}
+	return cx + vx*scale, cy + vy*scale
+}
+
+// arrowHead возвращает вершины треугольника стрелки на конце (x2, y2) отрезка.
+func arrowHead(x1, y1, x2, y2 float64) string {
+	const length, halfWidth = 10.0, 5.0
+	dx, dy := x2-x1, y2-y1
+	d := math.Hypot(dx, dy)
+	if d == 0 {
+		return fmt.Sprintf("%s,%s", num(x2), num(y2))
+	}
+	ux, uy := dx/d, dy/d
+	bx, by := x2-ux*length, y2-uy*length
+	return fmt.Sprintf("%s,%s %s,%s %s,%s", num(x2), num(y2),
+		num(bx-uy*halfWidth), num(by+ux*halfWidth), num(bx+uy*halfWidth), num(by-ux*halfWidth))
+}
+
+// argbToHex переводит цвет ARGB клиента во "#rrggbb" и непрозрачность от 0 до 1.
+func argbToHex(argb int) (string, string) {
+	c := uint32(argb)
+	return fmt.Sprintf("#%06x", c&0xFFFFFF), num(float64(c>>24) / 255)
+}
+
+// textColor выбирает черный или белый текст в зависимости от яркости фона.
+func textColor(argb int) string {
+	c := uint32(argb)
+	r, g, b := float64(c>>16&0xFF), float64(c>>8&0xFF), float64(c&0xFF)
+	if 0.299*r+0.587*g+0.114*b > 150 {
+		return "#000000"
+	}
+	return "#ffffff"
+}
+
+// num форматирует число без лишних знаков после запятой.
+func num(v float64) string {
+	return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64)
+}
+
+// truncate обрезает строку до max символов с многоточием; переводы строк заменяются пробелами.
+func truncate(s string, max int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	return string([]rune(s)[:max-1]) + "…"
+}
+
+// xmlEscape экранирует текст для вставки в XML.
+func xmlEscape(s string) string {
+	var sb strings.Builder
+	xml.EscapeText(&sb, []byte(s))
+	return sb.String()
+}
+
+// dotQuote заключает строку в кавычки DOT, экранируя кавычки, обратную косую черту и переводы строк.
+func dotQuote(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`).Replace(s)
+	return `"` + s + `"`
+}
@@ -0,0 +1,241 @@
+package pinboard
+
+import (
+	"sort"
+
+	"notes_server_go/models"
+)
+
+// Direction - направление связей при обходе графа доски.
+type Direction string
+
+const (
+	DirectionOut  Direction = "out"  // Связи, исходящие из карточки (FromNoteId)
+	DirectionIn   Direction = "in"   // Связи, входящие в карточку (ToNoteId)
+	DirectionBoth Direction = "both" // Связи в обе стороны
+)
+
+// IsValid проверяет, что направление известно.
+func (d Direction) IsValid() bool {
+	return d == DirectionOut || d == DirectionIn || d == DirectionBoth
+}
+
+// Card - краткое описание карточки доски в ответах графовых запросов.
+type Card struct {
+	Id    int64  `json:"id"`
+	Title string `json:"title"`
+}
+
+// Edge - связь между карточками как ребро графа. Reversed = true, если при обходе ребро
+// пройдено против направления связи (от ToNoteId к FromNoteId).
+type Edge struct {
+	ConnectionId int64  `json:"connection_id"`
+	From         int64  `json:"from_note_id"`
+	To           int64  `json:"to_note_id"`
+	Name         string `json:"name"`
+	Reversed     bool   `json:"reversed,omitempty"`
+}
+
+// Neighbor - соседняя карточка и связь, через которую она соседствует.
+type Neighbor struct {
+	Card      Card      `json:"card"`
+	Edge      Edge      `json:"connection"`
+	Direction Direction `json:"direction"` // out - связь из исходной карточки, in - в нее
+}
+
+// Graph - граф доски совместной БД: карточки (PinboardNotes) и связи (Connections) между ними.
+// Связи, ссылающиеся на отсутствующие карточки, в граф не попадают. Списки смежности упорядочены
+// по ID связи, поэтому результаты запросов не зависят от порядка строк в БД.
+type Graph struct {
+	cards map[int64]Card
+	ids   []int64 // ID карточек по возрастанию
+	out   map[int64][]Edge
+	in    map[int64][]Edge
+	edges []Edge
+}
+
+// NewGraph строит граф по карточкам и связям одной совместной БД.
+func NewGraph(notes []models.PinboardNote, connections []models.Connection) *Graph {
+	g := &Graph{
+		cards: make(map[int64]Card, len(notes)),
+		out:   make(map[int64][]Edge),
+		in:    make(map[int64][]Edge),
+	}
+	for _, note := range notes {
+		g.cards[note.Id] = Card{Id: note.Id, Title: note.Title}
+		g.ids = append(g.ids, note.Id)
+	}
+	sort.Slice(g.ids, func(i, j int) bool { return g.ids[i] < g.ids[j] })
+
+	sorted := make([]models.Connection, len(connections))
+	copy(sorted, connections)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Id < sorted[j].Id })
+	for _, conn := range sorted {
+		if !g.HasCard(conn.FromNoteId) || !g.HasCard(conn.ToNoteId) {
+			continue
+		}
+		edge := Edge{ConnectionId: conn.Id, From: conn.FromNoteId, To: conn.ToNoteId, Name: conn.Name}
+		g.edges = append(g.edges, edge)
+		g.out[edge.From] = append(g.out[edge.From], edge)
+		g.in[edge.To] = append(g.in[edge.To], edge)
+	}
+	return g
+}
+
+// HasCard проверяет, что карточка есть на доске.
+func (g *Graph) HasCard(id int64) bool {
+	_, ok := g.cards[id]
+	return ok
+}
+
+// Card возвращает карточку по ID.
+func (g *Graph) Card(id int64) (Card, bool) {
+	card, ok := g.cards[id]
+	return card, ok
+}
+
+// Cards возвращает все карточки по возрастанию ID.
+func (g *Graph) Cards() []Card {
+	cards := make([]Card, 0, len(g.ids))
+	for _, id := range g.ids {
+		cards = append(cards, g.cards[id])
+	}
+	return cards
+}
+
+// Edges возвращает все связи графа по возрастанию ID.
+func (g *Graph) Edges() []Edge {
+	return append([]Edge(nil), g.edges...)
+}
+
+// Neighbors возвращает соседей карточки по связям в направлении direction.
+// Петля (связь карточки с самой собой) возвращается один раз как исходящая.
+func (g *Graph) Neighbors(id int64, direction Direction) []Neighbor {
+	neighbors := []Neighbor{}
+	if direction != DirectionIn {
+		for _, edge := range g.out[id] {
+			neighbors = append(neighbors, Neighbor{Card: g.cards[edge.To], Edge: edge, Direction: DirectionOut})
+		}
+	}
+	if direction != DirectionOut {
+		for _, edge := range g.in[id] {
+			if direction == DirectionBoth && edge.From == edge.To {
+				continue
+			}
+			edge.Reversed = true
+			neighbors = append(neighbors, Neighbor{Card: g.cards[edge.From], Edge: edge, Direction: DirectionIn})
+		}
+	}
+	return neighbors
+}
+
+// ShortestPath ищет путь с наименьшим числом связей от карточки from до карточки to обходом в ширину.
+// directed = false разрешает проходить связи в обратную сторону. Возвращает карточки пути (включая
+// from и to) и связи между ними; ok = false, если пути нет. При нескольких кратчайших путях выбирается
+// путь через связи с меньшими ID.
+func (g *Graph) ShortestPath(from int64, to int64, directed bool) (cards []Card, edges []Edge, ok bool) {
+	if !g.HasCard(from) || !g.HasCard(to) {
+		return nil, nil, false
+	}
+	if from == to {
+		return []Card{g.cards[from]}, []Edge{}, true
+	}
+
+	direction := DirectionOut
+	if !directed {
+		direction = DirectionBoth
+	}
+	previous := map[int64]Edge{}
+	visited := map[int64]bool{from: true}
+	queue := []int64{from}
+	for len(queue) > 0 && !visited[to] {
+		current := queue[0]
+		queue = queue[1:]
+		for _, neighbor := range g.Neighbors(current, direction) {
+			next := neighbor.Card.Id
+			if visited[next] {
+				continue
+			}
+			visited[next] = true
+			previous[next] = neighbor.Edge
+			queue = append(queue, next)
+		}
+	}
+	if !visited[to] {
+		return nil, nil, false
+	}
+
+	for id := to; id != from; {
+		edge := previous[id]
+		edges = append(edges, edge)
+		if edge.Reversed {
+			id = edge.To
+		} else {
+			id = edge.From
+		}
+	}
+	for i, j := 0, len(edges)-1; i < j; i, j = i+1, j-1 {
+		edges[i], edges[j] = edges[j], edges[i]
+	}
+	cards = append(cards, g.cards[from])
+	for _, edge := range edges {
+		if edge.Reversed {
+			cards = append(cards, g.cards[edge.From])
+		} else {
+			cards = append(cards, g.cards[edge.To])
+		}
+	}
+	return cards, edges, true
+}
+
+// Components возвращает компоненты связности доски без учета направления связей: для каждой
+// компоненты - ID карточек по возрастанию. Компоненты упорядочены по убыванию размера,
+// при равном размере - по наименьшему ID карточки.
+func (g *Graph) Components() [][]int64 {
+	components := [][]int64{}
+	visited := make(map[int64]bool, len(g.ids))
+	for _, start := range g.ids {
+		if visited[start] {
+			continue
+		}
+		component := []int64{}
+		visited[start] = true
+		queue := []int64{start}
+		for len(queue) > 0 {
+			current := queue[0]
+			queue = queue[1:]
+			component = append(component, current)
+			for _, neighbor := range g.Neighbors(current, DirectionBoth) {
+				if !visited[neighbor.Card.Id] {
+					visited[neighbor.Card.Id] = true
+					queue = append(queue, neighbor.Card.Id)
+				}
+			}
+		}
+		sort.Slice(component, func(i, j int) bool { return component[i] < component[j] })
+		components = append(components, component)
+	}
+	sort.SliceStable(components, func(i, j int) bool { return len(components[i]) > len(components[j]) })
+	return components
+}
+
+// Orphans возвращает карточки без связей с другими карточками (петли не учитываются).
+func (g *Graph) Orphans() []Card {
+	orphans := []Card{}
+	for _, id := range g.ids {
+		if g.onlyLoops(id) {
+			orphans = append(orphans, g.cards[id])
+		}
+	}
+	return orphans
+}
+
+// onlyLoops проверяет, что у карточки нет связей, кроме петель.
+func (g *Graph) onlyLoops(id int64) bool {
+	for _, neighbor := range g.Neighbors(id, DirectionBoth) {
+		if neighbor.Card.Id != id {
+			return false
+		}
+	}
+	return true
+}
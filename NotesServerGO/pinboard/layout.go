@@ -0,0 +1,448 @@
+package pinboard
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+
+	"notes_server_go/models"
+)
+
+// Алгоритмы раскладки доски.
+const (
+	LayoutForce   = "force"   // Силовая раскладка (Fruchterman-Reingold)
+	LayoutLayered = "layered" // Послойная раскладка по направлению связей (Sugiyama)
+)
+
+// Параметры раскладки по умолчанию и ограничения.
+const (
+	DefaultLayoutSpacing    = 40.0
+	DefaultLayoutIterations = 300
+	MaxLayoutIterations     = 2000
+	MaxLayoutSpacing        = 1000.0
+	MaxLayoutCards          = 2000
+
+	// overlapPasses ограничивает число проходов устранения перекрытий карточек.
+	overlapPasses = 200
+	// maxLayoutPairWork - сколько раз можно обработать пару карточек в силовой раскладке и отдельно
+	// при устранении перекрытий. Каждая итерация и каждый проход перебирают все пары, поэтому
+	// на больших досках итераций и проходов выполняется меньше запрошенных: раскладка идет в обработчике запроса.
+	maxLayoutPairWork = 50_000_000
+	// forceGravity - сила притяжения карточек к центру доски в силовой раскладке.
+	forceGravity = 0.5
+)
+
+// LayoutOptions - параметры раскладки. Spacing - минимальный зазор между карточками.
+type LayoutOptions struct {
+	Algorithm  string
+	Spacing    float64
+	Iterations int // Только для силовой раскладки
+}
+
+// Normalize подставляет значения по умолчанию и проверяет параметры.
+func (o *LayoutOptions) Normalize() error {
+	if o.Algorithm == "" {
+		o.Algorithm = LayoutForce
+	}
+	if o.Algorithm != LayoutForce && o.Algorithm != LayoutLayered {
+		return fmt.Errorf("неизвестный алгоритм раскладки %q: ожидается %s или %s", o.Algorithm, LayoutForce, LayoutLayered)
+	}
+	if o.Spacing == 0 {
+		o.Spacing = DefaultLayoutSpacing
+	}
+	if o.Spacing < 0 || o.Spacing > MaxLayoutSpacing {
+		return fmt.Errorf("зазор между карточками должен быть от 0 до %v", MaxLayoutSpacing)
+	}
+	if o.Iterations == 0 {
+		o.Iterations = DefaultLayoutIterations
+	}
+	if o.Iterations < 1 || o.Iterations > MaxLayoutIterations {
+		return fmt.Errorf("число итераций должно быть от 1 до %d", MaxLayoutIterations)
+	}
+	return nil
+}
+
+// Position - предлагаемое положение левого верхнего угла карточки.
+type Position struct {
+	Id int64   `json:"id"`
+	X  float64 `json:"position_x"`
+	Y  float64 `json:"position_y"`
+}
+
+// layoutNode - карточка в раскладке; x, y - центр карточки.
+type layoutNode struct {
+	id            int64
+	width, height float64
+	x, y          float64
+}
+
+// LayoutResult - результат раскладки доски.
+type LayoutResult struct {
+	Positions  []Position
+	Iterations int  // Выполненные итерации силовой раскладки (на больших досках меньше запрошенных); 0 для послойной
+	Overlaps   bool // true - часть карточек осталась перекрытой: проходы устранения перекрытий закончились
+}
+
+// Layout раскладывает карточки доски с учетом их размеров и раздвигает перекрывающиеся карточки
+// (зазор не меньше opts.Spacing); если перекрытия устранить не удалось, result.Overlaps = true.
+// Результат детерминирован для одних и тех же карточек и связей и смещен так, чтобы левый верхний угол
+// раскладки совпадал с левым верхним углом карточек до раскладки. Связи с отсутствующими карточками
+// и петли не учитываются. Параметры должны быть нормализованы (Normalize).
+func Layout(notes []models.PinboardNote, connections []models.Connection, opts LayoutOptions) (*LayoutResult, error) {
+	if len(notes) > MaxLayoutCards {
+		return nil, fmt.Errorf("слишком много карточек для раскладки: %d (не больше %d)", len(notes), MaxLayoutCards)
+	}
+	board := NewBoard("", notes, connections)
+	if len(board.Notes) == 0 {
+		return &LayoutResult{Positions: []Position{}}, nil
+	}
+	originX, originY, _, _ := board.bounds()
+
+	nodes := make([]layoutNode, len(board.Notes))
+	index := make(map[int64]int, len(board.Notes))
+	for i, note := range board.Notes {
+		nodes[i] = layoutNode{id: note.Id, width: note.Width, height: note.Height}
+		index[note.Id] = i
+	}
+	edges := layoutEdges(board.Connections, index)
+
+	result := &LayoutResult{}
+	switch opts.Algorithm {
+	case LayoutLayered:
+		layeredLayout(nodes, edges, opts.Spacing)
+	default:
+		result.Iterations = min(opts.Iterations, pairWorkLimit(len(nodes)))
+		forceLayout(nodes, edges, opts.Spacing, result.Iterations)
+	}
+	result.Overlaps = !removeOverlaps(nodes, opts.Spacing, min(overlapPasses, pairWorkLimit(len(nodes))))
+
+	minX, minY := math.Inf(1), math.Inf(1)
+	for _, node := range nodes {
+		minX = math.Min(minX, node.x-node.width/2)
+		minY = math.Min(minY, node.y-node.height/2)
+	}
+	result.Positions = make([]Position, len(nodes))
+	for i, node := range nodes {
+		result.Positions[i] = Position{
+			Id: node.id,
+			X:  math.Round(node.x - node.width/2 - minX + originX),
+			Y:  math.Round(node.y - node.height/2 - minY + originY),
+		}
+	}
+	return result, nil
+}
+
+// pairWorkLimit возвращает, сколько полных переборов пар из n карточек укладывается в maxLayoutPairWork (не меньше 1).
+func pairWorkLimit(n int) int {
+	pairs := n * (n - 1) / 2
+	if pairs == 0 {
+		return maxLayoutPairWork
+	}
+	return max(1, maxLayoutPairWork/pairs)
+}
+
+// layoutEdges возвращает уникальные пары индексов карточек, соединенных связями (без петель).
+func layoutEdges(connections []models.Connection, index map[int64]int) [][2]int {
+	seen := make(map[[2]int]bool)
+	edges := [][2]int{}
+	for _, conn := range connections {
+		from, to := index[conn.FromNoteId], index[conn.ToNoteId]
+		edge := [2]int{from, to}
+		if from == to || seen[edge] {
+			continue
+		}
+		seen[edge] = true
+		edges = append(edges, edge)
+	}
+	return edges
+}
+
+// forceLayout - силовая раскладка Fruchterman-Reingold: карточки отталкиваются друг от друга,
+// связанные карточки притягиваются. Идеальное расстояние зависит от среднего размера карточек.
+func forceLayout(nodes []layoutNode, edges [][2]int, spacing float64, iterations int) {
+	n := len(nodes)
+	size := 0.0
+	for _, node := range nodes {
+		size += math.Max(node.width, node.height)
+	}
+	k := size/float64(n) + spacing
+
+	// Начальное положение - сетка по возрастанию ID с небольшим фиксированным смещением,
+	// чтобы симметричные конфигурации не застревали
+	random := rand.New(rand.NewSource(1))
+	cols := int(math.Ceil(math.Sqrt(float64(n))))
+	for i := range nodes {
+		nodes[i].x = float64(i%cols)*k + random.Float64()*k*0.1
+		nodes[i].y = float64(i/cols)*k + random.Float64()*k*0.1
+	}
+
+	temperature := k * math.Sqrt(float64(n))
+	dx := make([]float64, n)
+	dy := make([]float64, n)
+	for iteration := 0; iteration < iterations; iteration++ {
+		for i := range dx {
+			dx[i], dy[i] = 0, 0
+		}
+		for i := 0; i < n; i++ {
+			for j := i + 1; j < n; j++ {
+				vx, vy := nodes[i].x-nodes[j].x, nodes[i].y-nodes[j].y
+				d := math.Max(math.Hypot(vx, vy), 0.01)
+				force := k * k / d
+				dx[i] += vx / d * force
+				dy[i] += vy / d * force
+				dx[j] -= vx / d * force
+				dy[j] -= vy / d * force
+			}
+		}
+		// Притяжение к центру масс не дает несвязанным частям доски разлетаться
+		cx, cy := 0.0, 0.0
+		for _, node := range nodes {
+			cx += node.x
+			cy += node.y
+		}
+		cx, cy = cx/float64(n), cy/float64(n)
+		for i := range nodes {
+			dx[i] -= (nodes[i].x - cx) * forceGravity
+			dy[i] -= (nodes[i].y - cy) * forceGravity
+		}
+		for _, edge := range edges {
+			i, j := edge[0], edge[1]
+			vx, vy := nodes[i].x-nodes[j].x, nodes[i].y-nodes[j].y
+			d := math.Max(math.Hypot(vx, vy), 0.01)
+			force := d * d / k
+			dx[i] -= vx / d * force
+			dy[i] -= vy / d * force
+			dx[j] += vx / d * force
+			dy[j] += vy / d * force
+		}
+		for i := range nodes {
+			d := math.Hypot(dx[i], dy[i])
+			if d == 0 {
+				continue
+			}
+			step := math.Min(d, temperature)
+			nodes[i].x += dx[i] / d * step
+			nodes[i].y += dy[i] / d * step
+		}
+		// Линейное охлаждение
+		temperature = math.Max(temperature*(1-1/float64(iterations-iteration+1)), k*0.01)
+	}
+}
+
+// layeredLayout - послойная раскладка: циклы разрываются разворотом обратных связей, карточки
+// распределяются по слоям по самому длинному пути от источников (связи идут сверху вниз),
+// порядок внутри слоев уточняется методом барицентров для уменьшения пересечений.
+func layeredLayout(nodes []layoutNode, edges [][2]int, spacing float64) {
+	n := len(nodes)
+	dag := acyclicEdges(n, edges)
+	out := make([][]int, n)
+	in := make([][]int, n)
+	for _, edge := range dag {
+		out[edge[0]] = append(out[edge[0]], edge[1])
+		in[edge[1]] = append(in[edge[1]], edge[0])
+	}
+
+	// Слои по самому длинному пути (алгоритм Кана)
+	layer := make([]int, n)
+	degree := make([]int, n)
+	queue := []int{}
+	for i := 0; i < n; i++ {
+		degree[i] = len(in[i])
+		if degree[i] == 0 {
+			queue = append(queue, i)
+		}
+	}
+	for len(queue) > 0 {
+		v := queue[0]
+		queue = queue[1:]
+		for _, w := range out[v] {
+			if layer[v]+1 > layer[w] {
+				layer[w] = layer[v] + 1
+			}
+			degree[w]--
+			if degree[w] == 0 {
+				queue = append(queue, w)
+			}
+		}
+	}
+	layerCount := 0
+	for _, l := range layer {
+		if l+1 > layerCount {
+			layerCount = l + 1
+		}
+	}
+	layers := make([][]int, layerCount)
+	for i := 0; i < n; i++ {
+		layers[layer[i]] = append(layers[layer[i]], i)
+	}
+
+	// Порядок в слоях: проходы сверху вниз и снизу вверх по барицентрам соседей
+	order := make([]float64, n)
+	reindex := func(l []int) {
+		for position, v := range l {
+			order[v] = float64(position)
+		}
+	}
+	for _, l := range layers {
+		reindex(l)
+	}
+	barycenter := func(v int, neighbors []int) float64 {
+		if len(neighbors) == 0 {
+			return order[v]
+		}
+		sum := 0.0
+		for _, w := range neighbors {
+			sum += order[w]
+		}
+		return sum / float64(len(neighbors))
+	}
+	for sweep := 0; sweep < 4; sweep++ {
+		for l := 1; l < layerCount; l++ {
+			sortByBarycenter(layers[l], func(v int) float64 { return barycenter(v, in[v]) })
+			reindex(layers[l])
+		}
+		for l := layerCount - 2; l >= 0; l-- {
+			sortByBarycenter(layers[l], func(v int) float64 { return barycenter(v, out[v]) })
+			reindex(layers[l])
+		}
+	}
+
+	// Координаты: слои друг под другом, карточки в слое - слева направо, слои выровнены по центру
+	widths := make([]float64, layerCount)
+	maxWidth := 0.0
+	for l, vertices := range layers {
+		for i, v := range vertices {
+			if i > 0 {
+				widths[l] += spacing
+			}
+			widths[l] += nodes[v].width
+		}
+		maxWidth = math.Max(maxWidth, widths[l])
+	}
+	y := 0.0
+	for l, vertices := range layers {
+		height := 0.0
+		for _, v := range vertices {
+			height = math.Max(height, nodes[v].height)
+		}
+		x := (maxWidth - widths[l]) / 2
+		for _, v := range vertices {
+			nodes[v].x = x + nodes[v].width/2
+			nodes[v].y = y + height/2
+			x += nodes[v].width + spacing
+		}
+		y += height + spacing*2 // Между слоями - двойной зазор, чтобы были видны связи
+	}
+}
+
+// acyclicEdges возвращает связи без циклов: связи, ведущие назад при обходе в глубину
+// (по возрастанию ID), разворачиваются.
+func acyclicEdges(n int, edges [][2]int) [][2]int {
+	out := make([][]int, n)
+	for _, edge := range edges {
+		out[edge[0]] = append(out[edge[0]], edge[1])
+	}
+	const (
+		unvisited = iota
+		onStack
+		done
+	)
+	state := make([]int, n)
+	reversed := make(map[[2]int]bool)
+	var visit func(v int)
+	visit = func(v int) {
+		state[v] = onStack
+		for _, w := range out[v] {
+			switch state[w] {
+			case onStack:
+				reversed[[2]int{v, w}] = true
+			case unvisited:
+				visit(w)
+			}
+		}
+		state[v] = done
+	}
+	for v := 0; v < n; v++ {
+		if state[v] == unvisited {
+			visit(v)
+		}
+	}
+
+	seen := make(map[[2]int]bool)
+	dag := make([][2]int, 0, len(edges))
+	for _, edge := range edges {
+		if reversed[edge] {
+			edge = [2]int{edge[1], edge[0]}
+		}
+		if !seen[edge] {
+			seen[edge] = true
+			dag = append(dag, edge)
+		}
+	}
+	return dag
+}
+
+// sortByBarycenter упорядочивает вершины слоя по ключу, сохраняя текущий порядок при равенстве.
+func sortByBarycenter(vertices []int, key func(v int) float64) {
+	keys := make(map[int]float64, len(vertices))
+	for _, v := range vertices {
+		keys[v] = key(v)
+	}
+	sort.SliceStable(vertices, func(i, j int) bool { return keys[vertices[i]] < keys[vertices[j]] })
+}
+
+// removeOverlaps раздвигает перекрывающиеся карточки (с учетом зазора spacing) вдоль оси
+// наименьшего перекрытия, пока перекрытия не исчезнут или не закончатся проходы (passes).
+// Возвращает false, если перекрытия остались.
+func removeOverlaps(nodes []layoutNode, spacing float64, passes int) bool {
+	for pass := 0; pass < passes; pass++ {
+		moved := false
+		for i := range nodes {
+			for j := i + 1; j < len(nodes); j++ {
+				a, b := &nodes[i], &nodes[j]
+				vx, vy := b.x-a.x, b.y-a.y
+				overlapX, overlapY := nodesOverlap(a, b, spacing)
+				if overlapX <= 0.5 || overlapY <= 0.5 {
+					continue
+				}
+				moved = true
+				if overlapX < overlapY {
+					shift := overlapX / 2
+					if vx < 0 {
+						shift = -shift
+					}
+					a.x -= shift
+					b.x += shift
+				} else {
+					shift := overlapY / 2
+					if vy < 0 {
+						shift = -shift
+					}
+					a.y -= shift
+					b.y += shift
+				}
+			}
+		}
+		if !moved {
+			return true
+		}
+	}
+	// Последний проход мог устранить оставшиеся перекрытия: проверяем еще раз
+	for i := range nodes {
+		for j := i + 1; j < len(nodes); j++ {
+			if overlapX, overlapY := nodesOverlap(&nodes[i], &nodes[j], spacing); overlapX > 0.5 && overlapY > 0.5 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// nodesOverlap возвращает перекрытие карточек a и b по осям с учетом зазора spacing
+// (карточки перекрываются, если оба значения положительны).
+func nodesOverlap(a, b *layoutNode, spacing float64) (overlapX float64, overlapY float64) {
+	overlapX = (a.width+b.width)/2 + spacing - math.Abs(b.x-a.x)
+	overlapY = (a.height+b.height)/2 + spacing - math.Abs(b.y-a.y)
+	return overlapX, overlapY
+}
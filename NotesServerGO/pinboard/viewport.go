@@ -0,0 +1,81 @@
+package pinboard
+
+import (
+	"fmt"
+	"math"
+
+	"notes_server_go/models"
+)
+
+// Ограничения запросов по области просмотра.
+const (
+	DefaultViewportLimit = 500
+	MaxViewportLimit     = 5000
+)
+
+// Rect - прямоугольная область доски в координатах карточек.
+type Rect struct {
+	MinX float64 `json:"min_x"`
+	MinY float64 `json:"min_y"`
+	MaxX float64 `json:"max_x"`
+	MaxY float64 `json:"max_y"`
+}
+
+// NewViewport создает область просмотра по левому верхнему углу и размерам.
+func NewViewport(x, y, width, height float64) (Rect, error) {
+	for _, v := range []float64{x, y, width, height} {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return Rect{}, fmt.Errorf("координаты области должны быть конечными числами")
+		}
+	}
+	if width <= 0 || height <= 0 {
+		return Rect{}, fmt.Errorf("ширина и высота области должны быть больше нуля")
+	}
+	return Rect{MinX: x, MinY: y, MaxX: x + width, MaxY: y + height}, nil
+}
+
+// Expand расширяет область на margin с каждой стороны (запас для плавной прокрутки).
+func (r Rect) Expand(margin float64) Rect {
+	return Rect{MinX: r.MinX - margin, MinY: r.MinY - margin, MaxX: r.MaxX + margin, MaxY: r.MaxY + margin}
+}
+
+// ContainsCard проверяет, что карточка пересекает область (касание границы считается пересечением).
+func (r Rect) ContainsCard(note *models.PinboardNote) bool {
+	return note.PositionX+math.Max(note.Width, 0) >= r.MinX && note.PositionX <= r.MaxX &&
+		note.PositionY+math.Max(note.Height, 0) >= r.MinY && note.PositionY <= r.MaxY
+}
+
+// IntersectsSegment проверяет, что отрезок (x1, y1) - (x2, y2) пересекает область (отсечение Лианга-Барски).
+func (r Rect) IntersectsSegment(x1, y1, x2, y2 float64) bool {
+	t0, t1 := 0.0, 1.0
+	dx, dy := x2-x1, y2-y1
+	clip := func(p, q float64) bool {
+		if p == 0 {
+			return q >= 0
+		}
+		t := q / p
+		if p < 0 {
+			if t > t1 {
+				return false
+			}
+			t0 = math.Max(t0, t)
+		} else {
+			if t < t0 {
+				return false
+			}
+			t1 = math.Min(t1, t)
+		}
+		return true
+	}
+	return clip(-dx, x1-r.MinX) && clip(dx, r.MaxX-x1) && clip(-dy, y1-r.MinY) && clip(dy, r.MaxY-y1) && t0 <= t1
+}
+
+// ConnectionVisible проверяет, видна ли в области связь между карточками from и to: видна одна из карточек
+// или линия связи (отрезок между центрами карточек) проходит через область.
+func (r Rect) ConnectionVisible(from, to *models.PinboardNote) bool {
+	if r.ContainsCard(from) || r.ContainsCard(to) {
+		return true
+	}
+	return r.IntersectsSegment(from.PositionX+from.Width/2, from.PositionY+from.Height/2,
+		to.PositionX+to.Width/2, to.PositionY+to.Height/2)
+}
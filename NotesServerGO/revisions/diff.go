@@ -0,0 +1,126 @@
+// Package revisions содержит построчное сравнение версий заметок и настройки хранения истории версий.
+package revisions
+
+import "strings"
+
+// Операции строки сравнения.
+const (
+	OpEqual  = "equal"
+	OpInsert = "insert"
+	OpDelete = "delete"
+)
+
+// maxDiffCells ограничивает размер таблицы LCS (строки старой версии x строки новой после отбрасывания
+// общих начала и конца): ячейка занимает 2 байта, таблица - не больше ~2 МБ на сравнение.
+// Для больших изменений сравнение упрощается: старая часть удалена, новая вставлена.
+// Длина LCS не больше меньшей из частей (при таком ограничении - не больше 1000 строк), поэтому хватает uint16.
+const maxDiffCells = 1_000_000
+
+// Line - строка результата сравнения.
+type Line struct {
+	Op   string `json:"op"` // equal, insert, delete
+	Text string `json:"text"`
+}
+
+// Stats - количество вставленных и удаленных строк.
+type Stats struct {
+	Added   int `json:"added"`
+	Removed int `json:"removed"`
+}
+
+// Diff построчно сравнивает тексты по наибольшей общей подпоследовательности (LCS).
+func Diff(from, to string) []Line {
+	a, b := splitLines(from), splitLines(to)
+
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	lines := make([]Line, 0, len(a)+len(b))
+	for _, text := range a[:prefix] {
+		lines = append(lines, Line{Op: OpEqual, Text: text})
+	}
+	lines = append(lines, diffMiddle(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, text := range a[len(a)-suffix:] {
+		lines = append(lines, Line{Op: OpEqual, Text: text})
+	}
+	return lines
+}
+
+// Count считает вставленные и удаленные строки результата сравнения.
+func Count(lines []Line) Stats {
+	var stats Stats
+	for _, line := range lines {
+		switch line.Op {
+		case OpInsert:
+			stats.Added++
+		case OpDelete:
+			stats.Removed++
+		}
+	}
+	return stats
+}
+
+// diffMiddle сравнивает части текстов без общих начала и конца.
+func diffMiddle(a, b []string) []Line {
+	lines := make([]Line, 0, len(a)+len(b))
+	if len(a)*len(b) > maxDiffCells {
+		for _, text := range a {
+			lines = append(lines, Line{Op: OpDelete, Text: text})
+		}
+		for _, text := range b {
+			lines = append(lines, Line{Op: OpInsert, Text: text})
+		}
+		return lines
+	}
+
+	// lcs[i*width+j] - длина LCS суффиксов a[i:] и b[j:]; одна таблица вместо среза срезов
+	width := len(b) + 1
+	lcs := make([]uint16, (len(a)+1)*width)
+	at := func(i, j int) uint16 { return lcs[i*width+j] }
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i*width+j] = at(i+1, j+1) + 1
+			} else {
+				lcs[i*width+j] = max(at(i+1, j), at(i, j+1))
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			lines = append(lines, Line{Op: OpEqual, Text: a[i]})
+			i++
+			j++
+		case at(i+1, j) >= at(i, j+1):
+			lines = append(lines, Line{Op: OpDelete, Text: a[i]})
+			i++
+		default:
+			lines = append(lines, Line{Op: OpInsert, Text: b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		lines = append(lines, Line{Op: OpDelete, Text: a[i]})
+	}
+	for ; j < len(b); j++ {
+		lines = append(lines, Line{Op: OpInsert, Text: b[j]})
+	}
+	return lines
+}
+
+// splitLines делит текст на строки; пустой текст - ноль строк, завершающий перевод строки не дает пустой строки.
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(strings.ReplaceAll(text, "\r\n", "\n"), "\n"), "\n")
+}
@@ -0,0 +1,85 @@
+package revisions
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		from string
+		to   string
+		want []Line
+	}{
+		{
+			name: "пустые тексты",
+			want: []Line{},
+		},
+		{
+			name: "без изменений",
+			from: "a\nb\n",
+			to:   "a\r\nb",
+			want: []Line{{OpEqual, "a"}, {OpEqual, "b"}},
+		},
+		{
+			name: "новая заметка",
+			to:   "a\nb",
+			want: []Line{{OpInsert, "a"}, {OpInsert, "b"}},
+		},
+		{
+			name: "замена строки в середине",
+			from: "a\nb\nc",
+			to:   "a\nx\nc",
+			want: []Line{{OpEqual, "a"}, {OpDelete, "b"}, {OpInsert, "x"}, {OpEqual, "c"}},
+		},
+		{
+			name: "вставка и удаление",
+			from: "a\nb\nc\nd",
+			to:   "b\nc\ne\nd",
+			want: []Line{{OpDelete, "a"}, {OpEqual, "b"}, {OpEqual, "c"}, {OpInsert, "e"}, {OpEqual, "d"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Diff(tt.from, tt.to); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Diff = %+v, ожидалось %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffLargeChange(t *testing.T) {
+	// Части больше maxDiffCells: старая удаляется, новая вставляется без построения таблицы LCS
+	from := strings.Repeat("старая\n", 1500)
+	to := "общая\n" + strings.Repeat("новая\n", 1500) + "общая"
+	from = "общая\n" + from + "общая"
+	stats := Count(Diff(from, to))
+	if stats != (Stats{Added: 1500, Removed: 1500}) {
+		t.Errorf("Count = %+v", stats)
+	}
+}
+
+func TestDiffReconstructsBothTexts(t *testing.T) {
+	from := "заголовок\nпервый\nвторой\nтретий\nконец"
+	to := "заголовок\nвторой\nновый\nтретий\nчетвертый\nконец"
+	var old, updated []string
+	for _, line := range Diff(from, to) {
+		if line.Op != OpInsert {
+			old = append(old, line.Text)
+		}
+		if line.Op != OpDelete {
+			updated = append(updated, line.Text)
+		}
+	}
+	if got := strings.Join(old, "\n"); got != from {
+		t.Errorf("старая версия = %q, ожидалось %q", got, from)
+	}
+	if got := strings.Join(updated, "\n"); got != to {
+		t.Errorf("новая версия = %q, ожидалось %q", got, to)
+	}
+	if stats := Count(Diff(from, to)); stats != (Stats{Added: 2, Removed: 1}) {
+		t.Errorf("Count = %+v", stats)
+	}
+}
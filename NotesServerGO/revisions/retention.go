@@ -0,0 +1,45 @@
+package revisions
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultMaxPerNote - сколько последних версий заметки хранится по умолчанию.
+const DefaultMaxPerNote = 50
+
+// Retention - настройки хранения истории версий заметок. Нулевые значения - без ограничения.
+// Последняя версия заметки хранится всегда.
+type Retention struct {
+	MaxPerNote int           // Сколько последних версий хранить для каждой заметки
+	MaxAge     time.Duration // Сколько хранить версии с момента создания
+}
+
+// DefaultRetention - настройки хранения, если переменные окружения не заданы.
+var DefaultRetention = Retention{MaxPerNote: DefaultMaxPerNote}
+
+// RetentionFromEnv читает настройки хранения из переменных окружения:
+//
+//	NOTE_REVISIONS_MAX_PER_NOTE - сколько последних версий заметки хранить (по умолчанию 50, 0 - все)
+//	NOTE_REVISIONS_MAX_AGE_DAYS - сколько дней хранить версии (по умолчанию 0 - без ограничения)
+func RetentionFromEnv() (Retention, error) {
+	retention := DefaultRetention
+	if value := strings.TrimSpace(os.Getenv("NOTE_REVISIONS_MAX_PER_NOTE")); value != "" {
+		count, err := strconv.Atoi(value)
+		if err != nil || count < 0 {
+			return Retention{}, fmt.Errorf("неверное значение NOTE_REVISIONS_MAX_PER_NOTE: %q (целое число не меньше 0)", value)
+		}
+		retention.MaxPerNote = count
+	}
+	if value := strings.TrimSpace(os.Getenv("NOTE_REVISIONS_MAX_AGE_DAYS")); value != "" {
+		days, err := strconv.Atoi(value)
+		if err != nil || days < 0 {
+			return Retention{}, fmt.Errorf("неверное значение NOTE_REVISIONS_MAX_AGE_DAYS: %q (целое число не меньше 0)", value)
+		}
+		retention.MaxAge = time.Duration(days) * 24 * time.Hour
+	}
+	return retention, nil
+}
@@ -0,0 +1,170 @@
+package schedule
+
+import (
+	"sort"
+	"time"
+
+	"notes_server_go/models"
+)
+
+// MaxConflicts ограничивает количество пересечений в одном ответе.
+const MaxConflicts = 500
+
+// ConflictCheckDays - на сколько дней вперед проверяются пересечения повторяющихся записей
+// при их создании, изменении и синхронизации.
+const ConflictCheckDays = 92
+
+// ConflictOccurrence - повторение записи, участвующее в пересечении.
+type ConflictOccurrence struct {
+	EntryId      int64   `json:"entry_id"`
+	Date         string  `json:"date"`
+	Time         string  `json:"time"`
+	OriginalDate string  `json:"original_date"`
+	Note         *string `json:"note,omitempty"`
+	ExceptionId  *int64  `json:"exception_id,omitempty"`
+}
+
+// Conflict - пересечение по времени двух повторений разных записей расписания.
+// Date и OverlapStart - начало пересечения; OverlapEnd может приходиться на следующие сутки,
+// если интервал переходит через полночь. У записи без времени окончания ("HH:mm") пересечение -
+// момент ее начала, OverlapMinutes = 0.
+type Conflict struct {
+	Date           string             `json:"date"`
+	OverlapStart   string             `json:"overlap_start"` // "HH:mm"
+	OverlapEnd     string             `json:"overlap_end"`   // "HH:mm"
+	OverlapMinutes int                `json:"overlap_minutes"`
+	First          ConflictOccurrence `json:"first"`
+	Second         ConflictOccurrence `json:"second"`
+}
+
+// busyInterval - повторение записи в абсолютном времени.
+type busyInterval struct {
+	occurrence *Occurrence
+	start      time.Time
+	end        time.Time
+}
+
+// FindScheduleConflicts возвращает пересечения повторений записей расписания, начинающиеся
+// в диапазоне [from, to], с учетом исключений. truncated = true, если найдено больше MaxConflicts.
+// Записи без корректного времени (на весь день) в пересечениях не участвуют. Время записей сравнивается
+// с учетом их часовых поясов, даты и время пересечений выводятся в часовом поясе БД dbLoc.
+// Повторения разворачиваются с запасом в сутки с каждой стороны: интервалы соседних дней могут
+// переходить через полночь в диапазон, а записи в других часовых поясах - приходиться на другую дату.
+func FindScheduleConflicts(entries []models.ScheduleEntry, exceptions []models.ScheduleEntryException, dbLoc *time.Location, from time.Time, to time.Time) (conflicts []Conflict, truncated bool) {
+	occurrences := ExpandAll(entries, exceptions, dateOnly(from).AddDate(0, 0, -1), dateOnly(to).AddDate(0, 0, 1))
+	return FindConflicts(occurrences, dbLoc, from, to)
+}
+
+// FindConflicts ищет пересечения среди развернутых повторений. Пересекаются интервалы, у которых
+// начало одного раньше конца другого и наоборот, а также начинающиеся одновременно.
+// Повторения одной записи между собой не сравниваются.
+func FindConflicts(occurrences []Occurrence, dbLoc *time.Location, from time.Time, to time.Time) (conflicts []Conflict, truncated bool) {
+	return findConflicts(occurrences, dbLoc, from, to, 0)
+}
+
+// findConflicts - общая часть FindConflicts и FindEntryConflicts: при onlyEntryID != 0
+// учитываются только пары, в которых участвует эта запись.
+func findConflicts(occurrences []Occurrence, dbLoc *time.Location, from time.Time, to time.Time, onlyEntryID int64) (conflicts []Conflict, truncated bool) {
+	intervals := make([]busyInterval, 0, len(occurrences))
+	for i := range occurrences {
+		start, end, ok := occurrenceInterval(&occurrences[i], EntryLocation(occurrences[i].Entry, dbLoc))
+		if !ok {
+			continue
+		}
+		start, end = start.In(dbLoc), end.In(dbLoc)
+		intervals = append(intervals, busyInterval{occurrence: &occurrences[i], start: start, end: end})
+	}
+	sort.SliceStable(intervals, func(i, j int) bool {
+		if !intervals[i].start.Equal(intervals[j].start) {
+			return intervals[i].start.Before(intervals[j].start)
+		}
+		return intervals[i].occurrence.EntryId < intervals[j].occurrence.EntryId
+	})
+
+	conflicts = []Conflict{}
+	var active []busyInterval
+	for _, current := range intervals {
+		// Завершившиеся интервалы больше ни с чем не пересекутся (интервалы отсортированы по началу)
+		kept := active[:0]
+		for _, interval := range active {
+			if interval.end.After(current.start) || interval.start.Equal(current.start) {
+				kept = append(kept, interval)
+			}
+		}
+		active = kept
+
+		// Начало пересечения - начало текущего интервала: дата проверяется один раз для всех пар
+		if inRange(current.start.Format(DateLayout), from, to) {
+			for _, interval := range active {
+				if interval.occurrence.EntryId == current.occurrence.EntryId {
+					continue
+				}
+				if onlyEntryID != 0 && interval.occurrence.EntryId != onlyEntryID && current.occurrence.EntryId != onlyEntryID {
+					continue
+				}
+				if len(conflicts) >= MaxConflicts {
+					return conflicts, true
+				}
+				conflicts = append(conflicts, newConflict(interval, current))
+			}
+		}
+		active = append(active, current)
+	}
+	return conflicts, false
+}
+
+// FindEntryConflicts возвращает пересечения записи entryID с остальными записями в диапазоне [from, to].
+func FindEntryConflicts(entries []models.ScheduleEntry, exceptions []models.ScheduleEntryException, entryID int64, dbLoc *time.Location, from time.Time, to time.Time) (conflicts []Conflict, truncated bool) {
+	occurrences := ExpandAll(entries, exceptions, dateOnly(from).AddDate(0, 0, -1), dateOnly(to).AddDate(0, 0, 1))
+	return findConflicts(occurrences, dbLoc, from, to, entryID)
+}
+
+// ConflictWindow возвращает диапазон дат, в котором проверяются пересечения записи:
+// собственная дата с соседними днями (на случай разницы часовых поясов) для записи без повторения,
+// ConflictCheckDays дней начиная с сегодняшнего дня (или с начала серии, если она еще не началась)
+// для повторяющейся.
+func ConflictWindow(entry *models.ScheduleEntry, today time.Time) (from time.Time, to time.Time, err error) {
+	start, err := ParseDate(entry.Date)
+	if err != nil {
+		return from, to, err
+	}
+	if rule, ruleErr := entry.ParseRecurrence(); ruleErr != nil || rule == nil {
+		return start.AddDate(0, 0, -1), start.AddDate(0, 0, 1), nil
+	}
+	from = dateOnly(today)
+	if start.After(from) {
+		from = start
+	}
+	return from, from.AddDate(0, 0, ConflictCheckDays), nil
+}
+
+// newConflict формирует описание пересечения двух интервалов; second начинается не раньше first.
+func newConflict(first busyInterval, second busyInterval) Conflict {
+	overlapStart, overlapEnd := second.start, first.end
+	if second.end.Before(overlapEnd) {
+		overlapEnd = second.end
+	}
+	if overlapEnd.Before(overlapStart) {
+		overlapEnd = overlapStart // Начало точечного интервала совпадает с началом другого
+	}
+	return Conflict{
+		Date:           overlapStart.Format(DateLayout),
+		OverlapStart:   overlapStart.Format("15:04"),
+		OverlapEnd:     overlapEnd.Format("15:04"),
+		OverlapMinutes: int(overlapEnd.Sub(overlapStart).Minutes()),
+		First:          conflictOccurrence(first.occurrence),
+		Second:         conflictOccurrence(second.occurrence),
+	}
+}
+
+// conflictOccurrence возвращает краткое описание повторения для ответа.
+func conflictOccurrence(occurrence *Occurrence) ConflictOccurrence {
+	return ConflictOccurrence{
+		EntryId:      occurrence.EntryId,
+		Date:         occurrence.Date,
+		Time:         occurrence.Time,
+		OriginalDate: occurrence.OriginalDate,
+		Note:         occurrence.Note,
+		ExceptionId:  occurrence.ExceptionId,
+	}
+}
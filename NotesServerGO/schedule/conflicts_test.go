@@ -0,0 +1,143 @@
+package schedule
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"notes_server_go/models"
+)
+
+// conflictPair - пересечение в виде, удобном для сравнения в тестах.
+type conflictPair struct {
+	Date    string
+	Start   string
+	End     string
+	Minutes int
+	First   int64
+	Second  int64
+}
+
+func conflictPairs(conflicts []Conflict) []conflictPair {
+	pairs := []conflictPair{}
+	for _, c := range conflicts {
+		pairs = append(pairs, conflictPair{c.Date, c.OverlapStart, c.OverlapEnd, c.OverlapMinutes, c.First.EntryId, c.Second.EntryId})
+	}
+	return pairs
+}
+
+func TestFindConflicts(t *testing.T) {
+	daily := `{"type":"daily"}`
+	tests := []struct {
+		name    string
+		entries []models.ScheduleEntry
+		from    string
+		to      string
+		want    []conflictPair
+	}{
+		{
+			name: "частичное пересечение",
+			entries: []models.ScheduleEntry{
+				{Id: 1, Date: "2025-03-01", Time: "10:00 - 11:00"},
+				{Id: 2, Date: "2025-03-01", Time: "10:30 - 12:00"},
+			},
+			from: "2025-03-01", to: "2025-03-01",
+			want: []conflictPair{{"2025-03-01", "10:30", "11:00", 30, 1, 2}},
+		},
+		{
+			name: "смежные интервалы не пересекаются",
+			entries: []models.ScheduleEntry{
+				{Id: 1, Date: "2025-03-01", Time: "10:00 - 11:00"},
+				{Id: 2, Date: "2025-03-01", Time: "11:00 - 12:00"},
+			},
+			from: "2025-03-01", to: "2025-03-01",
+			want: []conflictPair{},
+		},
+		{
+			name: "запись без окончания внутри интервала",
+			entries: []models.ScheduleEntry{
+				{Id: 1, Date: "2025-03-01", Time: "10:00 - 11:00"},
+				{Id: 2, Date: "2025-03-01", Time: "10:15"},
+			},
+			from: "2025-03-01", to: "2025-03-01",
+			want: []conflictPair{{"2025-03-01", "10:15", "10:15", 0, 1, 2}},
+		},
+		{
+			name: "одновременное начало записей без окончания",
+			entries: []models.ScheduleEntry{
+				{Id: 1, Date: "2025-03-01", Time: "09:00"},
+				{Id: 2, Date: "2025-03-01", Time: "09:00"},
+			},
+			from: "2025-03-01", to: "2025-03-01",
+			want: []conflictPair{{"2025-03-01", "09:00", "09:00", 0, 1, 2}},
+		},
+		{
+			name: "интервал через полночь",
+			entries: []models.ScheduleEntry{
+				{Id: 1, Date: "2025-03-01", Time: "23:00 - 01:00"},
+				{Id: 2, Date: "2025-03-02", Time: "00:30 - 02:00"},
+			},
+			from: "2025-03-02", to: "2025-03-02",
+			want: []conflictPair{{"2025-03-02", "00:30", "01:00", 30, 1, 2}},
+		},
+		{
+			name: "повторения одной записи не сравниваются, записи без времени пропускаются",
+			entries: []models.ScheduleEntry{
+				{Id: 1, Date: "2025-03-01", Time: "10:00 - 11:00", RecurrenceJson: &daily},
+				{Id: 2, Date: "2025-03-02", Time: ""},
+			},
+			from: "2025-03-01", to: "2025-03-05",
+			want: []conflictPair{},
+		},
+		{
+			name: "разные часовые пояса",
+			entries: []models.ScheduleEntry{
+				{Id: 1, Date: "2025-03-01", Time: "10:00 - 11:00"},
+				{Id: 2, Date: "2025-03-01", Time: "09:30 - 10:30", TimeZone: stringPtr("Europe/London")},
+			},
+			from: "2025-03-01", to: "2025-03-01",
+			want: []conflictPair{{"2025-03-01", "10:30", "11:00", 30, 1, 2}},
+		},
+	}
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("нет базы часовых поясов: %v", err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conflicts, truncated := FindScheduleConflicts(tt.entries, nil, berlin, mustDate(t, tt.from), mustDate(t, tt.to))
+			if truncated {
+				t.Errorf("truncated = true")
+			}
+			if got := conflictPairs(conflicts); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("пересечения = %+v, ожидалось %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindConflictsTruncated(t *testing.T) {
+	daily := `{"type":"daily"}`
+	entries := []models.ScheduleEntry{
+		{Id: 1, Date: "2025-01-01", Time: "10:00 - 11:00", RecurrenceJson: &daily},
+		{Id: 2, Date: "2025-01-01", Time: "10:00 - 11:00", RecurrenceJson: &daily},
+	}
+	conflicts, truncated := FindScheduleConflicts(entries, nil, time.UTC, mustDate(t, "2025-01-01"), mustDate(t, "2026-12-31"))
+	if !truncated || len(conflicts) != MaxConflicts {
+		t.Errorf("пересечений: %d (truncated = %v), ожидалось %d и truncated", len(conflicts), truncated, MaxConflicts)
+	}
+}
+
+func TestFindEntryConflicts(t *testing.T) {
+	entries := []models.ScheduleEntry{
+		{Id: 1, Date: "2025-03-01", Time: "10:00 - 11:00"},
+		{Id: 2, Date: "2025-03-01", Time: "10:00 - 11:00"},
+		{Id: 3, Date: "2025-03-01", Time: "12:00 - 13:00"},
+		{Id: 4, Date: "2025-03-01", Time: "12:30 - 13:00"},
+	}
+	conflicts, _ := FindEntryConflicts(entries, nil, 4, time.UTC, mustDate(t, "2025-03-01"), mustDate(t, "2025-03-01"))
+	want := []conflictPair{{"2025-03-01", "12:30", "13:00", 30, 3, 4}}
+	if got := conflictPairs(conflicts); !reflect.DeepEqual(got, want) {
+		t.Errorf("пересечения = %+v, ожидалось %+v", got, want)
+	}
+}
@@ -0,0 +1,311 @@
+package schedule
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"notes_server_go/models"
+)
+
+// MaxFieldNameLength ограничивает длину названия динамического поля.
+const MaxFieldNameLength = 100
+
+// dynamicField - поле из DynamicFieldsJson записи в исходном порядке.
+type dynamicField struct {
+	key   string
+	value json.RawMessage
+}
+
+// parseDynamicFields разбирает DynamicFieldsJson (JSON-объект), сохраняя порядок полей:
+// клиент показывает поля в том порядке, в котором они записаны.
+func parseDynamicFields(fieldsJson *string) ([]dynamicField, error) {
+	if fieldsJson == nil || strings.TrimSpace(*fieldsJson) == "" {
+		return nil, nil
+	}
+	decoder := json.NewDecoder(strings.NewReader(*fieldsJson))
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, fmt.Errorf("неверный JSON динамических полей: %v", err)
+	}
+	if token == nil {
+		return nil, nil // null
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '{' {
+		return nil, fmt.Errorf("динамические поля должны быть JSON-объектом")
+	}
+	var fields []dynamicField
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, fmt.Errorf("неверный JSON динамических полей: %v", err)
+		}
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return nil, fmt.Errorf("неверный JSON динамических полей: %v", err)
+		}
+		fields = append(fields, dynamicField{key: token.(string), value: value})
+	}
+	if _, err := decoder.Token(); err != nil {
+		return nil, fmt.Errorf("неверный JSON динамических полей: %v", err)
+	}
+	return fields, nil
+}
+
+// encodeDynamicFields записывает поля в JSON-объект в их порядке.
+func encodeDynamicFields(fields []dynamicField) string {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, field := range fields {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(field.key)
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(field.value)
+	}
+	buf.WriteByte('}')
+	return buf.String()
+}
+
+// scalarFieldValue возвращает значение поля строкой, как его показывает клиент (value.toString()).
+// ok = false для объектов и массивов.
+func scalarFieldValue(raw json.RawMessage) (value string, ok bool) {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var decoded interface{}
+	if err := decoder.Decode(&decoded); err != nil {
+		return "", false
+	}
+	switch v := decoded.(type) {
+	case nil:
+		return "", true
+	case string:
+		return v, true
+	case json.Number:
+		return v.String(), true
+	case bool:
+		return strconv.FormatBool(v), true
+	}
+	return "", false
+}
+
+// NormalizeFieldValue проверяет значение поля по его типу и приводит его к каноническому виду:
+// число без лишних нулей, дату "yyyy-MM-dd", флажок "true"/"false", вариант choice - как в Options.
+func NormalizeFieldValue(definition *models.DynamicFieldDefinition, value string) (string, error) {
+	value = strings.TrimSpace(value)
+	switch definition.Type {
+	case models.DynamicFieldNumber:
+		number, err := strconv.ParseFloat(strings.Replace(value, ",", ".", 1), 64)
+		if err != nil || math.IsNaN(number) || math.IsInf(number, 0) {
+			return "", fmt.Errorf("поле %q: ожидается число, получено %q", definition.Name, value)
+		}
+		return strconv.FormatFloat(number, 'f', -1, 64), nil
+	case models.DynamicFieldDate:
+		date, err := NormalizeDate(value)
+		if err != nil {
+			return "", fmt.Errorf("поле %q: %v", definition.Name, err)
+		}
+		return date, nil
+	case models.DynamicFieldChoice:
+		for _, option := range definition.Options {
+			if strings.EqualFold(option, value) {
+				return option, nil
+			}
+		}
+		return "", fmt.Errorf("поле %q: значение %q не входит в варианты %s", definition.Name, value, strings.Join(definition.Options, ", "))
+	case models.DynamicFieldCheckbox:
+		checked, err := strconv.ParseBool(strings.ToLower(value))
+		if err != nil {
+			return "", fmt.Errorf("поле %q: ожидается true или false, получено %q", definition.Name, value)
+		}
+		return strconv.FormatBool(checked), nil
+	}
+	return value, nil
+}
+
+// ValidateDynamicFields проверяет DynamicFieldsJson записи по схеме полей совместной БД и возвращает его
+// с нормализованными значениями (строками, как их хранит клиент). Обязательные поля должны быть заполнены;
+// поля, которых нет в схеме, не проверяются и сохраняются как есть. Без схемы значение не меняется.
+// Ошибка перечисляет все нарушения.
+func ValidateDynamicFields(fieldsJson *string, definitions []models.DynamicFieldDefinition) (*string, error) {
+	if len(definitions) == 0 {
+		return fieldsJson, nil
+	}
+	fields, err := parseDynamicFields(fieldsJson)
+	if err != nil {
+		return nil, err
+	}
+	index := make(map[string]int, len(fields))
+	for i, field := range fields {
+		index[strings.TrimSpace(field.key)] = i
+	}
+
+	var problems []string
+	for i := range definitions {
+		definition := &definitions[i]
+		position, present := index[definition.Name]
+		value := ""
+		if present {
+			var ok bool
+			if value, ok = scalarFieldValue(fields[position].value); !ok {
+				problems = append(problems, fmt.Sprintf("поле %q: ожидается строка", definition.Name))
+				continue
+			}
+		}
+		if strings.TrimSpace(value) == "" {
+			if definition.Required {
+				problems = append(problems, fmt.Sprintf("поле %q обязательно", definition.Name))
+			}
+			continue
+		}
+		normalized, err := NormalizeFieldValue(definition, value)
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		encoded, _ := json.Marshal(normalized)
+		fields[position] = dynamicField{key: definition.Name, value: encoded}
+	}
+	if len(problems) > 0 {
+		return nil, errors.New(strings.Join(problems, "; "))
+	}
+	if len(fields) == 0 {
+		return fieldsJson, nil
+	}
+	result := encodeDynamicFields(fields)
+	return &result, nil
+}
+
+// DynamicFieldsEqual сравнивает два значения DynamicFieldsJson по содержимому (без учета порядка ключей и пробелов).
+// Отсутствующее значение, null и пустой объект считаются равными.
+func DynamicFieldsEqual(a *string, b *string) bool {
+	decode := func(fieldsJson *string) (map[string]interface{}, bool) {
+		fields := map[string]interface{}{}
+		if fieldsJson == nil || strings.TrimSpace(*fieldsJson) == "" {
+			return fields, true
+		}
+		if err := json.Unmarshal([]byte(*fieldsJson), &fields); err != nil {
+			return nil, false
+		}
+		return fields, true
+	}
+	left, okLeft := decode(a)
+	right, okRight := decode(b)
+	if !okLeft || !okRight {
+		return okLeft == okRight && *a == *b
+	}
+	return reflect.DeepEqual(left, right)
+}
+
+// FieldFilter - отбор записей расписания по значению динамического поля: точное совпадение Value
+// или диапазон [Min, Max] (для числовых полей и дат).
+type FieldFilter struct {
+	Definition models.DynamicFieldDefinition
+	Value      *string
+	Min        *string
+	Max        *string
+}
+
+// NewFieldFilter проверяет и нормализует условия отбора по типу поля. Поле, которого нет в схеме,
+// сравнивается как текст (definition с типом text).
+func NewFieldFilter(definition models.DynamicFieldDefinition, value *string, min *string, max *string) (*FieldFilter, error) {
+	if value == nil && min == nil && max == nil {
+		return nil, fmt.Errorf("укажите значение поля (value) или диапазон (min, max)")
+	}
+	if value != nil && (min != nil || max != nil) {
+		return nil, fmt.Errorf("value нельзя указывать вместе с min и max")
+	}
+	if (min != nil || max != nil) && definition.Type != models.DynamicFieldNumber && definition.Type != models.DynamicFieldDate {
+		return nil, fmt.Errorf("диапазон min/max поддерживается только для полей типа number и date")
+	}
+	filter := &FieldFilter{Definition: definition}
+	for _, bound := range []struct {
+		source *string
+		target **string
+	}{{value, &filter.Value}, {min, &filter.Min}, {max, &filter.Max}} {
+		if bound.source == nil {
+			continue
+		}
+		normalized := strings.TrimSpace(*bound.source)
+		if normalized != "" {
+			var err error
+			if normalized, err = NormalizeFieldValue(&filter.Definition, normalized); err != nil {
+				return nil, err
+			}
+		}
+		*bound.target = &normalized
+	}
+	return filter, nil
+}
+
+// Matches проверяет, что значение поля записи удовлетворяет условию. Пустое Value отбирает записи,
+// у которых поле не заполнено; текст сравнивается без учета регистра.
+func (f *FieldFilter) Matches(entry *models.ScheduleEntry) bool {
+	fields, err := parseDynamicFields(entry.DynamicFieldsJson)
+	if err != nil {
+		return false
+	}
+	value := ""
+	for _, field := range fields {
+		if strings.TrimSpace(field.key) == f.Definition.Name {
+			value, _ = scalarFieldValue(field.value)
+			break
+		}
+	}
+	value = strings.TrimSpace(value)
+
+	if f.Value != nil {
+		if *f.Value == "" || value == "" {
+			return *f.Value == value
+		}
+		normalized, err := NormalizeFieldValue(&f.Definition, value)
+		if err != nil {
+			return false
+		}
+		if f.Definition.Type == models.DynamicFieldText {
+			return strings.EqualFold(normalized, *f.Value)
+		}
+		return normalized == *f.Value
+	}
+
+	if value == "" {
+		return false
+	}
+	normalized, err := NormalizeFieldValue(&f.Definition, value)
+	if err != nil {
+		return false
+	}
+	return f.inRange(normalized)
+}
+
+// inRange сравнивает нормализованное значение с границами Min и Max: числа - как числа, даты - как строки.
+func (f *FieldFilter) inRange(value string) bool {
+	compare := func(a string, b string) int {
+		if f.Definition.Type == models.DynamicFieldNumber {
+			x, _ := strconv.ParseFloat(a, 64)
+			y, _ := strconv.ParseFloat(b, 64)
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			}
+			return 0
+		}
+		return strings.Compare(a, b)
+	}
+	if f.Min != nil && *f.Min != "" && compare(value, *f.Min) < 0 {
+		return false
+	}
+	if f.Max != nil && *f.Max != "" && compare(value, *f.Max) > 0 {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,77 @@
+package schedule
+
+import (
+	"sort"
+	"time"
+
+	"notes_server_go/models"
+)
+
+// BusyInterval - интервал занятости без подробностей записи. End не включается в интервал.
+type BusyInterval struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// EntryAttendee возвращает пользователя, которому принадлежит время записи: ответственного,
+// а если он не назначен - автора записи. nil - запись не относится ни к кому.
+func EntryAttendee(entry *models.ScheduleEntry) *int64 {
+	if entry == nil {
+		return nil
+	}
+	if entry.AssigneeUserId != nil {
+		return entry.AssigneeUserId
+	}
+	return entry.CreatedByUserId
+}
+
+// BusyIntervals возвращает интервалы занятости пользователя userID по развернутым повторениям,
+// обрезанные границами [from, to). Учитываются повторения записей, которые относятся к пользователю
+// (см. EntryAttendee) и у которых есть длительность: записи без времени или без времени окончания
+// занятость не создают. Время записей пересчитывается с учетом их часовых поясов (dbLoc - часовой пояс БД).
+func BusyIntervals(occurrences []Occurrence, dbLoc *time.Location, userID int64, from time.Time, to time.Time) []BusyInterval {
+	intervals := []BusyInterval{}
+	for i := range occurrences {
+		occurrence := &occurrences[i]
+		attendee := EntryAttendee(occurrence.Entry)
+		if attendee == nil || *attendee != userID {
+			continue
+		}
+		start, end, ok := occurrenceInterval(occurrence, EntryLocation(occurrence.Entry, dbLoc))
+		if !ok {
+			continue
+		}
+		if start.Before(from) {
+			start = from
+		}
+		if end.After(to) {
+			end = to
+		}
+		if !start.Before(end) {
+			continue
+		}
+		intervals = append(intervals, BusyInterval{Start: start, End: end})
+	}
+	return intervals
+}
+
+// MergeBusyIntervals сортирует интервалы и объединяет пересекающиеся и смежные.
+// Время результата переводится в часовой пояс loc.
+func MergeBusyIntervals(intervals []BusyInterval, loc *time.Location) []BusyInterval {
+	sort.Slice(intervals, func(i, j int) bool { return intervals[i].Start.Before(intervals[j].Start) })
+	merged := []BusyInterval{}
+	for _, interval := range intervals {
+		last := len(merged) - 1
+		if last >= 0 && !interval.Start.After(merged[last].End) {
+			if interval.End.After(merged[last].End) {
+				merged[last].End = interval.End
+			}
+			continue
+		}
+		merged = append(merged, interval)
+	}
+	for i := range merged {
+		merged[i].Start, merged[i].End = merged[i].Start.In(loc), merged[i].End.In(loc)
+	}
+	return merged
+}
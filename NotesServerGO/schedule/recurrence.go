@@ -0,0 +1,342 @@
+package schedule
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"notes_server_go/models"
+)
+
+// DateLayout - формат даты записей расписания (как на клиенте: "yyyy-MM-dd").
+const DateLayout = "2006-01-02"
+
+// MaxOccurrencesPerEntry ограничивает количество повторений одной записи в одном развертывании,
+// чтобы ошибочное правило (например, ежедневное без конца на огромном диапазоне) не исчерпало память.
+const MaxOccurrencesPerEntry = 5000
+
+// MaxRangeDays - максимальная длина диапазона дат, который можно развернуть за один запрос.
+const MaxRangeDays = 732
+
+// maxIterations ограничивает число шагов перебора для ежемесячных и ежегодных правил,
+// в которых часть шагов пропускается (например, 31 число в коротких месяцах).
+const maxIterations = 100000
+
+// Occurrence - конкретное повторение записи расписания в определенную дату.
+type Occurrence struct {
+	EntryId      int64                 `json:"entry_id"`
+	Date         string                `json:"date"`          // "yyyy-MM-dd"
+	Time         string                `json:"time"`          // "HH:mm - HH:mm"
+	OriginalDate string                `json:"original_date"` // Дата по правилу повторения
+	Note         *string               `json:"note,omitempty"`
+	IsRecurring  bool                  `json:"is_recurring"`
+	ExceptionId  *int64                `json:"exception_id,omitempty"` // Заполнено, если повторение изменено исключением
+	Entry        *models.ScheduleEntry `json:"entry"`
+
+	// Заполняются Localize: часовой пояс, в котором задано время повторения, и само повторение
+	// в часовом поясе пользователя
+	TimeZone  string     `json:"time_zone,omitempty"`
+	Start     *time.Time `json:"start,omitempty"`
+	End       *time.Time `json:"end,omitempty"`
+	LocalDate string     `json:"local_date,omitempty"` // "yyyy-MM-dd"
+	LocalTime string     `json:"local_time,omitempty"` // "HH:mm - HH:mm"
+}
+
+// ParseDate разбирает дату записи расписания. Допускается дата со временем ("2025-03-01T00:00:00.000") -
+// учитывается только дата. Результат - полночь UTC.
+func ParseDate(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if len(value) > len(DateLayout) {
+		value = value[:len(DateLayout)]
+	}
+	date, err := time.Parse(DateLayout, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("неверный формат даты %q, ожидается yyyy-MM-dd", value)
+	}
+	return date, nil
+}
+
+// dateOnly отбрасывает время и часовой пояс, оставляя календарную дату (полночь UTC).
+func dateOnly(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// daysIn возвращает количество дней в месяце.
+func daysIn(year int, month time.Month) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+// RecurrenceDates возвращает даты повторений правила rule для серии, начинающейся в start,
+// попадающие в диапазон [from, to] (обе границы включительно).
+// Семантика совпадает с клиентом: count включает первое повторение, endDate включительно,
+// ежемесячные повторения пропускают месяцы без нужного числа, ежегодные - 29 февраля в невисокосные годы.
+func RecurrenceDates(start time.Time, rule *models.Recurrence, from time.Time, to time.Time) []time.Time {
+	start, from, to = dateOnly(start), dateOnly(from), dateOnly(to)
+	limit := to
+	if rule.EndDate != nil && !rule.EndDate.IsZero() {
+		if endDate := dateOnly(rule.EndDate.Time); endDate.Before(limit) {
+			limit = endDate
+		}
+	}
+	count := -1 // Без ограничения по количеству
+	if rule.Count != nil && *rule.Count > 0 {
+		count = *rule.Count
+	}
+	interval := rule.IntervalOrDefault()
+
+	var dates []time.Time
+	if limit.Before(start) || limit.Before(from) {
+		return dates
+	}
+
+	switch rule.Type {
+	case models.RecurrenceTypeDaily, models.RecurrenceTypeWeekly:
+		stepDays := interval
+		if rule.Type == models.RecurrenceTypeWeekly {
+			stepDays = 7 * interval
+		}
+		// Шаги фиксированной длины: сразу переходим к первому повторению не раньше from
+		k := 0
+		if from.After(start) {
+			// Через Unix-секунды: time.Duration переполняется на промежутках длиннее ~292 лет
+			daysFromStart := int((from.Unix() - start.Unix()) / 86400)
+			k = (daysFromStart + stepDays - 1) / stepDays
+		}
+		for ; count < 0 || k < count; k++ {
+			date := start.AddDate(0, 0, k*stepDays)
+			if date.After(limit) || len(dates) >= MaxOccurrencesPerEntry {
+				break
+			}
+			if date.Before(from) {
+				continue
+			}
+			dates = append(dates, date)
+		}
+
+	case models.RecurrenceTypeMonthly, models.RecurrenceTypeYearly:
+		occurrenceIndex := 0
+		for k := 0; k < maxIterations; k++ {
+			var year int
+			var month time.Month
+			if rule.Type == models.RecurrenceTypeMonthly {
+				monthIndex := int(start.Month()) - 1 + k*interval
+				year, month = start.Year()+monthIndex/12, time.Month(monthIndex%12+1)
+			} else {
+				year, month = start.Year()+k*interval, start.Month()
+			}
+			if time.Date(year, month, 1, 0, 0, 0, 0, time.UTC).After(limit) {
+				break
+			}
+			if start.Day() > daysIn(year, month) {
+				continue // Такого числа в месяце нет - повторение пропускается и не учитывается в count
+			}
+			if count >= 0 && occurrenceIndex >= count {
+				break
+			}
+			occurrenceIndex++
+			date := time.Date(year, month, start.Day(), 0, 0, 0, 0, time.UTC)
+			if date.After(limit) || len(dates) >= MaxOccurrencesPerEntry {
+				break
+			}
+			if !date.Before(from) {
+				dates = append(dates, date)
+			}
+		}
+	}
+	return dates
+}
+
+// Expand разворачивает запись расписания в повторения, попадающие в диапазон [from, to].
+// Запись без повторения дает не более одного повторения - в собственную дату.
+func Expand(entry *models.ScheduleEntry, from time.Time, to time.Time) ([]Occurrence, error) {
+	start, err := ParseDate(entry.Date)
+	if err != nil {
+		return nil, fmt.Errorf("Expand: запись %d: %w", entry.Id, err)
+	}
+	rule, err := entry.ParseRecurrence()
+	if err != nil {
+		return nil, fmt.Errorf("Expand: %w", err)
+	}
+
+	var dates []time.Time
+	if rule == nil {
+		if !start.Before(dateOnly(from)) && !start.After(dateOnly(to)) {
+			dates = []time.Time{start}
+		}
+	} else {
+		dates = RecurrenceDates(start, rule, from, to)
+	}
+
+	occurrences := make([]Occurrence, 0, len(dates))
+	for _, date := range dates {
+		dateStr := date.Format(DateLayout)
+		occurrences = append(occurrences, Occurrence{
+			EntryId:      entry.Id,
+			Date:         dateStr,
+			Time:         entry.Time,
+			OriginalDate: dateStr,
+			Note:         entry.Note,
+			IsRecurring:  rule != nil,
+			Entry:        entry,
+		})
+	}
+	return occurrences, nil
+}
+
+// expandLenient работает как Expand, но запись с некорректным правилом повторения считает неповторяющейся.
+// Записи с некорректной датой не дают повторений.
+func expandLenient(entry *models.ScheduleEntry, from time.Time, to time.Time) []Occurrence {
+	expanded, err := Expand(entry, from, to)
+	if err == nil {
+		return expanded
+	}
+	log.Printf("ExpandAll: %v", err)
+	single := *entry
+	single.RecurrenceJson = nil
+	if expanded, err = Expand(&single, from, to); err != nil {
+		return nil
+	}
+	for i := range expanded {
+		expanded[i].Entry = entry
+	}
+	return expanded
+}
+
+// ExpandAll разворачивает все записи в повторения в диапазоне [from, to] с учетом исключений,
+// отсортированные по дате и времени. Записи с некорректной датой пропускаются,
+// с некорректным правилом повторения - считаются неповторяющимися.
+func ExpandAll(entries []models.ScheduleEntry, exceptions []models.ScheduleEntryException, from time.Time, to time.Time) []Occurrence {
+	exceptionsByEntry := make(map[int64]map[string]*models.ScheduleEntryException)
+	for i := range exceptions {
+		exception := &exceptions[i]
+		if exceptionsByEntry[exception.ScheduleEntryId] == nil {
+			exceptionsByEntry[exception.ScheduleEntryId] = make(map[string]*models.ScheduleEntryException)
+		}
+		exceptionsByEntry[exception.ScheduleEntryId][exception.OriginalDate] = exception
+	}
+
+	occurrences := []Occurrence{}
+	for i := range entries {
+		entry := &entries[i]
+		entryExceptions := exceptionsByEntry[entry.Id]
+		for _, occurrence := range expandLenient(entry, from, to) {
+			exception, ok := entryExceptions[occurrence.OriginalDate]
+			if !ok {
+				occurrences = append(occurrences, occurrence)
+				continue
+			}
+			if modified, keep := applyException(occurrence, exception, from, to); keep {
+				occurrences = append(occurrences, modified)
+			}
+		}
+
+		// Повторения, перенесенные в диапазон из-за его пределов
+		for originalDate, exception := range entryExceptions {
+			if exception.Type != models.ScheduleExceptionModify || exception.NewDate == nil || inRange(originalDate, from, to) {
+				continue
+			}
+			original, err := ParseDate(originalDate)
+			if err != nil {
+				continue
+			}
+			for _, occurrence := range expandLenient(entry, original, original) {
+				if modified, keep := applyException(occurrence, exception, from, to); keep {
+					occurrences = append(occurrences, modified)
+				}
+			}
+		}
+	}
+	SortOccurrences(occurrences)
+	return occurrences
+}
+
+// applyException применяет исключение к повторению. keep = false, если повторение отменено
+// или перенесено за пределы диапазона [from, to].
+func applyException(occurrence Occurrence, exception *models.ScheduleEntryException, from time.Time, to time.Time) (Occurrence, bool) {
+	if exception.Type == models.ScheduleExceptionSkip {
+		return occurrence, false
+	}
+	if exception.NewDate != nil && *exception.NewDate != "" {
+		occurrence.Date = *exception.NewDate
+	}
+	if exception.NewTime != nil && *exception.NewTime != "" {
+		occurrence.Time = *exception.NewTime
+	}
+	if exception.NewNote != nil {
+		occurrence.Note = exception.NewNote
+	}
+	exceptionID := exception.Id
+	occurrence.ExceptionId = &exceptionID
+	return occurrence, inRange(occurrence.Date, from, to)
+}
+
+// inRange проверяет, попадает ли дата (yyyy-MM-dd) в диапазон [from, to].
+func inRange(date string, from time.Time, to time.Time) bool {
+	parsed, err := ParseDate(date)
+	if err != nil {
+		return false
+	}
+	return !parsed.Before(dateOnly(from)) && !parsed.After(dateOnly(to))
+}
+
+// IsOccurrenceDate проверяет, приходится ли на дату date повторение записи по ее правилу.
+func IsOccurrenceDate(entry *models.ScheduleEntry, date time.Time) bool {
+	return len(expandLenient(entry, date, date)) > 0
+}
+
+// ValidateException нормализует и проверяет исключение: тип, формат дат и наличие изменений для "modify".
+func ValidateException(exception *models.ScheduleEntryException) error {
+	exception.Type = strings.ToLower(strings.TrimSpace(exception.Type))
+	if exception.Type != models.ScheduleExceptionSkip && exception.Type != models.ScheduleExceptionModify {
+		return fmt.Errorf("неизвестный тип исключения %q, допустимы skip и modify", exception.Type)
+	}
+	originalDate, err := NormalizeDate(exception.OriginalDate)
+	if err != nil {
+		return fmt.Errorf("original_date: %w", err)
+	}
+	exception.OriginalDate = originalDate
+
+	if exception.Type == models.ScheduleExceptionSkip {
+		exception.NewDate, exception.NewTime, exception.NewNote = nil, nil, nil
+		return nil
+	}
+	if exception.NewDate != nil && strings.TrimSpace(*exception.NewDate) == "" {
+		exception.NewDate = nil
+	}
+	if exception.NewTime != nil && strings.TrimSpace(*exception.NewTime) == "" {
+		exception.NewTime = nil
+	}
+	if exception.NewDate == nil && exception.NewTime == nil && exception.NewNote == nil {
+		return fmt.Errorf("для исключения modify нужно указать new_date, new_time или new_note")
+	}
+	if exception.NewDate != nil {
+		newDate, err := NormalizeDate(*exception.NewDate)
+		if err != nil {
+			return fmt.Errorf("new_date: %w", err)
+		}
+		exception.NewDate = &newDate
+	}
+	if exception.NewTime != nil {
+		newTime, err := NormalizeTime(*exception.NewTime)
+		if err != nil {
+			return fmt.Errorf("new_time: %w", err)
+		}
+		exception.NewTime = &newTime
+	}
+	return nil
+}
+
+// SortOccurrences сортирует повторения по дате, времени и ID записи.
+func SortOccurrences(occurrences []Occurrence) {
+	sort.SliceStable(occurrences, func(i, j int) bool {
+		if occurrences[i].Date != occurrences[j].Date {
+			return occurrences[i].Date < occurrences[j].Date
+		}
+		if occurrences[i].Time != occurrences[j].Time {
+			return occurrences[i].Time < occurrences[j].Time
+		}
+		return occurrences[i].EntryId < occurrences[j].EntryId
+	})
+}
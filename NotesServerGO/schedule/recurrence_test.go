@@ -0,0 +1,235 @@
+package schedule
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"notes_server_go/models"
+)
+
+func mustDate(t *testing.T, value string) time.Time {
+	t.Helper()
+	date, err := ParseDate(value)
+	if err != nil {
+		t.Fatalf("ParseDate(%q): %v", value, err)
+	}
+	return date
+}
+
+func intPtr(value int) *int {
+	return &value
+}
+
+func stringPtr(value string) *string {
+	return &value
+}
+
+func formatDates(dates []time.Time) []string {
+	formatted := []string{}
+	for _, date := range dates {
+		formatted = append(formatted, date.Format(DateLayout))
+	}
+	return formatted
+}
+
+func TestRecurrenceDates(t *testing.T) {
+	tests := []struct {
+		name  string
+		start string
+		rule  models.Recurrence
+		from  string
+		to    string
+		want  []string
+	}{
+		{
+			name:  "ежедневно",
+			start: "2025-03-01",
+			rule:  models.Recurrence{Type: models.RecurrenceTypeDaily},
+			from:  "2025-03-01",
+			to:    "2025-03-03",
+			want:  []string{"2025-03-01", "2025-03-02", "2025-03-03"},
+		},
+		{
+			name:  "ежедневно с интервалом, диапазон после начала серии",
+			start: "2025-03-01",
+			rule:  models.Recurrence{Type: models.RecurrenceTypeDaily, Interval: intPtr(3)},
+			from:  "2025-03-05",
+			to:    "2025-03-12",
+			want:  []string{"2025-03-07", "2025-03-10"},
+		},
+		{
+			name:  "ежедневно, серия началась больше 292 лет назад",
+			start: "1700-01-01",
+			rule:  models.Recurrence{Type: models.RecurrenceTypeDaily},
+			from:  "2025-01-01",
+			to:    "2025-01-03",
+			want:  []string{"2025-01-01", "2025-01-02", "2025-01-03"},
+		},
+		{
+			name:  "еженедельно, серия началась больше 292 лет назад",
+			start: "1700-01-01", // Пятница
+			rule:  models.Recurrence{Type: models.RecurrenceTypeWeekly},
+			from:  "2025-01-01",
+			to:    "2025-01-14",
+			want:  []string{"2025-01-03", "2025-01-10"},
+		},
+		{
+			name:  "count включает первое повторение",
+			start: "2025-03-01",
+			rule:  models.Recurrence{Type: models.RecurrenceTypeWeekly, Count: intPtr(3)},
+			from:  "2025-01-01",
+			to:    "2025-12-31",
+			want:  []string{"2025-03-01", "2025-03-08", "2025-03-15"},
+		},
+		{
+			name:  "endDate включительно",
+			start: "2025-03-01",
+			rule:  models.Recurrence{Type: models.RecurrenceTypeDaily, EndDate: &models.FlexibleTime{Time: time.Date(2025, 3, 3, 12, 0, 0, 0, time.UTC)}},
+			from:  "2025-03-01",
+			to:    "2025-03-31",
+			want:  []string{"2025-03-01", "2025-03-02", "2025-03-03"},
+		},
+		{
+			name:  "ежемесячно пропускает месяцы без 31 числа",
+			start: "2025-01-31",
+			rule:  models.Recurrence{Type: models.RecurrenceTypeMonthly},
+			from:  "2025-01-01",
+			to:    "2025-06-30",
+			want:  []string{"2025-01-31", "2025-03-31", "2025-05-31"},
+		},
+		{
+			name:  "ежегодно 29 февраля только в високосные годы",
+			start: "2024-02-29",
+			rule:  models.Recurrence{Type: models.RecurrenceTypeYearly},
+			from:  "2024-01-01",
+			to:    "2032-12-31",
+			want:  []string{"2024-02-29", "2028-02-29", "2032-02-29"},
+		},
+		{
+			name:  "диапазон до начала серии",
+			start: "2025-03-01",
+			rule:  models.Recurrence{Type: models.RecurrenceTypeDaily},
+			from:  "2025-02-01",
+			to:    "2025-02-28",
+			want:  []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := formatDates(RecurrenceDates(mustDate(t, tt.start), &tt.rule, mustDate(t, tt.from), mustDate(t, tt.to)))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("RecurrenceDates = %v, ожидалось %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecurrenceDatesLimit(t *testing.T) {
+	rule := models.Recurrence{Type: models.RecurrenceTypeDaily}
+	dates := RecurrenceDates(mustDate(t, "2000-01-01"), &rule, mustDate(t, "2000-01-01"), mustDate(t, "2099-12-31"))
+	if len(dates) != MaxOccurrencesPerEntry {
+		t.Errorf("повторений: %d, ожидалось %d", len(dates), MaxOccurrencesPerEntry)
+	}
+}
+
+func TestExpandAllWithExceptions(t *testing.T) {
+	daily := `{"type":"daily"}`
+	entries := []models.ScheduleEntry{
+		{Id: 1, Date: "2025-03-01", Time: "10:00 - 11:00", RecurrenceJson: &daily},
+		{Id: 2, Date: "2025-03-02", Time: "09:00 - 09:30"},
+	}
+
+	type occurrence struct {
+		EntryId      int64
+		Date         string
+		Time         string
+		OriginalDate string
+		Excepted     bool
+	}
+	tests := []struct {
+		name       string
+		exceptions []models.ScheduleEntryException
+		from       string
+		to         string
+		want       []occurrence
+	}{
+		{
+			name: "без исключений",
+			from: "2025-03-01",
+			to:   "2025-03-02",
+			want: []occurrence{
+				{1, "2025-03-01", "10:00 - 11:00", "2025-03-01", false},
+				{2, "2025-03-02", "09:00 - 09:30", "2025-03-02", false},
+				{1, "2025-03-02", "10:00 - 11:00", "2025-03-02", false},
+			},
+		},
+		{
+			name: "skip отменяет повторение",
+			exceptions: []models.ScheduleEntryException{
+				{Id: 10, ScheduleEntryId: 1, OriginalDate: "2025-03-02", Type: models.ScheduleExceptionSkip},
+			},
+			from: "2025-03-01",
+			to:   "2025-03-02",
+			want: []occurrence{
+				{1, "2025-03-01", "10:00 - 11:00", "2025-03-01", false},
+				{2, "2025-03-02", "09:00 - 09:30", "2025-03-02", false},
+			},
+		},
+		{
+			name: "modify меняет время",
+			exceptions: []models.ScheduleEntryException{
+				{Id: 11, ScheduleEntryId: 1, OriginalDate: "2025-03-01", Type: models.ScheduleExceptionModify, NewTime: stringPtr("12:00 - 13:00")},
+			},
+			from: "2025-03-01",
+			to:   "2025-03-01",
+			want: []occurrence{
+				{1, "2025-03-01", "12:00 - 13:00", "2025-03-01", true},
+			},
+		},
+		{
+			name: "modify переносит повторение за пределы диапазона",
+			exceptions: []models.ScheduleEntryException{
+				{Id: 12, ScheduleEntryId: 1, OriginalDate: "2025-03-01", Type: models.ScheduleExceptionModify, NewDate: stringPtr("2025-03-10")},
+			},
+			from: "2025-03-01",
+			to:   "2025-03-01",
+			want: []occurrence{},
+		},
+		{
+			name: "modify переносит повторение в диапазон из-за его пределов",
+			exceptions: []models.ScheduleEntryException{
+				{Id: 13, ScheduleEntryId: 1, OriginalDate: "2025-03-01", Type: models.ScheduleExceptionModify, NewDate: stringPtr("2025-03-05")},
+			},
+			from: "2025-03-05",
+			to:   "2025-03-05",
+			want: []occurrence{
+				{1, "2025-03-05", "10:00 - 11:00", "2025-03-05", false},
+				{1, "2025-03-05", "10:00 - 11:00", "2025-03-01", true},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []occurrence{}
+			for _, o := range ExpandAll(entries, tt.exceptions, mustDate(t, tt.from), mustDate(t, tt.to)) {
+				got = append(got, occurrence{o.EntryId, o.Date, o.Time, o.OriginalDate, o.ExceptionId != nil})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ExpandAll = %+v, ожидалось %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandAllInvalidRecurrence(t *testing.T) {
+	invalid := `{"type":"hourly"}`
+	entries := []models.ScheduleEntry{
+		{Id: 1, Date: "2025-03-01", Time: "10:00", RecurrenceJson: &invalid},
+		{Id: 2, Date: "not-a-date", Time: "10:00"},
+	}
+	got := ExpandAll(entries, nil, mustDate(t, "2025-03-01"), mustDate(t, "2025-03-31"))
+	if len(got) != 1 || got[0].EntryId != 1 || got[0].IsRecurring {
+		t.Errorf("ExpandAll = %+v, ожидалось одно неповторяющееся повторение записи 1", got)
+	}
+}
@@ -0,0 +1,60 @@
+package schedule
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MinutesPerDay - количество минут в сутках.
+const MinutesPerDay = 24 * 60
+
+// TimeRange - интервал времени записи расписания в минутах от полуночи.
+// Если конец раньше начала, интервал переходит через полночь и End больше MinutesPerDay.
+type TimeRange struct {
+	Start  int
+	End    int
+	HasEnd bool // false, если указано только время начала ("HH:mm")
+}
+
+// ParseTimeRange разбирает время записи расписания: "HH:mm - HH:mm" (как на клиенте) или "HH:mm".
+func ParseTimeRange(value string) (TimeRange, error) {
+	parts := strings.Split(value, "-")
+	if len(parts) > 2 {
+		return TimeRange{}, fmt.Errorf("неверный формат времени %q, ожидается HH:mm - HH:mm", value)
+	}
+	start, err := parseClock(parts[0])
+	if err != nil {
+		return TimeRange{}, fmt.Errorf("неверное время начала в %q: %w", value, err)
+	}
+	timeRange := TimeRange{Start: start, End: start}
+	if len(parts) == 2 {
+		end, err := parseClock(parts[1])
+		if err != nil {
+			return TimeRange{}, fmt.Errorf("неверное время окончания в %q: %w", value, err)
+		}
+		if end < start {
+			end += MinutesPerDay // Интервал переходит через полночь
+		}
+		timeRange.End, timeRange.HasEnd = end, true
+	}
+	return timeRange, nil
+}
+
+// parseClock разбирает время "HH:mm" (допускается "H:mm") в минуты от полуночи.
+func parseClock(value string) (int, error) {
+	parsed, err := time.Parse("15:04", strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("ожидается HH:mm")
+	}
+	return parsed.Hour()*60 + parsed.Minute(), nil
+}
+
+// At возвращает начало и конец интервала для календарной даты date.
+// Результат в часовом поясе date; для интервала без конца end совпадает со start.
+// Время считается по часам (а не прибавлением длительности к полуночи), поэтому в дни перехода
+// на летнее/зимнее время "10:00" остается 10:00 по местному времени.
+func (r TimeRange) At(date time.Time) (start time.Time, end time.Time) {
+	year, month, day := date.Date()
+	return time.Date(year, month, day, 0, r.Start, 0, 0, date.Location()), time.Date(year, month, day, 0, r.End, 0, 0, date.Location())
+}
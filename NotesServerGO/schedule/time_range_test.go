@@ -0,0 +1,56 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTimeRange(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    TimeRange
+		wantErr bool
+	}{
+		{value: "10:00 - 11:30", want: TimeRange{Start: 600, End: 690, HasEnd: true}},
+		{value: "9:05-9:35", want: TimeRange{Start: 545, End: 575, HasEnd: true}},
+		{value: "14:00", want: TimeRange{Start: 840, End: 840}},
+		{value: "23:00 - 01:00", want: TimeRange{Start: 1380, End: 1500, HasEnd: true}},
+		{value: "", wantErr: true},
+		{value: "25:00", wantErr: true},
+		{value: "10:00 - ", wantErr: true},
+		{value: "10:00 - 11:00 - 12:00", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseTimeRange(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseTimeRange(%q) = %+v, ожидалась ошибка", tt.value, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseTimeRange(%q): %v", tt.value, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseTimeRange(%q) = %+v, ожидалось %+v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTimeRangeAtDST(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("нет базы часовых поясов: %v", err)
+	}
+	// 2025-03-30 в Берлине переход на летнее время: сутки длятся 23 часа
+	timeRange := TimeRange{Start: 10 * 60, End: 23*60 + 30, HasEnd: true}
+	start, end := timeRange.At(time.Date(2025, 3, 30, 0, 0, 0, 0, loc))
+	if got := start.Format("2006-01-02 15:04 MST"); got != "2025-03-30 10:00 CEST" {
+		t.Errorf("начало = %s", got)
+	}
+	if got := end.Format("2006-01-02 15:04 MST"); got != "2025-03-30 23:30 CEST" {
+		t.Errorf("конец = %s", got)
+	}
+}
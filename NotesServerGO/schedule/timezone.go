@@ -0,0 +1,152 @@
+package schedule
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"notes_server_go/models"
+)
+
+// strictDatePattern и strictTimePattern - форматы Date и Time записи, которые принимаются при записи
+// (совпадают с проверкой в форме клиента). Время окончания необязательно.
+var (
+	strictDatePattern = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+	strictTimePattern = regexp.MustCompile(`^([01]\d|2[0-3]):([0-5]\d)(?:\s*-\s*([01]\d|2[0-3]):([0-5]\d))?$`)
+)
+
+// LoadTimeZone загружает часовой пояс по имени IANA ("Europe/Moscow", "UTC").
+// В отличие от time.LoadLocation не принимает пустое имя и "Local", зависящие от настроек сервера.
+func LoadTimeZone(name string) (*time.Location, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || name == "Local" {
+		return nil, fmt.Errorf("ожидается имя часового пояса IANA, например Europe/Moscow")
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("неизвестный часовой пояс %q", name)
+	}
+	return loc, nil
+}
+
+// DatabaseLocation возвращает часовой пояс совместной БД. Для БД без часового пояса
+// (или с некорректным значением в старых данных) используется часовой пояс сервера.
+func DatabaseLocation(database *models.SharedDatabase) *time.Location {
+	if database == nil || database.TimeZone == "" {
+		return time.Local
+	}
+	loc, err := LoadTimeZone(database.TimeZone)
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
+
+// EntryLocation возвращает часовой пояс записи: собственный, если задан, иначе часовой пояс БД dbLoc.
+func EntryLocation(entry *models.ScheduleEntry, dbLoc *time.Location) *time.Location {
+	if entry == nil || entry.TimeZone == nil || *entry.TimeZone == "" {
+		return dbLoc
+	}
+	loc, err := LoadTimeZone(*entry.TimeZone)
+	if err != nil {
+		return dbLoc
+	}
+	return loc
+}
+
+// NormalizeEntry строго проверяет и приводит к каноническому виду дату, время и часовой пояс записи:
+// Date - "yyyy-MM-dd", Time - "HH:mm - HH:mm" или "HH:mm", TimeZone - имя IANA
+// (пустая строка означает часовой пояс БД и сохраняется как NULL).
+func NormalizeEntry(entry *models.ScheduleEntry) error {
+	date, err := NormalizeDate(entry.Date)
+	if err != nil {
+		return err
+	}
+	entry.Date = date
+	if entry.Time, err = NormalizeTime(entry.Time); err != nil {
+		return err
+	}
+
+	entry.TimeZone, err = NormalizeTimeZone(entry.TimeZone)
+	return err
+}
+
+// NormalizeTimeZone проверяет часовой пояс записи и возвращает его каноническое имя IANA;
+// пустая строка означает часовой пояс БД (nil).
+func NormalizeTimeZone(timeZone *string) (*string, error) {
+	if timeZone == nil {
+		return nil, nil
+	}
+	name := strings.TrimSpace(*timeZone)
+	if name == "" {
+		return nil, nil
+	}
+	loc, err := LoadTimeZone(name)
+	if err != nil {
+		return nil, err
+	}
+	canonical := loc.String()
+	return &canonical, nil
+}
+
+// NormalizeDate строго проверяет дату "yyyy-MM-dd" (в отличие от ParseDate не допускает время и лишние символы).
+func NormalizeDate(value string) (string, error) {
+	date := strings.TrimSpace(value)
+	if !strictDatePattern.MatchString(date) {
+		return "", fmt.Errorf("неверная дата %q, ожидается yyyy-MM-dd", value)
+	}
+	if _, err := time.Parse(DateLayout, date); err != nil {
+		return "", fmt.Errorf("несуществующая дата %q", value)
+	}
+	return date, nil
+}
+
+// NormalizeTime строго проверяет время "HH:mm - HH:mm" или "HH:mm" и приводит пробелы вокруг "-" к виду клиента.
+func NormalizeTime(value string) (string, error) {
+	match := strictTimePattern.FindStringSubmatch(strings.TrimSpace(value))
+	if match == nil {
+		return "", fmt.Errorf("неверное время %q, ожидается HH:mm - HH:mm", value)
+	}
+	normalized := match[1] + ":" + match[2]
+	if match[3] != "" {
+		normalized += " - " + match[3] + ":" + match[4]
+	}
+	return normalized, nil
+}
+
+// Localize заполняет у повторений начало и конец в абсолютном времени: время записи отсчитывается
+// в ее часовом поясе (или в часовом поясе БД dbLoc), результат переводится в часовой пояс viewer.
+// Повторения с некорректной датой или временем остаются без Start/End.
+func Localize(occurrences []Occurrence, dbLoc *time.Location, viewer *time.Location) {
+	for i := range occurrences {
+		occurrence := &occurrences[i]
+		loc := EntryLocation(occurrence.Entry, dbLoc)
+		occurrence.TimeZone = loc.String()
+		start, end, ok := occurrenceInterval(occurrence, loc)
+		if !ok {
+			continue
+		}
+		start, end = start.In(viewer), end.In(viewer)
+		occurrence.Start, occurrence.End = &start, &end
+		occurrence.LocalDate = start.Format(DateLayout)
+		occurrence.LocalTime = start.Format("15:04")
+		if end.After(start) {
+			occurrence.LocalTime += " - " + end.Format("15:04")
+		}
+	}
+}
+
+// occurrenceInterval возвращает начало и конец повторения в часовом поясе loc.
+func occurrenceInterval(occurrence *Occurrence, loc *time.Location) (start time.Time, end time.Time, ok bool) {
+	date, err := ParseDate(occurrence.Date)
+	if err != nil {
+		return start, end, false
+	}
+	timeRange, err := ParseTimeRange(occurrence.Time)
+	if err != nil {
+		return start, end, false
+	}
+	start, end = timeRange.At(time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, loc))
+	return start, end, true
+}
@@ -0,0 +1,75 @@
+package trash
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"notes_server_go/data"
+)
+
+// Значения по умолчанию для корзины.
+const (
+	DefaultRetention    = 30 * 24 * time.Hour
+	DefaultPollInterval = time.Hour
+)
+
+// RetentionFromEnv читает срок хранения корзины из переменной окружения TRASH_RETENTION_DAYS:
+// сколько дней хранить удаленные заметки, папки и совместные БД (по умолчанию 30, 0 - без ограничения).
+func RetentionFromEnv() (time.Duration, error) {
+	value := strings.TrimSpace(os.Getenv("TRASH_RETENTION_DAYS"))
+	if value == "" {
+		return DefaultRetention, nil
+	}
+	days, err := strconv.Atoi(value)
+	if err != nil || days < 0 {
+		return 0, fmt.Errorf("неверное значение TRASH_RETENTION_DAYS: %q (целое число не меньше 0)", value)
+	}
+	return time.Duration(days) * 24 * time.Hour, nil
+}
+
+// Purger периодически окончательно удаляет элементы корзины и совместные БД,
+// которые находятся в корзине дольше Retention, вместе с их файлами.
+type Purger struct {
+	Retention    time.Duration
+	PollInterval time.Duration
+}
+
+// NewPurger создает задачу очистки корзины со сроком хранения retention.
+func NewPurger(retention time.Duration) *Purger {
+	return &Purger{Retention: retention, PollInterval: DefaultPollInterval}
+}
+
+// Run выполняет очистку сразу и затем каждые PollInterval, пока не отменен ctx.
+func (p *Purger) Run(ctx context.Context) {
+	log.Printf("Очистка корзины запущена: срок хранения %v, проверка каждые %v", p.Retention, p.PollInterval)
+
+	ticker := time.NewTicker(p.PollInterval)
+	defer ticker.Stop()
+	for {
+		if err := p.RunOnce(time.Now()); err != nil {
+			log.Printf("Очистка корзины: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce окончательно удаляет то, что попало в корзину раньше now - Retention.
+func (p *Purger) RunOnce(now time.Time) error {
+	items, databases, err := data.PurgeExpiredTrash(now.Add(-p.Retention))
+	if err != nil {
+		return err
+	}
+	if items > 0 || databases > 0 {
+		log.Printf("Очистка корзины: удалено элементов %d, совместных БД %d", items, databases)
+	}
+	return nil
+}
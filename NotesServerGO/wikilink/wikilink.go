@@ -0,0 +1,102 @@
+// Package wikilink разбирает вики-ссылки вида [[Заголовок заметки]] и [[Заголовок|подпись]]
+// в тексте заметок и переписывает их при переименовании заметки.
+package wikilink
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+// linkPattern - ссылка [[Заголовок]] или [[Заголовок|подпись]]. Заголовок не может содержать
+// квадратных скобок, вертикальной черты и перевода строки.
+var linkPattern = regexp.MustCompile(`\[\[([^\[\]|\n]+)(?:\|([^\[\]\n]*))?\]\]`)
+
+// Link - вики-ссылка из текста заметки. Повторные ссылки на один заголовок объединяются.
+type Link struct {
+	Title       string // Заголовок в том виде, в котором он впервые встретился в тексте
+	Key         string // Ключ заголовка для сравнения, см. Key
+	Alias       string // Подпись первой ссылки; "" - подписи нет
+	Occurrences int    // Сколько раз ссылка встречается в тексте
+}
+
+// Key нормализует заголовок для сравнения: без учета регистра и лишних пробелов.
+func Key(title string) string {
+	return strings.ToLower(strings.Join(strings.Fields(title), " "))
+}
+
+// Parse возвращает вики-ссылки текста в порядке первого появления.
+func Parse(text string) []Link {
+	var links []Link
+	index := make(map[string]int)
+	for _, m := range linkPattern.FindAllStringSubmatch(text, -1) {
+		title := strings.TrimSpace(m[1])
+		key := Key(title)
+		if key == "" {
+			continue
+		}
+		if i, seen := index[key]; seen {
+			links[i].Occurrences++
+			continue
+		}
+		index[key] = len(links)
+		links = append(links, Link{Title: title, Key: key, Alias: strings.TrimSpace(m[2]), Occurrences: 1})
+	}
+	return links
+}
+
+// Rename заменяет в тексте ссылки на заголовок oldTitle ссылками на newTitle, сохраняя подписи.
+// Возвращает новый текст и количество замененных ссылок.
+func Rename(text, oldTitle, newTitle string) (string, int) {
+	return rename(text, oldTitle, newTitle, func(s string) (string, bool) { return s, true }, func(s string) string { return s })
+}
+
+// RenameInJSON делает то же, что Rename, в исходном JSON (например, Quill Delta в ContentJson):
+// заголовки внутри строковых значений сравниваются после JSON-декодирования, а новый заголовок
+// экранируется по правилам JSON. Остальной документ не меняется.
+func RenameInJSON(raw, oldTitle, newTitle string) (string, int) {
+	return rename(raw, oldTitle, newTitle, unescapeJSON, escapeJSON)
+}
+
+// rename - общая часть Rename и RenameInJSON; decode и encode переводят фрагмент исходного текста
+// в обычную строку и обратно.
+func rename(text, oldTitle, newTitle string, decode func(string) (string, bool), encode func(string) string) (string, int) {
+	oldKey := Key(oldTitle)
+	if oldKey == "" {
+		return text, 0
+	}
+	replaced := 0
+	result := linkPattern.ReplaceAllStringFunc(text, func(match string) string {
+		m := linkPattern.FindStringSubmatch(match)
+		title, ok := decode(m[1])
+		if !ok || strings.Contains(title, "\n") || Key(title) != oldKey {
+			return match
+		}
+		replaced++
+		if strings.HasPrefix(match[len(m[1])+2:], "|") {
+			return "[[" + encode(newTitle) + "|" + m[2] + "]]"
+		}
+		return "[[" + encode(newTitle) + "]]"
+	})
+	return result, replaced
+}
+
+// unescapeJSON декодирует фрагмент содержимого JSON-строки.
+func unescapeJSON(s string) (string, bool) {
+	var decoded string
+	if err := json.Unmarshal([]byte(`"`+s+`"`), &decoded); err != nil {
+		return "", false
+	}
+	return decoded, true
+}
+
+// escapeJSON кодирует строку как содержимое JSON-строки (без кавычек и без экранирования HTML).
+func escapeJSON(s string) string {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(s)
+	encoded := strings.TrimSuffix(buf.String(), "\n")
+	return encoded[1 : len(encoded)-1]
+}
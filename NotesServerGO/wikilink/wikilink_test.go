@@ -0,0 +1,138 @@
+package wikilink
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []Link
+	}{
+		{
+			name: "без ссылок",
+			text: "обычный текст [не ссылка]",
+		},
+		{
+			name: "ссылка и ссылка с подписью",
+			text: "см. [[План проекта]] и [[Заметки|подробнее]]",
+			want: []Link{
+				{Title: "План проекта", Key: "план проекта", Occurrences: 1},
+				{Title: "Заметки", Key: "заметки", Alias: "подробнее", Occurrences: 1},
+			},
+		},
+		{
+			name: "повторы объединяются без учета регистра и пробелов",
+			text: "[[ План  проекта ]] [[план проекта|здесь]] [[ПЛАН ПРОЕКТА]]",
+			want: []Link{
+				{Title: "План  проекта", Key: "план проекта", Occurrences: 3},
+			},
+		},
+		{
+			name: "пустой заголовок и перевод строки не являются ссылками",
+			text: "[[ ]] [[План\nпроекта]] [[a[b]]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Parse(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse = %+v, ожидалось %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRename(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		oldTitle string
+		newTitle string
+		want     string
+		replaced int
+	}{
+		{
+			name:     "ссылки с подписью и без",
+			text:     "[[План]] и [[план|подробнее]], [[Другое]]",
+			oldTitle: "План",
+			newTitle: "План 2025",
+			want:     "[[План 2025]] и [[План 2025|подробнее]], [[Другое]]",
+			replaced: 2,
+		},
+		{
+			name:     "пустая подпись сохраняется",
+			text:     "[[План|]]",
+			oldTitle: "план",
+			newTitle: "Новый",
+			want:     "[[Новый|]]",
+			replaced: 1,
+		},
+		{
+			name:     "нет ссылок на заголовок",
+			text:     "[[Планы]] План",
+			oldTitle: "План",
+			newTitle: "Новый",
+			want:     "[[Планы]] План",
+		},
+		{
+			name:     "пустой старый заголовок",
+			text:     "[[План]]",
+			oldTitle: " ",
+			newTitle: "Новый",
+			want:     "[[План]]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, replaced := Rename(tt.text, tt.oldTitle, tt.newTitle)
+			if got != tt.want || replaced != tt.replaced {
+				t.Errorf("Rename = %q, %d; ожидалось %q, %d", got, replaced, tt.want, tt.replaced)
+			}
+		})
+	}
+}
+
+func TestRenameInJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		raw      string
+		oldTitle string
+		newTitle string
+		want     string
+		replaced int
+	}{
+		{
+			name:     "заголовок в JSON-экранировании",
+			raw:      `[{"insert":"см. [[План]]\n"}]`,
+			oldTitle: "План",
+			newTitle: "Новый план",
+			want:     `[{"insert":"см. [[Новый план]]\n"}]`,
+			replaced: 1,
+		},
+		{
+			name:     "новый заголовок экранируется",
+			raw:      `[{"insert":"[[План|подпись]]"}]`,
+			oldTitle: "План",
+			newTitle: `Кавычки "и" <теги>`,
+			want:     `[{"insert":"[[Кавычки \"и\" <теги>|подпись]]"}]`,
+			replaced: 1,
+		},
+		{
+			name:     "экранированный перевод строки в заголовке не ссылка",
+			raw:      `[{"insert":"[[План\nдалее]]"}]`,
+			oldTitle: "План далее",
+			newTitle: "Новый",
+			want:     `[{"insert":"[[План\nдалее]]"}]`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, replaced := RenameInJSON(tt.raw, tt.oldTitle, tt.newTitle)
+			if got != tt.want || replaced != tt.replaced {
+				t.Errorf("RenameInJSON = %q, %d; ожидалось %q, %d", got, replaced, tt.want, tt.replaced)
+			}
+		})
+	}
+}